@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "api",
+		usage: "Send a request to any endpoint of the API and print the response",
+		run:   runAPI,
+	})
+}
+
+// apiMethods are the methods the api command sends
+var apiMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// readRequestBody returns the JSON body given to --data: @file reads a
+// file, @- standard input, anything else is the body itself
+func readRequestBody(data string) (json.RawMessage, error) {
+	body := []byte(data)
+	var err error
+	switch {
+	case data == "@-":
+		body, err = ioutil.ReadAll(os.Stdin)
+	case strings.HasPrefix(data, "@"):
+		body, err = ioutil.ReadFile(data[1:])
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("--data is not valid JSON")
+	}
+	return json.RawMessage(body), nil
+}
+
+// printRawResponse prints a JSON response indented, anything else as is
+func printRawResponse(raw string) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return
+	}
+	var out bytes.Buffer
+	if json.Indent(&out, []byte(raw), "", "  ") != nil {
+		fmt.Println(raw)
+		return
+	}
+	fmt.Println(out.String())
+}
+
+func runAPI(ctx context.Context, args []string) error {
+	fs := newFlagSet("api")
+	data := fs.String("data", "", "JSON body of the request, @file to read it from a file or @- from standard input")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo api [flags] <method> <path>")
+		fmt.Println()
+		fmt.Println("The path is relative to the API base URL, e.g. projects/<project-id>/devices?per_page=5")
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	method := strings.ToUpper(fs.Arg(0))
+	if err := checkEnum("method", method, apiMethods); err != nil {
+		return usageErrorf("%v", err)
+	}
+	path := strings.TrimPrefix(strings.TrimPrefix(fs.Arg(1), apiURL), "/")
+	var body interface{}
+	if *data != "" {
+		if method == "GET" || method == "DELETE" {
+			return usageErrorf("--data cannot be sent with %s", method)
+		}
+		raw, err := readRequestBody(*data)
+		if err != nil {
+			return usageErrorf("%v", err)
+		}
+		body = raw
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	var raw string
+	err := newCLIClient().DoRequest(ctx, path, method, body, nil, &raw)
+	if errors.Is(err, ErrDryRun) {
+		return nil
+	}
+	// the body of a failed request tells why, print it as well
+	printRawResponse(raw)
+	return err
+}
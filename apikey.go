@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "apikey list",
+		usage: "List the user API keys, or the project API keys with --project",
+		run:   runAPIKeyList,
+	})
+	registerCommand(&command{
+		name:  "apikey create",
+		usage: "Create an API key, or rotate one with --rotate",
+		run:   runAPIKeyCreate,
+	})
+	registerCommand(&command{
+		name:  "apikey delete",
+		usage: "Delete an API key",
+		run:   runAPIKeyDelete,
+	})
+}
+
+// APIKey authenticates API requests as a user, or with access to a single
+// project only
+type APIKey struct {
+	ID          string `json:"id"`
+	Token       string `json:"token"`
+	Description string `json:"description"`
+	ReadOnly    bool   `json:"read_only"`
+	Created     string `json:"created_at,omitempty"`
+}
+
+// MaskedToken returns the token with all but its last 4 characters hidden
+func (k *APIKey) MaskedToken() string {
+	if len(k.Token) <= 4 {
+		return k.Token
+	}
+	return strings.Repeat("*", len(k.Token)-4) + k.Token[len(k.Token)-4:]
+}
+
+// APIKeyRequest creates an API key
+type APIKeyRequest struct {
+	Description string `json:"description"`
+	ReadOnly    bool   `json:"read_only"`
+}
+
+// apiKeyScope selects user keys, or the keys of a project when projectID is
+// set
+type apiKeyScope struct {
+	projectID string
+}
+
+func (s apiKeyScope) path() string {
+	if s.projectID != "" {
+		return "projects/" + s.projectID + "/api-keys"
+	}
+	return "user/api-keys"
+}
+
+func (s apiKeyScope) keyPath(id string) string {
+	if s.projectID != "" {
+		return "api-keys/" + id
+	}
+	return "user/api-keys/" + id
+}
+
+// listAPIKeys returns the API keys of the scope
+func listAPIKeys(ctx context.Context, scope apiKeyScope, c *Client) ([]APIKey, error) {
+	list := new(struct {
+		APIKeys []APIKey `json:"api_keys"`
+	})
+	if err := c.DoRequest(ctx, scope.path(), "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.APIKeys, nil
+}
+
+// createAPIKey creates an API key in the scope
+func createAPIKey(ctx context.Context, scope apiKeyScope, req *APIKeyRequest, c *Client) (*APIKey, error) {
+	key := new(APIKey)
+	if err := c.DoRequest(ctx, scope.path(), "POST", req, key, nil); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// deleteAPIKey deletes an API key, requests with its token fail from then on
+func deleteAPIKey(ctx context.Context, scope apiKeyScope, id string, c *Client) error {
+	return c.DoRequest(ctx, scope.keyPath(id), "DELETE", nil, nil, nil)
+}
+
+// addAPIKeyScopeFlag adds the flag selecting project keys over user keys
+func addAPIKeyScopeFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("project", false, "Manage the API keys of the project instead of the user, which only give access to the project")
+}
+
+// apiKeyScopeOf returns the scope selected by --project, checking the
+// credentials it needs
+func apiKeyScopeOf(project bool) (apiKeyScope, error) {
+	if project {
+		return apiKeyScope{projectID: projectID}, checkCredentials()
+	}
+	return apiKeyScope{}, checkToken()
+}
+
+func runAPIKeyList(ctx context.Context, args []string) error {
+	fs := newFlagSet("apikey list")
+	project := addAPIKeyScopeFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	scope, err := apiKeyScopeOf(*project)
+	if err != nil {
+		return err
+	}
+
+	keys, err := listAPIKeys(ctx, scope, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if keys == nil {
+			keys = []APIKey{}
+		}
+		prettyPrint(keys)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTOKEN\tACCESS\tCREATED\tDESCRIPTION")
+	for _, k := range keys {
+		access := "read-write"
+		if k.ReadOnly {
+			access = "read-only"
+		}
+		current := ""
+		if k.Token == token {
+			current = " (in use)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s%s\n", k.ID, k.MaskedToken(), access, k.Created, k.Description, current)
+	}
+	return w.Flush()
+}
+
+func runAPIKeyCreate(ctx context.Context, args []string) error {
+	fs := newFlagSet("apikey create")
+	project := addAPIKeyScopeFlag(fs)
+	req := &APIKeyRequest{}
+	fs.StringVar(&req.Description, "description", "", "Description of the key, e.g. what automation uses it")
+	fs.BoolVar(&req.ReadOnly, "read-only", false, "Only allow the key to read resources")
+	rotate := fs.String("rotate", "", "ID of a key to replace: the new key copies its description and access, and the old key is deleted once the new one works")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	scope, err := apiKeyScopeOf(*project)
+	if err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	var old *APIKey
+	if *rotate != "" {
+		keys, err := listAPIKeys(ctx, scope, client)
+		if err != nil {
+			return err
+		}
+		for i := range keys {
+			if keys[i].ID == *rotate {
+				old = &keys[i]
+			}
+		}
+		if old == nil {
+			return usageErrorf("no API key %s to rotate, see apikey list", *rotate)
+		}
+		if !isFlagPassed(fs, "description") {
+			req.Description = old.Description
+		}
+		if !isFlagPassed(fs, "read-only") {
+			req.ReadOnly = old.ReadOnly
+		}
+	}
+
+	key, err := createAPIKey(ctx, scope, req, client)
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		if err := rotateAPIKey(ctx, scope, old, key, client); err != nil {
+			return err
+		}
+	}
+
+	if jsonOutput() {
+		prettyPrint(key)
+		return nil
+	}
+	// the token goes to stdout alone, so that it can be captured
+	fmt.Println(key.Token)
+	if old != nil {
+		logger.Info("Rotated API key", "id", key.ID, "replaced", old.ID, "read_only", key.ReadOnly)
+		return nil
+	}
+	logger.Info("Created API key", "id", key.ID, "read_only", key.ReadOnly)
+	return nil
+}
+
+// rotateAPIKey deletes the old key once the new one is known to work. A new
+// key that does not work is deleted instead, leaving the old one in place.
+func rotateAPIKey(ctx context.Context, scope apiKeyScope, old, key *APIKey, client *Client) error {
+	if !dryRun {
+		check := "user"
+		if scope.projectID != "" {
+			check = "projects/" + scope.projectID
+		}
+		if err := NewClient(key.Token, apiURL).DoRequest(ctx, check, "GET", nil, nil, nil); err != nil {
+			if derr := deleteAPIKey(ctx, scope, key.ID, client); derr != nil {
+				logger.Error("Could not delete the new API key", "id", key.ID, "error", derr)
+			}
+			return fmt.Errorf("new API key was not accepted, kept %s: %w", old.ID, err)
+		}
+	}
+
+	if err := deleteAPIKey(ctx, scope, old.ID, client); err != nil {
+		fmt.Println(key.Token)
+		return &statusError{exitPartial, fmt.Errorf("created API key %s but could not delete %s, delete it with apikey delete: %w", key.ID, old.ID, err)}
+	}
+	logger.Info("Deleted API key", "id", old.ID)
+
+	// keep the keyring working when it held the rotated token
+	if old.Token != "" && old.Token == token {
+		if stored, err := keyringGet(keyringAccount()); err == nil && stored == old.Token {
+			if err := keyringSet(keyringAccount(), key.Token); err != nil {
+				return err
+			}
+			logger.Info("Replaced the token stored in the keyring", "profile", keyringAccount())
+		} else {
+			logger.Warn("The rotated key was the token in use, update PACKET_AUTH_TOKEN or --token")
+		}
+	}
+	return nil
+}
+
+func runAPIKeyDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("apikey delete")
+	project := addAPIKeyScopeFlag(fs)
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo apikey delete [flags] <key-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	scope, err := apiKeyScopeOf(*project)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteAPIKey(ctx, scope, fs.Arg(0), newCLIClient()); err != nil {
+		return err
+	}
+	logger.Info("Deleted API key", "id", fs.Arg(0))
+	return nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "apply",
+		usage: "Create and update devices to match a device manifest",
+		run:   runApply,
+	})
+	registerCommand(&command{
+		name:  "destroy",
+		usage: "Delete the devices declared in a device manifest",
+		run:   runDestroy,
+	})
+}
+
+func runApply(ctx context.Context, args []string) error {
+	fs := newFlagSet("apply")
+	addCacheFlags(fs)
+	file := fs.String("f", "devices.yaml", "Device manifest file (YAML or JSON)")
+	check := fs.Bool("check", false, "Only report drift from the manifest, exit with status 2 if there is any")
+	prune := fs.Bool("prune", false, "Delete devices of the project that are not declared in the manifest")
+	yes := fs.Bool("yes", false, "Delete pruned devices without asking for confirmation")
+	fs.DurationVar(&provisionTimeout, "provision-timeout", DefaultProvisionTimeout, "How long to wait for created devices to become active, 0 for no limit")
+	addCostFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *check && *prune {
+		return usageErrorf("--check only reports drift, it cannot be combined with --prune")
+	}
+	if cacheTTL > 0 && !*check {
+		return usageErrorf("--cache only applies to --check, apply must see the current devices to change them")
+	}
+
+	m, err := loadManifest(*file)
+	if err != nil {
+		return err
+	}
+	if m.Project != "" && !isFlagPassed(fs, "prid") {
+		projectID = m.Project
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	devices, err := listDevices(ctx, projectID, client)
+	if err != nil {
+		return err
+	}
+
+	drift := m.Drift(devices)
+	if *check {
+		return reportDrift(drift, *file)
+	}
+
+	changes := m.Changes(devices, *prune)
+	var creates []*DeviceRequest
+	for _, c := range changes {
+		if c.Action == changeCreate {
+			req, err := c.spec.request(projectID)
+			if err != nil {
+				return err
+			}
+			creates = append(creates, req)
+			if c.spec.Metro != "" && client.Flavor() != FlavorEquinixMetal {
+				return usageErrorf("%s: deploying to a metro requires the Equinix Metal API, use --api-url %s", c.Hostname, EquinixMetalURL)
+			}
+		}
+	}
+	if err := checkHourlyCost(ctx, client, billedPlans(creates...)); err != nil {
+		return err
+	}
+	for _, d := range drift {
+		if d.Field != "" && d.Field != "tags" {
+			logger.Warn("Device differs from the manifest, apply does not recreate devices", "hostname", d.Hostname, "field", d.Field, "want", d.Want, "got", d.Got)
+		}
+	}
+	if len(changes) == 0 {
+		if jsonOutput() {
+			prettyPrint([]Change{})
+		} else {
+			fmt.Printf("No changes, project %s matches %s\n", projectID, *file)
+		}
+		return nil
+	}
+
+	if !jsonOutput() {
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+	}
+	if ok, err := confirmDeletes(changes, *yes); !ok {
+		return err
+	}
+	err = applyChanges(ctx, client, changes)
+	if jsonOutput() {
+		prettyPrint(changes)
+	}
+	return err
+}
+
+func runDestroy(ctx context.Context, args []string) error {
+	fs := newFlagSet("destroy")
+	file := fs.String("f", "devices.yaml", "Device manifest file (YAML or JSON)")
+	yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	m, err := loadManifest(*file)
+	if err != nil {
+		return err
+	}
+	if m.Project != "" && !isFlagPassed(fs, "prid") {
+		projectID = m.Project
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	devices, err := listDevices(ctx, projectID, client)
+	if err != nil {
+		return err
+	}
+
+	// the devices apply manages are the first of each declared hostname
+	declared := map[string]bool{}
+	for _, spec := range m.Devices {
+		declared[spec.Hostname] = true
+	}
+	changes := []Change{}
+	for _, dev := range devices {
+		if declared[dev.Hostname] {
+			declared[dev.Hostname] = false
+			changes = append(changes, Change{Action: changeDelete, Hostname: dev.Hostname, DeviceID: dev.ID})
+		}
+	}
+
+	if len(changes) == 0 {
+		if jsonOutput() {
+			prettyPrint(changes)
+		} else {
+			fmt.Printf("Nothing to destroy, no device of %s exists in project %s\n", *file, projectID)
+		}
+		return nil
+	}
+	if !jsonOutput() {
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+	}
+	if ok, err := confirmDeletes(changes, *yes); !ok {
+		return err
+	}
+	err = applyChanges(ctx, client, changes)
+	if jsonOutput() {
+		prettyPrint(changes)
+	}
+	return err
+}
+
+// confirmDeletes asks before making changes that delete devices, unless yes
+// is set or the run is a dry run. Declining changes nothing.
+func confirmDeletes(changes []Change, yes bool) (bool, error) {
+	var deletes []Change
+	for _, c := range changes {
+		if c.Action == changeDelete {
+			deletes = append(deletes, c)
+		}
+	}
+	if len(deletes) == 0 || yes || dryRun {
+		return true, nil
+	}
+	if jsonOutput() {
+		// stdout holds the JSON document, the delete set goes with the question
+		for _, c := range deletes {
+			fmt.Fprintln(os.Stderr, c)
+		}
+	}
+	ok, err := confirm(fmt.Sprintf("Delete %d device(s) of project %s?", len(deletes), projectID))
+	if err == nil && !ok {
+		logger.Info("Nothing changed")
+	}
+	return ok, err
+}
+
+// reportDrift prints the drift from the manifest in file and returns the
+// drift exit status if there is any
+func reportDrift(drift []Drift, file string) error {
+	if jsonOutput() {
+		if drift == nil {
+			drift = []Drift{}
+		}
+		prettyPrint(drift)
+	} else if len(drift) == 0 {
+		fmt.Printf("No drift, project %s matches %s\n", projectID, file)
+	} else {
+		for _, d := range drift {
+			fmt.Println(d)
+		}
+		fmt.Printf("%d difference(s) between project %s and %s\n", len(drift), projectID, file)
+	}
+
+	if len(drift) > 0 {
+		return exitCode(exitDrift)
+	}
+	return nil
+}
+
+// applyChanges makes the changes concurrently. A failed change does not
+// stop the others, so that one bad device spec does not leave the rest of
+// the project behind. Created devices get their ID filled in.
+func applyChanges(ctx context.Context, client *Client, changes []Change) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures int
+		lastErr  error
+	)
+	for i := range changes {
+		c := &changes[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := applyChange(ctx, client, c)
+			if err == nil || errors.Is(err, ErrDryRun) {
+				return
+			}
+			logger.Error("Change failed", "action", c.Action, "hostname", c.Hostname, "error", err)
+			mu.Lock()
+			failures++
+			lastErr = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if failures == 1 && len(changes) == 1 {
+		return exitCode(exitCodeOf(lastErr))
+	}
+	if failures > 0 {
+		return &statusError{exitPartial, fmt.Errorf("%d of %d changes failed", failures, len(changes))}
+	}
+	return nil
+}
+
+func applyChange(ctx context.Context, client *Client, c *Change) error {
+	switch c.Action {
+	case changeCreate:
+		req, err := c.spec.request(projectID)
+		if err != nil {
+			return err
+		}
+		res, err := CreateDevice(ctx, client, req, provisionTimeout)
+		if res != nil && res.Device != nil {
+			c.DeviceID = res.Device.ID
+		}
+		if err != nil {
+			return err
+		}
+		logger.Info("Device created", "hostname", c.Hostname, "device", c.DeviceID, "duration", res.ProvisionTime)
+	case changeUpdate:
+		tags := append([]string{}, c.Tags...)
+		if _, err := updateDevice(ctx, c.DeviceID, &DeviceUpdateRequest{Tags: &tags}, client); err != nil {
+			return err
+		}
+		logger.Info("Device tags updated", "hostname", c.Hostname, "device", c.DeviceID, "tags", sortedTags(c.Tags))
+	case changeDelete:
+		if _, err := DeleteDevice(ctx, client, c.DeviceID); err != nil {
+			return err
+		}
+		logger.Info("Device deleted", "hostname", c.Hostname, "device", c.DeviceID)
+	}
+	return nil
+}
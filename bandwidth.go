@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "device bandwidth",
+		usage: "Show the inbound and outbound traffic of a device over time",
+		run:   runDeviceBandwidth,
+	})
+}
+
+// BandwidthSeries is the traffic of a device in one direction, its
+// datapoints being a rate in bits per second and a unix time
+type BandwidthSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// BandwidthSample is the traffic of a device at a time
+type BandwidthSample struct {
+	Time     time.Time `json:"time"`
+	Inbound  float64   `json:"inbound_bps"`
+	Outbound float64   `json:"outbound_bps"`
+}
+
+// getBandwidth returns the traffic series of the device between from and
+// until
+func getBandwidth(ctx context.Context, deviceID string, from, until time.Time, c *Client) ([]BandwidthSeries, error) {
+	q := url.Values{}
+	q.Set("from", strconv.FormatInt(from.Unix(), 10))
+	q.Set("until", strconv.FormatInt(until.Unix(), 10))
+	var res struct {
+		Bandwidth []BandwidthSeries `json:"bandwidth"`
+	}
+	uri := "devices/" + deviceID + "/bandwidth?" + q.Encode()
+	if err := c.DoRequest(ctx, uri, "GET", nil, &res, nil); err != nil {
+		return nil, err
+	}
+	return res.Bandwidth, nil
+}
+
+// bandwidthSamples merges the inbound and outbound series by time
+func bandwidthSamples(series []BandwidthSeries) []BandwidthSample {
+	byTime := map[int64]*BandwidthSample{}
+	for _, s := range series {
+		for _, p := range s.Datapoints {
+			ts := int64(p[1])
+			sample, ok := byTime[ts]
+			if !ok {
+				sample = &BandwidthSample{Time: time.Unix(ts, 0).UTC()}
+				byTime[ts] = sample
+			}
+			switch s.Target {
+			case "inbound":
+				sample.Inbound = p[0]
+			case "outbound":
+				sample.Outbound = p[0]
+			}
+		}
+	}
+	samples := []BandwidthSample{}
+	for _, s := range byTime {
+		samples = append(samples, *s)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+	return samples
+}
+
+// parseSince parses a time given as a date, an RFC 3339 time or a duration
+// before now
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return parseDay(s)
+}
+
+// formatBitrate formats a rate in bits per second with a decimal unit
+func formatBitrate(bps float64) string {
+	units := []string{"bps", "Kbps", "Mbps", "Gbps", "Tbps"}
+	i := 0
+	for bps >= 1000 && i < len(units)-1 {
+		bps /= 1000
+		i++
+	}
+	return strconv.FormatFloat(bps, 'f', 1, 64) + " " + units[i]
+}
+
+// sparkline draws the values as a line of block characters scaled to the
+// largest of them
+func sparkline(values []float64) string {
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	max := peak(values)
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if max > 0 {
+			i = int(v / max * float64(len(blocks)-1))
+		}
+		b.WriteRune(blocks[i])
+	}
+	return b.String()
+}
+
+// sparklineWidth is the most characters a sparkline takes
+const sparklineWidth = 60
+
+// downsample reduces the values to at most n by keeping the peak of each
+// run of consecutive values
+func downsample(values []float64, n int) []float64 {
+	if len(values) <= n {
+		return values
+	}
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = peak(values[i*len(values)/n : (i+1)*len(values)/n])
+	}
+	return out
+}
+
+// peak returns the largest of the values, 0 for none
+func peak(values []float64) float64 {
+	max := 0.0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+func runDeviceBandwidth(ctx context.Context, args []string) error {
+	fs := newFlagSet("device bandwidth")
+	fromFlag := fs.String("from", "24h", "Start of the period, a date, an RFC 3339 time or a duration before now")
+	toFlag := fs.String("to", "", "End of the period, like --from (default now)")
+	spark := fs.Bool("sparkline", false, "Draw the traffic as sparklines instead of a table")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo device bandwidth [flags] <device-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	now := time.Now().UTC()
+	from, err := parseSince(*fromFlag, now)
+	if err != nil {
+		return usageErrorf("invalid --from %q", *fromFlag)
+	}
+	to := now
+	if *toFlag != "" {
+		if to, err = parseSince(*toFlag, now); err != nil {
+			return usageErrorf("invalid --to %q", *toFlag)
+		}
+	}
+	if !from.Before(to) {
+		return usageErrorf("--from must be before --to")
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	series, err := getBandwidth(ctx, fs.Arg(0), from, to, client)
+	if err != nil {
+		return err
+	}
+	samples := bandwidthSamples(series)
+	if jsonOutput() {
+		prettyPrint(samples)
+		return nil
+	}
+	if len(samples) == 0 {
+		fmt.Println("No traffic recorded in the period")
+		return nil
+	}
+	if *spark {
+		printBandwidthSparklines(samples)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tINBOUND\tOUTBOUND")
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Time.Format(time.RFC3339), formatBitrate(s.Inbound), formatBitrate(s.Outbound))
+	}
+	w.Flush()
+	return nil
+}
+
+func printBandwidthSparklines(samples []BandwidthSample) {
+	in := make([]float64, len(samples))
+	out := make([]float64, len(samples))
+	for i, s := range samples {
+		in[i], out[i] = s.Inbound, s.Outbound
+	}
+	first, last := samples[0].Time, samples[len(samples)-1].Time
+	fmt.Printf("%s to %s\n", first.Format(time.RFC3339), last.Format(time.RFC3339))
+	for _, line := range []struct {
+		name   string
+		values []float64
+	}{{"in ", in}, {"out", out}} {
+		fmt.Printf("%s %s  peak %s\n", line.name, sparkline(downsample(line.values, sparklineWidth)), formatBitrate(peak(line.values)))
+	}
+}
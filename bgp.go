@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "bgp enable",
+		usage: "Enable BGP on the project",
+		run:   runBGPEnable,
+	})
+	registerCommand(&command{
+		name:  "bgp config",
+		usage: "Show the BGP configuration of the project",
+		run:   runBGPConfig,
+	})
+	registerCommand(&command{
+		name:  "bgp session create",
+		usage: "Create a BGP session on a device",
+		run:   runBGPSessionCreate,
+	})
+	registerCommand(&command{
+		name:  "bgp session list",
+		usage: "List the BGP sessions of a device and their state",
+		run:   runBGPSessionList,
+	})
+	registerCommand(&command{
+		name:  "bgp session delete",
+		usage: "Delete a BGP session",
+		run:   runBGPSessionDelete,
+	})
+}
+
+// BGPConfig is the BGP configuration of a project
+type BGPConfig struct {
+	ID             string `json:"id,omitempty"`
+	Status         string `json:"status,omitempty"`
+	DeploymentType string `json:"deployment_type,omitempty"`
+	ASN            int    `json:"asn,omitempty"`
+	MaxPrefix      int    `json:"max_prefix,omitempty"`
+	RouteObject    string `json:"route_object,omitempty"`
+}
+
+// BGPConfigRequest enables BGP on a project
+type BGPConfigRequest struct {
+	DeploymentType string `json:"deployment_type"`
+	ASN            int    `json:"asn"`
+	MD5            string `json:"md5,omitempty"`
+}
+
+// BGPSession is a BGP session of a device
+type BGPSession struct {
+	ID            string   `json:"id"`
+	Status        string   `json:"status"`
+	AddressFamily string   `json:"address_family"`
+	DefaultRoute  bool     `json:"default_route"`
+	LearnedRoutes []string `json:"learned_routes,omitempty"`
+	Created       string   `json:"created_at,omitempty"`
+}
+
+// BGPSessionRequest creates a BGP session on a device
+type BGPSessionRequest struct {
+	AddressFamily string `json:"address_family"`
+	DefaultRoute  bool   `json:"default_route,omitempty"`
+}
+
+// getBGPConfig returns the BGP configuration of the project, which has
+// no status while BGP was never enabled
+func getBGPConfig(ctx context.Context, projectID string, c *Client) (*BGPConfig, error) {
+	cfg := new(BGPConfig)
+	if err := c.DoRequest(ctx, "projects/"+projectID+"/bgp-config", "GET", nil, cfg, nil); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// enableBGP requests BGP for the project
+func enableBGP(ctx context.Context, projectID string, req *BGPConfigRequest, c *Client) error {
+	return c.DoRequest(ctx, "projects/"+projectID+"/bgp-configs", "POST", req, nil, nil)
+}
+
+// listBGPSessions returns the BGP sessions of the device
+func listBGPSessions(ctx context.Context, deviceID string, c *Client) ([]BGPSession, error) {
+	list := new(struct {
+		Sessions []BGPSession `json:"bgp_sessions"`
+	})
+	if err := c.DoRequest(ctx, "devices/"+deviceID+"/bgp/sessions", "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.Sessions, nil
+}
+
+// createBGPSession creates a BGP session on the device
+func createBGPSession(ctx context.Context, deviceID string, req *BGPSessionRequest, c *Client) (*BGPSession, error) {
+	s := new(BGPSession)
+	if err := c.DoRequest(ctx, "devices/"+deviceID+"/bgp/sessions", "POST", req, s, nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// deleteBGPSession deletes a BGP session
+func deleteBGPSession(ctx context.Context, sessionID string, c *Client) error {
+	return c.DoRequest(ctx, "bgp/sessions/"+sessionID, "DELETE", nil, nil, nil)
+}
+
+func runBGPEnable(ctx context.Context, args []string) error {
+	fs := newFlagSet("bgp enable")
+	req := &BGPConfigRequest{}
+	fs.IntVar(&req.ASN, "asn", 65000, "Autonomous system number of the project")
+	fs.StringVar(&req.DeploymentType, "type", "local", "Deployment type: local announces to the Packet network only, global to the internet")
+	fs.StringVar(&req.MD5, "md5", "", "Password of the BGP sessions")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+	if req.DeploymentType != "local" && req.DeploymentType != "global" {
+		return usageErrorf("--type must be local or global")
+	}
+
+	if err := enableBGP(ctx, projectID, req, newCLIClient()); err != nil {
+		return err
+	}
+	logger.Info("Requested BGP for project "+projectID, "asn", req.ASN, "type", req.DeploymentType)
+	return nil
+}
+
+func runBGPConfig(ctx context.Context, args []string) error {
+	fs := newFlagSet("bgp config")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	cfg, err := getBGPConfig(ctx, projectID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(cfg)
+		return nil
+	}
+	if cfg.Status == "" {
+		fmt.Println("BGP is not enabled on project " + projectID)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Status:\t%s\n", cfg.Status)
+	fmt.Fprintf(w, "ASN:\t%d\n", cfg.ASN)
+	fmt.Fprintf(w, "Type:\t%s\n", cfg.DeploymentType)
+	fmt.Fprintf(w, "Max prefix:\t%d\n", cfg.MaxPrefix)
+	return w.Flush()
+}
+
+func runBGPSessionCreate(ctx context.Context, args []string) error {
+	fs := newFlagSet("bgp session create")
+	device := fs.String("device", "", "Device to create the session on")
+	req := &BGPSessionRequest{}
+	fs.StringVar(&req.AddressFamily, "family", "ipv4", "Address family: ipv4 or ipv6")
+	fs.BoolVar(&req.DefaultRoute, "default-route", false, "Announce a default route to the device")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *device == "" {
+		return usageErrorf("--device is required")
+	}
+	if req.AddressFamily != "ipv4" && req.AddressFamily != "ipv6" {
+		return usageErrorf("--family must be ipv4 or ipv6")
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	s, err := createBGPSession(ctx, *device, req, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(s)
+		return nil
+	}
+	logger.Info("Created "+s.AddressFamily+" BGP session on device "+*device, "id", s.ID, "status", s.Status)
+	return nil
+}
+
+func runBGPSessionList(ctx context.Context, args []string) error {
+	fs := newFlagSet("bgp session list")
+	device := fs.String("device", "", "Device to list the sessions of")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *device == "" {
+		return usageErrorf("--device is required")
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	sessions, err := listBGPSessions(ctx, *device, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if sessions == nil {
+			sessions = []BGPSession{}
+		}
+		prettyPrint(sessions)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFAMILY\tSTATUS\tDEFAULT ROUTE\tLEARNED ROUTES")
+	for _, s := range sessions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%d\n", s.ID, s.AddressFamily, s.Status, s.DefaultRoute, len(s.LearnedRoutes))
+	}
+	return w.Flush()
+}
+
+func runBGPSessionDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("bgp session delete")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo bgp session delete [flags] <session-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	if err := deleteBGPSession(ctx, fs.Arg(0), newCLIClient()); err != nil {
+		return err
+	}
+	logger.Info("BGP session " + fs.Arg(0) + " deleted")
+	return nil
+}
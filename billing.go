@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "usage",
+		usage: "Summarize the usage and cost of the project devices over a period",
+		run:   runUsage,
+	})
+	registerCommand(&command{
+		name:  "invoice list",
+		usage: "List the invoices of the organization of the project",
+		run:   runInvoiceList,
+	})
+}
+
+// Usage is what a resource of the project was billed for a period
+type Usage struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Plan      string  `json:"plan"`
+	Facility  string  `json:"facility,omitempty"`
+	Unit      string  `json:"unit"`
+	Quantity  float64 `json:"quantity"`
+	Price     float64 `json:"price"`
+	Total     float64 `json:"total"`
+	StartDate string  `json:"start_date,omitempty"`
+	EndDate   string  `json:"end_date,omitempty"`
+}
+
+// UsageSummary is the cost of the project over a period, grouped by device
+// or plan
+type UsageSummary struct {
+	From   time.Time    `json:"from"`
+	To     time.Time    `json:"to"`
+	By     string       `json:"by"`
+	Groups []UsageGroup `json:"groups"`
+	Total  float64      `json:"total"`
+}
+
+// UsageGroup sums the usages of a device or plan
+type UsageGroup struct {
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+	Total    float64 `json:"total"`
+}
+
+// Invoice bills an organization for a month of usage
+type Invoice struct {
+	ID       string  `json:"id"`
+	Number   string  `json:"number"`
+	Status   string  `json:"status"`
+	Currency string  `json:"currency"`
+	Total    float64 `json:"total"`
+	Balance  float64 `json:"balance"`
+	Created  string  `json:"created_on"`
+	Due      string  `json:"due_on"`
+}
+
+// listUsages returns the usages of the project created within the period
+func listUsages(ctx context.Context, projectID string, from, to time.Time, c *Client) ([]Usage, error) {
+	var usages []Usage
+	err := eachUsage(ctx, projectID, from, to, c, func(u *Usage) error {
+		usages = append(usages, *u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// eachUsage calls f with every usage of the project created within the
+// period as the response arrives, usage exports can be larger than what
+// DoRequest reads
+func eachUsage(ctx context.Context, projectID string, from, to time.Time, c *Client, f func(*Usage) error) error {
+	q := url.Values{}
+	q.Set("created[after]", from.UTC().Format(time.RFC3339))
+	q.Set("created[before]", to.UTC().Format(time.RFC3339))
+	uri := fmt.Sprintf("projects/%s/usages?%s", projectID, q.Encode())
+	return streamArray(ctx, c, uri, "usages", func(dec *json.Decoder) error {
+		var u Usage
+		if err := dec.Decode(&u); err != nil {
+			return err
+		}
+		return f(&u)
+	})
+}
+
+// listInvoices returns the invoices of the organization, following
+// pagination
+func listInvoices(ctx context.Context, orgID string, c *Client) ([]Invoice, error) {
+	var invoices []Invoice
+	for page := 1; ; page++ {
+		list := new(struct {
+			Invoices []Invoice `json:"invoices"`
+			Meta     struct {
+				LastPage int `json:"last_page"`
+			} `json:"meta"`
+		})
+		uri := fmt.Sprintf("organizations/%s/invoices?page=%d&per_page=100", orgID, page)
+		if err := c.DoRequest(ctx, uri, "GET", nil, list, nil); err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, list.Invoices...)
+		if page >= list.Meta.LastPage {
+			return invoices, nil
+		}
+	}
+}
+
+// summarizeUsages groups the usages by device name or plan, the most
+// expensive first
+func summarizeUsages(usages []Usage, by string) ([]UsageGroup, float64) {
+	groups := map[string]*UsageGroup{}
+	var total float64
+	for _, u := range usages {
+		key := u.Name
+		if by == "plan" {
+			key = u.Plan
+		}
+		g := groups[key]
+		if g == nil {
+			g = &UsageGroup{Name: key, Unit: u.Unit}
+			groups[key] = g
+		}
+		g.Quantity += u.Quantity
+		g.Total += u.Total
+		total += u.Total
+	}
+	list := make([]UsageGroup, 0, len(groups))
+	for _, g := range groups {
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Total != list[j].Total {
+			return list[i].Total > list[j].Total
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list, total
+}
+
+// parseDay parses a date such as 2026-10-01, or a full RFC 3339 time
+func parseDay(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func runUsage(ctx context.Context, args []string) error {
+	fs := newFlagSet("usage")
+	addCacheFlags(fs)
+	now := time.Now().UTC()
+	fromFlag := fs.String("from", now.Format("2006-01")+"-01", "Start of the period, a date such as 2026-10-01 or an RFC 3339 time (default start of the month)")
+	toFlag := fs.String("to", "", "End of the period (default now)")
+	by := fs.String("by", "device", "Group the cost by device or plan")
+	export := fs.Bool("export", false, "Print every usage of the period as a JSON object per line instead of the summary")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	from, err := parseDay(*fromFlag)
+	if err != nil {
+		return usageErrorf("--from %q is not a date such as 2026-10-01", *fromFlag)
+	}
+	to := now
+	if *toFlag != "" {
+		if to, err = parseDay(*toFlag); err != nil {
+			return usageErrorf("--to %q is not a date such as 2026-10-31", *toFlag)
+		}
+	}
+	if !to.After(from) {
+		return usageErrorf("--to must be after --from")
+	}
+	if *by != "device" && *by != "plan" {
+		return usageErrorf("unknown --by %q, use device or plan", *by)
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	if *export {
+		enc := json.NewEncoder(os.Stdout)
+		return eachUsage(ctx, projectID, from, to, newCLIClient(), func(u *Usage) error {
+			return enc.Encode(u)
+		})
+	}
+	usages, err := listUsages(ctx, projectID, from, to, newCLIClient())
+	if err != nil {
+		return err
+	}
+	s := &UsageSummary{From: from, To: to, By: *by}
+	s.Groups, s.Total = summarizeUsages(usages, *by)
+	if jsonOutput() {
+		prettyPrint(s)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tQUANTITY\tTOTAL\n", map[string]string{"device": "DEVICE", "plan": "PLAN"}[*by])
+	for _, g := range s.Groups {
+		fmt.Fprintf(w, "%s\t%.2f %s\t$%.2f\n", g.Name, g.Quantity, g.Unit, g.Total)
+	}
+	fmt.Fprintf(w, "TOTAL\t\t$%.2f\n", s.Total)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nFrom %s to %s\n", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	return nil
+}
+
+func runInvoiceList(ctx context.Context, args []string) error {
+	fs := newFlagSet("invoice list")
+	addCacheFlags(fs)
+	orgID := fs.String("org", "", "Organization of the invoices (default organization of the project)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	client := newCLIClient()
+	if *orgID == "" {
+		if err := checkCredentials(); err != nil {
+			return err
+		}
+		p, err := getProject(ctx, projectID, client)
+		if err != nil {
+			return err
+		}
+		*orgID = p.OrganizationID()
+	} else if err := checkToken(); err != nil {
+		return err
+	}
+
+	invoices, err := listInvoices(ctx, *orgID, client)
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if invoices == nil {
+			invoices = []Invoice{}
+		}
+		prettyPrint(invoices)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NUMBER\tCREATED\tDUE\tSTATUS\tTOTAL\tBALANCE")
+	for _, i := range invoices {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.2f %s\t%.2f %s\n", i.Number, i.Created, i.Due, i.Status, i.Total, i.Currency, i.Balance, i.Currency)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultCatalogTTL is how long plans, facilities, metros and operating
+// systems are served from the catalog cache
+const DefaultCatalogTTL = 24 * time.Hour
+
+var (
+	cacheTTL   time.Duration
+	catalogTTL time.Duration
+	noCache    bool
+	purgeCache bool
+)
+
+// catalogPaths end the URL paths of the catalog endpoints, which rarely
+// change and are cached by every command
+var catalogPaths = []string{"/plans", "/facilities", "/locations/metros", "/operating-systems"}
+
+// addCacheFlags adds the response cache flags of read only commands
+func addCacheFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&cacheTTL, "cache", 0, "Serve API reads from a local cache of responses younger than this, e.g. 30s")
+	fs.BoolVar(&purgeCache, "purge-cache", false, "Delete all cached responses before running")
+}
+
+// cacheDir is where API responses are cached
+func cacheDir() string {
+	return filepath.Join(userCacheDir(), "responses")
+}
+
+// catalogCacheDir is where the catalog responses are cached
+func catalogCacheDir() string {
+	return filepath.Join(userCacheDir(), "catalog")
+}
+
+func userCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "packet-go-demo")
+}
+
+// CacheMiddleware serves GET requests from responses stored in dir that are
+// younger than ttl and stores successful responses. Entries are keyed by
+// the URL and the token, so accounts never see each other's responses.
+// With bypass set cached responses are not served, but still refreshed.
+func CacheMiddleware(dir string, ttl time.Duration, bypass bool) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			if r.Method != "GET" {
+				return next(r)
+			}
+			sum := sha256.Sum256([]byte(r.URL.String() + "\n" + r.Header.Get("X-Auth-Token")))
+			path := filepath.Join(dir, hex.EncodeToString(sum[:]))
+
+			if !bypass {
+				if resp := readCachedResponse(path, ttl, r); resp != nil {
+					logger.Debug("Serving cached response", "url", r.URL.String())
+					return resp, nil
+				}
+			}
+
+			resp, err := next(r)
+			if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return resp, err
+			}
+			dump, err := httputil.DumpResponse(resp, true)
+			if err != nil {
+				return nil, err
+			}
+			// the cache is best effort, a failed write only costs a request
+			if os.MkdirAll(dir, 0700) == nil {
+				ioutil.WriteFile(path, dump, 0600)
+			}
+			return resp, nil
+		}
+	}
+}
+
+// CatalogCacheMiddleware caches the responses of the catalog endpoints in
+// dir like CacheMiddleware, other requests are passed on
+func CatalogCacheMiddleware(dir string, ttl time.Duration, bypass bool) Middleware {
+	cache := CacheMiddleware(dir, ttl, bypass)
+	return func(next RoundTripFunc) RoundTripFunc {
+		cached := cache(next)
+		return func(r *http.Request) (*http.Response, error) {
+			for _, p := range catalogPaths {
+				if strings.HasSuffix(r.URL.Path, p) {
+					return cached(r)
+				}
+			}
+			return next(r)
+		}
+	}
+}
+
+func readCachedResponse(path string, ttl time.Duration, r *http.Request) *http.Response {
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > ttl {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), r)
+	if err != nil {
+		return nil
+	}
+	return resp
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrChaosDropped is returned for requests dropped by fault injection
+var ErrChaosDropped = errors.New("request dropped by fault injection")
+
+// ChaosConfig describes the faults injected into API calls, to see how
+// retries and cleanup behave when the API misbehaves
+type ChaosConfig struct {
+	// DropRate is the fraction of requests failing without a response
+	DropRate float64
+	// Delay is added before every response
+	Delay time.Duration
+	// Faults answer requests to matching paths with an error status
+	Faults []ChaosFault
+}
+
+// ChaosFault answers the requests whose path, relative to the API URL,
+// matches Path with Status instead of sending them. Path is a path.Match
+// pattern such as devices/* and may be prefixed by a method.
+type ChaosFault struct {
+	Method string
+	Path   string
+	Status int
+}
+
+var chaos *ChaosConfig
+
+// setupChaos reads the faults to inject from the environment:
+//
+//	PACKET_CHAOS_DROP    fraction of requests to drop, e.g. 0.1
+//	PACKET_CHAOS_DELAY   delay added to every response, e.g. 2s
+//	PACKET_CHAOS_STATUS  comma separated status=path faults, e.g.
+//	                     429=devices/*,500=POST projects/*/devices
+func setupChaos() error {
+	cfg := &ChaosConfig{}
+	if v := os.Getenv("PACKET_CHAOS_DROP"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return usageErrorf("PACKET_CHAOS_DROP must be a fraction between 0 and 1, got %q", v)
+		}
+		cfg.DropRate = rate
+	}
+	if v := os.Getenv("PACKET_CHAOS_DELAY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return usageErrorf("PACKET_CHAOS_DELAY: %s", err)
+		}
+		cfg.Delay = d
+	}
+	if v := os.Getenv("PACKET_CHAOS_STATUS"); v != "" {
+		faults, err := parseChaosFaults(v)
+		if err != nil {
+			return usageErrorf("PACKET_CHAOS_STATUS: %s", err)
+		}
+		cfg.Faults = faults
+	}
+	if cfg.DropRate == 0 && cfg.Delay == 0 && len(cfg.Faults) == 0 {
+		return nil
+	}
+	chaos = cfg
+	logger.Warn("Injecting faults into API calls", "drop_rate", cfg.DropRate, "delay", cfg.Delay, "faults", os.Getenv("PACKET_CHAOS_STATUS"))
+	return nil
+}
+
+func parseChaosFaults(s string) ([]ChaosFault, error) {
+	var faults []ChaosFault
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not status=path", item)
+		}
+		status, err := strconv.Atoi(parts[0])
+		if err != nil || status < 400 || status > 599 {
+			return nil, fmt.Errorf("%q is not an error status", parts[0])
+		}
+		f := ChaosFault{Status: status, Path: strings.TrimSpace(parts[1])}
+		if fields := strings.Fields(f.Path); len(fields) == 2 {
+			f.Method, f.Path = strings.ToUpper(fields[0]), fields[1]
+		}
+		f.Path = strings.TrimPrefix(f.Path, "/")
+		if _, err := path.Match(f.Path, ""); err != nil {
+			return nil, fmt.Errorf("%q: %s", f.Path, err)
+		}
+		faults = append(faults, f)
+	}
+	return faults, nil
+}
+
+// ChaosMiddleware injects the faults of cfg into the requests of a client
+// talking to the API at baseURL
+func ChaosMiddleware(cfg *ChaosConfig, baseURL string) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			if cfg.Delay > 0 {
+				if err := sleep(r.Context(), cfg.Delay); err != nil {
+					return nil, err
+				}
+			}
+			if cfg.DropRate > 0 && rand.Float64() < cfg.DropRate {
+				return nil, fmt.Errorf("%s %s: %w", r.Method, r.URL, ErrChaosDropped)
+			}
+			p := strings.TrimPrefix(r.URL.String(), baseURL)
+			p = strings.SplitN(p, "?", 2)[0]
+			for _, f := range cfg.Faults {
+				if f.Method != "" && f.Method != r.Method {
+					continue
+				}
+				if ok, _ := path.Match(f.Path, p); ok {
+					return chaosResponse(r, f.Status), nil
+				}
+			}
+			return next(r)
+		}
+	}
+}
+
+func chaosResponse(r *http.Request, status int) *http.Response {
+	body := fmt.Sprintf(`{"errors":["%s (injected fault)"]}`, http.StatusText(status))
+	header := http.Header{"Content-Type": {"application/json"}}
+	if status == http.StatusTooManyRequests {
+		header.Set("Retry-After", "1")
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}
+}
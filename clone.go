@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "device clone",
+		usage: "Create copies of a device with its plan, OS, location, tags and userdata",
+		run:   runDeviceClone,
+	})
+}
+
+// cloneRequests returns the requests creating count copies of the device.
+// One copy is named hostname, more get a -1, -2... suffix. Hardware
+// reservations and elastic IPs belong to the device and are not copied.
+func cloneRequests(d *Device, hostname string, count int, metro bool) ([]*DeviceRequest, error) {
+	var customdata json.RawMessage
+	if d.CustomData != nil {
+		data, err := json.Marshal(d.CustomData)
+		if err != nil {
+			return nil, err
+		}
+		customdata = data
+	}
+	var reqs []*DeviceRequest
+	for i := 1; i <= count; i++ {
+		req := reprovisionRequest(d, "", "", metro)
+		req.HardwareReservationID = ""
+		req.Tags = append([]string(nil), d.Tags...)
+		req.UserData = d.UserData
+		req.CustomData = customdata
+		req.Hostname = hostname
+		if count > 1 {
+			req.Hostname = fmt.Sprintf("%s-%d", hostname, i)
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+func runDeviceClone(ctx context.Context, args []string) error {
+	fs := newFlagSet("device clone")
+	hostname := fs.String("hostname", "", "Hostname of the copy, with --count the prefix of the hostnames (default hostname of the device with -clone)")
+	count := fs.Int("count", 1, "Number of copies to create")
+	fs.DurationVar(&provisionTimeout, "provision-timeout", DefaultProvisionTimeout, "How long to wait for the copies to become active, 0 for no limit")
+	addCostFlags(fs)
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo device clone [flags] <device-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if *count < 1 {
+		return usageErrorf("--count must be at least 1")
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	src, err := getDevice(ctx, fs.Arg(0), client)
+	if err != nil {
+		return err
+	}
+	if *hostname == "" {
+		*hostname = src.Hostname + "-clone"
+	}
+	reqs, err := cloneRequests(src, *hostname, *count, client.Flavor() == FlavorEquinixMetal)
+	if err != nil {
+		return err
+	}
+	if attrString(src.HardwareReservation, "id") != "" {
+		logger.Warn("The device runs on a hardware reservation, the copies are deployed on demand", "device", src.ID)
+	}
+	where := reqs[0].Metro
+	if where == "" {
+		where = reqs[0].Facility[0]
+	}
+	logger.Info("Cloning device", "copies", len(reqs), "device", src.ID, "hostname", src.Hostname,
+		"plan", reqs[0].Plan, "os", reqs[0].OS, "location", where)
+	if err := checkHourlyCost(ctx, client, billedPlans(reqs...)); err != nil {
+		return err
+	}
+	if dryRun {
+		prettyPrint(reqs)
+		return nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures int
+		lastErr  error
+	)
+	for _, req := range reqs {
+		req := req
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := CreateDevice(ctx, client, req, provisionTimeout)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if !errors.Is(err, ErrDryRun) {
+					logger.Error("Cloning failed", "hostname", req.Hostname, "error", err)
+				}
+				failures++
+				lastErr = err
+				return
+			}
+			printCreateResult(res)
+		}()
+	}
+	wg.Wait()
+
+	if failures == 1 && len(reqs) == 1 {
+		return exitCode(exitCodeOf(lastErr))
+	}
+	if failures > 0 {
+		return &statusError{exitPartial, fmt.Errorf("%d of %d copies could not be created", failures, len(reqs))}
+	}
+	return nil
+}
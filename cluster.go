@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/nurfet-becirevic/packet-go-demo/scenarios"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "cluster create",
+		usage: "Create devices in parallel, optionally on a new private VLAN, write an inventory of them and wait until they accept SSH",
+		run:   runClusterCreate,
+	})
+}
+
+// Cluster is what cluster create made
+type Cluster struct {
+	Name      string          `json:"name"`
+	Nodes     []*Device       `json:"nodes"`
+	VLAN      *VirtualNetwork `json:"vlan,omitempty"`
+	Inventory string          `json:"inventory"`
+	// Unreachable are the hostnames of the nodes that did not accept SSH
+	// connections in time
+	Unreachable []string `json:"unreachable,omitempty"`
+}
+
+// clusterHosts returns an Ansible INI inventory with the nodes in a group
+// named after the cluster, by hostname with their addresses as variables
+func clusterHosts(cl *Cluster) string {
+	group := ansibleGroupName(cl.Name)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# cluster %s, written by packet-go-demo cluster create\n", cl.Name)
+	fmt.Fprintf(&b, "[%s]\n", group)
+	for _, d := range cl.Nodes {
+		fmt.Fprintf(&b, "%s ansible_host=%s", d.Hostname, d.PublicIPv4())
+		if ip := d.PrivateIPv4(); ip != "" {
+			fmt.Fprintf(&b, " private_ip=%s", ip)
+		}
+		if ip := d.PublicIPv6(); ip != "" {
+			fmt.Fprintf(&b, " public_ipv6=%s", ip)
+		}
+		fmt.Fprintf(&b, " packet_id=%s\n", d.ID)
+	}
+	fmt.Fprintf(&b, "\n[%s:vars]\nansible_user=%s\n", group, sshUser)
+	if cl.VLAN != nil {
+		fmt.Fprintf(&b, "vlan=%d\nvlan_id=%s\n", cl.VLAN.VXLAN, cl.VLAN.ID)
+	}
+	return b.String()
+}
+
+func runClusterCreate(ctx context.Context, args []string) error {
+	fs := newFlagSet("cluster create")
+	size := fs.Int("size", 3, "Number of nodes")
+	name := fs.String("name", "cluster", "Name of the cluster, the nodes are named <name>-1, <name>-2... and tagged cluster:<name>")
+	spec := DeviceSpec{BillingCycle: BillingHourly}
+	fs.StringVar(&spec.Plan, "plan", "", "Server plan of the nodes")
+	fs.StringVar(&spec.OS, "os", "", "Server OS slug of the nodes")
+	fs.StringVar(&spec.Facility, "facility", "", "Facility code where to deploy the nodes")
+	fs.StringVar(&spec.Metro, "metro", "", "Metro code where to deploy the nodes instead of a facility (Equinix Metal API)")
+	tags := fs.String("tags", "", "Comma separated tags added to the nodes")
+	vlan := fs.Bool("vlan", false, "Create a private VLAN and attach it to the nodes")
+	vlanPort := fs.String("vlan-port", "eth1", "Port of the nodes the VLAN is attached to")
+	inventory := fs.String("inventory", "hosts.ini", "File the Ansible inventory of the nodes is written to")
+	noSSHWait := fs.Bool("no-ssh-wait", false, "Do not wait until the nodes accept SSH connections")
+	fs.DurationVar(&provisionTimeout, "provision-timeout", DefaultProvisionTimeout, "How long to wait for the nodes to become active, 0 for no limit")
+	addSSHFlags(fs)
+	addCostFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if *size < 1 {
+		return usageErrorf("--size must be at least 1")
+	}
+	if spec.Plan == "" || spec.OS == "" || (spec.Facility == "" && spec.Metro == "") {
+		return usageErrorf("--plan, --os and --facility or --metro must be set by flags or the profile")
+	}
+	if spec.Facility != "" && spec.Metro != "" {
+		return usageErrorf("--facility and --metro cannot be combined")
+	}
+	spec.Hostname = *name
+	spec.Tags = []string{"cluster:" + *name}
+	if *tags != "" {
+		spec.Tags = append(strings.Split(*tags, ","), spec.Tags...)
+	}
+	req, err := spec.request(projectID)
+	if err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	reqs := make([]*DeviceRequest, *size)
+	for i := range reqs {
+		reqs[i] = req
+	}
+	if err := checkHourlyCost(ctx, client, billedPlans(reqs...)); err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Printf("Would create %d x %s (%s) in %s as %s-1..%d", *size, spec.Plan, spec.OS, location(spec.Metro, spec.Facility), *name, *size)
+		if *vlan {
+			fmt.Printf(" on a new VLAN attached to %s", *vlanPort)
+		}
+		fmt.Printf(", and write %s\n", *inventory)
+		return nil
+	}
+
+	cl := &Cluster{Name: *name, Inventory: *inventory}
+	var undo undoStack
+	fail := func(err error) error {
+		logger.Error("Creating the cluster failed", "cluster", *name, "error", err)
+		undo.rollback(ctx, err)
+		return err
+	}
+
+	logger.Info("Creating cluster", "cluster", *name, "nodes", *size, "plan", spec.Plan, "location", location(spec.Metro, spec.Facility))
+	res, err := scenarios.ProvisionCluster(ctx, cliDevices(client), scenarios.ClusterOptions{Request: req, Count: *size, Timeout: provisionTimeout, KeepPartial: true})
+	for _, d := range res.Devices {
+		pushDeleteDevice(&undo, client, d)
+	}
+	if err != nil {
+		for hostname, nodeErr := range res.Failed {
+			logger.Error("Node failed", "hostname", hostname, "error", nodeErr)
+		}
+		return fail(err)
+	}
+	cl.Nodes = res.Devices
+
+	if *vlan {
+		v, err := createVLAN(ctx, projectID, &VirtualNetworkRequest{
+			Metro: spec.Metro, Facility: spec.Facility, Description: "cluster " + *name,
+		}, client)
+		if err != nil {
+			return fail(err)
+		}
+		undo.push("delete VLAN "+v.ID, func(ctx context.Context) error {
+			return deleteVLAN(ctx, v.ID, client)
+		})
+		cl.VLAN = v
+		for _, d := range cl.Nodes {
+			if err := attachVLAN(ctx, client, &undo, d, *vlanPort, v); err != nil {
+				return fail(fmt.Errorf("%s: %w", d.Hostname, err))
+			}
+		}
+		logger.Info("VLAN attached", "vlan", v.ID, "vxlan", v.VXLAN, "port", *vlanPort)
+	}
+
+	if err := ioutil.WriteFile(*inventory, []byte(clusterHosts(cl)), 0644); err != nil {
+		return fail(err)
+	}
+	logger.Info("Inventory written", "file", *inventory)
+
+	if !*noSSHWait {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		for _, d := range cl.Nodes {
+			d := d
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := waitForSSH(ctx, d, sshTimeout); err != nil {
+					logger.Error("Node does not accept SSH connections", "hostname", d.Hostname, "error", err)
+					mu.Lock()
+					cl.Unreachable = append(cl.Unreachable, d.Hostname)
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		sort.Strings(cl.Unreachable)
+	}
+
+	if jsonOutput() {
+		prettyPrint(cl)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tHOSTNAME\tPUBLIC IPV4\tPRIVATE IPV4\tSSH")
+		for _, d := range cl.Nodes {
+			ssh := "ok"
+			switch {
+			case *noSSHWait:
+				ssh = "-"
+			case contains(cl.Unreachable, d.Hostname):
+				ssh = "unreachable"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.ID, d.Hostname, d.PublicIPv4(), d.PrivateIPv4(), ssh)
+		}
+		w.Flush()
+		if cl.VLAN != nil {
+			fmt.Printf("VLAN %d (%s) on %s of every node\n", cl.VLAN.VXLAN, cl.VLAN.ID, *vlanPort)
+		}
+	}
+	// the nodes are up, unreachable ones are left to look into
+	if len(cl.Unreachable) > 0 {
+		return &statusError{exitPartial, fmt.Errorf("%d of %d nodes do not accept SSH connections after %s", len(cl.Unreachable), len(cl.Nodes), sshTimeout)}
+	}
+	return nil
+}
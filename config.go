@@ -0,0 +1,165 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const configFileName = ".packet-go-demo.yaml"
+
+var profileName string
+
+// Profile holds the credentials and defaults of one account
+type Profile struct {
+	APIURL       string `json:"api_url,omitempty"`
+	Token        string `json:"token,omitempty"`
+	TokenCommand string `json:"token_command,omitempty"`
+	// Tokens are used instead of Token, picked by project
+	Tokens        []ProfileToken `json:"tokens,omitempty"`
+	TokenFailover bool           `json:"token_failover,omitempty"`
+	ProjectID     string         `json:"project_id,omitempty"`
+	Facility      string         `json:"facility,omitempty"`
+	Metro         string         `json:"metro,omitempty"`
+	Plan          string         `json:"plan,omitempty"`
+	OS            string         `json:"os,omitempty"`
+	BillingCycle  string         `json:"billing_cycle,omitempty"`
+	Output        string         `json:"output,omitempty"`
+	// MaxHourlyCost is the default of --max-hourly-cost
+	MaxHourlyCost float64 `json:"max_hourly_cost,omitempty"`
+}
+
+// Config is the content of the configuration file
+type Config struct {
+	DefaultProfile string                `json:"default_profile,omitempty"`
+	Profiles       map[string]*Profile   `json:"profiles"`
+	Metros         map[string]*MetroInfo `json:"metros,omitempty"`
+	Schedules      []*Schedule           `json:"schedules,omitempty"`
+	Templates      map[string]*Template  `json:"templates,omitempty"`
+}
+
+// configPath returns the configuration file location, PACKET_CONFIG
+// overrides the default file in the home directory
+func configPath() string {
+	if path := os.Getenv("PACKET_CONFIG"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return configFileName
+	}
+	return filepath.Join(home, configFileName)
+}
+
+// loadConfig reads the configuration file, a missing file is an empty config
+func loadConfig() (*Config, error) {
+	cfg := new(Config)
+	path := configPath()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yamlUnmarshal(data, cfg); err != nil {
+		return nil, usageErrorf("%s: %s", path, err)
+	}
+	return cfg, nil
+}
+
+// profile returns the named profile, or the default one when name is empty
+func (c *Config) profile(name string) (*Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		name = "default"
+		if c.Profiles[name] == nil {
+			return new(Profile), nil
+		}
+	}
+
+	p := c.Profiles[name]
+	if p == nil {
+		return nil, usageErrorf("profile %q is not defined in %s", name, configPath())
+	}
+	return p, nil
+}
+
+// applyProfile sets the flags that were neither passed on the command line
+// nor given by their environment variable from the selected profile
+func applyProfile(fs *flag.FlagSet) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	p, err := cfg.profile(profileName)
+	if err != nil {
+		return err
+	}
+	metroOptions = cfg.Metros
+	// templates take precedence over the defaults of the profile
+	if err := applyTemplate(fs, cfg); err != nil {
+		return err
+	}
+
+	maxCost := ""
+	if p.MaxHourlyCost > 0 {
+		maxCost = strconv.FormatFloat(p.MaxHourlyCost, 'f', -1, 64)
+	}
+	values := []struct {
+		flag  string
+		env   []string
+		value string
+	}{
+		{"api-url", []string{"PACKET_API_URL"}, p.APIURL},
+		{"token", []string{"PACKET_AUTH_TOKEN", "METAL_AUTH_TOKEN"}, p.Token},
+		{"token-command", []string{"PACKET_TOKEN_COMMAND"}, p.TokenCommand},
+		{"prid", []string{"PACKET_PROJECT_ID", "METAL_PROJECT_ID"}, p.ProjectID},
+		{"facility", nil, p.Facility},
+		{"metro", nil, p.Metro},
+		{"plan", nil, p.Plan},
+		{"os", nil, p.OS},
+		{"bilcycle", nil, p.BillingCycle},
+		{"output", nil, p.Output},
+		{"max-hourly-cost", []string{"PACKET_MAX_HOURLY_COST"}, maxCost},
+		{"token-failover", []string{"PACKET_TOKEN_FAILOVER"}, strconv.FormatBool(p.TokenFailover)},
+	}
+	// a location from the command line or a template replaces that of the
+	// profile, rather than adding to it
+	location := isFlagSet(fs, "facility") || isFlagSet(fs, "metro")
+	for _, v := range values {
+		if v.value == "" || v.value == "false" || fs.Lookup(v.flag) == nil || isFlagSet(fs, v.flag) || anyEnvSet(v.env) {
+			continue
+		}
+		if location && (v.flag == "facility" || v.flag == "metro") {
+			continue
+		}
+		if err := fs.Set(v.flag, v.value); err != nil {
+			return usageErrorf("profile value for %s: %s", v.flag, err)
+		}
+	}
+	// a token given otherwise replaces the tokens of the profile
+	profileTokens = nil
+	if len(p.Tokens) > 0 && token == "" && tokenCommand == "" {
+		for i, t := range p.Tokens {
+			if t.Token == "" {
+				return usageErrorf("token %d of the profile is empty", i+1)
+			}
+		}
+		profileTokens = p.Tokens
+	}
+	return nil
+}
+
+func anyEnvSet(keys []string) bool {
+	for _, key := range keys {
+		if os.Getenv(key) != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// useConfig points the tool at a configuration file holding doc
+func useConfig(t *testing.T, doc string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(doc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PACKET_CONFIG", path)
+	for _, key := range []string{"PACKET_API_URL", "PACKET_AUTH_TOKEN", "METAL_AUTH_TOKEN", "PACKET_TOKEN_COMMAND",
+		"PACKET_PROJECT_ID", "METAL_PROJECT_ID", "PACKET_PROFILE", "PACKET_VCR", "PACKET_CHAOS_STATUS"} {
+		t.Setenv(key, "")
+	}
+}
+
+func TestApplyProfilePrecedence(t *testing.T) {
+	useConfig(t, `
+profiles:
+  default:
+    token: profile-token
+    project_id: profile-project
+    facility: am6
+    plan: c3.small.x86
+    os: ubuntu_22_04
+`)
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		facility string
+		metro    string
+		plan     string
+		project  string
+		passed   []string
+	}{
+		{"profile defaults", nil, nil, "am6", "", "c3.small.x86", "profile-project", nil},
+		{"metro replaces the profile facility", []string{"--metro", "da"}, nil, "", "da", "c3.small.x86", "profile-project", []string{"metro"}},
+		{"facility overrides", []string{"--facility", "ny5"}, nil, "ny5", "", "c3.small.x86", "profile-project", []string{"facility"}},
+		{"plan overrides", []string{"--plan", "m3.large.x86"}, nil, "am6", "", "m3.large.x86", "profile-project", []string{"plan"}},
+		{"environment overrides", nil, map[string]string{"PACKET_PROJECT_ID": "env-project"}, "am6", "", "c3.small.x86", "env-project", nil},
+		{"flag overrides environment", []string{"--prid", "flag-project"}, map[string]string{"PACKET_PROJECT_ID": "env-project"},
+			"am6", "", "c3.small.x86", "flag-project", []string{"prid"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			fs := newFlagSet("test")
+			fs.StringVar(&facility, "facility", "", "")
+			fs.StringVar(&metro, "metro", "", "")
+			fs.StringVar(&plan, "plan", "", "")
+			fs.StringVar(&ops, "os", "", "")
+			if err := parseFlags(fs, tt.args); err != nil {
+				t.Fatalf("parseFlags() error = %v", err)
+			}
+			if facility != tt.facility || metro != tt.metro || plan != tt.plan || projectID != tt.project {
+				t.Errorf("facility %q, metro %q, plan %q, project %q; want %q, %q, %q, %q",
+					facility, metro, plan, projectID, tt.facility, tt.metro, tt.plan, tt.project)
+			}
+			if ops != "ubuntu_22_04" || token != "profile-token" {
+				t.Errorf("os %q, token %q not taken from the profile", ops, token)
+			}
+			// values of the profile are not passed flags, so they never
+			// conflict with the flags of the command line
+			want := map[string]bool{}
+			for _, name := range tt.passed {
+				want[name] = true
+			}
+			for _, name := range []string{"facility", "metro", "plan", "os", "prid", "token"} {
+				if got := isFlagPassed(fs, name); got != want[name] {
+					t.Errorf("isFlagPassed(%s) = %v, want %v", name, got, want[name])
+				}
+			}
+		})
+	}
+}
+
+func TestApplyProfileSelection(t *testing.T) {
+	useConfig(t, `
+default_profile: work
+profiles:
+  work:
+    token: work-token
+  home:
+    token: home-token
+`)
+	tests := []struct {
+		args  []string
+		token string
+		err   bool
+	}{
+		{nil, "work-token", false},
+		{[]string{"--profile", "home"}, "home-token", false},
+		{[]string{"--profile", "home", "--token", "flag-token"}, "flag-token", false},
+		{[]string{"--profile", "missing"}, "", true},
+	}
+	for _, tt := range tests {
+		fs := newFlagSet("test")
+		err := parseFlags(fs, tt.args)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseFlags(%v) succeeded, want an error", tt.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("parseFlags(%v) error = %v", tt.args, err)
+		}
+		if token != tt.token {
+			t.Errorf("parseFlags(%v) token = %q, want %q", tt.args, token, tt.token)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "device console",
+		usage: "Open the serial console of a device over SSH (SOS)",
+		run:   runDeviceConsole,
+	})
+}
+
+// sosHost returns the serial over SSH host of the facility
+func sosHost(facility string, flavor APIFlavor) string {
+	if flavor == FlavorEquinixMetal {
+		return "sos." + facility + ".platformequinix.com"
+	}
+	return "sos." + facility + ".packet.net"
+}
+
+func runDeviceConsole(ctx context.Context, args []string) error {
+	fs := newFlagSet("device console")
+	fs.StringVar(&sshKey, "ssh-key", "", "Private key file used for SSH (default ssh client configuration)")
+	printOnly := fs.Bool("print", false, "Print the ssh command instead of running it")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo device console [flags] <device-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	device, err := getDevice(ctx, fs.Arg(0), client)
+	if err != nil {
+		return err
+	}
+	facility := device.FacilityCode()
+	if facility == "" {
+		return fmt.Errorf("device %s has no facility, its console host is unknown", device.ID)
+	}
+
+	// the console takes the key of the user, the device ID selects the device
+	sshArgs := []string{"-t"}
+	if sshKey != "" {
+		sshArgs = append(sshArgs, "-i", sshKey)
+	}
+	sshArgs = append(sshArgs, device.ID+"@"+sosHost(facility, client.Flavor()))
+	if *printOnly {
+		fmt.Println("ssh " + strings.Join(sshArgs, " "))
+		return nil
+	}
+
+	logger.Info("Connecting to the serial console, type ~. to disconnect", "device", device.ID, "hostname", device.Hostname)
+	cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return fmt.Errorf("ssh console session ended with status %d", exitErr.ExitCode())
+	}
+	return err
+}
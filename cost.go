@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+var (
+	maxHourlyCost float64
+	forceCost     bool
+)
+
+// addCostFlags adds the spending guardrail of the commands creating devices
+func addCostFlags(fs *flag.FlagSet) {
+	limit, _ := strconv.ParseFloat(os.Getenv("PACKET_MAX_HOURLY_COST"), 64)
+	fs.Float64Var(&maxHourlyCost, "max-hourly-cost", limit, "Refuse to create devices costing more than this many dollars an hour together, 0 for no limit")
+	fs.BoolVar(&forceCost, "force", false, "Create devices even when they exceed --max-hourly-cost")
+}
+
+// Plan is a server configuration devices are deployed with
+type Plan struct {
+	Slug    string `json:"slug"`
+	Name    string `json:"name"`
+	Pricing struct {
+		Hour float64 `json:"hour"`
+	} `json:"pricing"`
+}
+
+// listPlans returns the plans available to the project
+func listPlans(ctx context.Context, projectID string, c *Client) ([]Plan, error) {
+	list := new(struct {
+		Plans []Plan `json:"plans"`
+	})
+	if err := c.DoRequest(ctx, "projects/"+projectID+"/plans", "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.Plans, nil
+}
+
+// billedPlans counts the devices of the requests by plan. Devices on
+// hardware reservations or not billed hourly add nothing to the hourly cost.
+func billedPlans(reqs ...*DeviceRequest) map[string]int {
+	counts := map[string]int{}
+	for _, req := range reqs {
+		if req.HardwareReservationID != "" || (req.BillingCycle != "" && req.BillingCycle != BillingHourly) {
+			continue
+		}
+		counts[req.Plan]++
+	}
+	return counts
+}
+
+// checkHourlyCost refuses to create the devices, counted by plan, when their
+// on-demand price together exceeds --max-hourly-cost, unless --force is given
+func checkHourlyCost(ctx context.Context, c *Client, counts map[string]int) error {
+	if maxHourlyCost <= 0 || len(counts) == 0 {
+		return nil
+	}
+	plans, err := listPlans(ctx, projectID, c)
+	if err != nil {
+		return fmt.Errorf("looking up plan prices for --max-hourly-cost: %w", err)
+	}
+	prices := map[string]float64{}
+	for _, p := range plans {
+		prices[p.Slug] = p.Pricing.Hour
+	}
+
+	var total float64
+	for slug, n := range counts {
+		price, ok := prices[slug]
+		if !ok {
+			if forceCost {
+				logger.Warn("No price known for the plan, creating anyway", "plan", slug)
+				continue
+			}
+			return usageErrorf("no price known for plan %s to check --max-hourly-cost, pass --force to create anyway", slug)
+		}
+		total += price * float64(n)
+	}
+	if total <= maxHourlyCost {
+		logger.Debug("Projected cost within --max-hourly-cost", "hourly_cost", total, "limit", maxHourlyCost)
+		return nil
+	}
+	if forceCost {
+		logger.Warn(fmt.Sprintf("Projected cost $%.2f/hour exceeds --max-hourly-cost, creating anyway", total), "limit", maxHourlyCost)
+		return nil
+	}
+	return usageErrorf("projected cost $%.2f/hour exceeds --max-hourly-cost $%.2f, pass --force to create anyway", total, maxHourlyCost)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// exit status of a run that ended with a panic (EX_SOFTWARE)
+const crashExitCode = 70
+
+const maxRecentCalls = 20
+
+// apiCall is a summary of one API request kept for crash reports
+type apiCall struct {
+	Time     time.Time
+	Method   string
+	URL      string
+	Status   int
+	Duration time.Duration
+	Err      string
+	// RequestID is the ID the API gave the response
+	RequestID string
+}
+
+var (
+	recentCallsMu sync.Mutex
+	recentCalls   []apiCall
+)
+
+// recordAPICall remembers the last few API calls of the run
+func recordAPICall(call apiCall) {
+	recentCallsMu.Lock()
+	defer recentCallsMu.Unlock()
+	if len(recentCalls) == maxRecentCalls {
+		recentCalls = recentCalls[1:]
+	}
+	recentCalls = append(recentCalls, call)
+}
+
+// recoverCrash must be deferred by main. On panic it writes a crash report
+// and exits with a short pointer to it instead of a bare stack trace.
+func recoverCrash(args []string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	path, err := writeCrashReport(r, debug.Stack(), args)
+	fmt.Fprintf(os.Stderr, "packet-go-demo crashed: %v\n", r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Writing the crash report failed: %s\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "A crash report was written to %s, please attach it when filing a bug.\n", path)
+	}
+	os.Exit(crashExitCode)
+}
+
+func writeCrashReport(reason interface{}, stack []byte, args []string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, "packet-go-demo")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	var b strings.Builder
+	fmt.Fprintf(&b, "packet-go-demo crash report\n\n")
+	fmt.Fprintf(&b, "time:    %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "go:      %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "command: packet-go-demo %s\n", strings.Join(sanitizeArgs(args), " "))
+	fmt.Fprintf(&b, "panic:   %v\n\n", reason)
+
+	fmt.Fprintf(&b, "config:\n")
+	fmt.Fprintf(&b, "  api url:  %s\n", apiURL)
+	fmt.Fprintf(&b, "  token:    %s\n", redact(token))
+	fmt.Fprintf(&b, "  project:  %s\n", projectID)
+	fmt.Fprintf(&b, "  profile:  %s\n", profileName)
+	fmt.Fprintf(&b, "  config:   %s\n", configPath())
+	fmt.Fprintf(&b, "  output:   %s\n\n", outputFormat)
+
+	fmt.Fprintf(&b, "recent API calls:\n")
+	recentCallsMu.Lock()
+	for _, c := range recentCalls {
+		result := fmt.Sprint(c.Status)
+		if c.Err != "" {
+			result = c.Err
+		} else if c.RequestID != "" {
+			result += " request " + c.RequestID
+		}
+		fmt.Fprintf(&b, "  %s %s %s %s (%s)\n", c.Time.Format("15:04:05.000"), c.Method, c.URL, result, c.Duration.Round(time.Millisecond))
+	}
+	recentCallsMu.Unlock()
+
+	fmt.Fprintf(&b, "\nstack:\n%s", stack)
+
+	path := filepath.Join(dir, "crash-"+now.Format("20060102-150405")+".txt")
+	return path, ioutil.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// redact hides a secret, keeping only whether it was set
+func redact(secret string) string {
+	if secret == "" {
+		return "(not set)"
+	}
+	return "(redacted)"
+}
+
+// credentialFlags are the flags whose values are secrets, or commands and
+// URLs that may contain one
+var credentialFlags = map[string]bool{
+	"token":         true,
+	"token-command": true,
+	"otp":           true,
+	"secret":        true,
+	"notify":        true,
+	"accept-token":  true,
+	"require-otp":   true,
+}
+
+// sanitizeArgs replaces the values of the credential flags in a command
+// line, passed as --flag value or --flag=value
+func sanitizeArgs(args []string) []string {
+	out := make([]string, len(args))
+	copy(out, args)
+	for i := 0; i < len(out); i++ {
+		arg := out[i]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if eq := strings.Index(name, "="); eq >= 0 {
+			if credentialFlags[name[:eq]] {
+				out[i] = arg[:strings.Index(arg, "=")+1] + redact("x")
+			}
+			continue
+		}
+		if credentialFlags[name] && i+1 < len(out) {
+			out[i+1] = redact("x")
+			i++
+		}
+	}
+	return out
+}
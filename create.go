@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "device create",
+		usage: "Create a device and wait until it is active, optionally from a template of the configuration file",
+		run:   runDeviceCreate,
+	})
+}
+
+var templateName string
+
+// Template is a named provisioning preset of the configuration file. Its
+// values are used for the flags of device create that are not passed, in
+// preference to the values of the profile.
+type Template struct {
+	Plan         string   `json:"plan,omitempty"`
+	OS           string   `json:"os,omitempty"`
+	Facility     string   `json:"facility,omitempty"`
+	Metro        string   `json:"metro,omitempty"`
+	BillingCycle string   `json:"billing_cycle,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	UserDataFile string   `json:"userdata_file,omitempty"`
+}
+
+// applyTemplate sets the flags that were not passed on the command line
+// from the template selected with --template
+func applyTemplate(fs *flag.FlagSet, cfg *Config) error {
+	if templateName == "" {
+		return nil
+	}
+	t := cfg.Templates[templateName]
+	if t == nil {
+		return usageErrorf("template %q is not defined in %s", templateName, configPath())
+	}
+
+	values := []struct {
+		flag  string
+		value string
+	}{
+		{"plan", t.Plan},
+		{"os", t.OS},
+		{"facility", t.Facility},
+		{"metro", t.Metro},
+		{"bilcycle", t.BillingCycle},
+		{"tags", strings.Join(t.Tags, ",")},
+		{"userdata-file", t.UserDataFile},
+	}
+	// a location passed on the command line replaces that of the template
+	location := isFlagPassed(fs, "facility") || isFlagPassed(fs, "metro")
+	for _, v := range values {
+		if v.value == "" || fs.Lookup(v.flag) == nil || isFlagPassed(fs, v.flag) {
+			continue
+		}
+		if location && (v.flag == "facility" || v.flag == "metro") {
+			continue
+		}
+		if err := fs.Set(v.flag, v.value); err != nil {
+			return usageErrorf("template value for %s: %s", v.flag, err)
+		}
+	}
+	return nil
+}
+
+func runDeviceCreate(ctx context.Context, args []string) error {
+	fs := newFlagSet("device create")
+	fs.StringVar(&templateName, "template", "", "Template of the configuration file to take the device settings from")
+	fs.StringVar(&hostname, "hostname", "", "Hostname of the device (default generated by --hostname-style)")
+	fs.StringVar(&plan, "plan", "", "Server plan")
+	fs.StringVar(&ops, "os", "", "Server OS slug")
+	fs.StringVar(&facility, "facility", "", "Facility code where to deploy the device")
+	fs.StringVar(&metro, "metro", "", "Metro code where to deploy the device instead of a facility (Equinix Metal API)")
+	billingCycle = BillingHourly
+	fs.Var(&billingCycle, "bilcycle", "Billing cycle: hourly, daily, monthly or yearly")
+	tags := fs.String("tags", "", "Comma separated device tags")
+	userdataFile := fs.String("userdata-file", "", "File passed to the device as userdata, e.g. a cloud-init config")
+	fs.DurationVar(&provisionTimeout, "provision-timeout", DefaultProvisionTimeout, "How long to wait for the device to become active, 0 for no limit")
+	addHostnameFlags(fs)
+	addUserdataFlags(fs)
+	addIPXEFlags(fs)
+	addStorageFlags(fs)
+	addCostFlags(fs)
+	lifetime := fs.Duration("ttl", 0, "Set the termination time of the device this far ahead, the API deletes it then, e.g. 2h")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+	if err := checkIPXE(); err != nil {
+		return err
+	}
+
+	var missing []string
+	if plan == "" {
+		missing = append(missing, "--plan")
+	}
+	if ops == "" {
+		missing = append(missing, "--os")
+	}
+	if facility == "" && metro == "" {
+		missing = append(missing, "--facility or --metro")
+	}
+	if len(missing) > 0 {
+		return usageErrorf("%s must be set by flags, a --template or the profile", strings.Join(missing, ", "))
+	}
+	if facility != "" && metro != "" {
+		return usageErrorf("--facility and --metro cannot be combined")
+	}
+	if *userdataFile != "" && userdataTemplate != "" {
+		return usageErrorf("--userdata-file and --userdata-template cannot be combined")
+	}
+	if *lifetime != 0 && *lifetime < time.Minute {
+		return usageErrorf("--ttl %s is too short, the API needs a termination time at least a minute ahead", *lifetime)
+	}
+
+	if hostname == "" {
+		gen, err := flagHostnameGenerator()
+		if err == nil {
+			hostname, err = gen.Generate()
+		}
+		if err != nil {
+			return usageErrorf("%s", err)
+		}
+	}
+
+	req := demoDeviceRequest()
+	if *lifetime > 0 {
+		req.TerminationTime = terminationTime(*lifetime)
+	}
+	if *tags != "" {
+		req.Tags = strings.Split(*tags, ",")
+	}
+	if *userdataFile != "" {
+		data, err := ioutil.ReadFile(*userdataFile)
+		if err != nil {
+			return usageErrorf("%s", err)
+		}
+		req.UserData = string(data)
+	}
+	if err := applyUserdataTemplate(req); err != nil {
+		return err
+	}
+	cpr, err := loadStorageFile()
+	if err != nil {
+		return err
+	}
+	req.Storage = cpr
+	if req.CustomData, err = loadCustomdata(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	if err := checkHourlyCost(ctx, client, billedPlans(req)); err != nil {
+		return err
+	}
+
+	logger.Info("Provisioning device... please wait", "hostname", hostname, "template", templateName)
+	res, err := CreateDevice(ctx, client, req, provisionTimeout)
+	if err != nil {
+		if res != nil {
+			logger.Warn("The device exists but is not active, delete it with cleanup --run "+runState.run, "device", res.Device.ID)
+		}
+		return err
+	}
+	printCreateResult(res)
+	return nil
+}
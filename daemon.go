@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "daemon",
+		usage: "Power devices on and off by the schedules of the configuration file",
+		run:   runDaemon,
+	})
+}
+
+func runDaemon(ctx context.Context, args []string) error {
+	fs := newFlagSet("daemon")
+	interval := fs.Duration("interval", time.Minute, "How often the schedules are checked")
+	once := fs.Bool("once", false, "Apply the schedules once and exit, e.g. from cron")
+	mdns := fs.Bool("mdns", false, "Announce active devices as <hostname>.local on the local network over mDNS")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+	if *mdns && *once {
+		return usageErrorf("--mdns keeps answering queries, it cannot be combined with --once")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Schedules) == 0 && !*mdns {
+		return usageErrorf("no schedules in %s and --mdns not given, nothing to do", configPath())
+	}
+	for _, s := range cfg.Schedules {
+		if err := s.parse(); err != nil {
+			return usageErrorf("%s: %s", configPath(), err)
+		}
+	}
+
+	client := newCLIClient()
+	d := &scheduler{devices: cliDevices(client), schedules: cfg.Schedules, applied: map[string]bool{}}
+
+	var responder *mdnsResponder
+	if *mdns {
+		if responder, err = newMDNSResponder(); err != nil {
+			return fmt.Errorf("starting mDNS responder: %s", err)
+		}
+		// withdraw the announcements when the daemon is stopped
+		defer responder.close()
+	}
+
+	logger.Info("Daemon started", "project", projectID, "schedules", len(cfg.Schedules), "mdns", *mdns, "interval", *interval)
+	for {
+		err := d.tick(ctx, time.Now())
+		if *once {
+			return err
+		}
+		if responder != nil {
+			if devices, err := listDevices(ctx, projectID, client); err != nil {
+				logger.Error("Listing devices failed, mDNS announcements are refreshed on the next check", "error", err)
+			} else {
+				responder.update(devices)
+			}
+		}
+		if err := sleep(ctx, *interval); err != nil {
+			logger.Info("Daemon stopped")
+			return nil
+		}
+	}
+}
+
+// scheduler powers devices on and off when their schedule changes state.
+// Devices are brought in line on the first check, after that only on the
+// transitions, so devices switched manually stay as they are until the
+// next transition.
+type scheduler struct {
+	devices   DeviceService
+	schedules []*Schedule
+	// applied is the last state of each schedule that was enforced
+	applied map[string]bool
+}
+
+// tick enforces the schedules that changed state. It returns the listing
+// error, or which part of the power actions failed, all of them logged.
+func (d *scheduler) tick(ctx context.Context, now time.Time) error {
+	due := map[*Schedule]bool{}
+	for _, s := range d.schedules {
+		on := s.PoweredOn(now)
+		if last, ok := d.applied[s.Name]; !ok || last != on {
+			due[s] = on
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	devices, err := d.devices.List(ctx, projectID)
+	if err != nil {
+		logger.Error("Listing devices failed, schedules are retried on the next check", "error", err)
+		return exitCode(exitCodeOf(err))
+	}
+
+	failed := map[*Schedule]bool{}
+	actions, failures := 0, 0
+	for i := range devices {
+		dev := &devices[i]
+		s := d.scheduleOf(dev)
+		on, ok := due[s]
+		if !ok {
+			continue
+		}
+		// devices being provisioned or already switching are left alone
+		if (on && dev.State != StateInactive) || (!on && dev.State != StateActive) {
+			continue
+		}
+		actions++
+		if err := d.devices.Power(ctx, dev.ID, on); err != nil && !errors.Is(err, ErrDryRun) {
+			logger.Error("Power action failed", "schedule", s.Name, "device", dev.ID, "hostname", dev.Hostname, "error", err)
+			failed[s] = true
+			failures++
+			continue
+		}
+		logger.Info("Powering device "+powerWord(on), "schedule", s.Name, "device", dev.ID, "hostname", dev.Hostname)
+	}
+
+	for s, on := range due {
+		if !failed[s] {
+			d.applied[s.Name] = on
+		}
+	}
+	if failures > 0 {
+		return &statusError{exitPartial, fmt.Errorf("%d of %d power actions failed", failures, actions)}
+	}
+	return nil
+}
+
+// scheduleOf returns the first schedule selecting the device, or nil
+func (d *scheduler) scheduleOf(dev *Device) *Schedule {
+	for _, s := range d.schedules {
+		if s.selects(dev) {
+			return s
+		}
+	}
+	return nil
+}
+
+func powerWord(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
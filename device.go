@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/nurfet-becirevic/packet-go-demo/packet"
+)
+
+// attrValue reads an attribute of an embedded API object
+func attrValue(obj interface{}, key string) interface{} {
+	m, _ := obj.(map[string]interface{})
+	return m[key]
+}
+
+// attrString reads a string attribute of an embedded API object, which
+// Device keeps untyped so that the whole object is printed as returned
+func attrString(obj interface{}, key string) string {
+	s, _ := attrValue(obj, key).(string)
+	return s
+}
+
+// CreateDevice creates a device and waits until it is active, at most
+// timeout or without limit when it is 0. When the device was created but
+// did not become active, the result holds the device along with the error,
+// so that the caller can delete it. The device is recorded in the state of
+// the run, its provisioning reported as events, progress and hooks.
+func CreateDevice(ctx context.Context, c *Client, req *DeviceRequest, timeout time.Duration) (res *CreateDeviceResult, err error) {
+	ctx, span := startSpan(ctx, "create device", spanKindInternal)
+	span.setAttr("packet.hostname", req.Hostname)
+	span.setAttr("packet.plan", req.Plan)
+	defer func() { span.finish(err) }()
+
+	if req.Metro != "" && c.Flavor() != FlavorEquinixMetal {
+		return nil, fmt.Errorf("deploying to a metro requires the Equinix Metal API, use --api-url %s", EquinixMetalURL)
+	}
+
+	var progress *provisionProgress
+	defer func() { progressUI.remove(progress) }()
+	var state DeviceState
+	res, err = packet.CreateDevice(ctx, c, req, timeout, &packet.DeviceHooks{
+		Created: func(res *CreateDeviceResult) {
+			device := res.Device
+			runState.created("device", device.ID, device.Hostname, req.ProjectID)
+			span.setAttr("packet.device_id", device.ID)
+			lifecycle("device.created", "device_id", device.ID, "hostname", device.Hostname, "state", device.State)
+			progress = newProvisionProgress(req, res.Requested)
+		},
+		Polled: func(dev *Device) {
+			if progress != nil {
+				progress.poll(ctx, dev, c)
+			}
+			if dev.State != state {
+				lifecycle("device.state", "device_id", dev.ID, "state", dev.State, "previous", state)
+				state = dev.State
+			}
+		},
+	})
+	if res == nil {
+		return nil, err
+	}
+	span.setAttr("packet.polls", res.Polls)
+	span.setAttr("packet.retries", res.Retries)
+	runStats.retried(res.Retries)
+
+	if err != nil {
+		if ctx.Err() == nil {
+			recordProvisioning(req, time.Since(res.Requested), err)
+			lifecycle("device.failed", "device_id", res.Device.ID, "hostname", res.Device.Hostname, "error", err.Error(), "duration", Duration(time.Since(res.Requested)))
+		}
+		return res, err
+	}
+	recordProvisioning(req, time.Duration(res.ProvisionTime), nil)
+	lifecycle("device.active", "device_id", res.Device.ID, "provision_time", res.ProvisionTime, "device", res.Device)
+	return res, nil
+}
+
+// DeleteDevice deletes a device and removes it from the state of the run
+func DeleteDevice(ctx context.Context, c *Client, deviceID string) (*DeleteDeviceResult, error) {
+	res, err := c.Devices().Delete(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	runState.deleted(deviceID)
+	lifecycle("device.deleted", "device_id", deviceID, "duration", res.Duration)
+	return res, nil
+}
+
+func getDevice(ctx context.Context, deviceID string, c *Client) (*Device, error) {
+	return c.Devices().Get(ctx, deviceID)
+}
+
+// listDevices returns all devices of the project, following pagination
+func listDevices(ctx context.Context, projectID string, c *Client) ([]Device, error) {
+	return c.Devices().List(ctx, projectID)
+}
+
+// searchDevices returns the project devices matching the filters of the
+// query, such as tag, facility or search, with the embedded objects of opts
+func searchDevices(ctx context.Context, projectID string, query url.Values, opts *GetOptions, c *Client) ([]Device, error) {
+	return packet.SearchDevices(ctx, c, projectID, query, opts)
+}
+
+// updateDevice changes the device in place
+func updateDevice(ctx context.Context, deviceID string, req *DeviceUpdateRequest, c *Client) (*Device, error) {
+	dev := new(Device)
+	if err := c.DoRequest(ctx, "devices/"+deviceID, "PUT", req, dev, nil); err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
+// powerDevice powers the device on or off
+func powerDevice(ctx context.Context, deviceID string, on bool, c *Client) error {
+	return c.Devices().Power(ctx, deviceID, on)
+}
+
+// rebootDevice reboots the device
+func rebootDevice(ctx context.Context, deviceID string, c *Client) error {
+	req := map[string]string{"type": "reboot"}
+	return c.DoRequest(ctx, "devices/"+deviceID+"/actions", "POST", req, nil, nil)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "device list",
+		usage: "List the project devices, filtered by tag, facility, state, plan or hostname",
+		run:   runDeviceList,
+	})
+}
+
+// DeviceFilter selects devices of a list. Devices must match every filter
+// that is set.
+type DeviceFilter struct {
+	Tag          string
+	Facility     string
+	State        DeviceState
+	Plan         string
+	HostnameGlob string
+	Search       string
+}
+
+// Query returns the filters the API applies itself
+func (f *DeviceFilter) Query() url.Values {
+	q := url.Values{}
+	if f.Tag != "" {
+		q.Set("tag", f.Tag)
+	}
+	if f.Facility != "" {
+		q.Set("facility", f.Facility)
+	}
+	if f.Search != "" {
+		q.Set("search", f.Search)
+	}
+	return q
+}
+
+// Match reports whether the device is selected. The filters of the API are
+// checked again, as older API versions ignore some of them.
+func (f *DeviceFilter) Match(d *Device) bool {
+	if f.Tag != "" && !hasTag(d.Tags, f.Tag) {
+		return false
+	}
+	if f.Facility != "" && f.Facility != d.FacilityCode() && f.Facility != d.MetroCode() {
+		return false
+	}
+	if f.State != "" && f.State != d.State {
+		return false
+	}
+	if f.Plan != "" && f.Plan != d.PlanSlug() {
+		return false
+	}
+	if f.HostnameGlob != "" {
+		if ok, _ := path.Match(f.HostnameGlob, d.Hostname); !ok {
+			return false
+		}
+	}
+	return f.Search == "" || deviceContains(d, f.Search)
+}
+
+// deviceContains reports whether the ID, hostname, tags, plan, OS or an
+// address of the device contains the text, ignoring case
+func deviceContains(d *Device, text string) bool {
+	text = strings.ToLower(text)
+	fields := append([]string{d.ID, d.Hostname, d.PlanSlug(), d.OSSlug(), d.PublicIPv4()}, d.Tags...)
+	for _, s := range fields {
+		if strings.Contains(strings.ToLower(s), text) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func runDeviceList(ctx context.Context, args []string) error {
+	fs := newFlagSet("device list")
+	filter := &DeviceFilter{}
+	fs.StringVar(&filter.Tag, "tag", "", "Only list devices with this tag")
+	fs.StringVar(&filter.Facility, "facility", "", "Only list devices in this facility, or metro")
+	fs.Var(&filter.State, "state", "Only list devices in this state, e.g. active or provisioning")
+	fs.StringVar(&filter.Plan, "plan", "", "Only list devices of this plan")
+	fs.StringVar(&filter.HostnameGlob, "hostname-glob", "", "Only list devices whose hostname matches this pattern, e.g. 'web-*'")
+	fs.StringVar(&filter.Search, "search", "", "Only list devices whose ID, hostname, tags, plan, OS or IP contain this text")
+	addTableFlags(fs, "created, hostname, state or any other column")
+	watch := fs.Bool("watch", false, "Refresh the table until interrupted, highlighting state changes")
+	interval := fs.Duration("interval", 5*time.Second, "How often --watch refreshes the table")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if _, err := selectColumns(deviceColumns(nil)); err != nil {
+		return err
+	}
+	if _, err := path.Match(filter.HostnameGlob, ""); err != nil {
+		return usageErrorf("invalid --hostname-glob %q: %v", filter.HostnameGlob, err)
+	}
+	if *watch && jsonOutput() {
+		return usageErrorf("--watch refreshes a table, it cannot be combined with --output %s", outputFormat)
+	}
+	if *interval < time.Second {
+		return usageErrorf("--interval must be at least 1s")
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	if *watch {
+		return watchDevices(ctx, client, filter, *interval)
+	}
+	matched, err := matchingDevices(ctx, client, filter)
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(matched)
+		return nil
+	}
+	if len(matched) == 0 {
+		fmt.Println("No devices match")
+		return nil
+	}
+
+	return printTable(deviceColumns(matched), len(matched))
+}
+
+// matchingDevices returns the project devices selected by the filter
+func matchingDevices(ctx context.Context, client *Client, filter *DeviceFilter) ([]Device, error) {
+	devices, err := searchDevices(ctx, projectID, filter.Query(), DeviceGetOptions, client)
+	if err != nil {
+		return nil, err
+	}
+	matched := []Device{}
+	for i := range devices {
+		if filter.Match(&devices[i]) {
+			matched = append(matched, devices[i])
+		}
+	}
+	return matched, nil
+}
+
+// watchDevices redraws the device table every interval until ctx is done.
+// Devices whose state changed since the previous refresh are highlighted,
+// in reverse video on a terminal and with their previous state otherwise.
+func watchDevices(ctx context.Context, client *Client, filter *DeviceFilter, interval time.Duration) error {
+	fi, err := os.Stdout.Stat()
+	terminal := err == nil && fi.Mode()&os.ModeCharDevice != 0
+	var previous map[string]DeviceState
+	for {
+		matched, err := matchingDevices(ctx, client, filter)
+		if err != nil && ctx.Err() == nil {
+			logger.Warn("Listing devices failed, retrying on the next refresh", "error", err)
+		}
+		if err == nil {
+			if terminal {
+				// move home and clear the screen
+				fmt.Print("\033[H\033[2J")
+			}
+			states := map[string]DeviceState{}
+			changed := map[string]DeviceState{}
+			for _, d := range matched {
+				states[d.ID] = d.State
+				if was, ok := previous[d.ID]; previous != nil && (!ok || was != d.State) {
+					changed[d.ID] = was
+				}
+			}
+			fmt.Printf("Every %s: %d devices, %d changed, %s\n\n", interval, len(matched), len(changed), time.Now().Format("15:04:05"))
+			columns := deviceColumns(matched)
+			for i := range columns {
+				if columns[i].name == "state" {
+					columns[i].key = columns[i].value
+					columns[i].value = watchedState(matched, changed, terminal)
+				}
+			}
+			if err := printTable(columns, len(matched)); err != nil {
+				return err
+			}
+			previous = states
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return nil
+		}
+	}
+}
+
+// watchedState returns the value of the state column of a watched table
+func watchedState(devices []Device, changed map[string]DeviceState, terminal bool) func(int) string {
+	return func(i int) string {
+		d := devices[i]
+		was, ok := changed[d.ID]
+		if !terminal {
+			if !ok {
+				return d.State.String()
+			}
+			if was == "" {
+				return d.State.String() + " (new)"
+			}
+			return d.State.String() + " (was " + was.String() + ")"
+		}
+		// every cell gets escapes of the same length, so that the table
+		// stays aligned
+		if ok {
+			return "\033[7m" + d.State.String() + "\033[0m"
+		}
+		return "\033[0m" + d.State.String() + "\033[0m"
+	}
+}
+
+// deviceColumns returns the columns of a device table
+func deviceColumns(devices []Device) []tableColumn {
+	return []tableColumn{
+		{name: "id", value: func(i int) string { return devices[i].ID }},
+		{name: "hostname", value: func(i int) string { return devices[i].Hostname }},
+		{name: "state", value: func(i int) string { return devices[i].State.String() }},
+		{name: "plan", value: func(i int) string { return devices[i].PlanSlug() }},
+		{name: "facility", value: func(i int) string { return devices[i].FacilityCode() }},
+		{name: "ip", value: func(i int) string { return devices[i].PublicIPv4() }},
+		{name: "tags", value: func(i int) string { return strings.Join(devices[i].Tags, ",") }},
+		{name: "os", value: func(i int) string { return devices[i].OSSlug() }, hidden: true},
+		{name: "created", value: func(i int) string { return devices[i].Created }, hidden: true},
+	}
+}
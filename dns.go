@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DNSProvider manages the address records of a DNS zone
+type DNSProvider interface {
+	// UpsertRecord points name at value, creating the record or replacing
+	// the values it has
+	UpsertRecord(ctx context.Context, name, typ, value string, ttl int) error
+	// DeleteRecord removes the record, a missing record is not an error
+	DeleteRecord(ctx context.Context, name, typ string) error
+}
+
+// dnsProviderName selects the DNS provider devices are registered with,
+// rejecting unknown providers when the flags are parsed
+type dnsProviderName string
+
+var dnsProviders = []string{"cloudflare", "route53"}
+
+func (n dnsProviderName) String() string {
+	return string(n)
+}
+
+// Set parses a DNS provider from a flag
+func (n *dnsProviderName) Set(s string) error {
+	if err := checkEnum("DNS provider", s, dnsProviders); err != nil {
+		return err
+	}
+	*n = dnsProviderName(s)
+	return nil
+}
+
+var (
+	dnsProvider dnsProviderName
+	dnsZone     string
+	dnsTTL      int
+)
+
+// newDNSProvider returns the provider of --dns-provider for --dns-zone, with
+// the credentials of the provider environment variables
+func newDNSProvider() (DNSProvider, error) {
+	if dnsZone == "" {
+		return nil, fmt.Errorf("--dns-zone or PACKET_DNS_ZONE is needed with --dns-provider")
+	}
+	hc := &http.Client{Timeout: 30 * time.Second}
+	switch dnsProvider {
+	case "cloudflare":
+		token := os.Getenv("CLOUDFLARE_API_TOKEN")
+		if token == "" {
+			return nil, fmt.Errorf("CLOUDFLARE_API_TOKEN is needed to register devices with Cloudflare")
+		}
+		return &cloudflareDNS{token: token, zone: dnsZone, client: hc, baseURL: envOrDefault("CLOUDFLARE_API_URL", "https://api.cloudflare.com/client/v4/")}, nil
+	case "route53":
+		key, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if key == "" || secret == "" {
+			return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are needed to register devices with Route 53")
+		}
+		return &route53DNS{key: key, secret: secret, session: os.Getenv("AWS_SESSION_TOKEN"), zone: dnsZone, client: hc,
+			baseURL: envOrDefault("AWS_ROUTE53_URL", "https://route53.amazonaws.com/")}, nil
+	}
+	return nil, fmt.Errorf("unknown DNS provider %q", dnsProvider)
+}
+
+// dnsName returns the name of the device in the zone
+func dnsName(hostname string) string {
+	return strings.TrimSuffix(hostname, ".") + "." + strings.Trim(dnsZone, ".")
+}
+
+// dnsRecordID identifies a record in the state file
+func dnsRecordID(name, typ string) string {
+	return name + "/" + typ
+}
+
+// updateDNS registers the public addresses of a device that became active
+// and removes the records of a deleted device. The records are kept in the
+// state file with the device they point to, which is how they are found
+// again once the device is gone. Failures are logged and do not fail the
+// run.
+func updateDNS(event string, fields ...interface{}) {
+	if dnsProvider == "" || (event != "device.active" && event != "device.deleted") {
+		return
+	}
+	provider, err := newDNSProvider()
+	if err != nil {
+		logger.Error("Updating DNS failed", "error", err)
+		return
+	}
+	ctx, cancel := cleanupContext(context.Background())
+	defer cancel()
+	f := eventFields(fields)
+	deviceID := fmt.Sprint(f["device_id"])
+
+	if event == "device.active" {
+		d, _ := f["device"].(*Device)
+		if d == nil || d.Hostname == "" {
+			return
+		}
+		name := dnsName(d.Hostname)
+		for typ, addr := range map[string]string{"A": d.PublicIPv4(), "AAAA": d.PublicIPv6()} {
+			if addr == "" {
+				continue
+			}
+			if err := provider.UpsertRecord(ctx, name, typ, addr, dnsTTL); err != nil {
+				logger.Error("Registering the device in DNS failed", "name", name, "type", typ, "error", err)
+				continue
+			}
+			runState.createdRecord(dnsRecordID(name, typ), name, deviceID)
+			logger.Info("Registered the device in DNS", "name", name, "type", typ, "address", addr)
+		}
+		return
+	}
+
+	for _, r := range runState.records(deviceID) {
+		if err := deleteDNSRecord(ctx, provider, r); err != nil {
+			logger.Error("Removing the DNS record of the device failed, it stays in the state file", "name", r.Name, "error", err)
+			continue
+		}
+		logger.Info("Removed the DNS record of the device", "name", r.Name)
+	}
+}
+
+// deleteDNSRecord removes a record recorded in the state file
+func deleteDNSRecord(ctx context.Context, provider DNSProvider, r Resource) error {
+	typ := r.ID[strings.LastIndex(r.ID, "/")+1:]
+	if err := provider.DeleteRecord(ctx, r.Name, typ); err != nil {
+		return err
+	}
+	runState.deleted(r.ID)
+	return nil
+}
+
+// cloudflareDNS manages the records of a zone with the Cloudflare API
+type cloudflareDNS struct {
+	token   string
+	zone    string
+	baseURL string
+	client  *http.Client
+	zoneID  string
+}
+
+// cloudflareRecord is a DNS record of the Cloudflare API
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+// do sends a request to the Cloudflare API and decodes the result of its
+// response envelope into result
+func (c *cloudflareDNS) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var payload *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	} else {
+		payload = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var envelope struct {
+		Success bool            `json:"success"`
+		Result  json.RawMessage `json:"result"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("cloudflare: %s %s: status %d", method, path, resp.StatusCode)
+	}
+	if !envelope.Success {
+		var msgs []string
+		for _, e := range envelope.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return fmt.Errorf("cloudflare: %s", strings.Join(msgs, ", "))
+	}
+	if result != nil {
+		return json.Unmarshal(envelope.Result, result)
+	}
+	return nil
+}
+
+// lookupZone finds the ID of the zone by its name
+func (c *cloudflareDNS) lookupZone(ctx context.Context) error {
+	if c.zoneID != "" {
+		return nil
+	}
+	var zones []struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, "GET", "zones?name="+url.QueryEscape(strings.Trim(c.zone, ".")), nil, &zones); err != nil {
+		return err
+	}
+	if len(zones) == 0 {
+		return fmt.Errorf("cloudflare: no zone %s", c.zone)
+	}
+	c.zoneID = zones[0].ID
+	return nil
+}
+
+// find returns the records of the name and type
+func (c *cloudflareDNS) find(ctx context.Context, name, typ string) ([]cloudflareRecord, error) {
+	if err := c.lookupZone(ctx); err != nil {
+		return nil, err
+	}
+	var records []cloudflareRecord
+	q := url.Values{"name": {name}, "type": {typ}}
+	err := c.do(ctx, "GET", "zones/"+c.zoneID+"/dns_records?"+q.Encode(), nil, &records)
+	return records, err
+}
+
+func (c *cloudflareDNS) UpsertRecord(ctx context.Context, name, typ, value string, ttl int) error {
+	records, err := c.find(ctx, name, typ)
+	if err != nil {
+		return err
+	}
+	record := cloudflareRecord{Type: typ, Name: name, Content: value, TTL: ttl}
+	if len(records) > 0 {
+		return c.do(ctx, "PUT", "zones/"+c.zoneID+"/dns_records/"+records[0].ID, record, nil)
+	}
+	return c.do(ctx, "POST", "zones/"+c.zoneID+"/dns_records", record, nil)
+}
+
+func (c *cloudflareDNS) DeleteRecord(ctx context.Context, name, typ string) error {
+	records, err := c.find(ctx, name, typ)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := c.do(ctx, "DELETE", "zones/"+c.zoneID+"/dns_records/"+r.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "doctor",
+		usage: "Check the connection to the API, the token, the project and the rate limit, and suggest fixes",
+		run:   runDoctor,
+	})
+}
+
+// check results of doctor
+const (
+	checkOK      = "ok"
+	checkWarn    = "warn"
+	checkFailed  = "failed"
+	checkSkipped = "skipped"
+)
+
+// DoctorCheck is the result of a check of doctor, with how to fix it when
+// it did not pass
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// doctor runs the checks in order, skipping those that depend on a check
+// that failed
+type doctor struct {
+	checks []DoctorCheck
+}
+
+func (d *doctor) add(name, status, detail, fix string) {
+	d.checks = append(d.checks, DoctorCheck{name, status, detail, fix})
+}
+
+func (d *doctor) failed() bool {
+	return len(d.checks) > 0 && d.checks[len(d.checks)-1].Status == checkFailed
+}
+
+// tokenSource tells where the token in use comes from
+func tokenSource(fs *flag.FlagSet) string {
+	switch {
+	case tokenCommand != "":
+		return "--token-command " + tokenCommand
+	case len(profileTokens) > 0:
+		return "the tokens of the profile"
+	case isFlagPassed(fs, "token"):
+		return "--token"
+	case os.Getenv("PACKET_AUTH_TOKEN") != "":
+		return "PACKET_AUTH_TOKEN"
+	case os.Getenv("METAL_AUTH_TOKEN") != "":
+		return "METAL_AUTH_TOKEN"
+	}
+	return "the configuration profile or the keyring"
+}
+
+// explainRequestError tells what a failed request to the API means for
+// the connection, and how to fix it
+func explainRequestError(err error) (string, string) {
+	var dnsErr *net.DNSError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return "the API host name does not resolve: " + dnsErr.Error(), "check your DNS settings and the host of --api-url"
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr), errors.As(err, &invalidCert):
+		return "the TLS certificate of the API is not trusted: " + err.Error(), "a proxy inspecting TLS needs its CA in SSL_CERT_FILE or the system trust store; check the system clock too"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "the API did not answer in time: " + err.Error(), "check firewalls and HTTPS_PROXY, or raise --request-timeout"
+	case strings.Contains(err.Error(), "connection refused"):
+		return "the connection was refused: " + err.Error(), "check --api-url, nothing listens there"
+	}
+	return err.Error(), "check your network connection, HTTPS_PROXY and --api-url"
+}
+
+func runDoctor(ctx context.Context, args []string) error {
+	fs := newFlagSet("doctor")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+
+	d := &doctor{}
+	d.checkAPI(ctx)
+	if !d.failed() {
+		d.checkToken(ctx, tokenSource(fs))
+	}
+	if !d.failed() {
+		d.checkProject(ctx)
+		d.checkRateLimit()
+	}
+
+	failed := 0
+	for _, c := range d.checks {
+		if c.Status == checkFailed {
+			failed++
+		}
+	}
+	if jsonOutput() {
+		prettyPrint(d.checks)
+	} else {
+		for _, c := range d.checks {
+			fmt.Printf("[%s] %s: %s\n", strings.ToUpper(c.Status), c.Name, c.Detail)
+			if c.Fix != "" {
+				fmt.Printf("       fix: %s\n", c.Fix)
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	logger.Info("All checks passed")
+	return nil
+}
+
+// checkAPI checks that the API host resolves and answers over HTTPS
+func (d *doctor) checkAPI(ctx context.Context) {
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		d.add("api url", checkFailed, fmt.Sprintf("%q is not a URL", apiURL), "pass the base URL of the API, e.g. --api-url "+defaultAPIURL)
+		return
+	}
+
+	req, _ := http.NewRequest("GET", apiURL, nil)
+	proxy, _ := http.ProxyFromEnvironment(req)
+	if proxy != nil {
+		d.add("dns", checkSkipped, "requests go through the proxy "+proxy.Host+", which resolves "+u.Hostname(), "")
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		addrs, err := net.DefaultResolver.LookupHost(ctx, u.Hostname())
+		cancel()
+		if err != nil {
+			detail, fix := explainRequestError(err)
+			d.add("dns", checkFailed, detail, fix)
+			return
+		}
+		d.add("dns", checkOK, u.Hostname()+" resolves to "+strings.Join(addrs, ", "), "")
+	}
+
+	timeout := requestTimeout
+	if timeout == 0 || timeout > 30*time.Second {
+		timeout = 30 * time.Second
+	}
+	start := time.Now()
+	resp, err := (&http.Client{Timeout: timeout}).Do(req.WithContext(ctx))
+	if err != nil {
+		detail, fix := explainRequestError(err)
+		d.add("connection", checkFailed, detail, fix)
+		return
+	}
+	resp.Body.Close()
+	detail := fmt.Sprintf("%s answered in %s", u.Host, time.Since(start).Round(time.Millisecond))
+	if resp.TLS != nil {
+		cert := resp.TLS.PeerCertificates[0]
+		detail += fmt.Sprintf(", certificate valid until %s", cert.NotAfter.Format("2006-01-02"))
+		if time.Until(cert.NotAfter) < 14*24*time.Hour {
+			d.add("connection", checkWarn, detail, "the certificate of the API expires soon, check that the clock is right")
+			return
+		}
+	} else if u.Scheme == "http" {
+		detail += ", without TLS"
+	}
+	d.add("connection", checkOK, detail, "")
+}
+
+// checkToken checks that the API accepts the token
+func (d *doctor) checkToken(ctx context.Context, source string) {
+	if checkToken() != nil {
+		d.add("token", checkFailed, "no token is set", "set PACKET_AUTH_TOKEN, pass --token or run auth login")
+		return
+	}
+
+	var raw string
+	user := new(struct {
+		Email string `json:"email"`
+	})
+	err := newCLIClient().DoRequest(ctx, "user", "GET", nil, user, &raw)
+	var errResp *ErrorResponse
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &errResp) && errResp.StatusCode == http.StatusUnauthorized:
+		d.add("token", checkFailed, "the API rejected the token from "+source, "the token is wrong, expired or was deleted; create an API key in the console and set it with auth login or PACKET_AUTH_TOKEN")
+	case errors.As(err, &errResp) && errResp.StatusCode == http.StatusForbidden:
+		d.add("token", checkWarn, "the token from "+source+" cannot read the user, it may be a project API key", "")
+	case errors.As(err, &syntaxErr) || (errors.As(err, &errResp) && errResp.StatusCode == http.StatusNotFound) || (err == nil && user.Email == ""):
+		snippet := strings.TrimSpace(raw)
+		if len(snippet) > 60 {
+			snippet = snippet[:60] + "..."
+		}
+		d.add("token", checkFailed, fmt.Sprintf("%s does not answer like the Packet API: %q", apiURL, snippet), "check --api-url and that no captive portal or proxy answers instead of the API")
+	case err != nil:
+		detail, fix := explainRequestError(err)
+		d.add("token", checkFailed, detail, fix)
+	default:
+		d.add("token", checkOK, "the token from "+source+" belongs to "+user.Email, "")
+	}
+}
+
+// checkProject checks that the token can access the project
+func (d *doctor) checkProject(ctx context.Context) {
+	if strings.TrimSpace(projectID) == "" {
+		d.add("project", checkSkipped, "no project is set", "commands working on devices need PACKET_PROJECT_ID or --prid, project list shows your projects")
+		return
+	}
+	p, err := getProject(ctx, projectID, newCLIClient())
+	var errResp *ErrorResponse
+	switch {
+	case errors.As(err, &errResp) && (errResp.StatusCode == http.StatusNotFound || errResp.StatusCode == http.StatusForbidden):
+		d.add("project", checkFailed, "project "+projectID+" does not exist or the token has no access to it", "check PACKET_PROJECT_ID or --prid, project list shows the projects of the token")
+	case err != nil:
+		detail, fix := explainRequestError(err)
+		d.add("project", checkFailed, detail, fix)
+	default:
+		d.add("project", checkOK, fmt.Sprintf("project %s (%s) is accessible", p.Name, p.ID), "")
+	}
+}
+
+// checkRateLimit reports the rate limit left, as announced by the
+// responses of the previous checks
+func (d *doctor) checkRateLimit() {
+	runStats.mu.Lock()
+	limit, remaining, reset := runStats.rateLimit, runStats.rateRemaining, runStats.rateReset
+	runStats.mu.Unlock()
+	switch {
+	case remaining < 0:
+		d.add("rate limit", checkSkipped, "the API did not announce a rate limit", "")
+	case remaining == 0:
+		d.add("rate limit", checkFailed, fmt.Sprintf("the rate limit of %d requests is used up", limit), "wait until it resets at "+reset+", or use --cache for repeated reads")
+	case limit > 0 && remaining < limit/10:
+		d.add("rate limit", checkWarn, fmt.Sprintf("%d of %d requests left", remaining, limit), "slow down polling scripts or use --cache for repeated reads")
+	default:
+		d.add("rate limit", checkOK, fmt.Sprintf("%d of %d requests left", remaining, limit), "")
+	}
+}
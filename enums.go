@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkEnum returns an error listing the allowed values when s is not one
+// of them
+func checkEnum(what, s string, allowed []string) error {
+	for _, a := range allowed {
+		if s == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s %q, use one of %s", what, s, strings.Join(allowed, ", "))
+}
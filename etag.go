@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+)
+
+// etagEntry is a response stored for revalidation with its ETag
+type etagEntry struct {
+	etag string
+	dump []byte
+}
+
+// ETagMiddleware sends If-None-Match with GET requests whose response
+// carried an ETag, and serves the stored response when the API answers 304
+// Not Modified. Polling loops such as packet.WaitForDevice then only transfer
+// what changed. Responses are kept in memory for the life of the client,
+// keyed by the URL and the token like the response cache.
+func ETagMiddleware() Middleware {
+	var mu sync.Mutex
+	entries := map[string]etagEntry{}
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			if r.Method != "GET" {
+				return next(r)
+			}
+			key := r.URL.String() + "\n" + r.Header.Get("X-Auth-Token")
+			mu.Lock()
+			entry, ok := entries[key]
+			mu.Unlock()
+			if ok {
+				r.Header.Set("If-None-Match", entry.etag)
+			}
+
+			resp, err := next(r)
+			if err != nil {
+				return nil, err
+			}
+			if ok && resp.StatusCode == http.StatusNotModified {
+				resp.Body.Close()
+				logger.Debug("Serving not modified response", "url", r.URL.String())
+				return http.ReadResponse(bufio.NewReader(bytes.NewReader(entry.dump)), r)
+			}
+			etag := resp.Header.Get("ETag")
+			if etag == "" || resp.StatusCode != http.StatusOK {
+				return resp, nil
+			}
+			dump, err := httputil.DumpResponse(resp, true)
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			entries[key] = etagEntry{etag: etag, dump: dump}
+			mu.Unlock()
+			return resp, nil
+		}
+	}
+}
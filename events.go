@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "device events",
+		usage: "Show the events of a device, such as provisioning steps",
+		run:   runDeviceEvents,
+	})
+	registerCommand(&command{
+		name:  "project events",
+		usage: "Show the events of the project, across all its devices",
+		run:   runProjectEvents,
+	})
+}
+
+// Event is an entry of the event feed of a device or project
+type Event struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	State        string `json:"state,omitempty"`
+	Body         string `json:"body"`
+	Interpolated string `json:"interpolated,omitempty"`
+	Created      string `json:"created_at"`
+}
+
+// Message returns the event text with the names of the resources filled in
+func (e *Event) Message() string {
+	if e.Interpolated != "" {
+		return e.Interpolated
+	}
+	return e.Body
+}
+
+// listEvents returns the latest events of the feed, newest first. The feed
+// is decoded an event at a time as it arrives.
+func listEvents(ctx context.Context, uri string, limit int, c *Client) ([]Event, error) {
+	var events []Event
+	err := streamArray(ctx, c, fmt.Sprintf("%s?page=1&per_page=%d", uri, limit), "events", func(dec *json.Decoder) error {
+		var e Event
+		if err := dec.Decode(&e); err != nil {
+			return err
+		}
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// tailEvents prints the last limit events of the feed in the order they
+// happened. With follow it keeps polling the feed every interval and prints
+// new events until ctx is done.
+func tailEvents(ctx context.Context, uri string, limit int, follow bool, interval time.Duration, c *Client) error {
+	pageSize := limit
+	if follow {
+		// the API keeps no cursor, a full page between two polls is enough
+		// not to miss events
+		pageSize = 100
+	}
+	seen := map[string]bool{}
+	for first := true; ; first = false {
+		events, err := listEvents(ctx, uri, pageSize, c)
+		if err != nil {
+			return err
+		}
+		for i := len(events) - 1; i >= 0; i-- {
+			if seen[events[i].ID] {
+				continue
+			}
+			seen[events[i].ID] = true
+			if !first || i < limit {
+				printEvent(&events[i])
+			}
+		}
+		if !follow {
+			return nil
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return nil
+		}
+	}
+}
+
+// printEvent prints an event as a line, or as a JSON object per line with
+// --output json so that followed feeds can be piped
+func printEvent(e *Event) {
+	if outputFormat == "ndjson" {
+		printNDJSON(e)
+		return
+	}
+	if outputFormat == "json" {
+		data, _ := json.Marshal(e)
+		fmt.Println(string(data))
+		return
+	}
+	ts := e.Created
+	if t, err := time.Parse(time.RFC3339, e.Created); err == nil {
+		ts = t.Local().Format("2006-01-02 15:04:05")
+	}
+	fmt.Printf("%s  %s  %s\n", ts, e.Type, e.Message())
+}
+
+// addEventFlags adds the flags shared by the event commands
+func addEventFlags(fs *flag.FlagSet) (limit *int, follow *bool, interval *time.Duration) {
+	limit = fs.Int("limit", 20, "Number of past events to show")
+	follow = fs.Bool("follow", false, "Keep printing new events until interrupted")
+	interval = fs.Duration("interval", 5*time.Second, "How often to poll for new events with --follow")
+	return
+}
+
+func runDeviceEvents(ctx context.Context, args []string) error {
+	fs := newFlagSet("device events")
+	limit, follow, interval := addEventFlags(fs)
+	if err := parseIDArgs(fs, args, "device events [flags] <device-id>", 1); err != nil {
+		return err
+	}
+	if *limit < 1 || *limit > 100 {
+		return usageErrorf("--limit must be between 1 and 100")
+	}
+
+	return tailEvents(ctx, "devices/"+fs.Arg(0)+"/events", *limit, *follow, *interval, newCLIClient())
+}
+
+func runProjectEvents(ctx context.Context, args []string) error {
+	fs := newFlagSet("project events")
+	limit, follow, interval := addEventFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+	if *limit < 1 || *limit > 100 {
+		return usageErrorf("--limit must be between 1 and 100")
+	}
+
+	return tailEvents(ctx, "projects/"+projectID+"/events", *limit, *follow, *interval, newCLIClient())
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// exit statuses of runs, documented in the README for scripts to branch on.
+// crashExitCode and interruptedExitCode complete the list.
+const (
+	exitFailure     = 1
+	exitDrift       = 2
+	exitUsage       = 3
+	exitAuth        = 4
+	exitCapacity    = 5
+	exitTimeout     = 6
+	exitServerError = 7
+	exitPartial     = 8
+)
+
+// statusError is an error that ends the run with a specific exit status
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// usageErrorf reports invalid flags, arguments or configuration
+func usageErrorf(format string, a ...interface{}) error {
+	return &statusError{exitUsage, fmt.Errorf(format, a...)}
+}
+
+// exitCodeOf returns the exit status of a run that ended with err
+func exitCodeOf(err error) int {
+	var (
+		code     exitCode
+		statErr  *statusError
+		timeout  *ProvisionTimeoutError
+		tokenErr *TokenError
+		apiError *ErrorResponse
+	)
+	switch {
+	case err == nil, errors.Is(err, ErrDryRun):
+		return 0
+	case errors.As(err, &code):
+		return int(code)
+	case errors.Is(err, context.Canceled):
+		return interruptedExitCode
+	case errors.As(err, &statErr):
+		return statErr.status
+	case errors.As(err, &timeout):
+		return exitTimeout
+	case errors.As(err, &tokenErr):
+		return exitAuth
+	case errors.As(err, &apiError):
+		switch {
+		case apiError.StatusCode == 401 || apiError.StatusCode == 403:
+			return exitAuth
+		case apiError.NoCapacity():
+			return exitCapacity
+		case apiError.StatusCode >= 500:
+			return exitServerError
+		case apiError.StatusCode == 400 || apiError.StatusCode == 422:
+			return exitUsage
+		}
+	}
+	return exitFailure
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nurfet-becirevic/packet-go-demo/scenarios"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "failover demo",
+		usage: "Create an active and a standby device sharing an elastic IP, assigned to the active one",
+		run:   runFailoverDemo,
+	})
+	registerCommand(&command{
+		name:  "failover switch",
+		usage: "Move the elastic IP of a failover demo to the other device",
+		run:   runFailoverSwitch,
+	})
+}
+
+// Failover is the pair of devices of a failover demo, Active holding the
+// elastic IP
+type Failover struct {
+	Name    string  `json:"name"`
+	Address string  `json:"address"`
+	Active  *Device `json:"active"`
+	Standby *Device `json:"standby"`
+	// Downtime is how long the address was assigned to neither device
+	// during a switch
+	Downtime *Duration `json:"downtime,omitempty"`
+}
+
+func failoverTag(name string) string {
+	return "failover:" + name
+}
+
+// failoverUserdata binds the elastic IP on the loopback interface and
+// serves the hostname on port 80, so that curl shows which device the
+// address reaches
+func failoverUserdata(ip *IPReservation) string {
+	return fmt.Sprintf(`#!/bin/bash
+# written by packet-go-demo failover demo
+ip addr add %s/%d dev lo
+mkdir -p /srv/failover
+echo "served by $(hostname)" >/srv/failover/index.html
+cd /srv/failover && nohup python3 -m http.server 80 >/var/log/failover-http.log 2>&1 &
+`, ip.Network, ip.CIDR)
+}
+
+// elasticAssignment returns the ID of the assignment of the block to the
+// device, empty when the device does not hold it
+func elasticAssignment(d *Device, ip *IPReservation) string {
+	list, _ := d.Network.([]interface{})
+	for _, a := range list {
+		cidr, _ := attrValue(a, "cidr").(float64)
+		if attrString(a, "network") == ip.Network && int(cidr) == ip.CIDR {
+			return attrString(a, "id")
+		}
+	}
+	return ""
+}
+
+// moveElasticIP assigns the address to the device to, unassigning it from
+// the assignment of the device from first when set. When the address
+// cannot be assigned to to, it is assigned to from again. It returns how
+// long the address was unassigned.
+func moveElasticIP(ctx context.Context, ips IPService, address string, from *Device, assignmentID string, to *Device) (time.Duration, error) {
+	start := time.Now()
+	if assignmentID != "" {
+		if err := ips.Unassign(ctx, assignmentID); err != nil {
+			return 0, fmt.Errorf("unassigning %s from %s: %w", address, from.Hostname, err)
+		}
+	}
+	if _, err := ips.Assign(ctx, to.ID, address); err != nil {
+		err = fmt.Errorf("assigning %s to %s: %w", address, to.Hostname, err)
+		if assignmentID == "" {
+			return 0, err
+		}
+		cleanupCtx, cancel := cleanupContext(ctx)
+		defer cancel()
+		if _, berr := ips.Assign(cleanupCtx, from.ID, address); berr != nil {
+			return 0, fmt.Errorf("%w, and assigning it to %s again failed, the address is unassigned: %s", err, from.Hostname, berr)
+		}
+		return 0, fmt.Errorf("%w, it is back on %s", err, from.Hostname)
+	}
+	return time.Since(start), nil
+}
+
+func printFailover(f *Failover) {
+	if jsonOutput() {
+		prettyPrint(f)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROLE\tID\tHOSTNAME\tPUBLIC IPV4")
+	fmt.Fprintf(w, "active\t%s\t%s\t%s\n", f.Active.ID, f.Active.Hostname, f.Active.PublicIPv4())
+	fmt.Fprintf(w, "standby\t%s\t%s\t%s\n", f.Standby.ID, f.Standby.Hostname, f.Standby.PublicIPv4())
+	w.Flush()
+}
+
+func runFailoverDemo(ctx context.Context, args []string) error {
+	fs := newFlagSet("failover demo")
+	name := fs.String("name", "failover", "Name of the demo, the devices are named <name>-1 and <name>-2 and tagged failover:<name>")
+	spec := DeviceSpec{BillingCycle: BillingHourly}
+	fs.StringVar(&spec.Plan, "plan", "", "Server plan of the devices")
+	fs.StringVar(&spec.OS, "os", "ubuntu_22_04", "Server OS slug of the devices, which need python3 to serve their hostname")
+	fs.StringVar(&spec.Facility, "facility", "", "Facility code where to deploy the devices")
+	fs.StringVar(&spec.Metro, "metro", "", "Metro code where to deploy the devices instead of a facility (Equinix Metal API)")
+	block := fs.String("block", "ipv4/32", "Elastic IP block to reserve, e.g. ipv4/32 or global_ipv4/32")
+	fs.DurationVar(&provisionTimeout, "provision-timeout", DefaultProvisionTimeout, "How long to wait for the devices to become active, 0 for no limit")
+	addCostFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if spec.Plan == "" || spec.OS == "" || (spec.Facility == "" && spec.Metro == "") {
+		return usageErrorf("--plan, --os and --facility or --metro must be set by flags or the profile")
+	}
+	if spec.Facility != "" && spec.Metro != "" {
+		return usageErrorf("--facility and --metro cannot be combined")
+	}
+	ipReq, err := parseIPBlock(*block)
+	if err != nil {
+		return err
+	}
+	if ipReq.Type == "private_ipv4" {
+		return usageErrorf("--block %s is private, the demo needs a public block", *block)
+	}
+	if ipReq.Type != "global_ipv4" {
+		ipReq.Metro, ipReq.Facility = spec.Metro, spec.Facility
+	}
+	ipReq.Comments = "packet-go-demo failover demo " + *name
+	ipReq.Tags = []string{failoverTag(*name)}
+	spec.Hostname = *name
+	spec.Tags = []string{failoverTag(*name)}
+	req, err := spec.request(projectID)
+	if err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	if err := checkHourlyCost(ctx, client, billedPlans(req, req)); err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Printf("Would reserve %s, create 2 x %s (%s) in %s as %s-1 and %s-2, and assign the block to %s-1\n",
+			*block, spec.Plan, spec.OS, location(spec.Metro, spec.Facility), *name, *name, *name)
+		return nil
+	}
+
+	ips := cliIPs(client)
+	var undo undoStack
+	fail := func(err error) error {
+		logger.Error("The failover demo failed", "name", *name, "error", err)
+		undo.rollback(ctx, err)
+		return err
+	}
+
+	// the block is reserved first, so that the devices can bind it on boot
+	ip, err := ips.Request(ctx, projectID, ipReq)
+	if err != nil {
+		return err
+	}
+	address := fmt.Sprintf("%s/%d", ip.Network, ip.CIDR)
+	logger.Info("Reserved "+address, "id", ip.ID, "type", ip.Type())
+	undo.push("release IP reservation "+ip.ID, func(ctx context.Context) error {
+		return ips.Release(ctx, ip.ID)
+	})
+
+	req.UserData = failoverUserdata(ip)
+	res, err := scenarios.ProvisionCluster(ctx, cliDevices(client), scenarios.ClusterOptions{Request: req, Count: 2, Timeout: provisionTimeout, KeepPartial: true})
+	for _, d := range res.Devices {
+		pushDeleteDevice(&undo, client, d)
+	}
+	if err != nil {
+		for hostname, nodeErr := range res.Failed {
+			logger.Error("Device failed", "hostname", hostname, "error", nodeErr)
+		}
+		return fail(err)
+	}
+	f := &Failover{Name: *name, Address: address, Active: res.Devices[0], Standby: res.Devices[1]}
+
+	if _, err := ips.Assign(ctx, f.Active.ID, address); err != nil {
+		return fail(err)
+	}
+	logger.Info("Assigned "+address+" to "+f.Active.Hostname, "device", f.Active.ID)
+
+	printFailover(f)
+	if !jsonOutput() {
+		fmt.Printf("\n%s is on %s, curl http://%s/ shows which device answers. Move it to %s with:\n\n", address, f.Active.Hostname, ip.Network, f.Standby.Hostname)
+		fmt.Printf("packet-go-demo failover switch --name %s\n", *name)
+	}
+	return nil
+}
+
+func runFailoverSwitch(ctx context.Context, args []string) error {
+	fs := newFlagSet("failover switch")
+	name := fs.String("name", "failover", "Name of the failover demo")
+	to := fs.String("to", "", "ID of the device to move the address to (default the device not holding it)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	tag := failoverTag(*name)
+	blocks, err := listIPReservations(ctx, projectID, client)
+	if err != nil {
+		return err
+	}
+	var ip *IPReservation
+	for i := range blocks {
+		if contains(blocks[i].Tags, tag) {
+			ip = &blocks[i]
+			break
+		}
+	}
+	all, err := listDevices(ctx, projectID, client)
+	if err != nil {
+		return err
+	}
+	var pair []*Device
+	for i := range all {
+		if contains(all[i].Tags, tag) {
+			pair = append(pair, &all[i])
+		}
+	}
+	if ip == nil || len(pair) != 2 {
+		return fmt.Errorf("no failover demo %q with an IP block and two devices tagged %s in project %s, create one with failover demo", *name, tag, projectID)
+	}
+	sort.Slice(pair, func(i, j int) bool { return pair[i].Hostname < pair[j].Hostname })
+
+	// the address goes to the device not holding it, the first one when
+	// neither does
+	var holder *Device
+	assignmentID := ""
+	for _, d := range pair {
+		if id := elasticAssignment(d, ip); id != "" {
+			holder, assignmentID = d, id
+		}
+	}
+	target := pair[0]
+	if holder == pair[0] {
+		target = pair[1]
+	}
+	if *to != "" {
+		switch *to {
+		case pair[0].ID:
+			target = pair[0]
+		case pair[1].ID:
+			target = pair[1]
+		default:
+			return usageErrorf("--to %s is not a device of failover demo %q, use %s or %s", *to, *name, pair[0].ID, pair[1].ID)
+		}
+	}
+	f := &Failover{Name: *name, Address: fmt.Sprintf("%s/%d", ip.Network, ip.CIDR), Active: target, Standby: pair[0]}
+	if target == pair[0] {
+		f.Standby = pair[1]
+	}
+	if target == holder {
+		logger.Info(f.Address+" is already on "+target.Hostname, "device", target.ID)
+		printFailover(f)
+		return nil
+	}
+
+	downtime, err := moveElasticIP(ctx, cliIPs(client), f.Address, holder, assignmentID, f.Active)
+	if err != nil {
+		return err
+	}
+	if assignmentID != "" {
+		d := Duration(downtime)
+		f.Downtime = &d
+		logger.Info("Moved "+f.Address+" from "+holder.Hostname+" to "+f.Active.Hostname, "downtime", d)
+	} else {
+		logger.Info("Assigned "+f.Address+" to "+f.Active.Hostname, "device", f.Active.ID)
+	}
+	printFailover(f)
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "manifest generate",
+		usage: "Write a manifest describing the current project contents",
+		run:   runManifestGenerate,
+	})
+	registerCommand(&command{
+		name:  "device export",
+		usage: "Write the spec of devices, in the manifest format of apply",
+		run:   runDeviceExport,
+	})
+}
+
+func runManifestGenerate(ctx context.Context, args []string) error {
+	fs := newFlagSet("manifest generate")
+	addCacheFlags(fs)
+	project := fs.String("project", "", "Project to describe (default --prid)")
+	file := fs.String("f", "", "Write the manifest to this file instead of stdout, JSON for a .json extension")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *project != "" {
+		projectID = *project
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	var (
+		devices []Device
+		vlans   []VirtualNetwork
+		ips     []IPReservation
+	)
+	g, gctx := newGroup(ctx)
+	g.Go(func() (err error) {
+		devices, err = listDevices(gctx, projectID, client)
+		return err
+	})
+	g.Go(func() (err error) {
+		vlans, err = listVLANs(gctx, projectID, client)
+		return err
+	})
+	g.Go(func() (err error) {
+		ips, err = listIPReservations(gctx, projectID, client)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	m := generateManifest(projectID, devices, vlans, ips)
+	if err := writeManifest(m, *file); err != nil || *file == "" {
+		return err
+	}
+	logger.Info("Wrote manifest to "+*file, "project", projectID,
+		"devices", len(m.Devices), "vlans", len(m.VLANs), "ip_reservations", len(m.IPReservations))
+	return nil
+}
+
+// writeManifest writes the manifest to the file, or stdout when file is
+// empty, as JSON for a .json extension or --output json and YAML otherwise
+func writeManifest(m *Manifest, file string) error {
+	var (
+		data []byte
+		err  error
+	)
+	if outputFormat == "json" || strings.EqualFold(filepath.Ext(file), ".json") {
+		data, err = json.MarshalIndent(m, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yamlMarshal(m)
+	}
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+// deviceSpec describes a live device as the spec creating it again
+func deviceSpec(d *Device) DeviceSpec {
+	spec := DeviceSpec{
+		Hostname:     d.Hostname,
+		Plan:         d.PlanSlug(),
+		OS:           d.OSSlug(),
+		BillingCycle: d.BillingCycle,
+		Tags:         d.Tags,
+		UserData:     d.UserData,
+	}
+	if code := attrString(d.Metro, "code"); code != "" {
+		spec.Metro = code
+	} else {
+		spec.Facility = d.FacilityCode()
+	}
+	return spec
+}
+
+// generateManifest describes the project contents as a manifest, so that
+// existing projects can adopt the apply workflow
+func generateManifest(projectID string, devices []Device, vlans []VirtualNetwork, ips []IPReservation) *Manifest {
+	m := &Manifest{Project: projectID, Devices: []DeviceSpec{}}
+
+	seen := map[string]bool{}
+	for i := range devices {
+		d := &devices[i]
+		// manifests match devices by hostname, only the first one can be managed
+		if seen[d.Hostname] {
+			logger.Warn("Skipping device, its hostname is used by another device", "device", d.ID, "hostname", d.Hostname)
+			continue
+		}
+		seen[d.Hostname] = true
+		m.Devices = append(m.Devices, deviceSpec(d))
+	}
+
+	for _, v := range vlans {
+		m.VLANs = append(m.VLANs, VLANSpec{
+			Description: v.Description,
+			VXLAN:       v.VXLAN,
+			Facility:    v.FacilityCode,
+			Metro:       v.MetroCode,
+		})
+	}
+
+	for i := range ips {
+		ip := &ips[i]
+		// management addresses come with every device and are not reserved
+		if ip.Management {
+			continue
+		}
+		m.IPReservations = append(m.IPReservations, IPReservationSpec{
+			Type:        ip.Type(),
+			CIDR:        ip.CIDR,
+			Facility:    attrString(ip.Facility, "code"),
+			Metro:       attrString(ip.Metro, "code"),
+			Description: ip.Details,
+			Tags:        ip.Tags,
+		})
+	}
+	return m
+}
+
+func runDeviceExport(ctx context.Context, args []string) error {
+	fs := newFlagSet("device export")
+	file := fs.String("o", "", "Write the spec to this file instead of stdout, JSON for a .json extension")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo device export [flags] <device-id>...")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	m := &Manifest{Devices: []DeviceSpec{}}
+	seen := map[string]bool{}
+	for _, id := range fs.Args() {
+		d, err := getDevice(ctx, id, client)
+		if err != nil {
+			return err
+		}
+		if seen[d.Hostname] {
+			return usageErrorf("device %s has the hostname %s of another exported device, specs match devices by hostname", id, d.Hostname)
+		}
+		seen[d.Hostname] = true
+		if p := attrString(d.Project, "id"); m.Project == "" {
+			m.Project = p
+		} else if p != m.Project {
+			return usageErrorf("device %s belongs to project %s, export the devices of each project separately", id, p)
+		}
+		m.Devices = append(m.Devices, deviceSpec(d))
+	}
+	if err := writeManifest(m, *file); err != nil || *file == "" {
+		return err
+	}
+	logger.Info("Wrote spec to "+*file, "devices", len(m.Devices))
+	return nil
+}
@@ -0,0 +1,3 @@
+module github.com/nurfet-becirevic/packet-go-demo
+
+go 1.21
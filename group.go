@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cleanupTimeout bounds the cleanup of a run that was interrupted
+const cleanupTimeout = 2 * time.Minute
+
+// group runs functions concurrently and cancels the context of the others
+// when one of them fails, like golang.org/x/sync/errgroup
+type group struct {
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+	once   sync.Once
+	err    error
+}
+
+// newGroup returns a group and the context its functions run with
+func newGroup(ctx context.Context) (*group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &group{cancel: cancel}, ctx
+}
+
+// Go runs f in a new goroutine
+func (g *group) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := f(); err != nil {
+			g.once.Do(func() {
+				g.err = err
+				g.cancel()
+			})
+		}
+	}()
+}
+
+// Wait waits for all functions and returns the first error
+func (g *group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cleanupContext returns a context for undoing the work of a run, e.g.
+// deleting a device, that keeps working after ctx was cancelled
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
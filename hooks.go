@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// lifecycle hooks, local commands run when a device becomes active, fails
+// to provision or is deleted
+var (
+	onActive  string
+	onFailure string
+	onDelete  string
+)
+
+// shellCommand returns a command running script with the shell of the OS
+func shellCommand(ctx context.Context, script string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", script)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", script)
+}
+
+// runHook runs the hook of the lifecycle event with the device in DEVICE_
+// environment variables. Its output goes to stderr, stdout is kept for the
+// results, and a failing hook is logged without failing the run.
+func runHook(event string, fields ...interface{}) {
+	script := map[string]string{"device.active": onActive, "device.failed": onFailure, "device.deleted": onDelete}[event]
+	if script == "" {
+		return
+	}
+	f := eventFields(fields)
+	env := []string{"DEVICE_EVENT=" + event}
+	for name, key := range map[string]string{"DEVICE_ID": "device_id", "DEVICE_HOSTNAME": "hostname", "DEVICE_IP": "ip", "DEVICE_ERROR": "error"} {
+		if v, ok := f[key]; ok {
+			env = append(env, name+"="+fmt.Sprint(v))
+		}
+	}
+	if d, ok := f["device"].(*Device); ok {
+		env = append(env, "DEVICE_STATE="+d.State.String(), "DEVICE_PLAN="+d.PlanSlug(), "DEVICE_METRO="+d.MetroCode())
+	}
+
+	// hooks run when the run is interrupted too, e.g. on-delete of cleanup
+	ctx, cancel := cleanupContext(context.Background())
+	defer cancel()
+	cmd := shellCommand(ctx, script)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	logger.Debug("Running hook", "event", event, "command", script)
+	if err := cmd.Run(); err != nil {
+		logger.Error("Hook failed", "event", event, "command", script, "error", err)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+var (
+	hostnameStyle    string
+	hostnamePrefix   string
+	hostnameTemplate string
+)
+
+func addHostnameFlags(fs *flag.FlagSet) {
+	fs.StringVar(&hostnameStyle, "hostname-style", "random", "How to generate hostnames that are not provided: "+strings.Join(HostnameStyles(), ", "))
+	fs.StringVar(&hostnamePrefix, "hostname-prefix", "demo", "Prefix of sequential hostnames")
+	fs.StringVar(&hostnameTemplate, "hostname-template", "", "Go template of hostnames for the template style, e.g. {{.Prefix}}-{{random 4}}")
+}
+
+// flagHostnameGenerator creates the generator selected by the hostname
+// flags, a style that cannot be created is a usage error
+func flagHostnameGenerator() (HostnameGenerator, error) {
+	gen, err := NewHostnameGenerator(hostnameStyle, HostnameOptions{Prefix: hostnamePrefix, Template: hostnameTemplate})
+	if err != nil {
+		if hostnameStyle == "template" && hostnameTemplate == "" {
+			return nil, usageErrorf("--hostname-style template needs --hostname-template")
+		}
+		return nil, usageErrorf("%s", err)
+	}
+	return gen, nil
+}
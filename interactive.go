@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// interactive prompts for the demo parameters that were not given
+var interactive bool
+
+// demoPrompts are the demo parameters asked for in interactive mode, the
+// ones not given on the command line
+type demoPrompts struct {
+	project  bool
+	plan     bool
+	location bool
+	os       bool
+}
+
+var prompts demoPrompts
+
+// promptReader reads every answer from stdin, of prompts, confirmations
+// and secrets, shared so that no buffered input is lost between them
+var promptReader = bufio.NewReader(os.Stdin)
+
+// choice is an option of a prompt, value is what choosing it sets
+type choice struct {
+	value string
+	label string
+}
+
+// Facility is a datacenter devices are deployed in
+type Facility struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// OperatingSystem is an OS devices can be deployed with
+type OperatingSystem struct {
+	Slug            string   `json:"slug"`
+	Name            string   `json:"name"`
+	ProvisionableOn []string `json:"provisionable_on"`
+}
+
+// ProvisionableWith reports whether devices of the plan can run the OS,
+// any plan when the API does not tell
+func (o *OperatingSystem) ProvisionableWith(plan string) bool {
+	for _, p := range o.ProvisionableOn {
+		if p == plan {
+			return true
+		}
+	}
+	return len(o.ProvisionableOn) == 0
+}
+
+// listFacilities returns the facilities of the Packet API
+func listFacilities(ctx context.Context, c *Client) ([]Facility, error) {
+	list := new(struct {
+		Facilities []Facility `json:"facilities"`
+	})
+	if err := c.DoRequest(ctx, "facilities", "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.Facilities, nil
+}
+
+// listMetros returns the metros of the Equinix Metal API
+func listMetros(ctx context.Context, c *Client) ([]Facility, error) {
+	list := new(struct {
+		Metros []Facility `json:"metros"`
+	})
+	if err := c.DoRequest(ctx, "locations/metros", "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.Metros, nil
+}
+
+// listOperatingSystems returns the operating systems devices can be
+// deployed with
+func listOperatingSystems(ctx context.Context, c *Client) ([]OperatingSystem, error) {
+	list := new(struct {
+		OperatingSystems []OperatingSystem `json:"operating_systems"`
+	})
+	if err := c.DoRequest(ctx, "operating-systems", "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.OperatingSystems, nil
+}
+
+// checkInteractive validates the interactive mode and asks for a missing
+// token, the rest is asked for by promptDemo once a client exists
+func checkInteractive() error {
+	fi, err := os.Stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return usageErrorf("--interactive needs a terminal")
+	}
+	if strings.TrimSpace(token) == "" && tokenCommand == "" {
+		if token, err = readSecret("Packet API token: "); err != nil {
+			return fmt.Errorf("reading the token: %w", err)
+		}
+	}
+	return checkToken()
+}
+
+// promptDemo lets the user pick the project, plan, location and OS of the
+// demo device from the lists of the API, and confirm the device. It
+// reports whether the device should be provisioned.
+func promptDemo(ctx context.Context, client *Client) (bool, error) {
+	if prompts.project {
+		projects, err := listProjects(ctx, "", client)
+		if err != nil {
+			return false, err
+		}
+		var choices []choice
+		for _, p := range projects {
+			choices = append(choices, choice{p.ID, p.Name + " (" + p.ID + ")"})
+		}
+		if projectID, err = choose("Project", choices, projectID); err != nil {
+			return false, err
+		}
+	}
+
+	plans, err := listPlans(ctx, projectID, client)
+	if err != nil {
+		return false, err
+	}
+	if prompts.plan {
+		var choices []choice
+		for _, p := range plans {
+			choices = append(choices, choice{p.Slug, fmt.Sprintf("%s  %s  $%.2f/hour", p.Slug, p.Name, p.Pricing.Hour)})
+		}
+		if plan, err = choose("Plan", choices, plan); err != nil {
+			return false, err
+		}
+	}
+
+	if prompts.location {
+		if client.Flavor() == FlavorEquinixMetal {
+			metros, err := listMetros(ctx, client)
+			if err != nil {
+				return false, err
+			}
+			if metro, err = choose("Metro", locationChoices(metros), metro); err != nil {
+				return false, err
+			}
+			facility = ""
+		} else {
+			facilities, err := listFacilities(ctx, client)
+			if err != nil {
+				return false, err
+			}
+			if facility, err = choose("Facility", locationChoices(facilities), facility); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if prompts.os {
+		systems, err := listOperatingSystems(ctx, client)
+		if err != nil {
+			return false, err
+		}
+		var choices []choice
+		for _, s := range systems {
+			if !s.ProvisionableWith(plan) {
+				continue
+			}
+			choices = append(choices, choice{s.Slug, s.Slug + "  " + s.Name})
+		}
+		if ops, err = choose("Operating system", choices, ops); err != nil {
+			return false, err
+		}
+	}
+
+	location := facility
+	if metro != "" {
+		location = metro
+	}
+	price := "unknown"
+	for _, p := range plans {
+		if p.Slug == plan {
+			price = fmt.Sprintf("$%.2f", p.Pricing.Hour)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "\nHostname  %s\nProject   %s\nPlan      %s (%s/hour)\nLocation  %s\nOS        %s\nBilling   %s\n\n",
+		hostname, projectID, plan, price, location, ops, billingCycle)
+	return confirm("Provision this device?")
+}
+
+func locationChoices(locations []Facility) []choice {
+	var choices []choice
+	for _, l := range locations {
+		choices = append(choices, choice{l.Code, l.Code + "  " + l.Name})
+	}
+	return choices
+}
+
+// choose asks for one of the choices by number or value. An empty answer
+// keeps the current value when it is one of the choices, and a single
+// choice is taken without asking.
+func choose(title string, choices []choice, current string) (string, error) {
+	if len(choices) == 0 {
+		return "", fmt.Errorf("no %s to choose from", strings.ToLower(title))
+	}
+	known := false
+	for _, c := range choices {
+		known = known || c.value == current
+	}
+	if !known {
+		current = ""
+	}
+	if len(choices) == 1 && current == "" {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", title, choices[0].label)
+		return choices[0].value, nil
+	}
+	fmt.Fprintln(os.Stderr, title+":")
+	for i, c := range choices {
+		fmt.Fprintf(os.Stderr, "  %2d) %s\n", i+1, c.label)
+	}
+	for {
+		if current != "" {
+			fmt.Fprintf(os.Stderr, "%s [%s]: ", title, current)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: ", title)
+		}
+		line, err := promptReader.ReadString('\n')
+		answer := strings.TrimSpace(line)
+		if err != nil && answer == "" {
+			return "", usageErrorf("no %s chosen", strings.ToLower(title))
+		}
+		if answer == "" && current != "" {
+			return current, nil
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+			return choices[n-1].value, nil
+		}
+		for _, c := range choices {
+			if c.value == answer {
+				return answer, nil
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Enter a number between 1 and %d\n", len(choices))
+	}
+}
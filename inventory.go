@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "inventory",
+		usage: "Print the project devices as an Ansible dynamic inventory",
+		run:   runInventory,
+	})
+}
+
+// ansibleGroup is a group of an Ansible inventory
+type ansibleGroup struct {
+	Hosts    []string `json:"hosts,omitempty"`
+	Children []string `json:"children,omitempty"`
+}
+
+func runInventory(ctx context.Context, args []string) error {
+	fs := newFlagSet("inventory")
+	addCacheFlags(fs)
+	format := fs.String("format", "ansible", "Inventory format, only ansible for now")
+	// Ansible runs inventory scripts with --list or --host <name>
+	fs.Bool("list", true, "Print the whole inventory, as Ansible asks for it")
+	host := fs.String("host", "", "Print the variables of a single host only")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *format != "ansible" {
+		return usageErrorf("unknown inventory format %q, use ansible", *format)
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	devices, err := listDevices(ctx, projectID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	inv, hostvars := ansibleInventory(devices)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if isFlagPassed(fs, "host") {
+		vars := hostvars[*host]
+		if vars == nil {
+			vars = map[string]interface{}{}
+		}
+		return enc.Encode(vars)
+	}
+	return enc.Encode(inv)
+}
+
+// ansibleInventory groups the devices by tag, facility and plan, with the
+// device details as host variables, which are returned on their own too.
+// Hosts are named by hostname and reached at their public IPv4 address.
+func ansibleInventory(devices []Device) (map[string]interface{}, map[string]map[string]interface{}) {
+	hostvars := map[string]map[string]interface{}{}
+	groups := map[string]*ansibleGroup{}
+	addHost := func(group, host string) {
+		g := groups[group]
+		if g == nil {
+			g = &ansibleGroup{}
+			groups[group] = g
+		}
+		g.Hosts = append(g.Hosts, host)
+	}
+
+	var ungrouped []string
+	for i := range devices {
+		d := &devices[i]
+		name := d.Hostname
+		if _, dup := hostvars[name]; dup || name == "" {
+			name = d.Hostname + "-" + d.ID
+		}
+
+		vars := map[string]interface{}{
+			"packet_id":       d.ID,
+			"packet_state":    d.State,
+			"packet_plan":     d.PlanSlug(),
+			"packet_facility": d.FacilityCode(),
+			"packet_metro":    d.MetroCode(),
+			"packet_os":       d.OSSlug(),
+			"packet_tags":     append([]string{}, d.Tags...),
+		}
+		if ip := d.PublicIPv4(); ip != "" {
+			vars["ansible_host"] = ip
+			vars["ansible_user"] = "root"
+		}
+		hostvars[name] = vars
+
+		grouped := false
+		for _, tag := range d.Tags {
+			addHost("tag_"+ansibleGroupName(tag), name)
+			grouped = true
+		}
+		if code := d.FacilityCode(); code != "" {
+			addHost("facility_"+ansibleGroupName(code), name)
+			grouped = true
+		}
+		if slug := d.PlanSlug(); slug != "" {
+			addHost("plan_"+ansibleGroupName(slug), name)
+			grouped = true
+		}
+		if !grouped {
+			ungrouped = append(ungrouped, name)
+		}
+	}
+
+	inv := map[string]interface{}{
+		"_meta": map[string]interface{}{"hostvars": hostvars},
+	}
+	all := &ansibleGroup{Children: []string{"ungrouped"}}
+	for name, g := range groups {
+		sort.Strings(g.Hosts)
+		inv[name] = g
+		all.Children = append(all.Children, name)
+	}
+	sort.Strings(all.Children)
+	sort.Strings(ungrouped)
+	inv["all"] = all
+	inv["ungrouped"] = &ansibleGroup{Hosts: ungrouped}
+	return inv, hostvars
+}
+
+// ansibleGroupName turns a tag, facility or plan into a valid group name,
+// e.g. c3.small.x86 into c3_small_x86
+func ansibleGroupName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "ip list",
+		usage: "List the IP blocks reserved in the project",
+		run:   runIPList,
+	})
+	registerCommand(&command{
+		name:  "ip request",
+		usage: "Reserve a block of IP addresses in the project",
+		run:   runIPRequest,
+	})
+	registerCommand(&command{
+		name:  "ip assign",
+		usage: "Assign addresses of a reserved block to a device",
+		run:   runIPAssign,
+	})
+	registerCommand(&command{
+		name:  "ip unassign",
+		usage: "Remove an IP assignment from a device",
+		run:   runIPUnassign,
+	})
+}
+
+// parseIPBlock parses a block such as ipv4/31 or private_ipv4/30 into a
+// reservation request, ipv4 being short for public_ipv4
+func parseIPBlock(s string) (*IPReservationRequest, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return nil, usageErrorf("invalid IP block %q, want <type>/<prefix length> such as ipv4/31", s)
+	}
+	typ := parts[0]
+	if typ == "ipv4" {
+		typ = "public_ipv4"
+	}
+	switch typ {
+	case "public_ipv4", "private_ipv4", "global_ipv4":
+	default:
+		return nil, usageErrorf("invalid IP block type %q, use ipv4, public_ipv4, private_ipv4 or global_ipv4", parts[0])
+	}
+	cidr, err := strconv.Atoi(parts[1])
+	if err != nil || cidr < 1 || cidr > 32 {
+		return nil, usageErrorf("invalid prefix length %q in IP block %q", parts[1], s)
+	}
+	return &IPReservationRequest{Type: typ, Quantity: 1 << uint(32-cidr)}, nil
+}
+
+// listIPReservations returns the IP blocks reserved in the project
+func listIPReservations(ctx context.Context, projectID string, c *Client) ([]IPReservation, error) {
+	return c.IPs().List(ctx, projectID)
+}
+
+// requestIPReservation reserves a block of IP addresses in the project and
+// records it in the state of the run
+func requestIPReservation(ctx context.Context, projectID string, req *IPReservationRequest, c *Client) (*IPReservation, error) {
+	ip, err := c.IPs().Request(ctx, projectID, req)
+	if err != nil {
+		return nil, err
+	}
+	runState.created("ip_reservation", ip.ID, fmt.Sprintf("%s/%d", ip.Network, ip.CIDR), projectID)
+	return ip, nil
+}
+
+// assignIP assigns an address, given in CIDR notation, to the device
+func assignIP(ctx context.Context, deviceID, address string, c *Client) (*IPAssignment, error) {
+	return c.IPs().Assign(ctx, deviceID, address)
+}
+
+// releaseIPReservation gives a reserved block back
+func releaseIPReservation(ctx context.Context, reservationID string, c *Client) error {
+	if err := c.IPs().Release(ctx, reservationID); err != nil {
+		return err
+	}
+	runState.deleted(reservationID)
+	return nil
+}
+
+// unassignIP removes an IP assignment
+func unassignIP(ctx context.Context, assignmentID string, c *Client) error {
+	return c.IPs().Unassign(ctx, assignmentID)
+}
+
+func runIPList(ctx context.Context, args []string) error {
+	fs := newFlagSet("ip list")
+	addCacheFlags(fs)
+	all := fs.Bool("all", false, "Include the management addresses that come with every device")
+	addTableFlags(fs, "block, type, location or any other column")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if _, err := selectColumns(ipColumns(nil)); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	ips, err := listIPReservations(ctx, projectID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	list := []IPReservation{}
+	for _, ip := range ips {
+		if *all || !ip.Management {
+			list = append(list, ip)
+		}
+	}
+
+	if jsonOutput() {
+		prettyPrint(list)
+		return nil
+	}
+	return printTable(ipColumns(list), len(list))
+}
+
+// ipColumns returns the columns of an IP reservation table
+func ipColumns(list []IPReservation) []tableColumn {
+	return []tableColumn{
+		{name: "id", value: func(i int) string { return list[i].ID }},
+		{name: "block", value: func(i int) string { return fmt.Sprintf("%s/%d", list[i].Network, list[i].CIDR) }},
+		{name: "type", value: func(i int) string { return list[i].Type() }},
+		{name: "location", value: func(i int) string { return list[i].Location() }},
+		{name: "assigned", value: func(i int) string { return strconv.Itoa(len(list[i].Assignments)) }},
+		{name: "tags", value: func(i int) string { return strings.Join(list[i].Tags, ",") }},
+	}
+}
+
+func runIPRequest(ctx context.Context, args []string) error {
+	fs := newFlagSet("ip request")
+	req := &IPReservationRequest{}
+	fs.StringVar(&req.Type, "type", "public_ipv4", "Block type: public_ipv4, private_ipv4 or global_ipv4")
+	fs.IntVar(&req.Quantity, "quantity", 1, "Number of addresses, a power of two")
+	fs.StringVar(&req.Metro, "metro", "", "Metro of the block (Equinix Metal API)")
+	fs.StringVar(&req.Facility, "facility", "", "Facility of the block")
+	fs.StringVar(&req.Comments, "comments", "", "Why the block is needed, shown to support when approval is required")
+	tags := fs.String("tags", "", "Comma separated tags of the block")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+	if req.Quantity < 1 || req.Quantity&(req.Quantity-1) != 0 {
+		return usageErrorf("--quantity must be a power of two, got %d", req.Quantity)
+	}
+	if req.Type != "global_ipv4" && (req.Metro == "") == (req.Facility == "") {
+		return usageErrorf("provide either --metro or --facility")
+	}
+	req.Tags = splitList(*tags)
+
+	ip, err := requestIPReservation(ctx, projectID, req, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(ip)
+		return nil
+	}
+	logger.Info(fmt.Sprintf("Reserved %s/%d", ip.Network, ip.CIDR), "id", ip.ID, "type", ip.Type())
+	return nil
+}
+
+func runIPAssign(ctx context.Context, args []string) error {
+	fs := newFlagSet("ip assign")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo ip assign [flags] <device-id> <address/cidr>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	// a single address unless a prefix is given
+	address := fs.Arg(1)
+	if !strings.Contains(address, "/") {
+		if strings.Contains(address, ":") {
+			address += "/128"
+		} else {
+			address += "/32"
+		}
+	}
+	a, err := assignIP(ctx, fs.Arg(0), address, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(a)
+		return nil
+	}
+	logger.Info(fmt.Sprintf("Assigned %s/%d to device %s", a.Address, a.CIDR, fs.Arg(0)), "assignment", a.ID)
+	return nil
+}
+
+func runIPUnassign(ctx context.Context, args []string) error {
+	fs := newFlagSet("ip unassign")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo ip unassign [flags] <assignment-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	if err := unassignIP(ctx, fs.Arg(0), newCLIClient()); err != nil {
+		return err
+	}
+	logger.Info("Assignment " + fs.Arg(0) + " removed")
+	return nil
+}
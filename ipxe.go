@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"net/url"
+)
+
+// customIPXEOS is the operating system of devices booting an iPXE script
+const customIPXEOS = "custom_ipxe"
+
+var (
+	ipxeScriptURL string
+	alwaysPXE     bool
+)
+
+// addIPXEFlags adds the flags booting a device from a custom iPXE script
+func addIPXEFlags(fs *flag.FlagSet) {
+	fs.StringVar(&ipxeScriptURL, "ipxe-script-url", "", "URL of an iPXE script the device boots to install a custom OS, implies --os "+customIPXEOS)
+	fs.BoolVar(&alwaysPXE, "always-pxe", false, "Boot the iPXE script on every boot, not only the first one")
+}
+
+// checkIPXE validates the iPXE flags and switches the OS to custom iPXE
+// when a script is given. A script can also be passed as userdata, so
+// --always-pxe only needs the OS.
+func checkIPXE() error {
+	if ipxeScriptURL != "" {
+		u, err := url.Parse(ipxeScriptURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return usageErrorf("--ipxe-script-url must be an http or https URL, got %q", ipxeScriptURL)
+		}
+		ops = customIPXEOS
+	}
+	if alwaysPXE && ops != customIPXEOS {
+		return usageErrorf("--always-pxe needs --ipxe-script-url or --os %s", customIPXEOS)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/nurfet-becirevic/packet-go-demo/scenarios"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "k8s bootstrap",
+		usage: "Create control-plane and worker devices that install Kubernetes with kubeadm on boot, and print how to fetch the kubeconfig",
+		run:   runK8sBootstrap,
+	})
+}
+
+// kubeconfigCommands returns the commands fetching the kubeconfig of the
+// cluster from the control-plane node once kubeadm is done
+func kubeconfigCommands(cp *Device) string {
+	ssh := "ssh"
+	scp := "scp"
+	if sshKey != "" {
+		ssh += " -i " + sshKey
+		scp += " -i " + sshKey
+	}
+	host := sshUser + "@" + cp.PublicIPv4()
+	return fmt.Sprintf("%s %s cloud-init status --wait\n%s %s:/etc/kubernetes/admin.conf kubeconfig\nkubectl --kubeconfig kubeconfig --server https://%s:6443 get nodes\n",
+		ssh, host, scp, host, cp.PublicIPv4())
+}
+
+func runK8sBootstrap(ctx context.Context, args []string) error {
+	fs := newFlagSet("k8s bootstrap")
+	name := fs.String("name", "k8s", "Name of the cluster, the nodes are named <name>-cp-1... and <name>-worker-1... and tagged k8s:<name>")
+	controlPlanes := fs.Int("control-planes", 1, "Number of control-plane nodes")
+	workers := fs.Int("workers", 2, "Number of worker nodes")
+	spec := DeviceSpec{BillingCycle: BillingHourly}
+	fs.StringVar(&spec.Plan, "plan", "", "Server plan of the nodes")
+	workerPlan := fs.String("worker-plan", "", "Server plan of the workers (default --plan)")
+	fs.StringVar(&spec.OS, "os", "ubuntu_22_04", "Server OS slug of the nodes, Ubuntu or Debian")
+	fs.StringVar(&spec.Facility, "facility", "", "Facility code where to deploy the nodes")
+	fs.StringVar(&spec.Metro, "metro", "", "Metro code where to deploy the nodes instead of a facility (Equinix Metal API)")
+	version := fs.String("k8s-version", scenarios.DefaultKubernetesVersion, "Minor Kubernetes version to install")
+	fs.DurationVar(&provisionTimeout, "provision-timeout", DefaultProvisionTimeout, "How long to wait for the nodes to become active, 0 for no limit")
+	addSSHFlags(fs)
+	addCostFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if *controlPlanes < 1 || *workers < 0 {
+		return usageErrorf("--control-planes must be at least 1 and --workers at least 0")
+	}
+	if spec.Plan == "" || (spec.Facility == "" && spec.Metro == "") {
+		return usageErrorf("--plan and --facility or --metro must be set by flags or the profile")
+	}
+	if spec.Facility != "" && spec.Metro != "" {
+		return usageErrorf("--facility and --metro cannot be combined")
+	}
+	if !strings.HasPrefix(spec.OS, "ubuntu_") && !strings.HasPrefix(spec.OS, "debian_") {
+		return usageErrorf("--os %s is not supported, the nodes install Kubernetes with apt on Ubuntu or Debian", spec.OS)
+	}
+	if !scenarios.ValidKubernetesVersion(*version) {
+		return usageErrorf("--k8s-version %s is not a minor version such as %s", *version, scenarios.DefaultKubernetesVersion)
+	}
+	spec.Hostname = *name
+	spec.Tags = []string{"k8s:" + *name}
+	cpReq, err := spec.request(projectID)
+	if err != nil {
+		return err
+	}
+	workerReq := *cpReq
+	if *workerPlan != "" {
+		workerReq.Plan = *workerPlan
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	var reqs []*DeviceRequest
+	for i := 0; i < *controlPlanes; i++ {
+		reqs = append(reqs, cpReq)
+	}
+	for i := 0; i < *workers; i++ {
+		reqs = append(reqs, &workerReq)
+	}
+	if err := checkHourlyCost(ctx, client, billedPlans(reqs...)); err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Printf("Would create %d control-plane x %s and %d worker x %s (%s) in %s, installing Kubernetes %s\n",
+			*controlPlanes, cpReq.Plan, *workers, workerReq.Plan, spec.OS, location(spec.Metro, spec.Facility), *version)
+		return nil
+	}
+
+	var undo undoStack
+	logger.Info("Bootstrapping Kubernetes", "cluster", *name, "control_planes", *controlPlanes, "workers", *workers, "version", *version)
+	cluster, err := scenarios.BootstrapKubernetes(ctx, cliDevices(client), scenarios.KubernetesOptions{
+		Name: *name, ControlPlane: cpReq, Worker: &workerReq, ControlPlanes: *controlPlanes, Workers: *workers,
+		Version: *version, Timeout: provisionTimeout, KeepPartial: true,
+	})
+	if cluster != nil {
+		for _, d := range append(cluster.ControlPlanes, cluster.Workers...) {
+			pushDeleteDevice(&undo, client, d)
+		}
+	}
+	if err != nil {
+		if cluster != nil {
+			for hostname, nodeErr := range cluster.Failed {
+				logger.Error("Node failed", "hostname", hostname, "error", nodeErr)
+			}
+		}
+		logger.Error("Bootstrapping Kubernetes failed", "cluster", *name, "error", err)
+		undo.rollback(ctx, err)
+		return err
+	}
+
+	nodes := append(append([]*Device{}, cluster.ControlPlanes...), cluster.Workers...)
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		unreachable []string
+	)
+	for _, d := range nodes {
+		d := d
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := waitForSSH(ctx, d, sshTimeout); err != nil {
+				logger.Error("Node does not accept SSH connections", "hostname", d.Hostname, "error", err)
+				mu.Lock()
+				unreachable = append(unreachable, d.Hostname)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if jsonOutput() {
+		prettyPrint(cluster)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tHOSTNAME\tROLE\tPUBLIC IPV4\tPRIVATE IPV4")
+		for _, d := range cluster.ControlPlanes {
+			fmt.Fprintf(w, "%s\t%s\tcontrol-plane\t%s\t%s\n", d.ID, d.Hostname, d.PublicIPv4(), d.PrivateIPv4())
+		}
+		for _, d := range cluster.Workers {
+			fmt.Fprintf(w, "%s\t%s\tworker\t%s\t%s\n", d.ID, d.Hostname, d.PublicIPv4(), d.PrivateIPv4())
+		}
+		w.Flush()
+		fmt.Printf("\nkubeadm installs Kubernetes %s while the nodes boot, which takes a few minutes. Then fetch the kubeconfig with:\n\n", *version)
+		fmt.Print(kubeconfigCommands(cluster.ControlPlanes[0]))
+	}
+	if len(unreachable) > 0 {
+		return &statusError{exitPartial, fmt.Errorf("%d of %d nodes do not accept SSH connections after %s", len(unreachable), len(nodes), sshTimeout)}
+	}
+	return nil
+}
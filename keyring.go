@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// service name the token is stored under in the OS keyring
+const keyringService = "packet-go-demo"
+
+var errKeyringNotFound = errors.New("no token stored in the keyring")
+
+// Windows Credential Manager is reached through the PasswordVault API,
+// which is scriptable from the PowerShell available on every installation
+const psVault = `[void][Windows.Security.Credentials.PasswordVault,Windows.Security.Credentials,ContentType=WindowsRuntime];` +
+	`$v = New-Object Windows.Security.Credentials.PasswordVault;`
+
+func init() {
+	registerCommand(&command{
+		name:  "auth login",
+		usage: "Store the API token in the OS keyring",
+		run:   runAuthLogin,
+	})
+	registerCommand(&command{
+		name:  "auth logout",
+		usage: "Remove the API token from the OS keyring",
+		run:   runAuthLogout,
+	})
+}
+
+// keyringAccount is the keyring entry of the selected profile, so every
+// profile can keep its own token
+func keyringAccount() string {
+	if profileName != "" {
+		return profileName
+	}
+	return "default"
+}
+
+func runAuthLogin(ctx context.Context, args []string) error {
+	fs := newFlagSet("auth login")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	secret := token
+	if !isFlagPassed(fs, "token") {
+		var err error
+		if secret, err = readSecret("Packet API token: "); err != nil {
+			return err
+		}
+	}
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return usageErrorf("no token given")
+	}
+
+	user := new(struct {
+		Email string `json:"email"`
+	})
+	if err := NewClient(secret, apiURL).DoRequest(ctx, "user", "GET", nil, user, nil); err != nil {
+		return fmt.Errorf("token was not accepted: %w", err)
+	}
+
+	if err := keyringSet(keyringAccount(), secret); err != nil {
+		return err
+	}
+	logger.Info("Logged in as "+user.Email+", token stored in the keyring", "profile", keyringAccount())
+	return nil
+}
+
+func runAuthLogout(ctx context.Context, args []string) error {
+	fs := newFlagSet("auth logout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if err := keyringDelete(keyringAccount()); err != nil {
+		return err
+	}
+	logger.Info("Token removed from the keyring", "profile", keyringAccount())
+	return nil
+}
+
+// readSecret prompts for a value, hiding the input on terminals
+func readSecret(prompt string) (string, error) {
+	fmt.Print(prompt)
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 && runtime.GOOS != "windows" {
+		if stty("-echo") == nil {
+			defer func() {
+				stty("echo")
+				fmt.Println()
+			}()
+		}
+	}
+	line, err := promptReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func stty(args ...string) error {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// keyringGet returns the stored token of the account
+func keyringGet(account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+	case "windows":
+		cmd = powershell(`$c = $v.Retrieve($args[0], $args[1]); $c.RetrievePassword(); $c.Password`, keyringService, account)
+	default:
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", account)
+	}
+
+	out, err := cmd.Output()
+	secret := strings.TrimSpace(string(out))
+	if err != nil || secret == "" {
+		return "", errKeyringNotFound
+	}
+	return secret, nil
+}
+
+// keyringSet stores the token of the account, replacing a previous one
+func keyringSet(account, secret string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// security reads the command from stdin with -i, the secret stays
+		// out of the argv that other users can see in ps
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			securityQuote(keyringService), securityQuote(account), securityQuote(secret)))
+		// the interactive mode exits 0 when the command failed
+		if err := runKeyringTool(cmd); err != nil {
+			return err
+		}
+		if stored, _ := keyringGet(account); stored != secret {
+			return fmt.Errorf("keyring: security: the token was not stored")
+		}
+		return nil
+	case "windows":
+		cmd = powershell(`$v.Add((New-Object Windows.Security.Credentials.PasswordCredential($args[0], $args[1], [Console]::In.ReadLine())))`,
+			keyringService, account)
+		cmd.Stdin = strings.NewReader(secret + "\n")
+	default:
+		cmd = exec.Command("secret-tool", "store", "--label", keyringService+" ("+account+")", "service", keyringService, "account", account)
+		cmd.Stdin = strings.NewReader(secret)
+	}
+	return runKeyringTool(cmd)
+}
+
+// keyringDelete removes the token of the account
+func keyringDelete(account string) error {
+	if _, err := keyringGet(account); err != nil {
+		return err
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", account)
+	case "windows":
+		cmd = powershell(`$v.Remove($v.Retrieve($args[0], $args[1]))`, keyringService, account)
+	default:
+		cmd = exec.Command("secret-tool", "clear", "service", keyringService, "account", account)
+	}
+	return runKeyringTool(cmd)
+}
+
+// securityQuote quotes an argument of a security -i command line
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// powershell runs a vault script block. Arguments after -Command are not
+// passed to a plain script, so they are quoted into the block invocation.
+func powershell(script string, args ...string) *exec.Cmd {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.Replace(arg, "'", "''", -1) + "'"
+	}
+	return exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command",
+		"& {"+psVault+script+"} "+strings.Join(quoted, " "))
+}
+
+func runKeyringTool(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("keyring: %s: %s", cmd.Args[0], msg)
+		}
+		return fmt.Errorf("keyring: %s: %s", cmd.Args[0], err)
+	}
+	return nil
+}
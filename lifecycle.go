@@ -0,0 +1,18 @@
+package main
+
+// lifecycleObserver acts on a device lifecycle event, e.g. device.active,
+// with its fields as key and value pairs. Observers pick the events they
+// handle and log their failures, an observer never fails the run.
+type lifecycleObserver func(event string, fields ...interface{})
+
+// lifecycleObservers are called in order with every device lifecycle event
+var lifecycleObservers = []lifecycleObserver{updateDNS, runHook, notify}
+
+// lifecycle reports a device lifecycle event to the observers, then emits
+// it on the ndjson output
+func lifecycle(event string, fields ...interface{}) {
+	for _, observe := range lifecycleObservers {
+		observe(event, fields...)
+	}
+	emit(event, fields...)
+}
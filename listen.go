@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "listen",
+		usage: "Receive device webhooks and run a script or print them as JSON lines",
+		run:   runListen,
+	})
+}
+
+// maxWebhookSize limits the body of a webhook callback
+const maxWebhookSize = 1 << 20
+
+// WebhookEvent is a callback of the API about a change of a device
+type WebhookEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created string `json:"created_at"`
+	Device  struct {
+		ID       string `json:"id"`
+		Hostname string `json:"hostname"`
+		State    string `json:"state"`
+	} `json:"device"`
+}
+
+// webhookSignature returns the hex HMAC-SHA256 of the body with the secret
+// shared with the webhook, as sent in X-Packet-Signature
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookListener verifies the callbacks and queues them for dispatch in
+// the order they arrived
+type webhookListener struct {
+	secret string
+	types  map[string]bool
+	queue  chan webhookDelivery
+
+	mu sync.Mutex
+	// seen are the IDs of the latest events, deliveries retried by the API
+	// are dispatched once
+	seen  map[string]bool
+	order []string
+}
+
+type webhookDelivery struct {
+	event *WebhookEvent
+	body  []byte
+}
+
+func (l *webhookListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST webhooks here", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		http.Error(w, "reading the body failed", http.StatusBadRequest)
+		return
+	}
+	if l.secret != "" {
+		sig := strings.TrimPrefix(r.Header.Get("X-Packet-Signature"), "sha256=")
+		if !hmac.Equal([]byte(sig), []byte(webhookSignature(l.secret, body))) {
+			logger.Warn("Rejected a webhook with a wrong signature", "remote", r.RemoteAddr)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+	}
+	event := new(WebhookEvent)
+	if err := json.Unmarshal(body, event); err != nil || event.Type == "" {
+		http.Error(w, "not a webhook event", http.StatusBadRequest)
+		return
+	}
+	if len(l.types) > 0 && !l.types[event.Type] {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if event.ID != "" && l.duplicate(event.ID) {
+		logger.Debug("Ignoring a retried webhook", "id", event.ID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	select {
+	case l.queue <- webhookDelivery{event, body}:
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		// the API retries deliveries that were not accepted, the retry
+		// must not be taken for a duplicate
+		l.mu.Lock()
+		delete(l.seen, event.ID)
+		l.mu.Unlock()
+		http.Error(w, "too many webhooks waiting", http.StatusServiceUnavailable)
+	}
+}
+
+// duplicate records the event ID and reports whether it was seen before
+func (l *webhookListener) duplicate(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.seen[id] {
+		return true
+	}
+	l.seen[id] = true
+	l.order = append(l.order, id)
+	if len(l.order) > 1000 {
+		delete(l.seen, l.order[0])
+		l.order = l.order[1:]
+	}
+	return false
+}
+
+// dispatch prints the queued events as JSON lines, or runs the script with
+// each of them, until the queue is closed
+func (l *webhookListener) dispatch(ctx context.Context, script string) {
+	for d := range l.queue {
+		if script == "" {
+			var line bytes.Buffer
+			if json.Compact(&line, d.body) == nil {
+				line.WriteByte('\n')
+				emitMu.Lock()
+				os.Stdout.Write(line.Bytes())
+				emitMu.Unlock()
+			}
+			continue
+		}
+		if err := runWebhookScript(ctx, script, d); err != nil {
+			logger.Error("Webhook script failed", "type", d.event.Type, "device", d.event.Device.ID, "error", err)
+		}
+	}
+}
+
+// runWebhookScript runs the shell command with the event on its standard
+// input and its main fields in PACKET_ environment variables
+func runWebhookScript(ctx context.Context, script string, d webhookDelivery) error {
+	cmd := shellCommand(ctx, script)
+	cmd.Env = append(os.Environ(),
+		"PACKET_EVENT_ID="+d.event.ID,
+		"PACKET_EVENT_TYPE="+d.event.Type,
+		"PACKET_DEVICE_ID="+d.event.Device.ID,
+		"PACKET_DEVICE_HOSTNAME="+d.event.Device.Hostname,
+		"PACKET_DEVICE_STATE="+d.event.Device.State,
+	)
+	cmd.Stdin = bytes.NewReader(d.body)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+func runListen(ctx context.Context, args []string) error {
+	fs := newFlagSet("listen")
+	listen := fs.String("listen", ":8080", "Address to receive webhooks on")
+	path := fs.String("path", "/webhook", "Path the webhooks are posted to")
+	secret := fs.String("secret", os.Getenv("PACKET_WEBHOOK_SECRET"), "Secret of the webhook, callbacks without its signature are rejected")
+	noVerify := fs.Bool("no-verify", false, "Accept callbacks without checking their signature, for testing")
+	types := fs.String("events", "", "Comma separated event types to dispatch, e.g. device.provisioned,device.failed (default all)")
+	script := fs.String("exec", "", "Shell command run for every event, with the event on standard input (default print the events as JSON lines)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if *secret == "" && !*noVerify {
+		return usageErrorf("--secret or PACKET_WEBHOOK_SECRET is needed to verify the webhooks, or --no-verify to accept any")
+	}
+	if !strings.HasPrefix(*path, "/") {
+		return usageErrorf("--path must start with /")
+	}
+
+	l := &webhookListener{types: map[string]bool{}, queue: make(chan webhookDelivery, 100), seen: map[string]bool{}}
+	if !*noVerify {
+		l.secret = *secret
+	}
+	if *types != "" {
+		for _, t := range strings.Split(*types, ",") {
+			l.types[strings.TrimSpace(t)] = true
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		// scripts of accepted events finish when interrupted
+		l.dispatch(context.WithoutCancel(ctx), *script)
+		close(done)
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle(*path, l)
+	srv := &http.Server{Addr: *listen, Handler: mux}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	logger.Info(fmt.Sprintf("Receiving webhooks on %s%s", *listen, *path), "verify", l.secret != "")
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := cleanupContext(ctx)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	// events already accepted are still dispatched
+	close(l.queue)
+	<-done
+	logger.Info("Webhook listener stopped")
+	return err
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookListener(t *testing.T) {
+	const secret = "shared-secret"
+	body := `{"id":"event-1","type":"device.active","device":{"id":"d1","hostname":"web"}}`
+	tests := []struct {
+		name      string
+		secret    string
+		types     string
+		method    string
+		body      string
+		signature string
+		want      int
+		queued    bool
+	}{
+		{"valid signature", secret, "", "POST", body, webhookSignature(secret, []byte(body)), http.StatusAccepted, true},
+		{"signature with algorithm", secret, "", "POST", body, "sha256=" + webhookSignature(secret, []byte(body)), http.StatusAccepted, true},
+		{"missing signature", secret, "", "POST", body, "", http.StatusUnauthorized, false},
+		{"other secret", secret, "", "POST", body, webhookSignature("other", []byte(body)), http.StatusUnauthorized, false},
+		{"body changed", secret, "", "POST", strings.Replace(body, "web", "db", 1), webhookSignature(secret, []byte(body)), http.StatusUnauthorized, false},
+		{"not verified without a secret", "", "", "POST", body, "", http.StatusAccepted, true},
+		{"not an event", "", "", "POST", `{"id":"x"}`, "", http.StatusBadRequest, false},
+		{"type not listened to", "", "device.deleted", "POST", body, "", http.StatusNoContent, false},
+		{"type listened to", "", "device.active", "POST", body, "", http.StatusAccepted, true},
+		{"not a POST", "", "", "GET", "", "", http.StatusMethodNotAllowed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &webhookListener{secret: tt.secret, types: map[string]bool{}, queue: make(chan webhookDelivery, 1), seen: map[string]bool{}}
+			if tt.types != "" {
+				l.types[tt.types] = true
+			}
+			r := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))
+			if tt.signature != "" {
+				r.Header.Set("X-Packet-Signature", tt.signature)
+			}
+			w := httptest.NewRecorder()
+			l.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if queued := len(l.queue) == 1; queued != tt.queued {
+				t.Errorf("queued = %v, want %v", queued, tt.queued)
+			}
+		})
+	}
+}
+
+func TestWebhookListenerRetries(t *testing.T) {
+	l := &webhookListener{types: map[string]bool{}, queue: make(chan webhookDelivery, 1), seen: map[string]bool{}}
+	send := func(id string) int {
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"id":"`+id+`","type":"device.active"}`)))
+		return w.Code
+	}
+	steps := []struct {
+		id   string
+		want int
+	}{
+		{"event-1", http.StatusAccepted},
+		// a retried delivery is dispatched once
+		{"event-1", http.StatusNoContent},
+		// the queue is full, the API retries later
+		{"event-2", http.StatusServiceUnavailable},
+	}
+	for _, s := range steps {
+		if got := send(s.id); got != s.want {
+			t.Fatalf("delivery of %s: status %d, want %d", s.id, got, s.want)
+		}
+	}
+	<-l.queue
+	// the rejected delivery is not taken for a duplicate when retried
+	if got := send("event-2"); got != http.StatusAccepted {
+		t.Errorf("retried delivery of event-2: status %d, want %d", got, http.StatusAccepted)
+	}
+}
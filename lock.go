@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "device lock",
+		usage: "Lock devices, protecting them from deletion",
+		run:   runDeviceLock,
+	})
+	registerCommand(&command{
+		name:  "device unlock",
+		usage: "Unlock devices so that they can be deleted",
+		run:   runDeviceUnlock,
+	})
+	registerCommand(&command{
+		name:  "device delete",
+		usage: "Delete devices, refusing locked ones unless --force-unlock is given",
+		run:   runDeviceDelete,
+	})
+}
+
+// setDeviceLocked locks or unlocks the device
+func setDeviceLocked(ctx context.Context, deviceID string, locked bool, c *Client) error {
+	_, err := updateDevice(ctx, deviceID, &DeviceUpdateRequest{Locked: &locked}, c)
+	return err
+}
+
+func runDeviceLock(ctx context.Context, args []string) error {
+	return lockDevices(ctx, "device lock", args, true)
+}
+
+func runDeviceUnlock(ctx context.Context, args []string) error {
+	return lockDevices(ctx, "device unlock", args, false)
+}
+
+func lockDevices(ctx context.Context, name string, args []string, locked bool) error {
+	fs := newFlagSet(name)
+	fs.Usage = func() {
+		fmt.Printf("Usage: packet-go-demo %s [flags] <device-id>...\n", name)
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	var errs []error
+	for _, id := range fs.Args() {
+		if err := setDeviceLocked(ctx, id, locked, client); err != nil {
+			errs = append(errs, fmt.Errorf("device %s: %w", id, err))
+			continue
+		}
+		if locked {
+			logger.Info("Locked device", "device", id)
+		} else {
+			logger.Info("Unlocked device", "device", id)
+		}
+	}
+	return devicesFailed(errs, fs.NArg())
+}
+
+func runDeviceDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("device delete")
+	forceUnlock := fs.Bool("force-unlock", false, "Unlock locked devices and delete them")
+	yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo device delete [flags] <device-id>...")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	var errs []error
+	var devices []Device
+	for _, id := range fs.Args() {
+		dev, err := getDevice(ctx, id, client)
+		if err == nil && dev.Locked && !*forceUnlock {
+			err = fmt.Errorf("%s is locked, unlock it with device unlock or pass --force-unlock", dev.Hostname)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("device %s: %w", id, err))
+			continue
+		}
+		devices = append(devices, *dev)
+	}
+
+	if len(devices) > 0 && !*yes && !dryRun {
+		printDeviceSummary(devices, time.Now())
+		ok, err := confirm(fmt.Sprintf("Delete %d device(s)?", len(devices)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			logger.Info("Nothing deleted")
+			return devicesFailed(errs, fs.NArg())
+		}
+	}
+	for i := range devices {
+		if err := deleteDevice(ctx, client, &devices[i], *forceUnlock); err != nil {
+			errs = append(errs, fmt.Errorf("device %s: %w", devices[i].ID, err))
+		}
+	}
+	return devicesFailed(errs, fs.NArg())
+}
+
+// deleteDevice deletes the device, unlocking it first when it is locked
+// and forceUnlock is set
+func deleteDevice(ctx context.Context, client *Client, dev *Device, forceUnlock bool) error {
+	if dev.Locked {
+		if !forceUnlock {
+			return fmt.Errorf("%s is locked, unlock it with device unlock or pass --force-unlock", dev.Hostname)
+		}
+		if err := setDeviceLocked(ctx, dev.ID, false, client); err != nil {
+			return err
+		}
+		logger.Info("Unlocked device", "device", dev.ID, "hostname", dev.Hostname)
+	}
+	res, err := DeleteDevice(ctx, client, dev.ID)
+	if err != nil {
+		return err
+	}
+	logger.Info("Device successfully deleted", "device", dev.ID, "hostname", dev.Hostname, "duration", res.Duration)
+	return nil
+}
+
+// devicesFailed reports the errors of a command acting on several devices.
+// A single failing device ends the run with the status of its error, more
+// than one with the partial failure status.
+func devicesFailed(errs []error, total int) error {
+	failures := 0
+	for _, err := range errs {
+		logRunError(err)
+		if !errors.Is(err, ErrDryRun) {
+			failures++
+		}
+	}
+	switch {
+	case failures == 0:
+		return nil
+	case total == 1:
+		return exitCode(exitCodeOf(errs[0]))
+	}
+	return &statusError{exitPartial, fmt.Errorf("%d of %d devices failed", failures, total)}
+}
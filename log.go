@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	logFormat string
+	logLevel  string
+)
+
+// logger reports the progress of the tool on stderr. Results such as
+// devices, reports and generated files are printed to stdout,
+// independently of the logger, so that they can be piped.
+var logger = slog.New(newHumanHandler(slog.LevelInfo))
+
+// setupLogger configures the logger from --log-format and --log-level
+func setupLogger() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return usageErrorf("unknown log level %q, use debug, info, warn or error", logLevel)
+	}
+	// HTTP traces are logged at debug level
+	if debugHTTP && level > slog.LevelDebug {
+		level = slog.LevelDebug
+	}
+
+	switch logFormat {
+	case "text":
+		logger = slog.New(newHumanHandler(level))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	default:
+		return usageErrorf("unknown log format %q, use text or json", logFormat)
+	}
+	return nil
+}
+
+// slogLogger passes the HTTP trace of a client to the logger
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Printf(format string, v ...interface{}) {
+	s.l.Debug(fmt.Sprintf(format, v...))
+}
+
+// humanHandler is the default handler for interactive use. It prints the
+// message followed by its attributes as key=value, without time and level.
+// All records go to stderr, HTTP traces at debug level included.
+type humanHandler struct {
+	level slog.Leveler
+	mu    *sync.Mutex
+	out   io.Writer
+	attrs string // preformatted attributes of WithAttrs
+	group string // key prefix of WithGroup
+}
+
+func newHumanHandler(level slog.Leveler) *humanHandler {
+	return &humanHandler{level: level, mu: new(sync.Mutex), out: os.Stderr}
+}
+
+func (h *humanHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *humanHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if r.Level == slog.LevelWarn {
+		b.WriteString("Warning: ")
+	}
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		h.writeAttr(&b, h.group, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	var err error
+	progressUI.suspend(func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		_, err = io.WriteString(h.out, b.String())
+	})
+	return err
+}
+
+func (h *humanHandler) writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			h.writeAttr(b, prefix, ga)
+		}
+		return
+	}
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \"=\n") {
+		value = fmt.Sprintf("%q", value)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, a.Key, value)
+}
+
+func (h *humanHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	var b strings.Builder
+	for _, a := range attrs {
+		h.writeAttr(&b, h.group, a)
+	}
+	h2.attrs += b.String()
+	return &h2
+}
+
+func (h *humanHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.group += name + "."
+	return &h2
+}
@@ -1,238 +1,737 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"math/rand"
-	"net/http"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
 	"time"
 )
 
-const (
-	baseURL     = "https://api.packet.net/"
-	letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
-)
+// exit status of a run stopped by Ctrl-C, as shells report it
+const interruptedExitCode = 130
+
+const defaultAPIURL = "https://api.packet.net/"
 
 var (
-	token        *string
-	projectID    *string
-	hostname     *string
-	facility     *string
-	plan         *string
-	ops          *string
-	billingCycle *string
+	apiURL           string
+	token            string
+	tokenCommand     string
+	projectID        string
+	hostname         string
+	facility         string
+	metro            string
+	plan             string
+	ops              string
+	billingCycle     BillingCycle
+	maxResponseMB    int64
+	correlationID    string
+	runScript        string
+	outputFormat     string
+	dryRun           bool
+	otp              string
+	debugHTTP        bool
+	requestTimeout   time.Duration
+	provisionTimeout time.Duration
+	cleanupOnTimeout bool
+	failOnDeprecated bool
+	reserveIP        string
+	hwReservation    string
 )
 
-// Client is HTTP client
-type Client struct {
-	baseURL string
-	token   string
-	client  *http.Client
+// command is a subcommand of the tool, e.g. "apply" or "device list"
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
 }
 
-// NewClient creates a Client instance
-func NewClient(token, apiURL string) *Client {
-	return &Client{
-		token:   token,
-		baseURL: apiURL,
-		client:  &http.Client{},
-	}
+var commands = map[string]*command{}
+
+func registerCommand(c *command) {
+	commands[c.name] = c
+}
+
+func init() {
+	registerCommand(&command{
+		name:  "help",
+		usage: "List available commands",
+		run: func(ctx context.Context, args []string) error {
+			printUsage()
+			return nil
+		},
+	})
 }
 
-// DoRequest performs HTTP request
-func (c *Client) DoRequest(url string, method string, request interface{}, response interface{}, raw *string) error {
-	var payload io.Reader
+// exitCode is returned by a command that already reported its outcome
+// and only needs the process to exit with the given status
+type exitCode int
 
-	if request != nil {
-		data, err := json.Marshal(request)
-		if err != nil {
-			return err
+func (e exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// exitProcess is the single way out of a run once it started. It prints
+// the --stats summary and exports the trace before exiting with code.
+func exitProcess(code int) {
+	if showStats {
+		runStats.write(os.Stderr)
+	}
+	emit("run.finished", "exit_code", code)
+	finishTracing(code)
+	os.Exit(code)
+}
+
+func main() {
+	args := os.Args[1:]
+	defer recoverCrash(args)
+
+	// Ctrl-C and SIGTERM cancel every API call and wait in flight
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cmd, rest := lookupCommand(args)
+	if cmd == nil && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		// commands that are not built in may be plugins
+		if path, rest := lookupPlugin(args); path != "" {
+			os.Exit(runPlugin(path, rest))
 		}
-		payload = bytes.NewBuffer(data)
+		fmt.Printf("Unknown command %q\n\n", args[0])
+		printUsage()
+		os.Exit(1)
 	}
 
-	r, err := http.NewRequest(method, c.baseURL+url, payload)
-	if err != nil {
-		return err
+	// the run is the root span of the API calls when tracing is enabled
+	setupTracing()
+	name := "demo"
+	if cmd != nil {
+		name = cmd.name
 	}
+	ctx, traceRoot = startSpan(ctx, name, spanKindInternal)
+	// resources created from here on are recorded for cleanup --run
+	openRunState()
 
-	r.Header.Add("X-Auth-Token", c.token)
-	r.Header.Add("Content-Type", "application/json")
+	if cmd == nil {
+		runDemo(ctx, args)
+		exitProcess(0)
+	}
 
-	resp, err := c.client.Do(r)
-	if err != nil {
-		return err
+	if err := cmd.run(ctx, rest); err != nil {
+		if _, reported := err.(exitCode); !reported {
+			logRunError(err)
+		}
+		exitProcess(exitCodeOf(err))
 	}
+	exitProcess(0)
+}
 
-	if resp != nil {
-		var body []byte
-		defer resp.Body.Close()
-		body, err = ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return err
+// logRunError reports the error a run ended with. Dry runs end early on
+// purpose, which is not an error.
+func logRunError(err error) {
+	if errors.Is(err, ErrDryRun) {
+		logger.Info(err.Error())
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		logger.Error("Interrupted")
+		return
+	}
+	var apiError *ErrorResponse
+	if errors.As(err, &apiError) && apiError.OTPRequired() && otp == "" {
+		logger.Error(err.Error(), "hint", "pass the current code of your two-factor device with --otp")
+		return
+	}
+	logger.Error(err.Error())
+}
+
+// lookupCommand finds the longest command name made of the leading
+// non-flag arguments, so "device list" is preferred over "device"
+func lookupCommand(args []string) (*command, []string) {
+	n := 0
+	for n < len(args) && n < 3 && !strings.HasPrefix(args[n], "-") {
+		n++
+	}
+	for ; n > 0; n-- {
+		if c, ok := commands[strings.Join(args[:n], " ")]; ok {
+			return c, args[n:]
 		}
+	}
+	return nil, args
+}
 
-		if raw != nil {
-			*raw = string(body)
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("Usage: packet-go-demo [flags]            deploy and terminate a demo device")
+	fmt.Println("       packet-go-demo <command> [flags]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, name := range names {
+		fmt.Printf("  %-20s %s\n", name, commands[name].usage)
+	}
+	if plugins := listPlugins(); len(plugins) > 0 {
+		fmt.Println()
+		fmt.Println("Plugins:")
+		for _, name := range plugins {
+			fmt.Printf("  %-20s %s\n", name, "runs "+pluginPrefix+strings.Replace(name, " ", "-", -1))
 		}
+	}
+}
 
-		if method != "DELETE" {
-			err = json.Unmarshal(body, response)
+// envErrors are the environment variables of flags that hold invalid
+// values, by flag name
+var envErrors map[string]error
+
+// newFlagSet creates the flag set of a command, including the API,
+// credential and output flags every command accepts.
+//
+// Settings are resolved in one order for all commands: command line flag,
+// environment variable, configuration file profile, built-in default. The
+// token additionally falls back to the OS keyring.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	envErrors = map[string]error{}
+	fs.StringVar(&apiURL, "api-url", envOrDefault("PACKET_API_URL", defaultAPIURL), "Packet API base URL")
+	fs.StringVar(&token, "token", envOrDefault("PACKET_AUTH_TOKEN", os.Getenv("METAL_AUTH_TOKEN")), "Packet API key token")
+	fs.StringVar(&tokenCommand, "token-command", os.Getenv("PACKET_TOKEN_COMMAND"), "Command printing short-lived API tokens, used instead of --token")
+	fs.StringVar(&projectID, "prid", envOrDefault("PACKET_PROJECT_ID", os.Getenv("METAL_PROJECT_ID")), "project ID")
+	fs.StringVar(&profileName, "profile", os.Getenv("PACKET_PROFILE"), "Configuration file profile to use")
+	fs.StringVar(&outputFormat, "output", "text", "Output format: text, json, or ndjson for a JSON object per line and lifecycle events")
+	fs.BoolVar(&dryRun, "dry-run", false, "Print requests that would change resources instead of sending them")
+	fs.StringVar(&otp, "otp", os.Getenv("PACKET_OTP"), "Current code of your two-factor device, required by organizations enforcing two-factor authentication to delete resources")
+	fs.BoolVar(&debugHTTP, "debug", os.Getenv("PACKET_DEBUG") != "", "Log every API request and response to stderr, with the token redacted")
+	fs.DurationVar(&requestTimeout, "request-timeout", DefaultTimeout, "How long a single API request may take, 0 for no limit")
+	fs.BoolVar(&failOnDeprecated, "fail-on-deprecated", os.Getenv("PACKET_FAIL_ON_DEPRECATED") != "", "Fail when the API announces that an endpoint in use is deprecated")
+	fs.StringVar(&logFormat, "log-format", envOrDefault("PACKET_LOG_FORMAT", "text"), "Log format: text for people, json for log collectors")
+	fs.StringVar(&logLevel, "log-level", envOrDefault("PACKET_LOG_LEVEL", "info"), "Log level: debug, info, warn or error")
+	fs.StringVar(&vcrRecordPath, "record", "", "Record the API responses to this cassette file, for replaying with PACKET_VCR=replay")
+	fs.BoolVar(&showStats, "stats", false, "Print a summary of the API calls, their latency and the rate limit left when done")
+	ttl := DefaultCatalogTTL
+	if d, err := time.ParseDuration(os.Getenv("PACKET_CATALOG_TTL")); err == nil {
+		ttl = d
+	}
+	fs.DurationVar(&catalogTTL, "catalog-ttl", ttl, "Serve plans, facilities, metros and operating systems from a local cache this long, 0 to disable")
+	fs.BoolVar(&noCache, "no-cache", false, "Bypass cached responses, fresh responses are still cached")
+	notifyURLs = nil
+	for _, target := range strings.Split(os.Getenv("PACKET_NOTIFY"), ",") {
+		if target != "" {
+			notifyURLs.Set(target)
+		}
+	}
+	fs.Var(&notifyURLs, "notify", "Send device active, failed and deleted events to slack://..., an https:// webhook or mailto:address, may be repeated")
+	fs.StringVar(&onActive, "on-active", os.Getenv("PACKET_ON_ACTIVE"), "Command run when a device becomes active, with DEVICE_ID, DEVICE_IP and DEVICE_HOSTNAME set")
+	fs.StringVar(&onFailure, "on-failure", os.Getenv("PACKET_ON_FAILURE"), "Command run when a device fails to become active")
+	fs.StringVar(&onDelete, "on-delete", os.Getenv("PACKET_ON_DELETE"), "Command run when a device is deleted")
+	dnsProvider = ""
+	if p := os.Getenv("PACKET_DNS_PROVIDER"); p != "" {
+		if err := dnsProvider.Set(p); err != nil {
+			envErrors["dns-provider"] = fmt.Errorf("PACKET_DNS_PROVIDER: %w", err)
 		}
 	}
+	fs.Var(&dnsProvider, "dns-provider", "Register active devices as hostname.zone in cloudflare or route53, and remove the records on delete")
+	fs.StringVar(&dnsZone, "dns-zone", os.Getenv("PACKET_DNS_ZONE"), "DNS zone devices are registered in, e.g. demo.example.com")
+	fs.IntVar(&dnsTTL, "dns-ttl", 300, "TTL of the DNS records of devices, in seconds")
+	rollbackPolicy = rollbackAuto
+	if mode := os.Getenv("PACKET_ROLLBACK"); mode != "" {
+		if err := rollbackPolicy.Set(mode); err != nil {
+			envErrors["rollback"] = fmt.Errorf("PACKET_ROLLBACK: %w", err)
+		}
+	}
+	fs.Var(&rollbackPolicy, "rollback", "What to do with what a failed multi-step operation created: auto deletes it, prompt asks first, never keeps it")
+	fs.BoolVar(&tokenFailover, "token-failover", os.Getenv("PACKET_TOKEN_FAILOVER") != "", "Send rate limited requests again with the next token of the profile that may be used for them")
+	fs.BoolVar(&noProgress, "no-progress", os.Getenv("PACKET_NO_PROGRESS") != "", "Do not show the progress of devices being provisioned on a terminal")
+	fs.StringVar(&correlationID, "correlation-id", os.Getenv("PACKET_CORRELATION_ID"), "Send this ID in the X-Correlation-ID header of every request, to find the run in logs")
+	fs.Int64Var(&maxResponseMB, "max-response-mb", DefaultMaxResponseSize>>20, "Fail API responses larger than this many megabytes, 0 for no limit")
+	return fs
+}
 
-	return err
+// printDryRun prints a request that a dry run does not send
+func printDryRun(method, url string, request interface{}) {
+	fmt.Printf("%s %s\n", method, url)
+	if request != nil {
+		prettyPrint(request)
+	}
 }
 
-// DeviceRequest is used to create a Packet device
-type DeviceRequest struct {
-	Hostname     string   `json:"hostname"`
-	Plan         string   `json:"plan"`
-	Facility     []string `json:"facility"`
-	OS           string   `json:"operating_system"`
-	BillingCycle string   `json:"billing_cycle"`
-	ProjectID    string   `json:"project_id"`
+// newCLIClient creates a client configured by the command line settings
+func newCLIClient() *Client {
+	opts := []ClientOption{WithTimeout(requestTimeout), WithMaxResponseSize(maxResponseMB << 20), WithCorrelationID(correlationID)}
+	client := NewClient(token, apiURL, opts...)
+	if tokenCommand != "" {
+		client = NewClientWithTokenSource(CommandTokenSource(tokenCommand), apiURL, opts...)
+	} else if len(profileTokens) > 0 {
+		client = NewClientWithTokenSource(newTokenPool(profileTokens, projectID, tokenFailover), apiURL, opts...)
+	}
+	client.SetDryRun(dryRun)
+	client.OnDryRun(printDryRun)
+	client.SetOTP(otp)
+	client.Use(runMiddleware(client))
+	if purgeCache {
+		for _, dir := range []string{cacheDir(), catalogCacheDir()} {
+			if err := os.RemoveAll(dir); err != nil {
+				logger.Warn("Purging the response cache failed", "error", err)
+			}
+		}
+	}
+	if cacheTTL > 0 {
+		client.Use(CacheMiddleware(cacheDir(), cacheTTL, noCache))
+	}
+	if catalogTTL > 0 {
+		client.Use(CatalogCacheMiddleware(catalogCacheDir(), catalogTTL, noCache))
+	}
+	if chaos != nil {
+		client.Use(ChaosMiddleware(chaos, apiURL))
+	}
+	if cassette != nil {
+		client.Use(cassette.Middleware())
+	}
+	client.Use(ETagMiddleware())
+	client.Use(callMiddleware(client))
+	client.SetFailOnDeprecated(failOnDeprecated)
+	if !failOnDeprecated {
+		client.OnDeprecated(func(d *Deprecation) {
+			logger.Warn(d.String())
+		})
+	}
+	if debugHTTP {
+		client.SetLogger(slogLogger{logger})
+	}
+	return client
 }
 
-// Device represents a Packet device API instance
-type Device struct {
-	ID           string                 `json:"id"`
-	Hostname     string                 `json:"hostname,omitempty"`
-	State        string                 `json:"state,omitempty"`
-	Created      string                 `json:"created_at,omitempty"`
-	Updated      string                 `json:"updated_at,omitempty"`
-	Locked       bool                   `json:"locked,omitempty"`
-	BillingCycle string                 `json:"billing_cycle,omitempty"`
-	Storage      map[string]interface{} `json:"storage,omitempty"`
-	Tags         []string               `json:"tags,omitempty"`
-	Network      interface{}            `json:"ip_addresses"`
-	Volumes      interface{}            `json:"volumes"`
-	OS           interface{}            `json:"operating_system,omitempty"`
-	Plan         interface{}            `json:"plan,omitempty"`
-	Facility     interface{}            `json:"facility,omitempty"`
-	Project      interface{}            `json:"project,omitempty"`
+// parseFlags parses the command line and fills the flags that were not
+// passed from the selected configuration profile. A token that is still
+// missing is looked up in the OS keyring.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	// the flag package already printed the error and usage
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return exitCode(0)
+	} else if err != nil {
+		return exitCode(exitUsage)
+	}
+	recordPassedFlags(fs)
+	// an invalid environment variable is an error unless its flag
+	// overrides it
+	fs.Visit(func(f *flag.Flag) { delete(envErrors, f.Name) })
+	for _, err := range envErrors {
+		return &statusError{exitUsage, err}
+	}
+	if err := applyProfile(fs); err != nil {
+		return err
+	}
+	if token == "" && tokenCommand == "" && len(profileTokens) == 0 {
+		token, _ = keyringGet(keyringAccount())
+	}
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	if outputFormat != "text" && !jsonOutput() {
+		return usageErrorf("unknown output format %q, use text, json or ndjson", outputFormat)
+	}
+	// after the profile, which may set the output format
+	if err := setupLogger(); err != nil {
+		return err
+	}
+	if err := setupChaos(); err != nil {
+		return err
+	}
+	return setupVCR()
 }
 
-func main() {
-	parseInputParams()
+// envOrDefault returns the environment variable, or def when it is unset
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
 
-	client := NewClient(*token, baseURL)
+// passedFlags are the flags of each flag set given on the command line,
+// recorded before profiles and templates set others
+var passedFlags = map[*flag.FlagSet]map[string]bool{}
 
-	device := createDevice(client)
+// recordPassedFlags records the flags given on the command line
+func recordPassedFlags(fs *flag.FlagSet) {
+	passed := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { passed[f.Name] = true })
+	passedFlags[fs] = passed
+}
 
-	if device != nil {
-		fmt.Println("Device is ready. Terminating in 10s...")
-		time.Sleep(10 * time.Second)
-		deleteDevice(device.ID, client)
+// isFlagPassed reports whether the flag was given on the command line. A
+// flag set from a profile or a template does not count.
+func isFlagPassed(fs *flag.FlagSet, name string) bool {
+	if passed, ok := passedFlags[fs]; ok {
+		return passed[name]
 	}
+	return isFlagSet(fs, name)
+}
+
+// isFlagSet reports whether the flag was set at all, on the command line,
+// by a template or by a profile
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
-func parseInputParams() {
-	rand.Seed(time.Now().UnixNano())
+func checkToken() error {
+	if strings.TrimSpace(token) == "" && tokenCommand == "" && len(profileTokens) == 0 {
+		return &statusError{exitAuth, errors.New("You must provide Packet API token. Set PACKET_AUTH_TOKEN env variable, provide --token flag or run auth login.")}
+	}
+	return nil
+}
 
-	// generate random name for the device, if not provided
-	b := make([]byte, 15)
-	for i := range b {
-		b[i] = letterBytes[rand.Int63()%int64(len(letterBytes))]
+func checkCredentials() error {
+	if err := checkToken(); err != nil {
+		return err
 	}
-	name := string(b)
 
-	token = flag.String("token", os.Getenv("PACKET_AUTH_TOKEN"), "Packet API key token")
-	projectID = flag.String("prid", os.Getenv("PACKET_PROJECT_ID"), "project ID")
+	if strings.TrimSpace(projectID) == "" {
+		return usageErrorf("You must provide project ID. Set PACKET_PROJECT_ID env variable or provide --prid flag.")
+	}
+	return nil
+}
 
-	hostname = flag.String("hostname", name, "Hostname of the server to be deployed")
-	facility = flag.String("facility", "ams1", "Datacenter facility code where to deploy device")
-	plan = flag.String("plan", "baremetal_0", "Server deployment plan")
-	ops = flag.String("os", "centos_7", "Server OS slug")
-	billingCycle = flag.String("bilcycle", "hourly", "Billing cycle")
+// runDemo deploys a device, waits until it is active and terminates it
+func runDemo(ctx context.Context, args []string) {
+	parseInputParams(args)
 
-	flag.Parse()
+	client := newCLIClient()
 
-	if strings.TrimSpace(*token) == "" {
-		fmt.Println("You must provide Packet API token. Set PACKET_AUTH_TOKEN env variable or provide --token flag.")
-		os.Exit(0)
+	if interactive {
+		ok, err := promptDemo(ctx, client)
+		if err != nil {
+			demoFailed(err)
+			return
+		}
+		if !ok {
+			logger.Info("Nothing provisioned")
+			return
+		}
 	}
 
-	if strings.TrimSpace(*projectID) == "" {
-		fmt.Println("You must provide project ID. Set PACKET_PROJECT_ID env variable or provide --prid flag.")
-		os.Exit(0)
+	if preferGreen {
+		code, reason, err := placeGreen(ctx, client, plan)
+		if err != nil {
+			demoFailed(err)
+			return
+		}
+		metro, facility = code, ""
+		logger.Info("Placing device in metro "+code, "metro", code, "reason", reason)
 	}
-}
 
-func createDevice(client *Client) *Device {
-	devReq := &DeviceRequest{
-		Hostname:     *hostname,
-		Facility:     []string{*facility},
-		Plan:         *plan,
-		OS:           *ops,
-		ProjectID:    *projectID,
-		BillingCycle: *billingCycle,
+	req := demoDeviceRequest()
+	if err := applyUserdataTemplate(req); err != nil {
+		demoFailed(err)
+		return
+	}
+	var err error
+	if req.Storage, err = loadStorageFile(); err != nil {
+		demoFailed(err)
+		return
+	}
+	if req.CustomData, err = loadCustomdata(); err != nil {
+		demoFailed(err)
+		return
+	}
+	if err := checkHourlyCost(ctx, client, billedPlans(req)); err != nil {
+		demoFailed(err)
+		return
+	}
+	if ttlMode == ttlAPI {
+		// counted from the request, provisioning takes part of the TTL
+		req.TerminationTime = terminationTime(ttl)
 	}
 
-	uri := fmt.Sprintf("projects/%s/devices", *projectID)
+	// what was created is rolled back as --rollback says when a later
+	// step fails
+	undo := new(undoStack)
+	var reserved *IPReservation
+	if reserveIP != "" {
+		var err error
+		if reserved, err = reserveDemoIP(ctx, client); err != nil {
+			demoFailed(err)
+			return
+		}
+		undo.push(fmt.Sprintf("release IP reservation %s (%s/%d)", reserved.ID, reserved.Network, reserved.CIDR), func(ctx context.Context) error {
+			return releaseIPReservation(ctx, reserved.ID, client)
+		})
+	}
 
-	device := new(Device)
-	// raw response might be usefull for troubleshooting
-	rawResponse := new(string)
+	logger.Info("Provisioning device... please wait", "hostname", hostname)
+	created, err := CreateDevice(ctx, client, req, provisionTimeout)
+	if err != nil {
+		var timeoutErr *ProvisionTimeoutError
+		// a device that never became active is billed all the same
+		if created != nil && !(errors.As(err, &timeoutErr) && !cleanupOnTimeout) {
+			pushDeleteDevice(undo, client, created.Device)
+		}
+		demoRollback(ctx, undo, err)
+		return
+	}
+	printCreateResult(created)
+	pushDeleteDevice(undo, client, created.Device)
+
+	if reserved != nil {
+		block := fmt.Sprintf("%s/%d", reserved.Network, reserved.CIDR)
+		if _, err := assignIP(ctx, created.Device.ID, block, client); err != nil {
+			demoRollback(ctx, undo, fmt.Errorf("assigning %s to device %s: %w", block, created.Device.ID, err))
+			return
+		}
+		logger.Info("Assigned "+block+" to the device", "device", created.Device.ID)
+	}
 
-	err := client.DoRequest(uri, "POST", &devReq, device, rawResponse)
+	device := created.Device
+	exit := 0
+	if runScript != "" {
+		exit = runBootstrapScript(ctx, device)
+	}
 
+	if ttlMode == ttlAPI {
+		logger.Info("Device is ready. The API terminates it at "+req.TerminationTime.Format(time.RFC3339), "device", device.ID)
+		if reserved != nil {
+			logger.Warn("The reserved IP block outlives the device, release it with cleanup --run "+runState.run, "ip", reserved.ID)
+		}
+		exitProcess(exit)
+	}
+	logger.Info("Device is ready. Terminating in "+ttl.String()+"...", "device", device.ID)
+	// Ctrl-C skips the wait, the device is deleted right away
+	if sleep(ctx, ttl) != nil {
+		exit = interruptedExitCode
+	}
+	// the device is deleted also after Ctrl-C, with a deadline counted from
+	// now rather than from the start of the run
+	cleanupCtx, cancel := cleanupContext(ctx)
+	defer cancel()
+	deleted, err := DeleteDevice(cleanupCtx, client, device.ID)
 	if err != nil {
-		fmt.Println(err.Error())
-		return nil
+		logger.Error(err.Error(), "device", device.ID)
+		if exit == 0 {
+			exit = exitCodeOf(err)
+		}
+	} else {
+		if outputFormat == "json" {
+			prettyPrint(deleted)
+		}
+		logger.Info("Device successfully deleted", "device", deleted.DeviceID, "duration", deleted.Duration)
 	}
+	releaseDemoIP(cleanupCtx, client, reserved)
+	exitProcess(exit)
+}
 
-	fmt.Println("Provisioning device... please wait")
+// demoFailed reports an error that ended the demo early and exits with
+// its status
+func demoFailed(err error) {
+	logRunError(err)
+	exitProcess(exitCodeOf(err))
+}
+
+// demoRollback reports an error that ended the demo after it created
+// resources, rolls them back and exits with the status of the error
+func demoRollback(ctx context.Context, undo *undoStack, err error) {
+	logRunError(err)
+	undo.rollback(ctx, err)
+	exitProcess(exitCodeOf(err))
+}
 
-	device, err = waitUntilReady(device.ID, client)
+// pushDeleteDevice registers the deletion of a created device for a
+// rollback
+func pushDeleteDevice(undo *undoStack, client *Client, device *Device) {
+	undo.push("delete device "+device.ID+" ("+device.Hostname+")", func(ctx context.Context) error {
+		_, err := DeleteDevice(ctx, client, device.ID)
+		return err
+	})
+}
 
+// reserveDemoIP reserves the --reserve-ip block where the device is deployed
+func reserveDemoIP(ctx context.Context, client *Client) (*IPReservation, error) {
+	req, err := parseIPBlock(reserveIP)
 	if err != nil {
-		fmt.Println(err.Error())
-		return nil
+		return nil, err
+	}
+	if req.Type != "global_ipv4" {
+		if metro != "" {
+			req.Metro = metro
+		} else {
+			req.Facility = facility
+		}
 	}
+	req.Comments = "packet-go-demo device " + hostname
 
-	prettyPrint(device)
-	return device
+	ip, err := requestIPReservation(ctx, projectID, req, client)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("Reserved %s/%d", ip.Network, ip.CIDR), "id", ip.ID, "type", ip.Type())
+	return ip, nil
 }
 
-func deleteDevice(deviceID string, client *Client) {
-	uri := "devices/" + deviceID
-	err := client.DoRequest(uri, "DELETE", nil, nil, nil)
-
-	if err != nil {
-		fmt.Println(err.Error())
+// releaseDemoIP gives the block reserved for the demo back
+func releaseDemoIP(ctx context.Context, client *Client, ip *IPReservation) {
+	if ip == nil {
 		return
 	}
+	if err := releaseIPReservation(ctx, ip.ID, client); err != nil {
+		logger.Error(fmt.Sprintf("Releasing %s/%d failed: %s", ip.Network, ip.CIDR, err), "id", ip.ID)
+		return
+	}
+	logger.Info(fmt.Sprintf("Released %s/%d", ip.Network, ip.CIDR), "id", ip.ID)
+}
 
-	fmt.Printf("Device %s successfully deleted\n", deviceID)
+// demoDeviceRequest builds the device request from the demo flags
+func demoDeviceRequest() *DeviceRequest {
+	req := &DeviceRequest{
+		Hostname:              hostname,
+		Plan:                  plan,
+		OS:                    ops,
+		ProjectID:             projectID,
+		BillingCycle:          billingCycle,
+		HardwareReservationID: hwReservation,
+		IPXEScriptURL:         ipxeScriptURL,
+		AlwaysPXE:             alwaysPXE,
+	}
+	if metro != "" {
+		req.Metro = metro
+	} else {
+		req.Facility = []string{facility}
+	}
+	return req
 }
 
-func prettyPrint(in interface{}) {
-	res, err := json.MarshalIndent(in, "", "  ")
-	if err != nil {
-		fmt.Println(err.Error())
+func printCreateResult(r *CreateDeviceResult) {
+	switch outputFormat {
+	case "json":
+		prettyPrint(r)
 		return
+	case "ndjson":
+		// the device events already carry the result
+	default:
+		prettyPrint(r.Device)
 	}
-	fmt.Println(string(res))
+	for _, w := range r.Warnings {
+		logger.Warn(w)
+	}
+	logger.Info("Device provisioned", "device", r.Device.ID, "duration", r.ProvisionTime, "polls", r.Polls, "retries", r.Retries)
 }
 
-func waitUntilReady(deviceID string, c *Client) (*Device, error) {
-	for i := 0; i < 300; i++ {
-		time.Sleep(5 * time.Second)
-		dev := new(Device)
-		err := c.DoRequest("devices/"+deviceID, "GET", nil, dev, nil)
+func parseInputParams(args []string) {
+	fs := newFlagSet(os.Args[0])
+	fs.Usage = func() {
+		printUsage()
+		fmt.Println()
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&hostname, "hostname", "", "Hostname of the server to be deployed (default generated by --hostname-style)")
+	fs.StringVar(&facility, "facility", "ams1", "Datacenter facility code where to deploy device")
+	fs.StringVar(&metro, "metro", "", "Metro code where to deploy device instead of a facility (Equinix Metal API)")
+	fs.StringVar(&plan, "plan", "baremetal_0", "Server deployment plan")
+	fs.StringVar(&ops, "os", "centos_7", "Server OS slug")
+	billingCycle = BillingHourly
+	fs.Var(&billingCycle, "bilcycle", "Billing cycle: hourly, daily, monthly or yearly")
+	fs.DurationVar(&provisionTimeout, "provision-timeout", DefaultProvisionTimeout, "How long to wait for the device to become active, 0 for no limit")
+	fs.BoolVar(&cleanupOnTimeout, "cleanup-on-timeout", true, "Delete the device when it is not active within --provision-timeout")
+	fs.StringVar(&hwReservation, "hardware-reservation-id", "", "Deploy on this hardware reservation, or on any of the plan in the location with "+nextAvailableReservation)
+	fs.StringVar(&reserveIP, "reserve-ip", "", "Reserve an IP block such as ipv4/31 and assign it to the device once active")
+	fs.StringVar(&runScript, "run-script", "", "Local script to run on the device over SSH once it is active")
+	fs.BoolVar(&preferGreen, "prefer-green", false, "Deploy to the most sustainable metro with capacity, see metros in the configuration file")
+	fs.BoolVar(&interactive, "interactive", false, "Prompt for the token, project, plan, location and OS not given, and confirm before provisioning")
+	fs.StringVar(&greenMetros, "metros", "", "Comma separated candidate metros for --prefer-green (default all annotated metros)")
+	addSSHFlags(fs)
+	addHostnameFlags(fs)
+	addUserdataFlags(fs)
+	addIPXEFlags(fs)
+	addStorageFlags(fs)
+	addCostFlags(fs)
+	addTTLFlags(fs)
+
+	if err := parseFlags(fs, args); err != nil {
+		if _, reported := err.(exitCode); !reported {
+			logger.Error(err.Error())
+		}
+		exitProcess(exitCodeOf(err))
+	}
+
+	if preferGreen && (isFlagPassed(fs, "facility") || isFlagPassed(fs, "metro")) {
+		logger.Error("--prefer-green chooses the location, it cannot be combined with --facility or --metro")
+		exitProcess(exitUsage)
+	}
+	if preferGreen && hwReservation != "" && hwReservation != nextAvailableReservation {
+		logger.Error("--prefer-green chooses the location, it cannot be combined with a specific --hardware-reservation-id")
+		exitProcess(exitUsage)
+	}
+	if isFlagPassed(fs, "metro") && isFlagPassed(fs, "facility") {
+		logger.Error("Provide either --metro or --facility")
+		exitProcess(exitUsage)
+	}
+	if err := checkIPXE(); err != nil {
+		logger.Error(err.Error())
+		exitProcess(exitUsage)
+	}
+	if err := checkTTL(); err != nil {
+		logger.Error(err.Error())
+		exitProcess(exitUsage)
+	}
+	// a facility on the command line replaces the metro of the profile
+	if isFlagPassed(fs, "facility") {
+		metro = ""
+	}
+
+	// generate name for the device, if not provided
+	if hostname == "" {
+		gen, err := flagHostnameGenerator()
+		if err == nil {
+			hostname, err = gen.Generate()
+		}
 		if err != nil {
-			return nil, err
+			logger.Error(err.Error())
+			exitProcess(exitUsage)
+		}
+	}
+
+	if interactive {
+		if err := checkInteractive(); err != nil {
+			logger.Error(err.Error())
+			exitProcess(exitCodeOf(err))
 		}
-		if dev.State == "active" {
-			return dev, nil
+		prompts = demoPrompts{
+			project:  strings.TrimSpace(projectID) == "",
+			plan:     !isFlagPassed(fs, "plan"),
+			location: !preferGreen && !isFlagPassed(fs, "facility") && !isFlagPassed(fs, "metro"),
+			os:       !isFlagPassed(fs, "os"),
 		}
+		return
+	}
+	if err := checkCredentials(); err != nil {
+		logger.Error(err.Error())
+		exitProcess(exitCodeOf(err))
+	}
+}
+
+func prettyPrint(in interface{}) {
+	if outputFormat == "ndjson" {
+		printNDJSON(in)
+		return
+	}
+	res, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		fmt.Println(err.Error())
+		return
 	}
-	return nil, fmt.Errorf("device %s is still not provisioned", deviceID)
+	fmt.Println(string(res))
 }
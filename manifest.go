@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Manifest is a declarative description of the devices a project should
+// run. VLANs and IP reservations are recorded but not checked for drift yet.
+type Manifest struct {
+	Project        string              `json:"project,omitempty"`
+	Devices        []DeviceSpec        `json:"devices"`
+	VLANs          []VLANSpec          `json:"vlans,omitempty"`
+	IPReservations []IPReservationSpec `json:"ip_reservations,omitempty"`
+}
+
+// DeviceSpec describes a single desired device. Empty fields are not
+// checked against the live device, nil Tags means tags are not managed.
+// UserData is only passed when the device is created.
+type DeviceSpec struct {
+	Hostname     string       `json:"hostname"`
+	Plan         string       `json:"plan,omitempty"`
+	Facility     string       `json:"facility,omitempty"`
+	Metro        string       `json:"metro,omitempty"`
+	OS           string       `json:"os,omitempty"`
+	BillingCycle BillingCycle `json:"billing_cycle,omitempty"`
+	Tags         []string     `json:"tags,omitempty"`
+	UserData     string       `json:"userdata,omitempty"`
+}
+
+// VLANSpec describes a virtual network of the project
+type VLANSpec struct {
+	Description string `json:"description,omitempty"`
+	VXLAN       int    `json:"vxlan,omitempty"`
+	Facility    string `json:"facility,omitempty"`
+	Metro       string `json:"metro,omitempty"`
+}
+
+// IPReservationSpec describes a reserved block of IP addresses
+type IPReservationSpec struct {
+	Type        string   `json:"type"`
+	CIDR        int      `json:"cidr"`
+	Facility    string   `json:"facility,omitempty"`
+	Metro       string   `json:"metro,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// Drift is a single difference between a manifest and the live project
+type Drift struct {
+	Hostname string `json:"hostname"`
+	Field    string `json:"field,omitempty"` // empty when the device is missing altogether
+	Want     string `json:"want,omitempty"`
+	Got      string `json:"got,omitempty"`
+}
+
+func (d Drift) String() string {
+	if d.Field == "" {
+		return fmt.Sprintf("%s: missing", d.Hostname)
+	}
+	return fmt.Sprintf("%s: %s %s, manifest wants %s", d.Hostname, d.Field, d.Got, d.Want)
+}
+
+// loadManifest reads a YAML or JSON (by .json extension) manifest file
+func loadManifest(path string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := new(Manifest)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, m)
+	} else {
+		err = yamlUnmarshal(data, m)
+	}
+	if err != nil {
+		return nil, usageErrorf("%s: %s", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i, spec := range m.Devices {
+		if strings.TrimSpace(spec.Hostname) == "" {
+			return nil, fmt.Errorf("%s: device #%d has no hostname", path, i+1)
+		}
+		if seen[spec.Hostname] {
+			return nil, fmt.Errorf("%s: hostname %s is declared more than once", path, spec.Hostname)
+		}
+		seen[spec.Hostname] = true
+		if spec.BillingCycle != "" {
+			if err := new(BillingCycle).Set(spec.BillingCycle.String()); err != nil {
+				return nil, usageErrorf("%s: %s: %v", path, spec.Hostname, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// Drift compares the manifest with the devices of the project. Devices are
+// matched by hostname; devices not declared in the manifest are ignored.
+func (m *Manifest) Drift(devices []Device) []Drift {
+	byHostname := map[string]*Device{}
+	for i := range devices {
+		if _, ok := byHostname[devices[i].Hostname]; !ok {
+			byHostname[devices[i].Hostname] = &devices[i]
+		}
+	}
+
+	var drift []Drift
+	for _, spec := range m.Devices {
+		dev, ok := byHostname[spec.Hostname]
+		if !ok {
+			drift = append(drift, Drift{Hostname: spec.Hostname})
+			continue
+		}
+		drift = append(drift, spec.drift(dev)...)
+	}
+	return drift
+}
+
+func (s *DeviceSpec) drift(dev *Device) []Drift {
+	var drift []Drift
+	check := func(field, want, got string) {
+		if want != "" && want != got {
+			drift = append(drift, Drift{Hostname: s.Hostname, Field: field, Want: want, Got: got})
+		}
+	}
+	check("plan", s.Plan, dev.PlanSlug())
+	check("facility", s.Facility, dev.FacilityCode())
+	check("metro", s.Metro, dev.MetroCode())
+	check("os", s.OS, dev.OSSlug())
+	check("billing_cycle", s.BillingCycle.String(), dev.BillingCycle.String())
+
+	if s.Tags != nil {
+		want, got := sortedTags(s.Tags), sortedTags(dev.Tags)
+		if want != got {
+			drift = append(drift, Drift{Hostname: s.Hostname, Field: "tags", Want: want, Got: got})
+		}
+	}
+	return drift
+}
+
+// sortedTags formats tags independently of their order
+func sortedTags(tags []string) string {
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	return "[" + strings.Join(sorted, " ") + "]"
+}
+
+const (
+	changeCreate = "create"
+	changeUpdate = "update"
+	changeDelete = "delete"
+)
+
+// Change is a step of bringing the project in line with a manifest
+type Change struct {
+	Action   string   `json:"action"`
+	Hostname string   `json:"hostname"`
+	DeviceID string   `json:"device_id,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+
+	spec *DeviceSpec
+}
+
+func (c Change) String() string {
+	switch c.Action {
+	case changeCreate:
+		location := c.spec.Metro
+		if location == "" {
+			location = c.spec.Facility
+		}
+		return fmt.Sprintf("+ %s: create %s %s in %s", c.Hostname, c.spec.Plan, c.spec.OS, location)
+	case changeUpdate:
+		return fmt.Sprintf("~ %s: set tags %s", c.Hostname, sortedTags(c.Tags))
+	}
+	return fmt.Sprintf("- %s: delete %s", c.Hostname, c.DeviceID)
+}
+
+// Changes returns the steps bringing the devices of the project in line
+// with the manifest: creating missing devices and updating tags. With
+// prune set devices not declared in the manifest are deleted as well.
+// Other drift cannot be changed in place and is left to the caller.
+func (m *Manifest) Changes(devices []Device, prune bool) []Change {
+	byHostname := map[string]*Device{}
+	for i := range devices {
+		if _, ok := byHostname[devices[i].Hostname]; !ok {
+			byHostname[devices[i].Hostname] = &devices[i]
+		}
+	}
+
+	var changes []Change
+	declared := map[string]bool{}
+	for i := range m.Devices {
+		spec := &m.Devices[i]
+		declared[spec.Hostname] = true
+		dev, ok := byHostname[spec.Hostname]
+		if !ok {
+			changes = append(changes, Change{Action: changeCreate, Hostname: spec.Hostname, Tags: spec.Tags, spec: spec})
+			continue
+		}
+		if spec.Tags != nil && sortedTags(spec.Tags) != sortedTags(dev.Tags) {
+			changes = append(changes, Change{Action: changeUpdate, Hostname: spec.Hostname, DeviceID: dev.ID, Tags: spec.Tags, spec: spec})
+		}
+	}
+	if prune {
+		for _, dev := range devices {
+			if !declared[dev.Hostname] {
+				changes = append(changes, Change{Action: changeDelete, Hostname: dev.Hostname, DeviceID: dev.ID})
+			}
+		}
+	}
+	return changes
+}
+
+// request returns the request creating the device of the spec
+func (s *DeviceSpec) request(projectID string) (*DeviceRequest, error) {
+	if s.Plan == "" || s.OS == "" || (s.Facility == "" && s.Metro == "") {
+		return nil, usageErrorf("%s: plan, os and a facility or metro are needed to create the device", s.Hostname)
+	}
+	req := &DeviceRequest{
+		Hostname:     s.Hostname,
+		Plan:         s.Plan,
+		OS:           s.OS,
+		BillingCycle: s.BillingCycle,
+		ProjectID:    projectID,
+		Tags:         s.Tags,
+		UserData:     s.UserData,
+	}
+	if req.BillingCycle == "" {
+		req.BillingCycle = BillingHourly
+	}
+	if s.Metro != "" {
+		req.Metro = s.Metro
+	} else {
+		req.Facility = []string{s.Facility}
+	}
+	return req, nil
+}
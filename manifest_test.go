@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// liveDevice is a device of the project as the API lists it
+func liveDevice(id, hostname, plan, facility string, tags ...string) Device {
+	return Device{
+		ID:           id,
+		Hostname:     hostname,
+		BillingCycle: BillingHourly,
+		Tags:         tags,
+		Plan:         map[string]interface{}{"slug": plan},
+		Facility:     map[string]interface{}{"code": facility, "metro": map[string]interface{}{"code": facility[:2]}},
+		OS:           map[string]interface{}{"slug": "ubuntu_22_04"},
+	}
+}
+
+func TestManifestDrift(t *testing.T) {
+	devices := []Device{
+		liveDevice("id-web", "web", "c3.small.x86", "ny5", "web", "prod"),
+		liveDevice("id-db", "db", "m3.large.x86", "ny5"),
+		// a second device of a hostname is not the one the manifest manages
+		liveDevice("id-web-2", "web", "m3.large.x86", "da11"),
+		liveDevice("id-other", "other", "c3.small.x86", "ny5"),
+	}
+	tests := []struct {
+		name string
+		spec DeviceSpec
+		want []string
+	}{
+		{"matching", DeviceSpec{Hostname: "web", Plan: "c3.small.x86", Facility: "ny5", Metro: "ny", OS: "ubuntu_22_04", BillingCycle: BillingHourly}, nil},
+		{"empty fields are not checked", DeviceSpec{Hostname: "db"}, nil},
+		{"missing device", DeviceSpec{Hostname: "cache", Plan: "c3.small.x86"}, []string{"cache: missing"}},
+		{"plan and metro", DeviceSpec{Hostname: "db", Plan: "c3.small.x86", Metro: "da"},
+			[]string{"db: plan m3.large.x86, manifest wants c3.small.x86", "db: metro ny, manifest wants da"}},
+		{"billing cycle", DeviceSpec{Hostname: "db", BillingCycle: BillingMonthly}, []string{"db: billing_cycle hourly, manifest wants monthly"}},
+		{"tag order does not matter", DeviceSpec{Hostname: "web", Tags: []string{"prod", "web"}}, nil},
+		{"tags differ", DeviceSpec{Hostname: "web", Tags: []string{"web"}}, []string{"web: tags [prod web], manifest wants [web]"}},
+		{"no tags wanted", DeviceSpec{Hostname: "web", Tags: []string{}}, []string{"web: tags [prod web], manifest wants []"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manifest{Devices: []DeviceSpec{tt.spec}}
+			var got []string
+			for _, d := range m.Drift(devices) {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Drift() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifestChanges(t *testing.T) {
+	devices := []Device{
+		liveDevice("id-web", "web", "c3.small.x86", "ny5", "web"),
+		liveDevice("id-db", "db", "m3.large.x86", "ny5", "db"),
+		liveDevice("id-old", "old", "c3.small.x86", "ny5"),
+	}
+	m := &Manifest{Devices: []DeviceSpec{
+		{Hostname: "web", Tags: []string{"web", "prod"}},
+		// a different plan cannot be changed in place, it is only drift
+		{Hostname: "db", Plan: "c3.small.x86", Tags: []string{"db"}},
+		{Hostname: "cache", Plan: "c3.small.x86", OS: "ubuntu_22_04", Metro: "ny", Tags: []string{"cache"}},
+		// devices without managed tags are left alone
+		{Hostname: "old"},
+	}}
+	tests := []struct {
+		name  string
+		m     *Manifest
+		prune bool
+		want  []string
+	}{
+		{"create and update", m, false, []string{
+			"~ web: set tags [prod web]",
+			"+ cache: create c3.small.x86 ubuntu_22_04 in ny",
+		}},
+		{"prune keeps declared devices", m, true, []string{
+			"~ web: set tags [prod web]",
+			"+ cache: create c3.small.x86 ubuntu_22_04 in ny",
+		}},
+		{"prune deletes undeclared devices", &Manifest{Devices: m.Devices[:2]}, true, []string{
+			"~ web: set tags [prod web]",
+			"- old: delete id-old",
+		}},
+		{"empty manifest without prune", &Manifest{}, false, nil},
+		{"empty manifest with prune", &Manifest{}, true, []string{
+			"- web: delete id-web",
+			"- db: delete id-db",
+			"- old: delete id-old",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range tt.m.Changes(devices, tt.prune) {
+				got = append(got, c.String())
+				if c.Action == changeUpdate && c.DeviceID == "" {
+					t.Errorf("update of %s has no device ID", c.Hostname)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Changes() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+)
+
+const (
+	mdnsTTL     = 120
+	dnsTypeA    = 1
+	dnsTypeANY  = 255
+	dnsClassIN  = 1
+	dnsCacheBit = 0x8000 // cache-flush bit of answers, unicast-response bit of questions
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// mdnsResponder answers multicast DNS queries for <hostname>.local with the
+// public IPv4 address of the project devices, so that machines on the same
+// network resolve them without editing hosts files
+type mdnsResponder struct {
+	conn  *net.UDPConn
+	mu    sync.Mutex
+	hosts map[string]net.IP // by lower case name, e.g. "web1.local."
+}
+
+func newMDNSResponder() (*mdnsResponder, error) {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return nil, err
+	}
+	m := &mdnsResponder{conn: conn, hosts: map[string]net.IP{}}
+	go m.serve()
+	return m, nil
+}
+
+// update replaces the announced devices. New and changed names are
+// announced right away, removed ones are withdrawn.
+func (m *mdnsResponder) update(devices []Device) {
+	hosts := map[string]net.IP{}
+	for i := range devices {
+		d := &devices[i]
+		ip := net.ParseIP(d.PublicIPv4()).To4()
+		if d.State != StateActive || ip == nil || d.Hostname == "" {
+			continue
+		}
+		hosts[strings.ToLower(d.Hostname)+".local."] = ip
+	}
+
+	m.mu.Lock()
+	old := m.hosts
+	m.hosts = hosts
+	m.mu.Unlock()
+
+	for name, ip := range hosts {
+		if !ip.Equal(old[name]) {
+			logger.Info("Announcing "+strings.TrimSuffix(name, "."), "address", ip.String())
+			m.send(mdnsGroup, 0, nil, name, ip, mdnsTTL)
+		}
+	}
+	for name, ip := range old {
+		if hosts[name] == nil {
+			m.send(mdnsGroup, 0, nil, name, ip, 0)
+		}
+	}
+}
+
+// close withdraws all announcements
+func (m *mdnsResponder) close() {
+	m.update(nil)
+	m.conn.Close()
+}
+
+func (m *mdnsResponder) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := m.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		m.answer(buf[:n], from)
+	}
+}
+
+func (m *mdnsResponder) answer(msg []byte, from *net.UDPAddr) {
+	if len(msg) < 12 || msg[2]&0x80 != 0 {
+		return // too short or a response
+	}
+	id := binary.BigEndian.Uint16(msg)
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	off := 12
+	for i := 0; i < qdcount; i++ {
+		name, next, err := readDNSName(msg, off)
+		if err != nil || next+4 > len(msg) {
+			return
+		}
+		qtype := binary.BigEndian.Uint16(msg[next:])
+		qclass := binary.BigEndian.Uint16(msg[next+2:])
+		question := msg[off : next+4]
+		off = next + 4
+
+		if (qtype != dnsTypeA && qtype != dnsTypeANY) || qclass&^dnsCacheBit != dnsClassIN {
+			continue
+		}
+		m.mu.Lock()
+		ip := m.hosts[strings.ToLower(name)]
+		m.mu.Unlock()
+		if ip == nil {
+			continue
+		}
+
+		switch {
+		case from.Port != mdnsGroup.Port:
+			// legacy unicast resolvers expect a plain DNS reply
+			m.send(from, id, question, name, ip, 10)
+		case qclass&dnsCacheBit != 0:
+			m.send(from, 0, nil, name, ip, mdnsTTL)
+		default:
+			m.send(mdnsGroup, 0, nil, name, ip, mdnsTTL)
+		}
+	}
+}
+
+// send writes a response with a single A record, echoing the question for
+// legacy unicast replies
+func (m *mdnsResponder) send(to *net.UDPAddr, id uint16, question []byte, name string, ip net.IP, ttl uint32) {
+	msg := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(msg, id)
+	binary.BigEndian.PutUint16(msg[2:], 0x8400) // response, authoritative
+	if question != nil {
+		binary.BigEndian.PutUint16(msg[4:], 1)
+		msg = append(msg, question...)
+	}
+	binary.BigEndian.PutUint16(msg[6:], 1)
+
+	msg = appendDNSName(msg, name)
+	class := uint16(dnsClassIN)
+	if question == nil {
+		class |= dnsCacheBit
+	}
+	msg = append(msg, 0, dnsTypeA, byte(class>>8), byte(class))
+	msg = append(msg, byte(ttl>>24), byte(ttl>>16), byte(ttl>>8), byte(ttl))
+	msg = append(msg, 0, 4)
+	msg = append(msg, ip.To4()...)
+	m.conn.WriteToUDP(msg, to)
+}
+
+func appendDNSName(msg []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	return append(msg, 0)
+}
+
+// readDNSName reads a possibly compressed name and returns it with the
+// offset following it
+func readDNSName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errors.New("name out of bounds")
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(msg) || jumps > 10 {
+				return "", 0, errors.New("bad compression pointer")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+			jumps++
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errors.New("label out of bounds")
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "project member list",
+		usage: "List the members of the project and their roles",
+		run:   runProjectMemberList,
+	})
+	registerCommand(&command{
+		name:  "project invite",
+		usage: "Invite someone to the project by email",
+		run:   runProjectInvite,
+	})
+	registerCommand(&command{
+		name:  "project invitation list",
+		usage: "List the pending invitations to the project",
+		run:   runProjectInvitationList,
+	})
+	registerCommand(&command{
+		name:  "project invitation revoke",
+		usage: "Revoke an invitation that was not accepted yet",
+		run:   runProjectInvitationRevoke,
+	})
+}
+
+// projectRoles are the roles a project member can be invited with
+var projectRoles = []string{"admin", "collaborator", "limited_collaborator", "billing"}
+
+// InvitationRequest invites an email address to the project
+type InvitationRequest struct {
+	Invitee    string   `json:"invitee"`
+	Roles      []string `json:"roles"`
+	ProjectIDs []string `json:"projects_ids,omitempty"`
+}
+
+// listProjectMembers returns the memberships of the project
+func listProjectMembers(ctx context.Context, projectID string, c *Client) ([]Member, error) {
+	list := new(struct {
+		Memberships []Member `json:"memberships"`
+	})
+	if err := c.DoRequest(ctx, "projects/"+projectID+"/memberships", "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.Memberships, nil
+}
+
+// listProjectInvitations returns the pending invitations to the project
+func listProjectInvitations(ctx context.Context, projectID string, c *Client) ([]Invitation, error) {
+	list := new(struct {
+		Invitations []Invitation `json:"invitations"`
+	})
+	if err := c.DoRequest(ctx, "projects/"+projectID+"/invitations", "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.Invitations, nil
+}
+
+// inviteToProject sends an invitation to join the project
+func inviteToProject(ctx context.Context, projectID string, req *InvitationRequest, c *Client) (*Invitation, error) {
+	inv := new(Invitation)
+	if err := c.DoRequest(ctx, "projects/"+projectID+"/invitations", "POST", req, inv, nil); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// revokeInvitation deletes an invitation, the link it sent stops working
+func revokeInvitation(ctx context.Context, invitationID string, c *Client) error {
+	return c.DoRequest(ctx, "invitations/"+invitationID, "DELETE", nil, nil, nil)
+}
+
+func runProjectMemberList(ctx context.Context, args []string) error {
+	fs := newFlagSet("project member list")
+	addCacheFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	members, err := listProjectMembers(ctx, projectID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if members == nil {
+			members = []Member{}
+		}
+		prettyPrint(members)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tNAME\tROLES")
+	for _, m := range members {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.ID, attrString(m.User, "email"), attrString(m.User, "full_name"), strings.Join(m.Roles, ","))
+	}
+	return w.Flush()
+}
+
+func runProjectInvite(ctx context.Context, args []string) error {
+	fs := newFlagSet("project invite")
+	role := fs.String("role", "collaborator", "Role of the invitee: "+strings.Join(projectRoles, ", ")+", or several separated by commas")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo project invite [flags] <email>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	email := fs.Arg(0)
+	if !strings.Contains(email, "@") {
+		return usageErrorf("%q is not an email address", email)
+	}
+	var roles []string
+	for _, r := range strings.Split(*role, ",") {
+		r = strings.TrimSpace(r)
+		valid := false
+		for _, known := range projectRoles {
+			valid = valid || r == known
+		}
+		if !valid {
+			return usageErrorf("unknown role %q, use %s", r, strings.Join(projectRoles, ", "))
+		}
+		roles = append(roles, r)
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	req := &InvitationRequest{Invitee: email, Roles: roles, ProjectIDs: []string{projectID}}
+	inv, err := inviteToProject(ctx, projectID, req, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(inv)
+		return nil
+	}
+	logger.Info("Invited "+email+" to the project", "id", inv.ID, "project", projectID, "roles", strings.Join(roles, ","))
+	return nil
+}
+
+func runProjectInvitationList(ctx context.Context, args []string) error {
+	fs := newFlagSet("project invitation list")
+	addCacheFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	invitations, err := listProjectInvitations(ctx, projectID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if invitations == nil {
+			invitations = []Invitation{}
+		}
+		prettyPrint(invitations)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tINVITEE\tROLES\tCREATED")
+	for _, i := range invitations {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", i.ID, i.Invitee, strings.Join(i.Roles, ","), i.Created)
+	}
+	return w.Flush()
+}
+
+func runProjectInvitationRevoke(ctx context.Context, args []string) error {
+	fs := newFlagSet("project invitation revoke")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo project invitation revoke [flags] <invitation-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	if err := revokeInvitation(ctx, fs.Arg(0), newCLIClient()); err != nil {
+		return err
+	}
+	logger.Info("Revoked invitation", "id", fs.Arg(0))
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nurfet-becirevic/packet-go-demo/packet/metadata"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "metadata",
+		usage: "Print the identity, network or userdata of the device the tool runs on",
+		run:   runMetadata,
+	})
+}
+
+func runMetadata(ctx context.Context, args []string) error {
+	fs := newFlagSet("metadata")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo metadata [flags] [identity|network|userdata|customdata]")
+		fs.PrintDefaults()
+	}
+	metadataURL := fs.String("metadata-url", envOrDefault("PACKET_METADATA_URL", metadata.DefaultURL), "Metadata service base URL")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	section := "identity"
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if fs.NArg() == 1 {
+		section = fs.Arg(0)
+	}
+	switch section {
+	case "identity", "network", "userdata", "customdata":
+	default:
+		return usageErrorf("unknown metadata section %q, use identity, network, userdata or customdata", section)
+	}
+
+	mc := metadata.NewClient(*metadataURL)
+	if section == "userdata" {
+		data, err := mc.UserData(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Print(data)
+		return nil
+	}
+
+	md, raw, err := mc.Metadata(ctx)
+	if err != nil {
+		return err
+	}
+	switch section {
+	case "identity":
+		if jsonOutput() {
+			// the whole document, for scripts to pick what they need
+			prettyPrint(raw)
+			return nil
+		}
+		printMetadataIdentity(md)
+	case "network":
+		if jsonOutput() {
+			prettyPrint(md.Network)
+			return nil
+		}
+		printMetadataNetwork(&md.Network)
+	case "customdata":
+		prettyPrint(md.CustomData)
+	}
+	return nil
+}
+
+func printMetadataIdentity(md *metadata.Metadata) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\t%s\n", md.ID)
+	fmt.Fprintf(w, "HOSTNAME\t%s\n", md.Hostname)
+	fmt.Fprintf(w, "PLAN\t%s\n", md.Plan)
+	fmt.Fprintf(w, "FACILITY\t%s\n", md.Facility)
+	if md.Metro != "" {
+		fmt.Fprintf(w, "METRO\t%s\n", md.Metro)
+	}
+	fmt.Fprintf(w, "OS\t%s\n", md.OS.Slug)
+	fmt.Fprintf(w, "TAGS\t%s\n", strings.Join(md.Tags, ","))
+	w.Flush()
+}
+
+func printMetadataNetwork(n *metadata.Network) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INTERFACE\tMAC\tBOND")
+	for _, i := range n.Interfaces {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", i.Name, i.MAC, i.Bond)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "ADDRESS\tGATEWAY\tPUBLIC\tMANAGEMENT")
+	for _, a := range n.Addresses {
+		fmt.Fprintf(w, "%s/%d\t%s\t%t\t%t\n", a.Address, a.CIDR, a.Gateway, a.Public, a.Management)
+	}
+	fmt.Fprintf(w, "\nBonding mode %d\n", n.Bonding.Mode)
+	w.Flush()
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "serve-metrics",
+		usage: "Serve Prometheus metrics of the project devices and API calls",
+		run:   runServeMetrics,
+	})
+}
+
+// upper bounds in seconds of the provisioning duration histogram buckets
+var provisionBuckets = []float64{60, 120, 300, 600, 900, 1200, 1800, 3600}
+
+type deviceLabels struct {
+	state, plan, facility string
+}
+
+type requestLabels struct {
+	method, code string
+}
+
+// metricsCollector holds the metrics served by serve-metrics. Device counts
+// are replaced on every poll, the other metrics accumulate.
+type metricsCollector struct {
+	mu sync.Mutex
+
+	devices map[deviceLabels]int
+	// creation time of the devices seen before they were active
+	provisioning map[string]time.Time
+
+	provisionCounts []int
+	provisionSum    float64
+	provisionCount  int
+
+	requests   map[requestLabels]int
+	polls      int
+	pollErrors int
+	lastPoll   time.Time
+}
+
+func newMetricsCollector() *metricsCollector {
+	return &metricsCollector{
+		devices:         map[deviceLabels]int{},
+		provisioning:    map[string]time.Time{},
+		provisionCounts: make([]int, len(provisionBuckets)),
+		requests:        map[requestLabels]int{},
+	}
+}
+
+// middleware counts the API requests by method and status code, failed
+// requests without response are counted with code "error"
+func (m *metricsCollector) middleware(next RoundTripFunc) RoundTripFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		resp, err := next(r)
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		m.mu.Lock()
+		m.requests[requestLabels{r.Method, code}]++
+		m.mu.Unlock()
+		return resp, err
+	}
+}
+
+// observe updates the metrics from a listing of the project devices taken
+// at now. The provisioning duration of a device is observed when it is
+// seen active after having been seen in another state.
+func (m *metricsCollector) observe(devices []Device, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.polls++
+	m.lastPoll = now
+	m.devices = map[deviceLabels]int{}
+	present := map[string]bool{}
+	for i := range devices {
+		d := &devices[i]
+		present[d.ID] = true
+		m.devices[deviceLabels{d.State.String(), d.PlanSlug(), d.FacilityCode()}]++
+
+		created, seen := m.provisioning[d.ID]
+		switch {
+		case d.State == StateActive && seen:
+			m.observeProvision(now.Sub(created).Seconds())
+			delete(m.provisioning, d.ID)
+		case d.State != StateActive && !seen:
+			if t, err := time.Parse(time.RFC3339, d.Created); err == nil {
+				m.provisioning[d.ID] = t
+			}
+		}
+	}
+	// devices deleted before they were active
+	for id := range m.provisioning {
+		if !present[id] {
+			delete(m.provisioning, id)
+		}
+	}
+}
+
+func (m *metricsCollector) observeProvision(seconds float64) {
+	for i, le := range provisionBuckets {
+		if seconds <= le {
+			m.provisionCounts[i]++
+		}
+	}
+	m.provisionSum += seconds
+	m.provisionCount++
+}
+
+func (m *metricsCollector) pollFailed() {
+	m.mu.Lock()
+	m.pollErrors++
+	m.mu.Unlock()
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *metricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.write(w)
+}
+
+func (m *metricsCollector) write(w io.Writer) {
+	fmt.Fprintln(w, "# HELP packet_devices Devices of the project by state, plan and facility.")
+	fmt.Fprintln(w, "# TYPE packet_devices gauge")
+	var lines []string
+	for l, n := range m.devices {
+		lines = append(lines, fmt.Sprintf("packet_devices{state=%s,plan=%s,facility=%s} %d",
+			promLabel(l.state), promLabel(l.plan), promLabel(l.facility), n))
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintln(w, "# HELP packet_device_provision_duration_seconds Time from creation until devices were seen active.")
+	fmt.Fprintln(w, "# TYPE packet_device_provision_duration_seconds histogram")
+	for i, le := range provisionBuckets {
+		fmt.Fprintf(w, "packet_device_provision_duration_seconds_bucket{le=\"%g\"} %d\n", le, m.provisionCounts[i])
+	}
+	fmt.Fprintf(w, "packet_device_provision_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.provisionCount)
+	fmt.Fprintf(w, "packet_device_provision_duration_seconds_sum %g\n", m.provisionSum)
+	fmt.Fprintf(w, "packet_device_provision_duration_seconds_count %d\n", m.provisionCount)
+
+	fmt.Fprintln(w, "# HELP packet_api_requests_total API requests by method and status code, error when no response was received.")
+	fmt.Fprintln(w, "# TYPE packet_api_requests_total counter")
+	lines = lines[:0]
+	for l, n := range m.requests {
+		lines = append(lines, fmt.Sprintf("packet_api_requests_total{method=%s,code=%s} %d", promLabel(l.method), promLabel(l.code), n))
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintln(w, "# HELP packet_polls_total Listings of the project devices.")
+	fmt.Fprintln(w, "# TYPE packet_polls_total counter")
+	fmt.Fprintf(w, "packet_polls_total %d\n", m.polls)
+	fmt.Fprintln(w, "# HELP packet_poll_errors_total Listings of the project devices that failed.")
+	fmt.Fprintln(w, "# TYPE packet_poll_errors_total counter")
+	fmt.Fprintf(w, "packet_poll_errors_total %d\n", m.pollErrors)
+	if !m.lastPoll.IsZero() {
+		fmt.Fprintln(w, "# HELP packet_last_poll_timestamp_seconds Time of the last successful listing of the project devices.")
+		fmt.Fprintln(w, "# TYPE packet_last_poll_timestamp_seconds gauge")
+		fmt.Fprintf(w, "packet_last_poll_timestamp_seconds %d\n", m.lastPoll.Unix())
+	}
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promLabel quotes a label value for the Prometheus text format
+func promLabel(s string) string {
+	return `"` + promLabelEscaper.Replace(s) + `"`
+}
+
+func runServeMetrics(ctx context.Context, args []string) error {
+	fs := newFlagSet("serve-metrics")
+	listen := fs.String("listen", ":9400", "Address to serve the metrics on, at /metrics")
+	interval := fs.Duration("interval", time.Minute, "How often the project devices are listed")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	m := newMetricsCollector()
+	client.Use(m.middleware)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	srv := &http.Server{Addr: *listen, Handler: mux}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	logger.Info("Serving metrics on "+*listen+"/metrics", "project", projectID, "interval", *interval)
+
+	for {
+		devices, err := searchDevices(ctx, projectID, nil, DeviceSummaryOptions, client)
+		if err != nil && ctx.Err() == nil {
+			m.pollFailed()
+			logger.Error("Listing devices failed, metrics are updated on the next poll", "error", err)
+		} else if err == nil {
+			m.observe(devices, time.Now())
+		}
+
+		select {
+		case err := <-errc:
+			return err
+		case <-ctx.Done():
+		case <-time.After(*interval):
+			continue
+		}
+		shutdownCtx, cancel := cleanupContext(ctx)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		logger.Info("Metrics server stopped")
+		return nil
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nurfet-becirevic/packet-go-demo/packet"
+)
+
+// runMiddleware traces every request of the client in a span, counts the
+// retries and emits the request.sent events of the run. It comes first, so
+// that it also sees requests answered from a cache.
+func runMiddleware(c *Client) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (resp *http.Response, err error) {
+			url := strings.TrimPrefix(r.URL.String(), c.BaseURL())
+			path := strings.SplitN(url, "?", 2)[0]
+			attempt := packet.Attempt(r)
+			ctx, span := startSpan(r.Context(), r.Method+" "+path, spanKindClient)
+			if span != nil {
+				span.setAttr("http.request.method", r.Method)
+				span.setAttr("url.path", path)
+				span.setAttr("packet.retries", attempt)
+				r = r.WithContext(ctx)
+				r.Header.Set("traceparent", span.traceparent())
+			}
+			defer func() {
+				if err == nil && resp.StatusCode > 399 {
+					span.finish(errors.New(resp.Status))
+					return
+				}
+				span.finish(err)
+			}()
+
+			if attempt > 0 {
+				runStats.retried(1)
+			}
+			emit("request.sent", "method", r.Method, "path", url, "attempt", attempt+1)
+			resp, err = next(r)
+			if err == nil {
+				span.setAttr("http.response.status_code", resp.StatusCode)
+			}
+			return resp, err
+		}
+	}
+}
+
+// callMiddleware records the requests that reach the API for the stats
+// and crash reports of the run. It comes last, after the middleware that
+// may answer requests itself.
+func callMiddleware(c *Client) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next(r)
+			call := apiCall{Time: start, Method: r.Method, URL: strings.TrimPrefix(r.URL.String(), c.BaseURL()), Duration: time.Since(start)}
+			if err != nil {
+				call.Err = err.Error()
+			} else {
+				call.Status = resp.StatusCode
+				call.RequestID = packet.RequestID(resp)
+			}
+			recordAPICall(call)
+			runStats.record(call, resp)
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/nurfet-becirevic/packet-go-demo/packettest"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "mock-api",
+		usage: "Serve an in-memory fake of the device, project and SSH key API",
+		run:   runMockAPI,
+	})
+}
+
+func runMockAPI(ctx context.Context, args []string) error {
+	fs := newFlagSet("mock-api")
+	f := packettest.NewFakeAPI()
+	listen := fs.String("listen", "127.0.0.1:8999", "Address to serve the fake API on")
+	fs.StringVar(&f.Token, "accept-token", "", "Only accept this token (default any token)")
+	fs.DurationVar(&f.Latency, "latency", 0, "Delay of every response")
+	fs.StringVar(&f.OTP, "require-otp", "", "Two-factor code DELETE requests must send (default none)")
+	fs.Float64Var(&f.FailureRate, "failure-rate", 0, "Share of requests failing with a server error, between 0 and 1")
+	fs.DurationVar(&f.ProvisionTime, "provision-time", f.ProvisionTime, "How long new devices take to become active")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if f.FailureRate < 0 || f.FailureRate > 1 {
+		return usageErrorf("--failure-rate must be between 0 and 1")
+	}
+
+	srv := &http.Server{Addr: *listen, Handler: f}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	logger.Info("Serving the fake API on http://"+*listen+"/, use project project-1", "latency", f.Latency, "failure_rate", f.FailureRate)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := cleanupContext(ctx)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	logger.Info("Fake API stopped")
+	return nil
+}
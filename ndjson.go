@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var emitMu sync.Mutex
+
+// jsonOutput reports whether results are printed as JSON, by --output json
+// or ndjson
+func jsonOutput() bool {
+	return outputFormat == "json" || outputFormat == "ndjson"
+}
+
+// emit writes an event such as run.finished as a JSON object on its own
+// line when --output is ndjson. Fields are key and value pairs, as with the
+// logger, and keep their order. Device events go through lifecycle instead.
+func emit(event string, fields ...interface{}) {
+	if outputFormat != "ndjson" {
+		return
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `{"time":%q,"event":%q`, time.Now().UTC().Format(time.RFC3339Nano), event)
+	for i := 0; i+1 < len(fields); i += 2 {
+		key, _ := json.Marshal(fmt.Sprint(fields[i]))
+		value, err := json.Marshal(fields[i+1])
+		if err != nil {
+			value, _ = json.Marshal(err.Error())
+		}
+		fmt.Fprintf(&b, ",%s:%s", key, value)
+	}
+	b.WriteString("}\n")
+
+	emitMu.Lock()
+	defer emitMu.Unlock()
+	os.Stdout.Write(b.Bytes())
+}
+
+// printNDJSON prints a result as a result event, a list as one event per
+// item, so that every line of the stream has the same shape
+func printNDJSON(in interface{}) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return
+	}
+	var items []json.RawMessage
+	if json.Unmarshal(data, &items) != nil {
+		items = []json.RawMessage{data}
+	}
+	for _, item := range items {
+		emit("result", "data", item)
+	}
+}
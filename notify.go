@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// notifyTargets are where device lifecycle notifications are sent, set by
+// --notify. It is a flag value rejecting unknown schemes when parsed.
+type notifyTargets []string
+
+var notifyURLs notifyTargets
+
+// notifyEvents are the events notified, the end of a provision and deletions
+var notifyEvents = map[string]bool{"device.active": true, "device.failed": true, "device.deleted": true}
+
+func (t *notifyTargets) String() string {
+	return strings.Join(*t, ",")
+}
+
+// Set adds a target: slack://hooks.slack.com/services/..., an http(s) URL
+// receiving JSON or mailto:address
+func (t *notifyTargets) Set(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "slack", "http", "https":
+		if u.Host == "" {
+			return fmt.Errorf("%s has no host", s)
+		}
+	case "mailto":
+		if u.Opaque == "" {
+			return fmt.Errorf("%s has no address", s)
+		}
+	default:
+		return fmt.Errorf("unknown notification target %q, use slack://, https:// or mailto:", s)
+	}
+	*t = append(*t, s)
+	return nil
+}
+
+// eventFields returns the key and value pairs of an event as a map, with
+// the hostname and IP of the device it carries
+func eventFields(fields []interface{}) map[string]interface{} {
+	f := map[string]interface{}{}
+	for i := 0; i+1 < len(fields); i += 2 {
+		f[fmt.Sprint(fields[i])] = fields[i+1]
+	}
+	if d, ok := f["device"].(*Device); ok {
+		f["hostname"] = d.Hostname
+		f["ip"] = d.PublicIPv4()
+	}
+	return f
+}
+
+// notifyMessage returns the text of a lifecycle event
+func notifyMessage(event string, f map[string]interface{}) string {
+	name := fmt.Sprint(f["device_id"])
+	if h, _ := f["hostname"].(string); h != "" {
+		name = h + " (" + name + ")"
+	}
+	switch event {
+	case "device.active":
+		ip := ""
+		if d, ok := f["device"].(*Device); ok && d.PublicIPv4() != "" {
+			ip = " at " + d.PublicIPv4()
+		}
+		return fmt.Sprintf("Device %s is active%s, provisioned in %v", name, ip, f["provision_time"])
+	case "device.failed":
+		return fmt.Sprintf("Device %s failed after %v: %v", name, f["duration"], f["error"])
+	default:
+		return fmt.Sprintf("Device %s deleted", name)
+	}
+}
+
+// notify sends the lifecycle event to every --notify target. Failures are
+// logged, a notification never fails the run.
+func notify(event string, fields ...interface{}) {
+	if len(notifyURLs) == 0 || !notifyEvents[event] {
+		return
+	}
+	f := eventFields(fields)
+	text := notifyMessage(event, f)
+	for _, target := range notifyURLs {
+		if err := sendNotification(target, event, text, f); err != nil {
+			logger.Warn("Sending a notification failed", "target", redactTarget(target), "error", err)
+		}
+	}
+}
+
+func sendNotification(target, event, text string, f map[string]interface{}) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "mailto":
+		return sendMail(u.Opaque, "packet-go-demo: "+text, text)
+	case "slack":
+		u.Scheme = "https"
+		return postJSON(u.String(), map[string]string{"text": text})
+	}
+	if u.Host == "hooks.slack.com" {
+		return postJSON(target, map[string]string{"text": text})
+	}
+	payload := map[string]interface{}{"event": event, "text": text, "time": time.Now().UTC()}
+	for _, k := range []string{"device_id", "hostname", "ip", "provision_time", "duration", "error"} {
+		if v, ok := f[k]; ok {
+			payload[k] = v
+		}
+	}
+	return postJSON(target, payload)
+}
+
+func postJSON(target string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(target, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+// sendMail sends the notification through the SMTP server of
+// PACKET_SMTP_ADDR, authenticating when PACKET_SMTP_USER is set
+func sendMail(to, subject, body string) error {
+	addr := envOrDefault("PACKET_SMTP_ADDR", "localhost:25")
+	from := os.Getenv("PACKET_SMTP_FROM")
+	if from == "" {
+		host, _ := os.Hostname()
+		from = "packet-go-demo@" + host
+	}
+	var auth smtp.Auth
+	if user := os.Getenv("PACKET_SMTP_USER"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("PACKET_SMTP_PASSWORD"), strings.Split(addr, ":")[0])
+	}
+	msg := "From: " + from + "\r\nTo: " + to + "\r\nSubject: " + subject + "\r\n\r\n" + body + "\r\n"
+	return smtp.SendMail(addr, auth, from, []string{to}, []byte(msg))
+}
+
+// redactTarget hides the secret path of webhook URLs in logs
+func redactTarget(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "mailto" {
+		return target
+	}
+	return u.Scheme + "://" + u.Host + "/…"
+}
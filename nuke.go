@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "project nuke",
+		usage: "Delete every device, IP reservation, volume and SSH key of a project in parallel",
+		run:   runProjectNuke,
+	})
+}
+
+// nukeTarget is a resource of a project about to be deleted
+type nukeTarget struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	delete func(ctx context.Context) error
+}
+
+// hasAnyTag reports whether tags hold any of the tags in except
+func hasAnyTag(tags []string, except map[string]bool) bool {
+	for _, t := range tags {
+		if except[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// nukeTargets lists the resources of the project to delete, in two waves:
+// devices first, then what the devices may still hold on to. Locked
+// resources and those tagged with a tag of except are skipped.
+func nukeTargets(ctx context.Context, projectID string, except map[string]bool, c *Client) ([][]nukeTarget, error) {
+	devices, err := listDevices(ctx, projectID, c)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := listIPReservations(ctx, projectID, c)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := listVolumes(ctx, projectID, c)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := listSSHKeys(ctx, projectID, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var first, second []nukeTarget
+	for _, d := range devices {
+		d := d
+		switch {
+		case d.Locked:
+			logger.Warn("Skipping locked device", "device", d.ID, "hostname", d.Hostname)
+		case hasAnyTag(d.Tags, except):
+			logger.Info("Skipping tagged device", "device", d.ID, "hostname", d.Hostname)
+		default:
+			first = append(first, nukeTarget{"device", d.ID, d.Hostname, func(ctx context.Context) error {
+				_, err := DeleteDevice(ctx, c, d.ID)
+				return err
+			}})
+		}
+	}
+	for _, ip := range ips {
+		ip := ip
+		// the addresses the API assigns to devices go with them
+		if ip.Management || (ip.AddressFamily == 6 && !ip.GlobalIP) {
+			continue
+		}
+		if hasAnyTag(ip.Tags, except) {
+			logger.Info("Skipping tagged IP reservation", "id", ip.ID, "block", fmt.Sprintf("%s/%d", ip.Network, ip.CIDR))
+			continue
+		}
+		second = append(second, nukeTarget{"ip_reservation", ip.ID, fmt.Sprintf("%s/%d", ip.Network, ip.CIDR), func(ctx context.Context) error {
+			return releaseIPReservation(ctx, ip.ID, c)
+		}})
+	}
+	for _, v := range volumes {
+		v := v
+		if v.Locked {
+			logger.Warn("Skipping locked volume", "volume", v.ID, "name", v.Name)
+			continue
+		}
+		second = append(second, nukeTarget{"volume", v.ID, v.Name, func(ctx context.Context) error {
+			for _, a := range v.Attachments {
+				if err := detachVolume(ctx, a.AttachmentID(), c); err != nil && !isNotFound(err) {
+					return err
+				}
+			}
+			return deleteVolume(ctx, v.ID, c)
+		}})
+	}
+	for _, k := range keys {
+		k := k
+		second = append(second, nukeTarget{"ssh_key", k.ID, k.Label, func(ctx context.Context) error {
+			return deleteSSHKey(ctx, k.ID, c)
+		}})
+	}
+	return [][]nukeTarget{first, second}, nil
+}
+
+// isNotFound reports whether the API answered 404, the resource is gone
+func isNotFound(err error) bool {
+	var errResp *ErrorResponse
+	return errors.As(err, &errResp) && errResp.StatusCode == http.StatusNotFound
+}
+
+// nuke deletes the targets of each wave with at most parallel deletions at
+// a time, reporting every deletion as it finishes. It returns the number of
+// deletions that failed.
+func nuke(ctx context.Context, waves [][]nukeTarget, parallel int) int {
+	total := 0
+	for _, wave := range waves {
+		total += len(wave)
+	}
+	var (
+		mu       sync.Mutex
+		done     int
+		failures int
+	)
+	sem := make(chan struct{}, parallel)
+	for _, wave := range waves {
+		var wg sync.WaitGroup
+		for _, t := range wave {
+			t := t
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				err := t.delete(ctx)
+				if isNotFound(err) {
+					err = nil
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				done++
+				progress := fmt.Sprintf("[%d/%d] ", done, total)
+				if err != nil {
+					failures++
+					logger.Error(progress+"Deleting "+strings.Replace(t.Type, "_", " ", -1)+" failed", "id", t.ID, "name", t.Name, "error", err)
+					return
+				}
+				logger.Info(progress+"Deleted "+strings.Replace(t.Type, "_", " ", -1), "id", t.ID, "name", t.Name)
+			}()
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return failures
+}
+
+func runProjectNuke(ctx context.Context, args []string) error {
+	fs := newFlagSet("project nuke")
+	exceptTags := fs.String("except-tag", "", "Comma separated tags of devices and IP reservations to keep, e.g. keep")
+	parallel := fs.Int("parallel", 10, "How many resources to delete at a time")
+	yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo project nuke [flags] <project-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if *parallel < 1 {
+		return usageErrorf("--parallel must be at least 1")
+	}
+	except := map[string]bool{}
+	for _, t := range strings.Split(*exceptTags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			except[t] = true
+		}
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	p, err := getProject(ctx, fs.Arg(0), client)
+	if err != nil {
+		return err
+	}
+	waves, err := nukeTargets(ctx, p.ID, except, client)
+	if err != nil {
+		return err
+	}
+	var targets []nukeTarget
+	for _, wave := range waves {
+		targets = append(targets, wave...)
+	}
+
+	if jsonOutput() {
+		if targets == nil {
+			targets = []nukeTarget{}
+		}
+		prettyPrint(targets)
+	} else if len(targets) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TYPE\tID\tNAME")
+		for _, t := range targets {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.Type, t.ID, t.Name)
+		}
+		w.Flush()
+	}
+	if len(targets) == 0 {
+		logger.Info("Nothing to delete in project " + p.Name)
+		return nil
+	}
+	if dryRun {
+		return nil
+	}
+
+	if !*yes {
+		ok, err := confirm(fmt.Sprintf("Delete these %d resources of project %s (%s)?", len(targets), p.Name, p.ID))
+		if err != nil {
+			return err
+		}
+		if ok {
+			fmt.Fprintf(os.Stderr, "This cannot be undone. Type the name of the project to confirm: ")
+			line, _ := promptReader.ReadString('\n')
+			ok = strings.TrimSpace(line) == p.Name
+		}
+		if !ok {
+			logger.Info("Nothing deleted")
+			return nil
+		}
+	}
+
+	if failures := nuke(ctx, waves, *parallel); failures > 0 {
+		return &statusError{exitPartial, fmt.Errorf("%d of %d resources of project %s could not be deleted, run project nuke again once they are idle", failures, len(targets), p.Name)}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	logger.Info(fmt.Sprintf("Deleted %d resources of project %s", len(targets), p.Name))
+	return nil
+}
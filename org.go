@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "org list",
+		usage: "List the organizations the token has access to",
+		run:   runOrgList,
+	})
+	registerCommand(&command{
+		name:  "org get",
+		usage: "Show an organization",
+		run:   runOrgGet,
+	})
+	registerCommand(&command{
+		name:  "org invitations",
+		usage: "List the pending invitations of an organization",
+		run:   runOrgInvitations,
+	})
+	registerCommand(&command{
+		name:  "org members",
+		usage: "List the members of an organization and their roles",
+		run:   runOrgMembers,
+	})
+	registerCommand(&command{
+		name:  "project list",
+		usage: "List the projects the token has access to, or those of an organization",
+		run:   runProjectList,
+	})
+	registerCommand(&command{
+		name:  "project delete",
+		usage: "Delete a project, after confirming what it still holds",
+		run:   runProjectDelete,
+	})
+}
+
+// Organization owns projects and bills them
+type Organization struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Website     string        `json:"website,omitempty"`
+	Created     string        `json:"created_at,omitempty"`
+	Projects    []interface{} `json:"projects,omitempty"`
+}
+
+// Project groups devices and other resources of an organization
+type Project struct {
+	ID           string      `json:"id"`
+	Name         string      `json:"name"`
+	Created      string      `json:"created_at,omitempty"`
+	Organization interface{} `json:"organization,omitempty"`
+}
+
+// OrganizationID returns the ID of the organization owning the project
+func (p *Project) OrganizationID() string {
+	if id := attrString(p.Organization, "id"); id != "" {
+		return id
+	}
+	// references only carry the URL of the organization
+	href := attrString(p.Organization, "href")
+	return href[strings.LastIndex(href, "/")+1:]
+}
+
+// Invitation invites an email address to join an organization
+type Invitation struct {
+	ID       string        `json:"id"`
+	Invitee  string        `json:"invitee"`
+	Roles    []string      `json:"roles"`
+	Projects []interface{} `json:"projects,omitempty"`
+	Created  string        `json:"created_at,omitempty"`
+}
+
+// Member is a user of an organization
+type Member struct {
+	ID       string        `json:"id"`
+	Roles    []string      `json:"roles"`
+	User     interface{}   `json:"user"`
+	Projects []interface{} `json:"projects,omitempty"`
+}
+
+// listOrganizations returns the organizations of the token, following
+// pagination
+func listOrganizations(ctx context.Context, c *Client) ([]Organization, error) {
+	var orgs []Organization
+	for page := 1; ; page++ {
+		list := new(struct {
+			Organizations []Organization `json:"organizations"`
+			Meta          struct {
+				LastPage int `json:"last_page"`
+			} `json:"meta"`
+		})
+		uri := fmt.Sprintf("organizations?page=%d&per_page=100", page)
+		if err := c.DoRequest(ctx, uri, "GET", nil, list, nil); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, list.Organizations...)
+		if page >= list.Meta.LastPage {
+			return orgs, nil
+		}
+	}
+}
+
+// getOrganization returns an organization
+func getOrganization(ctx context.Context, orgID string, c *Client) (*Organization, error) {
+	org := new(Organization)
+	if err := c.DoRequest(ctx, "organizations/"+orgID, "GET", nil, org, nil); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// getProject returns the project
+func getProject(ctx context.Context, id string, c *Client) (*Project, error) {
+	p := new(Project)
+	if err := c.DoRequest(ctx, "projects/"+id, "GET", nil, p, nil); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// listProjects returns the projects of the organization, or all projects of
+// the token when orgID is empty, following pagination
+func listProjects(ctx context.Context, orgID string, c *Client) ([]Project, error) {
+	path := "projects"
+	if orgID != "" {
+		path = "organizations/" + orgID + "/projects"
+	}
+	var projects []Project
+	for page := 1; ; page++ {
+		list := new(struct {
+			Projects []Project `json:"projects"`
+			Meta     struct {
+				LastPage int `json:"last_page"`
+			} `json:"meta"`
+		})
+		uri := fmt.Sprintf("%s?page=%d&per_page=100", path, page)
+		if err := c.DoRequest(ctx, uri, "GET", nil, list, nil); err != nil {
+			return nil, err
+		}
+		projects = append(projects, list.Projects...)
+		if page >= list.Meta.LastPage {
+			return projects, nil
+		}
+	}
+}
+
+// listInvitations returns the pending invitations of the organization
+func listInvitations(ctx context.Context, orgID string, c *Client) ([]Invitation, error) {
+	list := new(struct {
+		Invitations []Invitation `json:"invitations"`
+	})
+	if err := c.DoRequest(ctx, "organizations/"+orgID+"/invitations", "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.Invitations, nil
+}
+
+// listMembers returns the members of the organization
+func listMembers(ctx context.Context, orgID string, c *Client) ([]Member, error) {
+	list := new(struct {
+		Members []Member `json:"members"`
+	})
+	if err := c.DoRequest(ctx, "organizations/"+orgID+"/members", "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.Members, nil
+}
+
+// orgArg parses the flags of an org command taking the organization ID as
+// its only argument
+func orgArg(name string, args []string) (string, error) {
+	fs := newFlagSet(name)
+	addCacheFlags(fs)
+	fs.Usage = func() {
+		fmt.Printf("Usage: packet-go-demo %s [flags] <organization-id>\n", name)
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return "", err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return "", exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return "", err
+	}
+	return fs.Arg(0), nil
+}
+
+func runOrgList(ctx context.Context, args []string) error {
+	fs := newFlagSet("org list")
+	addCacheFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	orgs, err := listOrganizations(ctx, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if orgs == nil {
+			orgs = []Organization{}
+		}
+		prettyPrint(orgs)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPROJECTS")
+	for _, o := range orgs {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", o.ID, o.Name, len(o.Projects))
+	}
+	return w.Flush()
+}
+
+func runOrgGet(ctx context.Context, args []string) error {
+	orgID, err := orgArg("org get", args)
+	if err != nil {
+		return err
+	}
+	org, err := getOrganization(ctx, orgID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(org)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\t%s\n", org.ID)
+	fmt.Fprintf(w, "NAME\t%s\n", org.Name)
+	if org.Description != "" {
+		fmt.Fprintf(w, "DESCRIPTION\t%s\n", org.Description)
+	}
+	if org.Website != "" {
+		fmt.Fprintf(w, "WEBSITE\t%s\n", org.Website)
+	}
+	fmt.Fprintf(w, "CREATED\t%s\n", org.Created)
+	fmt.Fprintf(w, "PROJECTS\t%d\n", len(org.Projects))
+	return w.Flush()
+}
+
+func runOrgInvitations(ctx context.Context, args []string) error {
+	orgID, err := orgArg("org invitations", args)
+	if err != nil {
+		return err
+	}
+	invitations, err := listInvitations(ctx, orgID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if invitations == nil {
+			invitations = []Invitation{}
+		}
+		prettyPrint(invitations)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tINVITEE\tROLES\tPROJECTS\tCREATED")
+	for _, i := range invitations {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", i.ID, i.Invitee, strings.Join(i.Roles, ","), len(i.Projects), i.Created)
+	}
+	return w.Flush()
+}
+
+func runOrgMembers(ctx context.Context, args []string) error {
+	orgID, err := orgArg("org members", args)
+	if err != nil {
+		return err
+	}
+	members, err := listMembers(ctx, orgID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if members == nil {
+			members = []Member{}
+		}
+		prettyPrint(members)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tROLES\tPROJECTS")
+	for _, m := range members {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", m.ID, attrString(m.User, "email"), strings.Join(m.Roles, ","), len(m.Projects))
+	}
+	return w.Flush()
+}
+
+func runProjectList(ctx context.Context, args []string) error {
+	fs := newFlagSet("project list")
+	addCacheFlags(fs)
+	orgID := fs.String("org", "", "Only list the projects of this organization")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	projects, err := listProjects(ctx, *orgID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if projects == nil {
+			projects = []Project{}
+		}
+		prettyPrint(projects)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tORGANIZATION\tCREATED")
+	for _, p := range projects {
+		current := ""
+		if p.ID == projectID {
+			current = " *"
+		}
+		fmt.Fprintf(w, "%s\t%s%s\t%s\t%s\n", p.ID, p.Name, current, p.OrganizationID(), p.Created)
+	}
+	return w.Flush()
+}
+
+func runProjectDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("project delete")
+	yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo project delete [flags] <project-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	p, err := getProject(ctx, fs.Arg(0), client)
+	if err != nil {
+		return err
+	}
+	devices, err := listDevices(ctx, p.ID, client)
+	if err != nil {
+		return err
+	}
+	if !*yes && !dryRun {
+		fmt.Printf("Project %s (%s) of organization %s\n", p.Name, p.ID, p.OrganizationID())
+		if len(devices) > 0 {
+			fmt.Printf("It still has %d device(s), the API refuses to delete projects with devices:\n", len(devices))
+			printDeviceSummary(devices, time.Now())
+		}
+		ok, err := confirm(fmt.Sprintf("Delete project %s?", p.Name))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			logger.Info("Nothing deleted")
+			return nil
+		}
+	}
+	if err := client.DoRequest(ctx, "projects/"+p.ID, "DELETE", nil, nil, nil); err != nil {
+		return err
+	}
+	logger.Info("Deleted project", "project", p.ID, "name", p.Name)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import "github.com/nurfet-becirevic/packet-go-demo/packet"
+
+// The API client and types live in the packet package, for programs built
+// on them. The commands use them under their short names.
+
+type (
+	Client       = packet.Client
+	ClientOption = packet.ClientOption
+	APIFlavor    = packet.APIFlavor
+	Logger       = packet.Logger
+
+	ErrorResponse         = packet.ErrorResponse
+	TokenError            = packet.TokenError
+	Deprecation           = packet.Deprecation
+	DeprecationError      = packet.DeprecationError
+	ResponseTooLargeError = packet.ResponseTooLargeError
+	ProvisionTimeoutError = packet.ProvisionTimeoutError
+
+	Token              = packet.Token
+	TokenSource        = packet.TokenSource
+	TokenSourceFunc    = packet.TokenSourceFunc
+	RequestTokenSource = packet.RequestTokenSource
+
+	RoundTripFunc = packet.RoundTripFunc
+	Middleware    = packet.Middleware
+
+	DeviceService = packet.DeviceService
+	IPService     = packet.IPService
+	VLANService   = packet.VLANService
+	VolumeService = packet.VolumeService
+
+	GetOptions            = packet.GetOptions
+	Device                = packet.Device
+	DeviceRequest         = packet.DeviceRequest
+	DeviceUpdateRequest   = packet.DeviceUpdateRequest
+	DeviceState           = packet.DeviceState
+	BillingCycle          = packet.BillingCycle
+	NetworkType           = packet.NetworkType
+	Port                  = packet.Port
+	CPR                   = packet.CPR
+	CPRDisk               = packet.CPRDisk
+	CPRPartition          = packet.CPRPartition
+	CPRRAID               = packet.CPRRAID
+	CPRFilesystem         = packet.CPRFilesystem
+	CreateDeviceResult    = packet.CreateDeviceResult
+	DeleteDeviceResult    = packet.DeleteDeviceResult
+	Duration              = packet.Duration
+	IPReservation         = packet.IPReservation
+	IPReservationRequest  = packet.IPReservationRequest
+	IPAssignment          = packet.IPAssignment
+	VirtualNetwork        = packet.VirtualNetwork
+	VirtualNetworkRequest = packet.VirtualNetworkRequest
+	Volume                = packet.Volume
+	VolumeAttachment      = packet.VolumeAttachment
+	VolumeRequest         = packet.VolumeRequest
+
+	HostnameGenerator = packet.HostnameGenerator
+	HostnameOptions   = packet.HostnameOptions
+)
+
+const (
+	FlavorPacket       = packet.FlavorPacket
+	FlavorEquinixMetal = packet.FlavorEquinixMetal
+	EquinixMetalURL    = packet.EquinixMetalURL
+
+	DefaultTimeout          = packet.DefaultTimeout
+	DefaultMaxResponseSize  = packet.DefaultMaxResponseSize
+	DefaultUserAgent        = packet.DefaultUserAgent
+	DefaultProvisionTimeout = packet.DefaultProvisionTimeout
+
+	BillingHourly  = packet.BillingHourly
+	BillingDaily   = packet.BillingDaily
+	BillingMonthly = packet.BillingMonthly
+	BillingYearly  = packet.BillingYearly
+
+	StateQueued         = packet.StateQueued
+	StateProvisioning   = packet.StateProvisioning
+	StateActive         = packet.StateActive
+	StateInactive       = packet.StateInactive
+	StateFailed         = packet.StateFailed
+	StateReinstalling   = packet.StateReinstalling
+	StateRescuing       = packet.StateRescuing
+	StatePoweringOn     = packet.StatePoweringOn
+	StatePoweringOff    = packet.StatePoweringOff
+	StateDeprovisioning = packet.StateDeprovisioning
+
+	NetworkLayer3           = packet.NetworkLayer3
+	NetworkHybrid           = packet.NetworkHybrid
+	NetworkLayer2Bonded     = packet.NetworkLayer2Bonded
+	NetworkLayer2Individual = packet.NetworkLayer2Individual
+)
+
+var (
+	ErrDryRun = packet.ErrDryRun
+
+	DeviceGetOptions     = packet.DeviceGetOptions
+	DeviceSummaryOptions = packet.DeviceSummaryOptions
+
+	NewClient                = packet.NewClient
+	NewClientWithTokenSource = packet.NewClientWithTokenSource
+	StaticTokenSource        = packet.StaticTokenSource
+	ReuseTokenSource         = packet.ReuseTokenSource
+	CommandTokenSource       = packet.CommandTokenSource
+	WithHTTPClient           = packet.WithHTTPClient
+	WithTimeout              = packet.WithTimeout
+	WithProxy                = packet.WithProxy
+	WithTLSConfig            = packet.WithTLSConfig
+	WithUserAgent            = packet.WithUserAgent
+	WithMaxResponseSize      = packet.WithMaxResponseSize
+	WithCorrelationID        = packet.WithCorrelationID
+	ReadOnly                 = packet.ReadOnly
+
+	NewHostnameGenerator = packet.NewHostnameGenerator
+	HostnameStyles       = packet.HostnameStyles
+	RandomLetters        = packet.RandomLetters
+	Petname              = packet.Petname
+)
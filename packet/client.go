@@ -0,0 +1,514 @@
+// Package packet is a client of the Packet API and of its successor, the
+// Equinix Metal API. The services of a Client manage devices, IP blocks,
+// VLANs and volumes; the mocks of the services stand in for them in tests
+// of code built on top.
+package packet
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIFlavor is the generation of the API served at a base URL
+type APIFlavor int
+
+const (
+	// FlavorPacket is the original api.packet.net API
+	FlavorPacket APIFlavor = iota
+	// FlavorEquinixMetal is the Equinix Metal API, which adds metros
+	FlavorEquinixMetal
+)
+
+// EquinixMetalURL is the base URL of the Equinix Metal API
+const EquinixMetalURL = "https://api.equinix.com/metal/v1/"
+
+func (f APIFlavor) String() string {
+	if f == FlavorEquinixMetal {
+		return "Equinix Metal"
+	}
+	return "Packet"
+}
+
+// detectFlavor tells the API flavor from its base URL: equinix.com hosts
+// and /metal/ paths are Equinix Metal, anything else is treated as Packet
+func detectFlavor(apiURL string) APIFlavor {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return FlavorPacket
+	}
+	if strings.HasSuffix(u.Hostname(), "equinix.com") || strings.Contains(u.Path, "/metal/") {
+		return FlavorEquinixMetal
+	}
+	return FlavorPacket
+}
+
+// ErrDryRun is returned for requests that were printed instead of sent
+var ErrDryRun = errors.New("dry run, request was not sent")
+
+const (
+	// DefaultTimeout limits how long a single API request may take
+	DefaultTimeout = 60 * time.Second
+	// DefaultMaxResponseSize limits the size of a response body DoRequest
+	// reads, streamed responses are not limited
+	DefaultMaxResponseSize = 32 << 20
+	// DefaultUserAgent identifies the requests of the tool
+	DefaultUserAgent = "packet-go-demo"
+)
+
+// Client is HTTP client
+type Client struct {
+	baseURL   string
+	tokens    TokenSource
+	flavor    APIFlavor
+	dryRun    bool
+	onDryRun  func(method, url string, request interface{})
+	otp       string
+	logger    Logger
+	userAgent string
+	client    *http.Client
+	// maxResponseSize is the largest body DoRequest reads, 0 for no limit
+	maxResponseSize int64
+	// correlationID is sent with every request to tie them to a run
+	correlationID string
+
+	middleware []Middleware
+
+	onDeprecated     func(*Deprecation)
+	failOnDeprecated bool
+	deprecationsMu   sync.Mutex
+	deprecations     map[string]bool
+}
+
+// ClientOption customizes a Client created by NewClient
+type ClientOption func(*Client)
+
+// WithHTTPClient sends requests with hc instead of a client with
+// DefaultTimeout. Options applied after it work on a copy of hc.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.client = hc
+	}
+}
+
+// WithTimeout limits how long a single request may take, 0 means no limit
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		hc := *c.client
+		hc.Timeout = d
+		c.client = &hc
+	}
+}
+
+// WithProxy sends requests through the proxy instead of the one given by
+// the HTTP_PROXY and HTTPS_PROXY environment variables
+func WithProxy(proxy *url.URL) ClientOption {
+	return func(c *Client) {
+		c.transport().Proxy = http.ProxyURL(proxy)
+	}
+}
+
+// WithTLSConfig sets the TLS configuration, e.g. to trust a private CA or
+// to pin the API certificate
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.transport().TLSClientConfig = config
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request
+func WithUserAgent(ua string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithMaxResponseSize fails responses whose body is larger than n bytes
+// instead of DefaultMaxResponseSize, 0 means no limit
+func WithMaxResponseSize(n int64) ClientOption {
+	return func(c *Client) {
+		c.maxResponseSize = n
+	}
+}
+
+// WithCorrelationID sends id in the X-Correlation-ID header of every
+// request and adds it to the errors of failed provisions, so that a run can
+// be found in the logs of the caller and of the API
+func WithCorrelationID(id string) ClientOption {
+	return func(c *Client) {
+		c.correlationID = id
+	}
+}
+
+// NewClient creates a Client instance
+func NewClient(token, apiURL string, opts ...ClientOption) *Client {
+	return newClient(StaticTokenSource(token), apiURL, opts)
+}
+
+// NewClientWithTokenSource creates a Client that fetches tokens lazily
+// from src, reusing each one until it expires or the API rejects it. A
+// RequestTokenSource is asked for the token of every request instead.
+func NewClientWithTokenSource(src TokenSource, apiURL string, opts ...ClientOption) *Client {
+	if _, ok := src.(RequestTokenSource); !ok {
+		src = ReuseTokenSource(src)
+	}
+	return newClient(src, apiURL, opts)
+}
+
+func newClient(tokens TokenSource, apiURL string, opts []ClientOption) *Client {
+	c := &Client{
+		tokens:    tokens,
+		baseURL:   apiURL,
+		flavor:    detectFlavor(apiURL),
+		userAgent: DefaultUserAgent,
+		client:    &http.Client{Timeout: DefaultTimeout},
+
+		maxResponseSize: DefaultMaxResponseSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// transport returns a copy of the HTTP transport for an option to change.
+// Round trippers other than *http.Transport are replaced by a copy of the
+// default transport.
+func (c *Client) transport() *http.Transport {
+	t, ok := c.client.Transport.(*http.Transport)
+	if !ok {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	hc := *c.client
+	hc.Transport = t
+	c.client = &hc
+	return t
+}
+
+// SetDryRun makes the client skip requests that change resources and
+// return ErrDryRun for them, reporting them to the OnDryRun hook or else
+// the logger. Read requests are still sent.
+func (c *Client) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
+// OnDryRun calls f with the requests a dry run does not send, e.g. to
+// print them
+func (c *Client) OnDryRun(f func(method, url string, request interface{})) {
+	c.onDryRun = f
+}
+
+// SetOTP sends the one-time password of a two-factor device with the
+// requests that change resources, which organizations enforcing two-factor
+// authentication require for destructive actions
+func (c *Client) SetOTP(otp string) {
+	c.otp = otp
+}
+
+// SetLogger traces every request and response of the client to l, with
+// credentials redacted. A nil logger turns tracing off.
+func (c *Client) SetLogger(l Logger) {
+	c.logger = l
+}
+
+// OnDeprecated calls f the first time a response announces that its
+// endpoint is deprecated
+func (c *Client) OnDeprecated(f func(*Deprecation)) {
+	c.onDeprecated = f
+}
+
+// SetFailOnDeprecated makes requests to deprecated endpoints fail with a
+// *DeprecationError, so that CI catches them before the endpoints are gone
+func (c *Client) SetFailOnDeprecated(fail bool) {
+	c.failOnDeprecated = fail
+}
+
+// Flavor returns the API flavor the client talks to
+func (c *Client) Flavor() APIFlavor {
+	return c.flavor
+}
+
+// BaseURL returns the base URL of the API the client talks to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// ErrorResponse is returned when the API responds with a non-2xx status
+type ErrorResponse struct {
+	StatusCode int      `json:"-"`
+	Errors     []string `json:"errors"`
+	// RequestID is the X-Request-Id of the response, the reference support
+	// asks for
+	RequestID string `json:"-"`
+}
+
+func (e *ErrorResponse) Error() string {
+	msg := fmt.Sprintf("API request failed with status %d", e.StatusCode)
+	if len(e.Errors) > 0 {
+		msg += ": " + strings.Join(e.Errors, ", ")
+	}
+	if e.RequestID != "" {
+		msg += " (request ID " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// OTPRequired reports whether the API refused an action for lack of a
+// two-factor code
+func (e *ErrorResponse) OTPRequired() bool {
+	for _, msg := range e.Errors {
+		msg = strings.ToLower(msg)
+		if strings.Contains(msg, "otp") || strings.Contains(msg, "two factor") || strings.Contains(msg, "two-factor") {
+			return true
+		}
+	}
+	return false
+}
+
+// NoCapacity reports whether the API refused a device for lack of hardware,
+// which it only tells in the error messages
+func (e *ErrorResponse) NoCapacity() bool {
+	for _, msg := range e.Errors {
+		msg = strings.ToLower(msg)
+		for _, hint := range []string{"capacity", "not enough", "no provisionable", "not available"} {
+			if strings.Contains(msg, hint) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TokenError is returned when the token source of the client fails, before
+// the request is sent
+type TokenError struct {
+	Err error
+}
+
+func (e *TokenError) Error() string {
+	return "getting API token: " + e.Err.Error()
+}
+
+func (e *TokenError) Unwrap() error {
+	return e.Err
+}
+
+// reference returns the correlation ID of the client to add to errors, if
+// one is set
+func (c *Client) reference() string {
+	if c.correlationID == "" {
+		return ""
+	}
+	return " (correlation ID " + c.correlationID + ")"
+}
+
+// RequestID returns the ID the API gave a response
+func RequestID(resp *http.Response) string {
+	return resp.Header.Get("X-Request-Id")
+}
+
+// attemptKey is the context key of the attempt number of a request
+type attemptKey struct{}
+
+// Attempt returns how many times the request was sent before, 0 for the
+// first attempt, e.g. for middleware counting retries
+func Attempt(r *http.Request) int {
+	n, _ := r.Context().Value(attemptKey{}).(int)
+	return n
+}
+
+// readOnlyKey is the context key marking requests that change nothing
+type readOnlyKey struct{}
+
+// ReadOnly marks the requests made with ctx as changing nothing although
+// their method is not GET, e.g. POST queries of capacity, so that a dry run
+// still sends them
+func ReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+func isReadOnly(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyKey{}).(bool)
+	return readOnly
+}
+
+// DoRequest performs HTTP request
+func (c *Client) DoRequest(ctx context.Context, url string, method string, request interface{}, response interface{}, raw *string) (err error) {
+	var data []byte
+
+	if request != nil {
+		data, err = json.Marshal(request)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.dryRun && method != "GET" && !isReadOnly(ctx) {
+		if c.onDryRun != nil {
+			c.onDryRun(method, c.baseURL+url, request)
+		} else if c.logger != nil {
+			c.logger.Printf("--> %s %s not sent, dry run", method, c.baseURL+url)
+			traceBody(c.logger, data)
+		}
+		return ErrDryRun
+	}
+
+	resp, err := c.send(ctx, method, url, data)
+	if err != nil {
+		return err
+	}
+
+	if resp != nil {
+		defer resp.Body.Close()
+		body := io.Reader(&limitedReader{r: resp.Body, left: c.maxResponseSize, limit: c.maxResponseSize, url: url})
+
+		if raw != nil {
+			data, err := ioutil.ReadAll(body)
+			if err != nil {
+				return err
+			}
+			*raw = string(data)
+			body = bytes.NewReader(data)
+		}
+
+		if err := c.checkResponse(method, resp, body); err != nil {
+			return err
+		}
+
+		// actions are accepted without a body, the rest is decoded as it
+		// arrives instead of being read whole first
+		if method != "DELETE" && response != nil {
+			if err = json.NewDecoder(body).Decode(response); err == io.EOF {
+				err = nil
+			}
+		}
+	}
+
+	return err
+}
+
+// checkResponse returns the error of a response with a non-2xx status, read
+// from its body, or the deprecation it announces when failing on them
+func (c *Client) checkResponse(method string, resp *http.Response, body io.Reader) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		errResp := &ErrorResponse{StatusCode: resp.StatusCode, RequestID: RequestID(resp)}
+		// error body is best effort, status code alone is enough to fail
+		json.NewDecoder(body).Decode(errResp)
+		return errResp
+	}
+
+	if d := parseDeprecation(method, resp.Request.URL.Path, resp.Header); d != nil {
+		c.deprecated(d)
+		if c.failOnDeprecated {
+			return &DeprecationError{Deprecation: d}
+		}
+	}
+	return nil
+}
+
+// do sends a request at the end of the middleware chain, tracing it
+func (c *Client) do(r *http.Request) (*http.Response, error) {
+	if c.logger != nil {
+		c.traceRequest(r)
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(r)
+	latency := time.Since(start)
+	if err != nil {
+		if c.logger != nil {
+			c.logger.Printf("<-- %s %s failed: %s (%s)", r.Method, r.URL, err, latency.Round(time.Millisecond))
+		}
+		return nil, err
+	}
+
+	if c.logger != nil {
+		return c.traceResponse(resp, latency)
+	}
+	return resp, nil
+}
+
+// deprecated reports a deprecation once per endpoint, whatever resources
+// the requests were for
+func (c *Client) deprecated(d *Deprecation) {
+	c.deprecationsMu.Lock()
+	key := d.Method + " " + endpointTemplate(d.Endpoint)
+	seen := c.deprecations[key]
+	if c.deprecations == nil {
+		c.deprecations = map[string]bool{}
+	}
+	c.deprecations[key] = true
+	c.deprecationsMu.Unlock()
+
+	if !seen && c.onDeprecated != nil {
+		c.onDeprecated(d)
+	}
+}
+
+// send performs the request with the current token. A rejected token is
+// refreshed and the request sent once more. A rate limited request is sent
+// again with another token of a pool failing over.
+func (c *Client) send(ctx context.Context, method, url string, data []byte) (*http.Response, error) {
+	pool, _ := c.tokens.(RequestTokenSource)
+	for attempt := 0; ; attempt++ {
+		var tok *Token
+		var err error
+		if pool != nil {
+			tok, err = pool.TokenFor(url)
+		} else {
+			tok, err = c.tokens.Token()
+		}
+		if err != nil {
+			return nil, &TokenError{err}
+		}
+
+		var payload io.Reader
+		if data != nil {
+			payload = bytes.NewReader(data)
+		}
+		r, err := http.NewRequestWithContext(context.WithValue(ctx, attemptKey{}, attempt), method, c.baseURL+url, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Header.Add("X-Auth-Token", tok.Value)
+		r.Header.Add("Content-Type", "application/json")
+		if c.otp != "" && method != "GET" {
+			r.Header.Set("X-Auth-OTP", c.otp)
+		}
+		if c.userAgent != "" {
+			r.Header.Set("User-Agent", c.userAgent)
+		}
+		if c.correlationID != "" {
+			r.Header.Set("X-Correlation-ID", c.correlationID)
+		}
+
+		resp, err := c.roundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+
+		reuse, ok := c.tokens.(*reuseTokenSource)
+		if resp.StatusCode == http.StatusUnauthorized && ok && attempt == 0 {
+			resp.Body.Close()
+			reuse.invalidate(tok)
+			continue
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && pool != nil && pool.RateLimited(tok, url, resp) {
+			resp.Body.Close()
+			continue
+		}
+		return resp, nil
+	}
+}
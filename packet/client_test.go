@@ -0,0 +1,362 @@
+package packet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nurfet-becirevic/packet-go-demo/packettest"
+)
+
+// serve starts the fake behind handler, which sees every request first and
+// passes it on to the fake by calling next
+func serve(t *testing.T, f *packettest.FakeAPI, handler func(w http.ResponseWriter, r *http.Request, next http.Handler)) string {
+	t.Helper()
+	h := http.Handler(f)
+	if handler != nil {
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handler(w, r, f) })
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv.URL + "/"
+}
+
+// createDevices creates n devices in project-1 without waiting for them
+func createDevices(t *testing.T, c *Client, n int) []*Device {
+	t.Helper()
+	var devices []*Device
+	for i := 0; i < n; i++ {
+		d := new(Device)
+		req := &DeviceRequest{Hostname: fmt.Sprintf("node-%d", i), Plan: "baremetal_0", Facility: []string{"ewr1"}, OS: "ubuntu_22_04"}
+		if err := c.DoRequest(context.Background(), "projects/project-1/devices", "POST", req, d, nil); err != nil {
+			t.Fatalf("creating device %d: %v", i, err)
+		}
+		devices = append(devices, d)
+	}
+	return devices
+}
+
+func TestWaitForDeviceRetries(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	tests := []struct {
+		name     string
+		failures int
+		wantErr  bool
+	}{
+		{"no failures", 0, false},
+		{"retried", MaxPollRetries, false},
+		{"too many failures", MaxPollRetries + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := packettest.NewFakeAPI()
+			f.ProvisionTime = 0
+			var mu sync.Mutex
+			failures := tt.failures
+			url := serve(t, f, func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+				mu.Lock()
+				fail := r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/devices/") && failures > 0
+				if fail {
+					failures--
+				}
+				mu.Unlock()
+				if fail {
+					http.Error(w, `{"errors":["Injected failure"]}`, http.StatusInternalServerError)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+			c := NewClient("t", url)
+			created := createDevices(t, c, 1)[0]
+
+			d, stats, err := WaitForDevice(context.Background(), c, created.ID, time.Now().Add(time.Minute), time.Minute, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("WaitForDevice() succeeded after %d failed polls", tt.failures)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WaitForDevice() error = %v", err)
+			}
+			if d.State != StateActive {
+				t.Errorf("state = %s, want %s", d.State, StateActive)
+			}
+			if stats.Retries != tt.failures || stats.Polls != tt.failures+1 {
+				t.Errorf("stats = %+v, want %d retries and %d polls", stats, tt.failures, tt.failures+1)
+			}
+		})
+	}
+}
+
+func TestSearchDevicesFollowsPages(t *testing.T) {
+	f := packettest.NewFakeAPI()
+	var pages []string
+	url := serve(t, f, func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		if r.Method == "GET" {
+			pages = append(pages, r.URL.Query().Get("page"))
+		}
+		next.ServeHTTP(w, r)
+	})
+	c := NewClient("t", url)
+	createDevices(t, c, 205)
+
+	devices, err := SearchDevices(context.Background(), c, "project-1", nil, nil)
+	if err != nil {
+		t.Fatalf("SearchDevices() error = %v", err)
+	}
+	if len(devices) != 205 {
+		t.Errorf("got %d devices, want 205", len(devices))
+	}
+	if got := strings.Join(pages, ","); got != "1,2,3" {
+		t.Errorf("pages requested = %s, want 1,2,3", got)
+	}
+	seen := map[string]bool{}
+	for _, d := range devices {
+		if seen[d.ID] {
+			t.Errorf("device %s listed twice", d.ID)
+		}
+		seen[d.ID] = true
+	}
+}
+
+func TestUnauthorizedRefreshesToken(t *testing.T) {
+	f := packettest.NewFakeAPI()
+	f.Token = "fresh"
+	url := serve(t, f, nil)
+
+	tokens := []string{"stale", "fresh"}
+	calls := 0
+	c := NewClientWithTokenSource(TokenSourceFunc(func() (*Token, error) {
+		tok := &Token{Value: tokens[calls]}
+		calls++
+		return tok, nil
+	}), url)
+	var user map[string]interface{}
+	if err := c.DoRequest(context.Background(), "user", "GET", nil, &user, nil); err != nil {
+		t.Fatalf("DoRequest() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("token source called %d times, want 2", calls)
+	}
+
+	// a static token is not refreshed, the 401 is returned
+	c = NewClient("stale", url)
+	err := c.DoRequest(context.Background(), "user", "GET", nil, &user, nil)
+	var errResp *ErrorResponse
+	if !errors.As(err, &errResp) || errResp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("DoRequest() error = %v, want a 401 ErrorResponse", err)
+	}
+}
+
+func TestTokenSourceError(t *testing.T) {
+	c := NewClientWithTokenSource(TokenSourceFunc(func() (*Token, error) {
+		return nil, errors.New("keyring locked")
+	}), serve(t, packettest.NewFakeAPI(), nil))
+	err := c.DoRequest(context.Background(), "user", "GET", nil, nil, nil)
+	var tokErr *TokenError
+	if !errors.As(err, &tokErr) {
+		t.Fatalf("DoRequest() error = %v, want a TokenError", err)
+	}
+}
+
+// failoverPool hands out its tokens in order, moving to the next one when
+// the current one is rate limited
+type failoverPool struct {
+	tokens []string
+	next   int
+}
+
+func (p *failoverPool) Token() (*Token, error) { return p.TokenFor("") }
+
+func (p *failoverPool) TokenFor(path string) (*Token, error) {
+	return &Token{Value: p.tokens[p.next]}, nil
+}
+
+func (p *failoverPool) RateLimited(tok *Token, path string, resp *http.Response) bool {
+	if p.next+1 >= len(p.tokens) {
+		return false
+	}
+	p.next++
+	return true
+}
+
+func TestRateLimited(t *testing.T) {
+	var sent []string
+	url := serve(t, packettest.NewFakeAPI(), func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		tok := r.Header.Get("X-Auth-Token")
+		sent = append(sent, tok)
+		if tok != "spare" {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, `{"errors":["Rate limit exceeded"]}`, http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+
+	tests := []struct {
+		name       string
+		tokens     TokenSource
+		wantStatus int
+		wantSent   string
+	}{
+		{"single token", StaticTokenSource("busy"), http.StatusTooManyRequests, "busy"},
+		{"pool fails over", &failoverPool{tokens: []string{"busy", "spare"}}, 0, "busy,spare"},
+		{"pool exhausted", &failoverPool{tokens: []string{"busy", "other"}}, http.StatusTooManyRequests, "busy,other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent = nil
+			c := NewClientWithTokenSource(tt.tokens, url)
+			err := c.DoRequest(context.Background(), "user", "GET", nil, nil, nil)
+			var errResp *ErrorResponse
+			switch {
+			case tt.wantStatus == 0 && err != nil:
+				t.Fatalf("DoRequest() error = %v", err)
+			case tt.wantStatus != 0 && (!errors.As(err, &errResp) || errResp.StatusCode != tt.wantStatus):
+				t.Fatalf("DoRequest() error = %v, want status %d", err, tt.wantStatus)
+			}
+			if got := strings.Join(sent, ","); got != tt.wantSent {
+				t.Errorf("tokens sent = %s, want %s", got, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	f := packettest.NewFakeAPI()
+	f.OTP = "123456"
+	c := NewClient("t", serve(t, f, nil))
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		request    interface{}
+		wantStatus int
+		wantErrors string
+		wantOTP    bool
+	}{
+		{"not found", "GET", "devices/device-404", nil, http.StatusNotFound, "Not found", false},
+		{"invalid request", "POST", "projects/project-1/devices", &DeviceRequest{Hostname: "web"}, http.StatusUnprocessableEntity,
+			"facility or metro is required, operating_system is required, plan is required", false},
+		{"two-factor code missing", "DELETE", "devices/device-1", nil, http.StatusForbidden, "OTP required for this action", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.DoRequest(ctx, tt.path, tt.method, tt.request, nil, nil)
+			var errResp *ErrorResponse
+			if !errors.As(err, &errResp) {
+				t.Fatalf("DoRequest() error = %v, want an ErrorResponse", err)
+			}
+			if errResp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", errResp.StatusCode, tt.wantStatus)
+			}
+			if got := strings.Join(errResp.Errors, ", "); got != tt.wantErrors {
+				t.Errorf("errors = %q, want %q", got, tt.wantErrors)
+			}
+			if !strings.HasPrefix(errResp.RequestID, "req-") || !strings.Contains(err.Error(), errResp.RequestID) {
+				t.Errorf("error %q does not carry the request ID %q", err, errResp.RequestID)
+			}
+			if errResp.OTPRequired() != tt.wantOTP {
+				t.Errorf("OTPRequired() = %v, want %v", errResp.OTPRequired(), tt.wantOTP)
+			}
+		})
+	}
+}
+
+func TestResponseTooLarge(t *testing.T) {
+	f := packettest.NewFakeAPI()
+	c := NewClient("t", serve(t, f, nil))
+	createDevices(t, c, 3)
+
+	c = NewClient("t", c.BaseURL(), WithMaxResponseSize(100))
+	_, err := listDevices(context.Background(), "project-1", c)
+	var tooLarge *ResponseTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 100 {
+		t.Fatalf("listDevices() error = %v, want a ResponseTooLargeError", err)
+	}
+}
+
+func TestDeprecationReportedOncePerEndpoint(t *testing.T) {
+	f := packettest.NewFakeAPI()
+	url := serve(t, f, func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		if r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/devices/") {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Sunset", "Wed, 01 Jul 2026 00:00:00 GMT")
+		}
+		next.ServeHTTP(w, r)
+	})
+	c := NewClient("t", url)
+	devices := createDevices(t, c, 3)
+
+	var reported []*Deprecation
+	c.OnDeprecated(func(d *Deprecation) { reported = append(reported, d) })
+	for _, d := range devices {
+		if _, err := getDevice(context.Background(), d.ID, c); err != nil {
+			t.Fatalf("getDevice() error = %v", err)
+		}
+	}
+	if _, err := listDevices(context.Background(), "project-1", c); err != nil {
+		t.Fatalf("listDevices() error = %v", err)
+	}
+	if len(reported) != 1 {
+		t.Fatalf("%d deprecations reported, want 1", len(reported))
+	}
+	if reported[0].Sunset.IsZero() {
+		t.Errorf("sunset not parsed: %+v", reported[0])
+	}
+
+	c.SetFailOnDeprecated(true)
+	_, err := getDevice(context.Background(), devices[0].ID, c)
+	var depErr *DeprecationError
+	if !errors.As(err, &depErr) {
+		t.Fatalf("getDevice() error = %v, want a DeprecationError", err)
+	}
+}
+
+func TestDryRunSendsReadOnlyRequests(t *testing.T) {
+	var sent []string
+	url := serve(t, packettest.NewFakeAPI(), func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		sent = append(sent, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{}`))
+	})
+	c := NewClient("t", url)
+	c.SetDryRun(true)
+	var dryRun []string
+	c.OnDryRun(func(method, url string, request interface{}) { dryRun = append(dryRun, method) })
+
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		method  string
+		wantErr error
+		sent    bool
+	}{
+		{"read", ctx, "GET", nil, true},
+		{"change", ctx, "POST", ErrDryRun, false},
+		{"read-only change", ReadOnly(ctx), "POST", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent, dryRun = nil, nil
+			err := c.DoRequest(tt.ctx, "capacity/metros", tt.method, nil, nil, nil)
+			if err != tt.wantErr {
+				t.Fatalf("DoRequest() error = %v, want %v", err, tt.wantErr)
+			}
+			if (len(sent) == 1) != tt.sent || (len(dryRun) == 1) == tt.sent {
+				t.Errorf("sent %v, dry run %v; want sent %v", sent, dryRun, tt.sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package packet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Logger receives the HTTP trace of a client. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// redacted replaces credentials in traces
+const redacted = "(redacted)"
+
+// headers whose values never appear in traces
+var secretHeaders = map[string]bool{
+	"X-Auth-Token":  true,
+	"X-Auth-Otp":    true,
+	"Authorization": true,
+}
+
+// secretFields matches the JSON string fields of bodies that hold
+// credentials, such as the token of a new API key
+var secretFields = regexp.MustCompile(`("(?:token|password|otp|secret|api_key|auth_token|access_token|refresh_token)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// redactBody replaces the values of the credential fields of a JSON body
+func redactBody(body []byte) []byte {
+	return RedactSecrets(body, redacted)
+}
+
+// RedactSecrets replaces the values of the credential fields of a JSON
+// body, such as the token of a new API key, with placeholder, e.g. before
+// the body is stored
+func RedactSecrets(body []byte, placeholder string) []byte {
+	return secretFields.ReplaceAll(body, []byte(`${1}`+strconv.Quote(placeholder)))
+}
+
+func (c *Client) traceRequest(r *http.Request) {
+	c.logger.Printf("--> %s %s", r.Method, r.URL)
+	traceHeaders(c.logger, r.Header)
+	if r.GetBody != nil {
+		if body, err := r.GetBody(); err == nil {
+			data, _ := ioutil.ReadAll(body)
+			traceBody(c.logger, data)
+		}
+	}
+}
+
+// traceResponse logs the response and returns it with the body still
+// readable by the caller
+func (c *Client) traceResponse(resp *http.Response, latency time.Duration) (*http.Response, error) {
+	id := ""
+	if rid := RequestID(resp); rid != "" {
+		id = " request " + rid
+	}
+	c.logger.Printf("<-- %s %s %s (%s)%s", resp.Request.Method, resp.Request.URL, resp.Status, latency.Round(time.Millisecond), id)
+	traceHeaders(c.logger, resp.Header)
+	// the limit of the client applies before the body is buffered
+	url := resp.Request.URL.String()
+	body, err := ioutil.ReadAll(&limitedReader{r: resp.Body, left: c.maxResponseSize, limit: c.maxResponseSize, url: url})
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	traceBody(c.logger, body)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+func traceHeaders(l Logger, h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(h[name], ", ")
+		if secretHeaders[http.CanonicalHeaderKey(name)] {
+			value = redacted
+		}
+		l.Printf("    %s: %s", name, value)
+	}
+}
+
+func traceBody(l Logger, body []byte) {
+	if len(body) > 0 {
+		l.Printf("    %s", bytes.TrimSpace(redactBody(body)))
+	}
+}
@@ -0,0 +1,94 @@
+package packet
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deprecation describes an endpoint the API announced for removal through
+// the Deprecation and Sunset response headers
+type Deprecation struct {
+	Method   string
+	Endpoint string
+	// Since is when the endpoint was deprecated, zero when not announced
+	Since time.Time
+	// Sunset is when the endpoint stops working, zero when not announced
+	Sunset time.Time
+	// Link points to the deprecation notice, if any
+	Link string
+}
+
+func (d *Deprecation) String() string {
+	s := fmt.Sprintf("%s %s is deprecated", d.Method, d.Endpoint)
+	if !d.Sunset.IsZero() {
+		s += " and will be removed on " + d.Sunset.Format("2006-01-02")
+	}
+	if d.Link != "" {
+		s += ", see " + d.Link
+	}
+	return s
+}
+
+// DeprecationError is returned for deprecated endpoints by clients that
+// fail on deprecation
+type DeprecationError struct {
+	Deprecation *Deprecation
+}
+
+func (e *DeprecationError) Error() string {
+	return e.Deprecation.String()
+}
+
+// deprecationLink matches the Link header entries of deprecation notices
+var deprecationLink = regexp.MustCompile(`<([^>]*)>\s*;[^,]*rel="?(deprecation|sunset)"?`)
+
+// resourceID matches the path segments that are resource IDs, UUIDs and
+// numbers
+var resourceID = regexp.MustCompile(`^([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9]+)$`)
+
+// endpointTemplate returns the path with the resource IDs replaced by
+// {id}, e.g. /devices/{id}/ips for the IPs of any device
+func endpointTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if resourceID.MatchString(s) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// parseDeprecation reads the deprecation headers of a response, nil when
+// the endpoint is not deprecated
+func parseDeprecation(method, endpoint string, h http.Header) *Deprecation {
+	dep, sunset := h.Get("Deprecation"), h.Get("Sunset")
+	if (dep == "" || dep == "false") && sunset == "" {
+		return nil
+	}
+
+	d := &Deprecation{Method: method, Endpoint: endpoint}
+	switch {
+	case strings.HasPrefix(dep, "@"):
+		// RFC 9745 structured date, seconds since the epoch
+		if sec, err := strconv.ParseInt(dep[1:], 10, 64); err == nil {
+			d.Since = time.Unix(sec, 0).UTC()
+		}
+	case dep != "" && dep != "true":
+		// earlier drafts used an HTTP date
+		d.Since, _ = http.ParseTime(dep)
+	}
+	if sunset != "" {
+		d.Sunset, _ = http.ParseTime(sunset)
+	}
+	for _, link := range h.Values("Link") {
+		if m := deprecationLink.FindStringSubmatch(link); m != nil {
+			d.Link = m[1]
+			break
+		}
+	}
+	return d
+}
@@ -0,0 +1,421 @@
+package packet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetOptions selects the embedded objects of a response. Include embeds
+// the named objects in full instead of as links to them, Exclude leaves
+// them out of the response.
+type GetOptions struct {
+	Include []string
+	Exclude []string
+}
+
+// Apply adds the options to the query of uri
+func (o *GetOptions) Apply(uri string) string {
+	if o == nil {
+		return uri
+	}
+	q := url.Values{}
+	if len(o.Include) > 0 {
+		q.Set("include", strings.Join(o.Include, ","))
+	}
+	if len(o.Exclude) > 0 {
+		q.Set("exclude", strings.Join(o.Exclude, ","))
+	}
+	if len(q) == 0 {
+		return uri
+	}
+	sep := "?"
+	if strings.Contains(uri, "?") {
+		sep = "&"
+	}
+	return uri + sep + q.Encode()
+}
+
+// DeviceGetOptions embeds what the methods of Device read, so that devices
+// are hydrated the same whichever call fetched them
+var DeviceGetOptions = &GetOptions{Include: []string{"facility", "metro", "plan", "operating_system"}}
+
+// DeviceSummaryOptions also leaves out the objects of a device that
+// summaries of many devices, such as status and metrics, do not need
+var DeviceSummaryOptions = &GetOptions{
+	Include: DeviceGetOptions.Include,
+	Exclude: []string{"network_ports", "volumes", "storage", "customdata"},
+}
+
+// DeviceRequest is used to create a Packet device
+type DeviceRequest struct {
+	Hostname              string          `json:"hostname"`
+	Plan                  string          `json:"plan"`
+	Facility              []string        `json:"facility,omitempty"`
+	Metro                 string          `json:"metro,omitempty"`
+	OS                    string          `json:"operating_system"`
+	BillingCycle          BillingCycle    `json:"billing_cycle"`
+	ProjectID             string          `json:"project_id"`
+	HardwareReservationID string          `json:"hardware_reservation_id,omitempty"`
+	Tags                  []string        `json:"tags,omitempty"`
+	UserData              string          `json:"userdata,omitempty"`
+	IPXEScriptURL         string          `json:"ipxe_script_url,omitempty"`
+	AlwaysPXE             bool            `json:"always_pxe,omitempty"`
+	Storage               *CPR            `json:"storage,omitempty"`
+	CustomData            json.RawMessage `json:"customdata,omitempty"`
+	TerminationTime       *time.Time      `json:"termination_time,omitempty"`
+}
+
+// DeviceUpdateRequest changes a device in place, nil fields are kept
+type DeviceUpdateRequest struct {
+	Tags            *[]string  `json:"tags,omitempty"`
+	TerminationTime *time.Time `json:"termination_time,omitempty"`
+	Locked          *bool      `json:"locked,omitempty"`
+}
+
+// Device represents a Packet device API instance
+type Device struct {
+	ID                  string                 `json:"id"`
+	Hostname            string                 `json:"hostname,omitempty"`
+	State               DeviceState            `json:"state,omitempty"`
+	Created             string                 `json:"created_at,omitempty"`
+	Updated             string                 `json:"updated_at,omitempty"`
+	Locked              bool                   `json:"locked,omitempty"`
+	BillingCycle        BillingCycle           `json:"billing_cycle,omitempty"`
+	Storage             map[string]interface{} `json:"storage,omitempty"`
+	Tags                []string               `json:"tags,omitempty"`
+	Network             interface{}            `json:"ip_addresses"`
+	Volumes             interface{}            `json:"volumes"`
+	OS                  interface{}            `json:"operating_system,omitempty"`
+	Plan                interface{}            `json:"plan,omitempty"`
+	Facility            interface{}            `json:"facility,omitempty"`
+	Metro               interface{}            `json:"metro,omitempty"`
+	Project             interface{}            `json:"project,omitempty"`
+	HardwareReservation interface{}            `json:"hardware_reservation,omitempty"`
+	NetworkPorts        []Port                 `json:"network_ports,omitempty"`
+	CustomData          interface{}            `json:"customdata,omitempty"`
+	UserData            string                 `json:"userdata,omitempty"`
+	TerminationTime     string                 `json:"termination_time,omitempty"`
+	// ProvisioningPercentage is how far provisioning got, while it runs
+	ProvisioningPercentage float64 `json:"provisioning_percentage,omitempty"`
+}
+
+// PlanSlug returns the slug of the device plan
+func (d *Device) PlanSlug() string {
+	return attrString(d.Plan, "slug")
+}
+
+// FacilityCode returns the code of the facility the device is deployed in
+func (d *Device) FacilityCode() string {
+	return attrString(d.Facility, "code")
+}
+
+// OSSlug returns the slug of the device operating system
+func (d *Device) OSSlug() string {
+	return attrString(d.OS, "slug")
+}
+
+// MetroCode returns the code of the metro the device is deployed in,
+// falling back to the facility code for devices without metro
+func (d *Device) MetroCode() string {
+	if code := attrString(d.Metro, "code"); code != "" {
+		return code
+	}
+	if code := attrString(attrValue(d.Facility, "metro"), "code"); code != "" {
+		return code
+	}
+	return d.FacilityCode()
+}
+
+// HourlyPrice returns the on-demand hourly price of the device plan
+func (d *Device) HourlyPrice() float64 {
+	price, _ := attrValue(attrValue(d.Plan, "pricing"), "hour").(float64)
+	return price
+}
+
+// PublicIPv4 returns the first public IPv4 address assigned to the device
+func (d *Device) PublicIPv4() string {
+	addrs, _ := d.Network.([]interface{})
+	for _, a := range addrs {
+		if public, _ := attrValue(a, "public").(bool); public && attrValue(a, "address_family") == float64(4) {
+			return attrString(a, "address")
+		}
+	}
+	return ""
+}
+
+// PublicIPv6 returns the first public IPv6 address assigned to the device
+func (d *Device) PublicIPv6() string {
+	addrs, _ := d.Network.([]interface{})
+	for _, a := range addrs {
+		if public, _ := attrValue(a, "public").(bool); public && attrValue(a, "address_family") == float64(6) {
+			return attrString(a, "address")
+		}
+	}
+	return ""
+}
+
+// PrivateIPv4 returns the first private IPv4 address assigned to the device
+func (d *Device) PrivateIPv4() string {
+	addrs, _ := d.Network.([]interface{})
+	for _, a := range addrs {
+		if public, _ := attrValue(a, "public").(bool); !public && attrValue(a, "address_family") == float64(4) {
+			return attrString(a, "address")
+		}
+	}
+	return ""
+}
+
+// ElasticAddresses returns the addresses assigned to the device from
+// reserved blocks, in CIDR notation, leaving out the management addresses
+// every device gets
+func (d *Device) ElasticAddresses() []string {
+	var addrs []string
+	list, _ := d.Network.([]interface{})
+	for _, a := range list {
+		if management, _ := attrValue(a, "management").(bool); management {
+			continue
+		}
+		addrs = append(addrs, assignedCIDR(a))
+	}
+	return addrs
+}
+
+// Assignments returns the IDs of the IP assignments of the device by
+// address, in CIDR notation
+func (d *Device) Assignments() map[string]string {
+	ids := map[string]string{}
+	list, _ := d.Network.([]interface{})
+	for _, a := range list {
+		ids[assignedCIDR(a)] = attrString(a, "id")
+	}
+	return ids
+}
+
+// assignedCIDR returns the address of an embedded IP assignment in CIDR
+// notation
+func assignedCIDR(a interface{}) string {
+	cidr, _ := attrValue(a, "cidr").(float64)
+	return fmt.Sprintf("%s/%d", attrString(a, "address"), int(cidr))
+}
+
+// attrValue reads an attribute of an embedded API object
+func attrValue(obj interface{}, key string) interface{} {
+	m, _ := obj.(map[string]interface{})
+	return m[key]
+}
+
+// attrString reads a string attribute of an embedded API object, which
+// Device keeps untyped so that the whole object is printed as returned
+func attrString(obj interface{}, key string) string {
+	s, _ := attrValue(obj, key).(string)
+	return s
+}
+
+// deviceList is a single page of the project devices listing
+type deviceList struct {
+	Devices []Device `json:"devices"`
+	Meta    struct {
+		LastPage int `json:"last_page"`
+	} `json:"meta"`
+}
+
+// DefaultProvisionTimeout is how long CreateDevice waits for a device
+const DefaultProvisionTimeout = 25 * time.Minute
+
+// ProvisionTimeoutError is returned when a device is not active within the
+// provisioning timeout. The device exists and is still billed.
+type ProvisionTimeoutError struct {
+	DeviceID      string
+	Timeout       time.Duration
+	CorrelationID string
+}
+
+func (e *ProvisionTimeoutError) Error() string {
+	msg := fmt.Sprintf("device %s is still not active after %s", e.DeviceID, e.Timeout)
+	if e.CorrelationID != "" {
+		msg += " (correlation ID " + e.CorrelationID + ")"
+	}
+	return msg
+}
+
+// DeviceHooks are called by CreateDevice as provisioning goes, e.g. to
+// record the device or show its progress. Nil hooks are skipped.
+type DeviceHooks struct {
+	// Created is called once the API accepted the device, with the device
+	// as returned by the API
+	Created func(res *CreateDeviceResult)
+	// Polled is called with the device after every check of its state
+	Polled func(d *Device)
+}
+
+// CreateDevice creates a device and waits until it is active, at most
+// timeout or without limit when it is 0. When the device was created but
+// did not become active, the result holds the device along with the error,
+// so that the caller can delete it.
+func CreateDevice(ctx context.Context, c *Client, req *DeviceRequest, timeout time.Duration, hooks *DeviceHooks) (*CreateDeviceResult, error) {
+	if hooks == nil {
+		hooks = &DeviceHooks{}
+	}
+	res := &CreateDeviceResult{Requested: time.Now()}
+
+	if req.Metro != "" && c.Flavor() != FlavorEquinixMetal {
+		return nil, fmt.Errorf("deploying to a metro requires the Equinix Metal API at %s", EquinixMetalURL)
+	}
+	if req.Metro == "" && c.Flavor() == FlavorEquinixMetal {
+		res.Warnings = append(res.Warnings, "facilities are deprecated by Equinix Metal, deploy to a metro instead")
+	}
+
+	uri := fmt.Sprintf("projects/%s/devices", req.ProjectID)
+
+	device := new(Device)
+	// raw response might be usefull for troubleshooting
+	rawResponse := new(string)
+
+	if err := c.DoRequest(ctx, uri, "POST", req, device, rawResponse); err != nil {
+		return nil, err
+	}
+	res.CreateTime = Duration(time.Since(res.Requested))
+	res.Device = device
+	if hooks.Created != nil {
+		hooks.Created(res)
+	}
+
+	device, stats, err := WaitForDevice(ctx, c, device.ID, res.Requested.Add(timeout), timeout, hooks.Polled)
+	res.Polls, res.Retries = stats.Polls, stats.Retries
+	if err != nil {
+		return res, err
+	}
+	res.Device = device
+	res.ProvisionTime = Duration(time.Since(res.Requested))
+	return res, nil
+}
+
+// deleteDevice deletes a device
+func deleteDevice(ctx context.Context, c *Client, deviceID string) (*DeleteDeviceResult, error) {
+	start := time.Now()
+	if err := c.DoRequest(ctx, "devices/"+deviceID, "DELETE", nil, nil, nil); err != nil {
+		return nil, err
+	}
+	return &DeleteDeviceResult{DeviceID: deviceID, Duration: Duration(time.Since(start))}, nil
+}
+
+func getDevice(ctx context.Context, deviceID string, c *Client) (*Device, error) {
+	dev := new(Device)
+	if err := c.DoRequest(ctx, DeviceGetOptions.Apply("devices/"+deviceID), "GET", nil, dev, nil); err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
+// listDevices returns all devices of the project, following pagination
+func listDevices(ctx context.Context, projectID string, c *Client) ([]Device, error) {
+	return SearchDevices(ctx, c, projectID, nil, DeviceGetOptions)
+}
+
+// SearchDevices returns the project devices matching the filters of the
+// query, such as tag, facility or search, with the embedded objects of
+// opts. It follows pagination.
+func SearchDevices(ctx context.Context, c *Client, projectID string, query url.Values, opts *GetOptions) ([]Device, error) {
+	var devices []Device
+	for page := 1; ; page++ {
+		list := new(deviceList)
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", "100")
+		uri := opts.Apply(fmt.Sprintf("projects/%s/devices?%s", projectID, q.Encode()))
+		if err := c.DoRequest(ctx, uri, "GET", nil, list, nil); err != nil {
+			return nil, err
+		}
+		devices = append(devices, list.Devices...)
+		if page >= list.Meta.LastPage {
+			return devices, nil
+		}
+	}
+}
+
+// powerDevice powers the device on or off
+func powerDevice(ctx context.Context, deviceID string, on bool, c *Client) error {
+	action := "power_off"
+	if on {
+		action = "power_on"
+	}
+	req := map[string]string{"type": action}
+	return c.DoRequest(ctx, "devices/"+deviceID+"/actions", "POST", req, nil, nil)
+}
+
+// MaxPollRetries is how many polls of a device in a row may fail while
+// WaitForDevice waits for it
+const MaxPollRetries = 3
+
+// pollInterval is how long WaitForDevice waits between polls
+var pollInterval = 5 * time.Second
+
+// PollStats counts the state checks of a device while waiting, Retries the
+// failed ones
+type PollStats struct {
+	Polls   int
+	Retries int
+}
+
+// WaitForDevice polls the device until it is active or the deadline of
+// the timeout passes, retrying polls that fail up to MaxPollRetries times
+// in a row. A zero timeout waits without limit. polled, unless nil, is
+// called with the device after every poll.
+func WaitForDevice(ctx context.Context, c *Client, deviceID string, deadline time.Time, timeout time.Duration, polled func(*Device)) (*Device, PollStats, error) {
+	var stats PollStats
+	failures := 0
+	for {
+		wait := pollInterval
+		if timeout > 0 {
+			left := time.Until(deadline)
+			if left <= 0 {
+				return nil, stats, &ProvisionTimeoutError{DeviceID: deviceID, Timeout: timeout, CorrelationID: c.correlationID}
+			}
+			if left < wait {
+				wait = left
+			}
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, stats, err
+		}
+		stats.Polls++
+		dev, err := getDevice(ctx, deviceID, c)
+		if err != nil {
+			if failures++; failures > MaxPollRetries {
+				return nil, stats, err
+			}
+			stats.Retries++
+			continue
+		}
+		failures = 0
+		if polled != nil {
+			polled(dev)
+		}
+		if dev.State == StateActive {
+			return dev, stats, nil
+		}
+		if dev.State == StateFailed {
+			return nil, stats, fmt.Errorf("device %s failed to provision%s", deviceID, c.reference())
+		}
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
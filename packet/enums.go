@@ -0,0 +1,105 @@
+package packet
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BillingCycle is how often a device or volume is billed. It is a flag
+// value, rejecting unknown cycles when the flags are parsed.
+type BillingCycle string
+
+// billing cycles of the API
+const (
+	BillingHourly  BillingCycle = "hourly"
+	BillingDaily   BillingCycle = "daily"
+	BillingMonthly BillingCycle = "monthly"
+	BillingYearly  BillingCycle = "yearly"
+)
+
+var billingCycles = []string{"hourly", "daily", "monthly", "yearly"}
+
+func (b BillingCycle) String() string {
+	return string(b)
+}
+
+// Set parses a billing cycle from a flag
+func (b *BillingCycle) Set(s string) error {
+	if err := checkEnum("billing cycle", s, billingCycles); err != nil {
+		return err
+	}
+	*b = BillingCycle(s)
+	return nil
+}
+
+// DeviceState is the lifecycle state of a device
+type DeviceState string
+
+// device states of the API
+const (
+	StateQueued         DeviceState = "queued"
+	StateProvisioning   DeviceState = "provisioning"
+	StateActive         DeviceState = "active"
+	StateInactive       DeviceState = "inactive"
+	StateFailed         DeviceState = "failed"
+	StateReinstalling   DeviceState = "reinstalling"
+	StateRescuing       DeviceState = "rescuing"
+	StatePoweringOn     DeviceState = "powering_on"
+	StatePoweringOff    DeviceState = "powering_off"
+	StateDeprovisioning DeviceState = "deprovisioning"
+)
+
+var deviceStates = []string{"queued", "provisioning", "active", "inactive", "failed", "reinstalling",
+	"rescuing", "powering_on", "powering_off", "deprovisioning"}
+
+func (s DeviceState) String() string {
+	return string(s)
+}
+
+// Set parses a device state from a flag
+func (s *DeviceState) Set(v string) error {
+	if err := checkEnum("device state", v, deviceStates); err != nil {
+		return err
+	}
+	*s = DeviceState(v)
+	return nil
+}
+
+// NetworkType is how the ports of a device are set up, as reported on its
+// bond port
+type NetworkType string
+
+// network types of the API
+const (
+	NetworkLayer3           NetworkType = "layer3"
+	NetworkHybrid           NetworkType = "hybrid"
+	NetworkLayer2Bonded     NetworkType = "layer2-bonded"
+	NetworkLayer2Individual NetworkType = "layer2-individual"
+)
+
+// NetworkTypes are the network types of the API
+var NetworkTypes = []string{"layer3", "hybrid", "layer2-bonded", "layer2-individual"}
+
+func (n NetworkType) String() string {
+	return string(n)
+}
+
+// Set parses a network type from a flag or argument
+func (n *NetworkType) Set(s string) error {
+	if err := checkEnum("network type", s, NetworkTypes); err != nil {
+		return err
+	}
+	*n = NetworkType(s)
+	return nil
+}
+
+// checkEnum returns an error listing the allowed values when s is not one
+// of them
+func checkEnum(what, s string, allowed []string) error {
+	for _, a := range allowed {
+		if s == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s %q, use one of %s", what, s, strings.Join(allowed, ", "))
+}
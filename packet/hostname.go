@@ -0,0 +1,173 @@
+package packet
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// HostnameGenerator produces hostnames for new devices
+type HostnameGenerator interface {
+	Generate() (string, error)
+}
+
+// HostnameGeneratorFunc adapts a plain function to HostnameGenerator
+type HostnameGeneratorFunc func() (string, error)
+
+// Generate calls f
+func (f HostnameGeneratorFunc) Generate() (string, error) {
+	return f()
+}
+
+// HostnameOptions configure the generator created for a style
+type HostnameOptions struct {
+	Prefix   string
+	Template string
+}
+
+// HostnameStyle creates a generator from options
+type HostnameStyle func(opts HostnameOptions) (HostnameGenerator, error)
+
+var (
+	hostnameStylesMu sync.RWMutex
+	hostnameStyles   = map[string]HostnameStyle{
+		"random":     newRandomHostnames,
+		"petname":    newPetnameHostnames,
+		"sequential": newSequentialHostnames,
+		"template":   newTemplateHostnames,
+	}
+)
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+// RegisterHostnameStyle makes a custom generator selectable by name,
+// replacing a built-in style of the same name
+func RegisterHostnameStyle(name string, style HostnameStyle) {
+	hostnameStylesMu.Lock()
+	defer hostnameStylesMu.Unlock()
+	hostnameStyles[name] = style
+}
+
+// NewHostnameGenerator creates a generator of the named style
+func NewHostnameGenerator(name string, opts HostnameOptions) (HostnameGenerator, error) {
+	hostnameStylesMu.RLock()
+	style, ok := hostnameStyles[name]
+	hostnameStylesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown hostname style %q, available: %s", name, strings.Join(HostnameStyles(), ", "))
+	}
+	return style(opts)
+}
+
+// HostnameStyles returns the names of the registered styles, sorted
+func HostnameStyles() []string {
+	hostnameStylesMu.RLock()
+	defer hostnameStylesMu.RUnlock()
+	names := make([]string, 0, len(hostnameStyles))
+	for name := range hostnameStyles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RandomLetters returns n random ASCII letters
+func RandomLetters(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letterBytes[rand.Int63()%int64(len(letterBytes))]
+	}
+	return string(b)
+}
+
+func newRandomHostnames(opts HostnameOptions) (HostnameGenerator, error) {
+	return HostnameGeneratorFunc(func() (string, error) {
+		return RandomLetters(15), nil
+	}), nil
+}
+
+var (
+	petAdjectives = []string{
+		"able", "bold", "brave", "bright", "calm", "clever", "cosmic", "crisp", "eager", "fair",
+		"fancy", "fast", "gentle", "happy", "keen", "lively", "lucky", "merry", "mighty", "noble",
+		"proud", "quick", "quiet", "rapid", "sharp", "shiny", "smart", "steady", "sunny", "witty",
+	}
+	petNouns = []string{
+		"badger", "beaver", "bison", "cobra", "condor", "coyote", "falcon", "ferret", "gecko", "heron",
+		"ibis", "jaguar", "koala", "lemur", "lynx", "marmot", "moose", "narwhal", "ocelot", "orca",
+		"otter", "panda", "puffin", "quokka", "raven", "salmon", "tapir", "walrus", "wombat", "yak",
+	}
+)
+
+// Petname returns an adjective and an animal, e.g. brave-otter
+func Petname() string {
+	return petAdjectives[rand.Intn(len(petAdjectives))] + "-" + petNouns[rand.Intn(len(petNouns))]
+}
+
+func newPetnameHostnames(opts HostnameOptions) (HostnameGenerator, error) {
+	return HostnameGeneratorFunc(func() (string, error) {
+		return Petname(), nil
+	}), nil
+}
+
+func newSequentialHostnames(opts HostnameOptions) (HostnameGenerator, error) {
+	if opts.Prefix == "" {
+		return nil, fmt.Errorf("sequential hostnames need a prefix")
+	}
+	var mu sync.Mutex
+	seq := 0
+	return HostnameGeneratorFunc(func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		seq++
+		return fmt.Sprintf("%s-%02d", opts.Prefix, seq), nil
+	}), nil
+}
+
+// hostnameData is available to hostname templates
+type hostnameData struct {
+	Prefix string
+	Seq    int
+	Date   string
+}
+
+func newTemplateHostnames(opts HostnameOptions) (HostnameGenerator, error) {
+	if opts.Template == "" {
+		return nil, fmt.Errorf("template hostnames need a template")
+	}
+	tmpl, err := template.New("hostname").Funcs(template.FuncMap{
+		"random":  RandomLetters,
+		"petname": Petname,
+	}).Parse(opts.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	seq := 0
+	return HostnameGeneratorFunc(func() (string, error) {
+		mu.Lock()
+		seq++
+		data := hostnameData{Prefix: opts.Prefix, Seq: seq, Date: time.Now().Format("20060102")}
+		mu.Unlock()
+
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", err
+		}
+		name := b.String()
+		if name == "" || strings.Trim(name, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-.") != "" {
+			return "", fmt.Errorf("template produced invalid hostname %q", name)
+		}
+		return name, nil
+	}), nil
+}
@@ -0,0 +1,106 @@
+package packet
+
+import (
+	"context"
+	"fmt"
+)
+
+// IPReservation is a block of IP addresses reserved in a project
+type IPReservation struct {
+	ID            string        `json:"id"`
+	Address       string        `json:"address"`
+	Network       string        `json:"network"`
+	CIDR          int           `json:"cidr"`
+	AddressFamily int           `json:"address_family"`
+	Public        bool          `json:"public"`
+	Management    bool          `json:"management"`
+	GlobalIP      bool          `json:"global_ip"`
+	Facility      interface{}   `json:"facility,omitempty"`
+	Metro         interface{}   `json:"metro,omitempty"`
+	Tags          []string      `json:"tags"`
+	Details       string        `json:"details,omitempty"`
+	Assignments   []interface{} `json:"assignments"`
+}
+
+// Type returns the kind of the block as named when reserving it, e.g.
+// public_ipv4
+func (ip *IPReservation) Type() string {
+	switch {
+	case ip.GlobalIP:
+		return "global_ipv4"
+	case ip.AddressFamily == 6:
+		return "public_ipv6"
+	case ip.Public:
+		return "public_ipv4"
+	}
+	return "private_ipv4"
+}
+
+// Location returns the metro of the block, or its facility
+func (ip *IPReservation) Location() string {
+	if code := attrString(ip.Metro, "code"); code != "" {
+		return code
+	}
+	return attrString(ip.Facility, "code")
+}
+
+// IPReservationRequest reserves a block of IP addresses
+type IPReservationRequest struct {
+	Type     string   `json:"type"`
+	Quantity int      `json:"quantity"`
+	Facility string   `json:"facility,omitempty"`
+	Metro    string   `json:"metro,omitempty"`
+	Comments string   `json:"comments,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// IPAssignment is an address of a block assigned to a device
+type IPAssignment struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	Network string `json:"network"`
+	CIDR    int    `json:"cidr"`
+	Public  bool   `json:"public"`
+}
+
+// listIPReservations returns the IP blocks reserved in the project
+func listIPReservations(ctx context.Context, projectID string, c *Client) ([]IPReservation, error) {
+	list := new(struct {
+		IPAddresses []IPReservation `json:"ip_addresses"`
+	})
+	uri := fmt.Sprintf("projects/%s/ips", projectID)
+	if err := c.DoRequest(ctx, uri, "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.IPAddresses, nil
+}
+
+// requestIPReservation reserves a block of IP addresses in the project
+func requestIPReservation(ctx context.Context, projectID string, req *IPReservationRequest, c *Client) (*IPReservation, error) {
+	ip := new(IPReservation)
+	uri := fmt.Sprintf("projects/%s/ips", projectID)
+	if err := c.DoRequest(ctx, uri, "POST", req, ip, nil); err != nil {
+		return nil, err
+	}
+	return ip, nil
+}
+
+// assignIP assigns an address, given in CIDR notation, to the device
+func assignIP(ctx context.Context, deviceID, address string, c *Client) (*IPAssignment, error) {
+	a := new(IPAssignment)
+	req := map[string]string{"address": address}
+	if err := c.DoRequest(ctx, "devices/"+deviceID+"/ips", "POST", req, a, nil); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// releaseIPReservation gives a reserved block back
+func releaseIPReservation(ctx context.Context, reservationID string, c *Client) error {
+	return c.DoRequest(ctx, "ips/"+reservationID, "DELETE", nil, nil, nil)
+}
+
+// unassignIP removes an IP assignment
+func unassignIP(ctx context.Context, assignmentID string, c *Client) error {
+	return c.DoRequest(ctx, "ips/"+assignmentID, "DELETE", nil, nil, nil)
+}
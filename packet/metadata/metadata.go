@@ -0,0 +1,112 @@
+// Package metadata is a client of the metadata service, which tells a
+// device about itself: its identity, network and userdata.
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the metadata service answering devices about themselves,
+// reachable from devices only
+const DefaultURL = "https://metadata.packet.net/"
+
+// Metadata is what the metadata service knows about the device asking
+type Metadata struct {
+	ID       string   `json:"id"`
+	Hostname string   `json:"hostname"`
+	IQN      string   `json:"iqn,omitempty"`
+	Plan     string   `json:"plan"`
+	Class    string   `json:"class,omitempty"`
+	Facility string   `json:"facility"`
+	Metro    string   `json:"metro,omitempty"`
+	Tags     []string `json:"tags"`
+	SSHKeys  []string `json:"ssh_keys,omitempty"`
+	OS       struct {
+		Slug    string `json:"slug"`
+		Distro  string `json:"distro,omitempty"`
+		Version string `json:"version,omitempty"`
+	} `json:"operating_system"`
+	Network    Network     `json:"network"`
+	CustomData interface{} `json:"customdata,omitempty"`
+}
+
+// Network is the network configuration of the device
+type Network struct {
+	Bonding struct {
+		Mode int `json:"mode"`
+	} `json:"bonding"`
+	Interfaces []struct {
+		Name string `json:"name"`
+		MAC  string `json:"mac"`
+		Bond string `json:"bond,omitempty"`
+	} `json:"interfaces"`
+	Addresses []struct {
+		Address       string `json:"address"`
+		AddressFamily int    `json:"address_family"`
+		CIDR          int    `json:"cidr"`
+		Gateway       string `json:"gateway"`
+		Public        bool   `json:"public"`
+		Management    bool   `json:"management"`
+	} `json:"addresses"`
+}
+
+// Client queries the metadata service. It needs no token, the service
+// answers devices by their address.
+type Client struct {
+	BaseURL string
+	client  *http.Client
+}
+
+// NewClient returns a client of the metadata service at baseURL
+func NewClient(baseURL string) *Client {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &Client{BaseURL: baseURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (m *Client) get(ctx context.Context, path string) ([]byte, error) {
+	r, err := http.NewRequestWithContext(ctx, "GET", m.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.client.Do(r)
+	if err != nil {
+		return nil, fmt.Errorf("metadata service not reachable, it only answers devices: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("metadata service %s failed with status %d", path, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// Metadata returns the metadata of the device, along with the raw document
+// which holds more than Metadata decodes
+func (m *Client) Metadata(ctx context.Context) (*Metadata, json.RawMessage, error) {
+	body, err := m.get(ctx, "metadata")
+	if err != nil {
+		return nil, nil, err
+	}
+	md := new(Metadata)
+	if err := json.Unmarshal(body, md); err != nil {
+		return nil, nil, fmt.Errorf("decoding metadata: %s", err)
+	}
+	return md, body, nil
+}
+
+// UserData returns the userdata the device was created with
+func (m *Client) UserData(ctx context.Context) (string, error) {
+	body, err := m.get(ctx, "userdata")
+	return string(body), err
+}
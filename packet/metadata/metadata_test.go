@@ -0,0 +1,82 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const document = `{
+  "id": "8f2b1c4e-5a7d-4e3b-9c61-0d2f8a7b6e15",
+  "hostname": "web",
+  "plan": "c3.small.x86",
+  "facility": "ny5",
+  "metro": "ny",
+  "tags": ["web"],
+  "operating_system": {"slug": "ubuntu_22_04", "distro": "ubuntu", "version": "22.04"},
+  "network": {
+    "bonding": {"mode": 4},
+    "interfaces": [{"name": "eth0", "mac": "0c:c4:7a:00:00:01", "bond": "bond0"}],
+    "addresses": [{"address": "192.0.2.3", "address_family": 4, "cidr": 31, "gateway": "192.0.2.2", "public": true, "management": true}]
+  },
+  "volumes": []
+}`
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata":
+			w.Write([]byte(document))
+		case "/userdata":
+			w.Write([]byte("#!/bin/sh\necho hello\n"))
+		case "/broken/metadata":
+			w.Write([]byte("{"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	// the base URL works with and without its trailing slash
+	for _, base := range []string{srv.URL, srv.URL + "/"} {
+		c := NewClient(base)
+		md, raw, err := c.Metadata(ctx)
+		if err != nil {
+			t.Fatalf("Metadata() error = %v", err)
+		}
+		if md.Hostname != "web" || md.Metro != "ny" || md.OS.Slug != "ubuntu_22_04" || md.Network.Bonding.Mode != 4 {
+			t.Errorf("Metadata() = %+v", md)
+		}
+		if len(md.Network.Addresses) != 1 || md.Network.Addresses[0].Gateway != "192.0.2.2" || md.Network.Interfaces[0].Bond != "bond0" {
+			t.Errorf("network = %+v", md.Network)
+		}
+		if !strings.Contains(string(raw), `"volumes"`) {
+			t.Errorf("raw document lost the fields Metadata does not decode: %s", raw)
+		}
+		userdata, err := c.UserData(ctx)
+		if err != nil || userdata != "#!/bin/sh\necho hello\n" {
+			t.Errorf("UserData() = %q, %v", userdata, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		base string
+		want string
+	}{
+		{"not found", srv.URL + "/missing", "metadata service metadata failed with status 404"},
+		{"not JSON", srv.URL + "/broken", "decoding metadata: unexpected end of JSON input"},
+		{"unreachable", "http://127.0.0.1:1", "metadata service not reachable, it only answers devices"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := NewClient(tt.base).Metadata(ctx)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("Metadata() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
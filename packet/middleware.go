@@ -0,0 +1,26 @@
+package packet
+
+import "net/http"
+
+// RoundTripFunc sends a request and returns its response
+type RoundTripFunc func(*http.Request) (*http.Response, error)
+
+// Middleware wraps the sending of requests, e.g. to add headers, sign
+// requests, audit or measure them. It calls next to pass the request on.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Use adds middleware to the client. The first one added sees requests
+// first and responses last. Requests reach the middleware with the token
+// and the other client headers already set.
+func (c *Client) Use(mw ...Middleware) {
+	c.middleware = append(c.middleware, mw...)
+}
+
+// roundTrip sends the request through the middleware chain
+func (c *Client) roundTrip(r *http.Request) (*http.Response, error) {
+	next := RoundTripFunc(c.do)
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		next = c.middleware[i](next)
+	}
+	return next(r)
+}
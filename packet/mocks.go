@@ -0,0 +1,129 @@
+package packet
+
+import (
+	"context"
+	"time"
+)
+
+// The mocks implement the service interfaces with one function field per
+// method. Tests set the fields for the calls they expect, calling a method
+// whose field is nil panics so that unexpected calls are not missed.
+
+// DeviceServiceMock is a DeviceService calling its function fields
+type DeviceServiceMock struct {
+	ListFunc   func(ctx context.Context, projectID string) ([]Device, error)
+	GetFunc    func(ctx context.Context, deviceID string) (*Device, error)
+	CreateFunc func(ctx context.Context, req *DeviceRequest, timeout time.Duration) (*CreateDeviceResult, error)
+	DeleteFunc func(ctx context.Context, deviceID string) (*DeleteDeviceResult, error)
+	PowerFunc  func(ctx context.Context, deviceID string, on bool) error
+}
+
+func (m *DeviceServiceMock) List(ctx context.Context, projectID string) ([]Device, error) {
+	return m.ListFunc(ctx, projectID)
+}
+
+func (m *DeviceServiceMock) Get(ctx context.Context, deviceID string) (*Device, error) {
+	return m.GetFunc(ctx, deviceID)
+}
+
+func (m *DeviceServiceMock) Create(ctx context.Context, req *DeviceRequest, timeout time.Duration) (*CreateDeviceResult, error) {
+	return m.CreateFunc(ctx, req, timeout)
+}
+
+func (m *DeviceServiceMock) Delete(ctx context.Context, deviceID string) (*DeleteDeviceResult, error) {
+	return m.DeleteFunc(ctx, deviceID)
+}
+
+func (m *DeviceServiceMock) Power(ctx context.Context, deviceID string, on bool) error {
+	return m.PowerFunc(ctx, deviceID, on)
+}
+
+// IPServiceMock is an IPService calling its function fields
+type IPServiceMock struct {
+	ListFunc     func(ctx context.Context, projectID string) ([]IPReservation, error)
+	RequestFunc  func(ctx context.Context, projectID string, req *IPReservationRequest) (*IPReservation, error)
+	ReleaseFunc  func(ctx context.Context, reservationID string) error
+	AssignFunc   func(ctx context.Context, deviceID, address string) (*IPAssignment, error)
+	UnassignFunc func(ctx context.Context, assignmentID string) error
+}
+
+func (m *IPServiceMock) List(ctx context.Context, projectID string) ([]IPReservation, error) {
+	return m.ListFunc(ctx, projectID)
+}
+
+func (m *IPServiceMock) Request(ctx context.Context, projectID string, req *IPReservationRequest) (*IPReservation, error) {
+	return m.RequestFunc(ctx, projectID, req)
+}
+
+func (m *IPServiceMock) Release(ctx context.Context, reservationID string) error {
+	return m.ReleaseFunc(ctx, reservationID)
+}
+
+func (m *IPServiceMock) Assign(ctx context.Context, deviceID, address string) (*IPAssignment, error) {
+	return m.AssignFunc(ctx, deviceID, address)
+}
+
+func (m *IPServiceMock) Unassign(ctx context.Context, assignmentID string) error {
+	return m.UnassignFunc(ctx, assignmentID)
+}
+
+// VLANServiceMock is a VLANService calling its function fields
+type VLANServiceMock struct {
+	ListFunc   func(ctx context.Context, projectID string) ([]VirtualNetwork, error)
+	CreateFunc func(ctx context.Context, projectID string, req *VirtualNetworkRequest) (*VirtualNetwork, error)
+	DeleteFunc func(ctx context.Context, vlanID string) error
+}
+
+func (m *VLANServiceMock) List(ctx context.Context, projectID string) ([]VirtualNetwork, error) {
+	return m.ListFunc(ctx, projectID)
+}
+
+func (m *VLANServiceMock) Create(ctx context.Context, projectID string, req *VirtualNetworkRequest) (*VirtualNetwork, error) {
+	return m.CreateFunc(ctx, projectID, req)
+}
+
+func (m *VLANServiceMock) Delete(ctx context.Context, vlanID string) error {
+	return m.DeleteFunc(ctx, vlanID)
+}
+
+// VolumeServiceMock is a VolumeService calling its function fields
+type VolumeServiceMock struct {
+	ListFunc   func(ctx context.Context, projectID string) ([]Volume, error)
+	GetFunc    func(ctx context.Context, volumeID string) (*Volume, error)
+	CreateFunc func(ctx context.Context, projectID string, req *VolumeRequest) (*Volume, error)
+	DeleteFunc func(ctx context.Context, volumeID string) error
+	AttachFunc func(ctx context.Context, volumeID, deviceID string) (*VolumeAttachment, error)
+	DetachFunc func(ctx context.Context, attachmentID string) error
+}
+
+func (m *VolumeServiceMock) List(ctx context.Context, projectID string) ([]Volume, error) {
+	return m.ListFunc(ctx, projectID)
+}
+
+func (m *VolumeServiceMock) Get(ctx context.Context, volumeID string) (*Volume, error) {
+	return m.GetFunc(ctx, volumeID)
+}
+
+func (m *VolumeServiceMock) Create(ctx context.Context, projectID string, req *VolumeRequest) (*Volume, error) {
+	return m.CreateFunc(ctx, projectID, req)
+}
+
+func (m *VolumeServiceMock) Delete(ctx context.Context, volumeID string) error {
+	return m.DeleteFunc(ctx, volumeID)
+}
+
+func (m *VolumeServiceMock) Attach(ctx context.Context, volumeID, deviceID string) (*VolumeAttachment, error) {
+	return m.AttachFunc(ctx, volumeID, deviceID)
+}
+
+func (m *VolumeServiceMock) Detach(ctx context.Context, attachmentID string) error {
+	return m.DetachFunc(ctx, attachmentID)
+}
+
+// the mocks satisfy the interfaces
+var (
+	_ DeviceService = (*DeviceServiceMock)(nil)
+	_ IPService     = (*IPServiceMock)(nil)
+	_ VLANService   = (*VLANServiceMock)(nil)
+	_ VolumeService = (*VolumeServiceMock)(nil)
+)
@@ -0,0 +1,26 @@
+package packet
+
+// Port is a network port of a device, either a physical one such as eth1
+// or a bond such as bond0
+type Port struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	NetworkType NetworkType `json:"network_type,omitempty"`
+	Data        struct {
+		Bonded bool   `json:"bonded"`
+		MAC    string `json:"mac,omitempty"`
+	} `json:"data"`
+	VirtualNetworks []interface{} `json:"virtual_networks,omitempty"`
+}
+
+// Port returns the port of the device with the given name, nil when it has
+// none
+func (d *Device) Port(name string) *Port {
+	for i := range d.NetworkPorts {
+		if d.NetworkPorts[i].Name == name {
+			return &d.NetworkPorts[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package packet
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Duration is a time.Duration that is marshalled to JSON as text, e.g. "4m12.5s"
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).Round(100 * time.Millisecond).String()
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// CreateDeviceResult describes a device provisioned by CreateDevice
+type CreateDeviceResult struct {
+	Device    *Device   `json:"device"`
+	Requested time.Time `json:"requested_at"`
+	// CreateTime is how long the API took to accept the request
+	CreateTime Duration `json:"create_time"`
+	// ProvisionTime is the time from the request until the device was active
+	ProvisionTime Duration `json:"provision_time"`
+	// Polls counts the state checks while waiting, Retries the failed ones
+	Polls    int      `json:"polls"`
+	Retries  int      `json:"retries"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// DeleteDeviceResult describes a device deleted by DeleteDevice
+type DeleteDeviceResult struct {
+	DeviceID string   `json:"device_id"`
+	Duration Duration `json:"duration"`
+}
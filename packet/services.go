@@ -0,0 +1,140 @@
+package packet
+
+import (
+	"context"
+	"time"
+)
+
+// DeviceService manages the devices of projects. Client.Devices returns
+// the implementation backed by the API, DeviceServiceMock stands in for it
+// in tests of code built on top.
+type DeviceService interface {
+	List(ctx context.Context, projectID string) ([]Device, error)
+	Get(ctx context.Context, deviceID string) (*Device, error)
+	Create(ctx context.Context, req *DeviceRequest, timeout time.Duration) (*CreateDeviceResult, error)
+	Delete(ctx context.Context, deviceID string) (*DeleteDeviceResult, error)
+	Power(ctx context.Context, deviceID string, on bool) error
+}
+
+// IPService manages the IP blocks reserved in projects and their
+// assignment to devices
+type IPService interface {
+	List(ctx context.Context, projectID string) ([]IPReservation, error)
+	Request(ctx context.Context, projectID string, req *IPReservationRequest) (*IPReservation, error)
+	Release(ctx context.Context, reservationID string) error
+	Assign(ctx context.Context, deviceID, address string) (*IPAssignment, error)
+	Unassign(ctx context.Context, assignmentID string) error
+}
+
+// VLANService manages the VLANs of projects
+type VLANService interface {
+	List(ctx context.Context, projectID string) ([]VirtualNetwork, error)
+	Create(ctx context.Context, projectID string, req *VirtualNetworkRequest) (*VirtualNetwork, error)
+	Delete(ctx context.Context, vlanID string) error
+}
+
+// VolumeService manages block storage volumes and their attachments
+type VolumeService interface {
+	List(ctx context.Context, projectID string) ([]Volume, error)
+	Get(ctx context.Context, volumeID string) (*Volume, error)
+	Create(ctx context.Context, projectID string, req *VolumeRequest) (*Volume, error)
+	Delete(ctx context.Context, volumeID string) error
+	Attach(ctx context.Context, volumeID, deviceID string) (*VolumeAttachment, error)
+	Detach(ctx context.Context, attachmentID string) error
+}
+
+// Devices returns the device service of the client
+func (c *Client) Devices() DeviceService { return deviceService{c} }
+
+// IPs returns the IP service of the client
+func (c *Client) IPs() IPService { return ipService{c} }
+
+// VLANs returns the VLAN service of the client
+func (c *Client) VLANs() VLANService { return vlanService{c} }
+
+// Volumes returns the volume service of the client
+func (c *Client) Volumes() VolumeService { return volumeService{c} }
+
+type deviceService struct{ c *Client }
+
+func (s deviceService) List(ctx context.Context, projectID string) ([]Device, error) {
+	return listDevices(ctx, projectID, s.c)
+}
+
+func (s deviceService) Get(ctx context.Context, deviceID string) (*Device, error) {
+	return getDevice(ctx, deviceID, s.c)
+}
+
+func (s deviceService) Create(ctx context.Context, req *DeviceRequest, timeout time.Duration) (*CreateDeviceResult, error) {
+	return CreateDevice(ctx, s.c, req, timeout, nil)
+}
+
+func (s deviceService) Delete(ctx context.Context, deviceID string) (*DeleteDeviceResult, error) {
+	return deleteDevice(ctx, s.c, deviceID)
+}
+
+func (s deviceService) Power(ctx context.Context, deviceID string, on bool) error {
+	return powerDevice(ctx, deviceID, on, s.c)
+}
+
+type ipService struct{ c *Client }
+
+func (s ipService) List(ctx context.Context, projectID string) ([]IPReservation, error) {
+	return listIPReservations(ctx, projectID, s.c)
+}
+
+func (s ipService) Request(ctx context.Context, projectID string, req *IPReservationRequest) (*IPReservation, error) {
+	return requestIPReservation(ctx, projectID, req, s.c)
+}
+
+func (s ipService) Release(ctx context.Context, reservationID string) error {
+	return releaseIPReservation(ctx, reservationID, s.c)
+}
+
+func (s ipService) Assign(ctx context.Context, deviceID, address string) (*IPAssignment, error) {
+	return assignIP(ctx, deviceID, address, s.c)
+}
+
+func (s ipService) Unassign(ctx context.Context, assignmentID string) error {
+	return unassignIP(ctx, assignmentID, s.c)
+}
+
+type vlanService struct{ c *Client }
+
+func (s vlanService) List(ctx context.Context, projectID string) ([]VirtualNetwork, error) {
+	return listVLANs(ctx, projectID, s.c)
+}
+
+func (s vlanService) Create(ctx context.Context, projectID string, req *VirtualNetworkRequest) (*VirtualNetwork, error) {
+	return createVLAN(ctx, projectID, req, s.c)
+}
+
+func (s vlanService) Delete(ctx context.Context, vlanID string) error {
+	return deleteVLAN(ctx, vlanID, s.c)
+}
+
+type volumeService struct{ c *Client }
+
+func (s volumeService) List(ctx context.Context, projectID string) ([]Volume, error) {
+	return listVolumes(ctx, projectID, s.c)
+}
+
+func (s volumeService) Get(ctx context.Context, volumeID string) (*Volume, error) {
+	return getVolume(ctx, volumeID, s.c)
+}
+
+func (s volumeService) Create(ctx context.Context, projectID string, req *VolumeRequest) (*Volume, error) {
+	return createVolume(ctx, projectID, req, s.c)
+}
+
+func (s volumeService) Delete(ctx context.Context, volumeID string) error {
+	return deleteVolume(ctx, volumeID, s.c)
+}
+
+func (s volumeService) Attach(ctx context.Context, volumeID, deviceID string) (*VolumeAttachment, error) {
+	return attachVolume(ctx, volumeID, deviceID, s.c)
+}
+
+func (s volumeService) Detach(ctx context.Context, attachmentID string) error {
+	return detachVolume(ctx, attachmentID, s.c)
+}
@@ -0,0 +1,146 @@
+package packet
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CPR is a custom partitioning and RAID layout, the storage field of a
+// device request
+type CPR struct {
+	Disks       []CPRDisk       `json:"disks,omitempty"`
+	RAID        []CPRRAID       `json:"raid,omitempty"`
+	Filesystems []CPRFilesystem `json:"filesystems,omitempty"`
+}
+
+// CPRDisk partitions a disk
+type CPRDisk struct {
+	Device     string         `json:"device"`
+	WipeTable  bool           `json:"wipeTable,omitempty"`
+	Partitions []CPRPartition `json:"partitions,omitempty"`
+}
+
+// CPRPartition is a partition of a disk. Size is a number of bytes, a
+// string with a K, M, G or T suffix, or 0 for the rest of the disk.
+type CPRPartition struct {
+	Label  string      `json:"label"`
+	Number int         `json:"number"`
+	Size   interface{} `json:"size"`
+}
+
+// CPRRAID is a software RAID array of partitions
+type CPRRAID struct {
+	Devices []string `json:"devices"`
+	Level   string   `json:"level"`
+	Name    string   `json:"name"`
+}
+
+// CPRFilesystem formats and mounts a partition or array
+type CPRFilesystem struct {
+	Mount struct {
+		Device string `json:"device"`
+		Format string `json:"format"`
+		Point  string `json:"point,omitempty"`
+		Create *struct {
+			Options []string `json:"options,omitempty"`
+		} `json:"create,omitempty"`
+	} `json:"mount"`
+}
+
+var (
+	cprSizePattern = regexp.MustCompile(`^[0-9]+[KMGT]?$`)
+	cprRAIDLevels  = map[string]bool{"0": true, "1": true, "5": true, "6": true, "10": true}
+	cprFormats     = map[string]bool{"ext2": true, "ext3": true, "ext4": true, "xfs": true, "vfat": true, "swap": true}
+)
+
+// Validate checks the layout before the API gets it, as a layout the
+// installer cannot apply fails the provisioning only after several minutes
+func (c *CPR) Validate() error {
+	if len(c.Disks) == 0 && len(c.Filesystems) == 0 {
+		return fmt.Errorf("no disks or filesystems")
+	}
+
+	// devices that can be formatted: partitions and arrays
+	devices := map[string]bool{}
+	for i, d := range c.Disks {
+		if !strings.HasPrefix(d.Device, "/dev/") {
+			return fmt.Errorf("disk #%d: device %q is not a /dev path", i+1, d.Device)
+		}
+		numbers := map[int]bool{}
+		for j, p := range d.Partitions {
+			where := fmt.Sprintf("disk %s partition #%d", d.Device, j+1)
+			if p.Number < 1 || numbers[p.Number] {
+				return fmt.Errorf("%s: number must be unique and at least 1", where)
+			}
+			numbers[p.Number] = true
+			if p.Label == "" {
+				return fmt.Errorf("%s: label is required", where)
+			}
+			if err := checkCPRSize(p.Size); err != nil {
+				return fmt.Errorf("%s: %s", where, err)
+			}
+			devices[partitionDevice(d.Device, p.Number)] = true
+		}
+	}
+
+	for i, r := range c.RAID {
+		where := fmt.Sprintf("raid #%d", i+1)
+		if r.Name == "" {
+			return fmt.Errorf("%s: name is required", where)
+		}
+		if !cprRAIDLevels[r.Level] {
+			return fmt.Errorf("%s: level %q is not one of 0, 1, 5, 6 or 10", where, r.Level)
+		}
+		if len(r.Devices) < 2 {
+			return fmt.Errorf("%s: at least two devices are required", where)
+		}
+		for _, dev := range r.Devices {
+			if !devices[dev] {
+				return fmt.Errorf("%s: device %s is not a partition of the disks", where, dev)
+			}
+		}
+		devices[r.Name] = true
+	}
+
+	for i, f := range c.Filesystems {
+		where := fmt.Sprintf("filesystem #%d", i+1)
+		m := f.Mount
+		if !cprFormats[m.Format] {
+			return fmt.Errorf("%s: format %q is not one of ext2, ext3, ext4, xfs, vfat or swap", where, m.Format)
+		}
+		if m.Format != "swap" && !strings.HasPrefix(m.Point, "/") {
+			return fmt.Errorf("%s: mount point %q is not an absolute path", where, m.Point)
+		}
+		// with disks declared, filesystems must be on their partitions
+		if len(c.Disks) > 0 && !devices[m.Device] {
+			return fmt.Errorf("%s: device %s is not a partition or RAID array of the layout", where, m.Device)
+		}
+	}
+	return nil
+}
+
+func checkCPRSize(size interface{}) error {
+	switch s := size.(type) {
+	case float64:
+		if s < 0 || s != float64(int64(s)) {
+			return fmt.Errorf("size %v is not a whole number of bytes", s)
+		}
+		return nil
+	case string:
+		if !cprSizePattern.MatchString(s) {
+			return fmt.Errorf("size %q is not a number with an optional K, M, G or T suffix", s)
+		}
+		return nil
+	}
+	return fmt.Errorf("size is required")
+}
+
+// partitionDevice names a partition of a disk, e.g. /dev/sda1, or
+// /dev/nvme0n1p1 for disks whose name ends in a digit
+func partitionDevice(disk string, number int) string {
+	if last := disk[len(disk)-1]; last >= '0' && last <= '9' {
+		return fmt.Sprintf("%sp%d", disk, number)
+	}
+	return fmt.Sprintf("%s%d", disk, number)
+}
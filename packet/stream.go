@@ -0,0 +1,63 @@
+package packet
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// ResponseTooLargeError is returned for a response body larger than the
+// limit of the client
+type ResponseTooLargeError struct {
+	URL   string
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response of %s is larger than %d bytes, raise the response size limit or use a streaming call", e.URL, e.Limit)
+}
+
+// limitedReader reads up to limit bytes and fails with a
+// ResponseTooLargeError when there are more, where io.LimitReader would
+// silently truncate the body. A limit of 0 reads everything.
+type limitedReader struct {
+	r     io.Reader
+	left  int64
+	limit int64
+	url   string
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.limit <= 0 {
+		return l.r.Read(p)
+	}
+	if l.left <= 0 {
+		var probe [1]byte
+		if n, err := l.r.Read(probe[:]); n == 0 {
+			return 0, err
+		}
+		return 0, &ResponseTooLargeError{URL: l.url, Limit: l.limit}
+	}
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	return n, err
+}
+
+// Stream sends a GET request and returns the body of the response for the
+// caller to read as it arrives, without the size limit of DoRequest. The
+// caller must close it.
+func (c *Client) Stream(ctx context.Context, url string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	body := &limitedReader{r: resp.Body, left: c.maxResponseSize, limit: c.maxResponseSize, url: url}
+	if err := c.checkResponse("GET", resp, body); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,144 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokens are refreshed this long before they expire, so that a request
+// does not start with a token that expires while it is in flight
+const tokenExpiryLeeway = 30 * time.Second
+
+// Token is an API credential
+type Token struct {
+	Value string
+	// Expiry is when the token stops being valid, zero if it does not expire
+	Expiry time.Time
+}
+
+// Valid reports whether the token is set and not about to expire
+func (t *Token) Valid() bool {
+	return t != nil && t.Value != "" && (t.Expiry.IsZero() || time.Now().Add(tokenExpiryLeeway).Before(t.Expiry))
+}
+
+// TokenSource supplies the token of every API request. Implementations
+// must be safe for concurrent use.
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+// RequestTokenSource is a TokenSource choosing the token of each request,
+// e.g. among the tokens of several accounts. The client asks it instead of
+// calling Token.
+type RequestTokenSource interface {
+	TokenSource
+	// TokenFor returns the token for the request of the API path
+	TokenFor(path string) (*Token, error)
+	// RateLimited reports that the API rate limited the token of the
+	// request of the path, it returns whether another token can take over
+	// so that the request is sent again
+	RateLimited(tok *Token, path string, resp *http.Response) bool
+}
+
+// TokenSourceFunc adapts a plain function to TokenSource
+type TokenSourceFunc func() (*Token, error)
+
+// Token calls f
+func (f TokenSourceFunc) Token() (*Token, error) {
+	return f()
+}
+
+// StaticTokenSource always returns the same token
+func StaticTokenSource(value string) TokenSource {
+	t := &Token{Value: value}
+	return TokenSourceFunc(func() (*Token, error) {
+		return t, nil
+	})
+}
+
+// ReuseTokenSource caches the token of src until it expires or the API
+// rejects it. Concurrent callers wait for a single refresh.
+func ReuseTokenSource(src TokenSource) TokenSource {
+	if _, ok := src.(*reuseTokenSource); ok {
+		return src
+	}
+	return &reuseTokenSource{src: src}
+}
+
+type reuseTokenSource struct {
+	src TokenSource
+	mu  sync.Mutex
+	tok *Token
+}
+
+func (s *reuseTokenSource) Token() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok.Valid() {
+		return s.tok, nil
+	}
+	tok, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil || tok.Value == "" {
+		return nil, errors.New("token source returned an empty token")
+	}
+	s.tok = tok
+	return tok, nil
+}
+
+// invalidate drops the cached token after the API rejected it, unless
+// another request already replaced it
+func (s *reuseTokenSource) invalidate(tok *Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok == tok {
+		s.tok = nil
+	}
+}
+
+// CommandTokenSource runs a shell command for every new token. The command
+// prints either the token or a JSON object {"token": "...", "expiry":
+// "<RFC 3339 time>"}, as short-lived credential brokers usually do.
+func CommandTokenSource(command string) TokenSource {
+	return ReuseTokenSource(TokenSourceFunc(func() (*Token, error) {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", command)
+		} else {
+			cmd = exec.Command("sh", "-c", command)
+		}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("token command failed: %s %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return parseCommandToken(out)
+	}))
+}
+
+func parseCommandToken(out []byte) (*Token, error) {
+	out = bytes.TrimSpace(out)
+	if !bytes.HasPrefix(out, []byte("{")) {
+		return &Token{Value: string(out)}, nil
+	}
+
+	var resp struct {
+		Token  string    `json:"token"`
+		Expiry time.Time `json:"expiry"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("token command output: %s", err)
+	}
+	return &Token{Value: resp.Token, Expiry: resp.Expiry}, nil
+}
@@ -0,0 +1,58 @@
+package packet
+
+import (
+	"context"
+	"fmt"
+)
+
+// VirtualNetwork is a VLAN of the project
+type VirtualNetwork struct {
+	ID           string `json:"id"`
+	Description  string `json:"description"`
+	VXLAN        int    `json:"vxlan"`
+	FacilityCode string `json:"facility_code,omitempty"`
+	MetroCode    string `json:"metro_code,omitempty"`
+}
+
+// Location returns the metro of the VLAN, or its facility
+func (v *VirtualNetwork) Location() string {
+	if v.MetroCode != "" {
+		return v.MetroCode
+	}
+	return v.FacilityCode
+}
+
+// VirtualNetworkRequest creates a VLAN
+type VirtualNetworkRequest struct {
+	Facility    string `json:"facility,omitempty"`
+	Metro       string `json:"metro,omitempty"`
+	VXLAN       int    `json:"vxlan,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// listVLANs returns the virtual networks of the project
+func listVLANs(ctx context.Context, projectID string, c *Client) ([]VirtualNetwork, error) {
+	list := new(struct {
+		VirtualNetworks []VirtualNetwork `json:"virtual_networks"`
+	})
+	uri := fmt.Sprintf("projects/%s/virtual-networks", projectID)
+	if err := c.DoRequest(ctx, uri, "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.VirtualNetworks, nil
+}
+
+// createVLAN creates a virtual network in the project
+func createVLAN(ctx context.Context, projectID string, req *VirtualNetworkRequest, c *Client) (*VirtualNetwork, error) {
+	v := new(VirtualNetwork)
+	uri := fmt.Sprintf("projects/%s/virtual-networks", projectID)
+	if err := c.DoRequest(ctx, uri, "POST", req, v, nil); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// deleteVLAN deletes a virtual network, which must not be attached to ports
+func deleteVLAN(ctx context.Context, vlanID string, c *Client) error {
+	return c.DoRequest(ctx, "virtual-networks/"+vlanID, "DELETE", nil, nil, nil)
+}
@@ -0,0 +1,106 @@
+package packet
+
+import (
+	"context"
+	"path"
+)
+
+// Volume is an elastic block storage volume of the project
+type Volume struct {
+	ID           string             `json:"id"`
+	Name         string             `json:"name,omitempty"`
+	Description  string             `json:"description,omitempty"`
+	Size         int                `json:"size"`
+	State        string             `json:"state"`
+	Locked       bool               `json:"locked,omitempty"`
+	BillingCycle string             `json:"billing_cycle,omitempty"`
+	Created      string             `json:"created_at,omitempty"`
+	Plan         interface{}        `json:"plan,omitempty"`
+	Facility     interface{}        `json:"facility,omitempty"`
+	Metro        interface{}        `json:"metro,omitempty"`
+	Attachments  []VolumeAttachment `json:"attachments"`
+}
+
+// Location returns the metro of the volume, or its facility
+func (v *Volume) Location() string {
+	if code := attrString(v.Metro, "code"); code != "" {
+		return code
+	}
+	return attrString(v.Facility, "code")
+}
+
+// VolumeAttachment attaches a volume to a device. Listings only hold the
+// href of attachments.
+type VolumeAttachment struct {
+	ID     string      `json:"id,omitempty"`
+	Href   string      `json:"href,omitempty"`
+	Device interface{} `json:"device,omitempty"`
+}
+
+// AttachmentID returns the ID of the attachment, taken from its href when
+// the API did not include it
+func (a *VolumeAttachment) AttachmentID() string {
+	if a.ID != "" {
+		return a.ID
+	}
+	return path.Base(a.Href)
+}
+
+// VolumeRequest creates a volume
+type VolumeRequest struct {
+	Size         int          `json:"size"`
+	Plan         string       `json:"plan"`
+	Facility     string       `json:"facility,omitempty"`
+	Metro        string       `json:"metro,omitempty"`
+	Description  string       `json:"description,omitempty"`
+	BillingCycle BillingCycle `json:"billing_cycle,omitempty"`
+}
+
+// listVolumes returns the volumes of the project
+func listVolumes(ctx context.Context, projectID string, c *Client) ([]Volume, error) {
+	list := new(struct {
+		Volumes []Volume `json:"volumes"`
+	})
+	if err := c.DoRequest(ctx, "projects/"+projectID+"/storage", "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.Volumes, nil
+}
+
+func getVolume(ctx context.Context, volumeID string, c *Client) (*Volume, error) {
+	v := new(Volume)
+	if err := c.DoRequest(ctx, "storage/"+volumeID, "GET", nil, v, nil); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// createVolume creates a volume in the project, which is provisioned in
+// the background
+func createVolume(ctx context.Context, projectID string, req *VolumeRequest, c *Client) (*Volume, error) {
+	v := new(Volume)
+	if err := c.DoRequest(ctx, "projects/"+projectID+"/storage", "POST", req, v, nil); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// deleteVolume deletes a volume, which must not be attached
+func deleteVolume(ctx context.Context, volumeID string, c *Client) error {
+	return c.DoRequest(ctx, "storage/"+volumeID, "DELETE", nil, nil, nil)
+}
+
+// attachVolume attaches the volume to the device
+func attachVolume(ctx context.Context, volumeID, deviceID string, c *Client) (*VolumeAttachment, error) {
+	a := new(VolumeAttachment)
+	req := map[string]string{"device_id": deviceID}
+	if err := c.DoRequest(ctx, "storage/"+volumeID+"/attachments", "POST", req, a, nil); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// detachVolume deletes a volume attachment
+func detachVolume(ctx context.Context, attachmentID string, c *Client) error {
+	return c.DoRequest(ctx, "storage/attachments/"+attachmentID, "DELETE", nil, nil, nil)
+}
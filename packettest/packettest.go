@@ -0,0 +1,826 @@
+// Package packettest serves an in-memory fake of the Packet API, for tests
+// of code using the client and for trying the tool without an account.
+package packettest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakePlanPrices are the hourly prices of the plans of the fake
+var fakePlanPrices = map[string]float64{"baremetal_0": 0.07, "c3.small.x86": 0.5, "m3.large.x86": 3.1}
+
+// FakeAPI is an in-memory implementation of the project, device and SSH key
+// endpoints of the Packet API. It lets the tool, scripts and Go code using
+// the client run without an account, and injects latency and failures to
+// exercise retries and cleanup.
+type FakeAPI struct {
+	// Token is the only token accepted, any token when empty
+	Token string
+	// Latency delays every response
+	Latency time.Duration
+	// FailureRate is the share of requests, between 0 and 1, failing with
+	// a 500 response
+	FailureRate float64
+	// ProvisionTime is how long new devices take to become active
+	ProvisionTime time.Duration
+	// OTP is the two-factor code DELETE requests must send in the
+	// X-Auth-OTP header, none when empty
+	OTP string
+
+	mu     sync.Mutex
+	rand   *rand.Rand
+	nextID int
+	// requests numbers the request IDs of the responses
+	requests int
+	projects map[string]map[string]interface{}
+	devices  map[string]*fakeDevice
+	sshKeys  map[string]map[string]interface{}
+	// invitations are pending invitations to the projects
+	invitations map[string]map[string]interface{}
+	// apiKeys are user keys, and project keys with a "project" field
+	apiKeys map[string]map[string]interface{}
+	// ipReservations are blocks of 198.51.100.0/24, handed out in order
+	ipReservations map[string]map[string]interface{}
+	nextIP         int
+}
+
+type fakeDevice struct {
+	// seq orders the devices by creation, as the API lists them
+	seq     int
+	created time.Time
+	fields  map[string]interface{}
+	// busyUntil is when a rescue or reinstall is done
+	busyUntil time.Time
+}
+
+// deviceRequest is the body of a device creation, as the client sends it
+type deviceRequest struct {
+	Hostname        string          `json:"hostname"`
+	Plan            string          `json:"plan"`
+	Facility        []string        `json:"facility"`
+	Metro           string          `json:"metro"`
+	OS              string          `json:"operating_system"`
+	BillingCycle    string          `json:"billing_cycle"`
+	Tags            []string        `json:"tags"`
+	UserData        string          `json:"userdata"`
+	CustomData      json.RawMessage `json:"customdata"`
+	TerminationTime *time.Time      `json:"termination_time"`
+}
+
+// deviceUpdateRequest is the body of a device update
+type deviceUpdateRequest struct {
+	Tags            *[]string  `json:"tags"`
+	TerminationTime *time.Time `json:"termination_time"`
+	Locked          *bool      `json:"locked"`
+}
+
+// ipReservationRequest is the body of an IP block reservation
+type ipReservationRequest struct {
+	Type     string   `json:"type"`
+	Quantity int      `json:"quantity"`
+	Facility string   `json:"facility"`
+	Metro    string   `json:"metro"`
+	Comments string   `json:"comments"`
+	Tags     []string `json:"tags"`
+}
+
+// NewFakeAPI returns a fake with one project, "project-1", that devices
+// can be created in
+func NewFakeAPI() *FakeAPI {
+	f := &FakeAPI{
+		ProvisionTime: 10 * time.Second,
+		rand:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		projects:      map[string]map[string]interface{}{},
+		devices:       map[string]*fakeDevice{},
+		sshKeys:       map[string]map[string]interface{}{},
+		invitations:   map[string]map[string]interface{}{},
+		apiKeys:       map[string]map[string]interface{}{},
+
+		ipReservations: map[string]map[string]interface{}{},
+	}
+	f.projects["project-1"] = map[string]interface{}{"id": "project-1", "name": "Demo project", "organization": map[string]interface{}{"href": "/organizations/org-1"}}
+	return f
+}
+
+// Start serves the fake on a local port until the returned server is
+// closed. Its URL is the API URL to pass to NewClient.
+func (f *FakeAPI) Start() *httptest.Server {
+	return httptest.NewServer(f)
+}
+
+// newID returns a random version 4 UUID, the form of the IDs of the API
+func (f *FakeAPI) newID() string {
+	f.nextID++
+	b := make([]byte, 16)
+	f.rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// ServeHTTP answers the API requests
+func (f *FakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.Latency > 0 {
+		select {
+		case <-time.After(f.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests++
+	w.Header().Set("X-Request-Id", fmt.Sprintf("req-%06d", f.requests))
+	if id := r.Header.Get("X-Correlation-ID"); id != "" {
+		w.Header().Set("X-Correlation-ID", id)
+	}
+	tok := r.Header.Get("X-Auth-Token")
+	if tok == "" || (f.Token != "" && tok != f.Token) {
+		fakeError(w, http.StatusUnauthorized, "Invalid authentication token")
+		return
+	}
+	// devices past their termination time are gone, as with the API
+	for id, d := range f.devices {
+		if t, err := time.Parse(time.RFC3339, fmt.Sprint(d.fields["termination_time"])); err == nil && time.Now().After(t) {
+			delete(f.devices, id)
+		}
+	}
+	if f.OTP != "" && r.Method == "DELETE" && r.Header.Get("X-Auth-OTP") != f.OTP {
+		fakeError(w, http.StatusForbidden, "OTP required for this action")
+		return
+	}
+	if f.FailureRate > 0 && f.rand.Float64() < f.FailureRate {
+		fakeError(w, http.StatusInternalServerError, "Injected failure")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	route := r.Method + " " + parts[0]
+	if len(parts) > 1 {
+		route += "/{id}"
+	}
+	if len(parts) > 2 {
+		route += "/" + strings.Join(parts[2:], "/")
+	}
+	id := ""
+	if len(parts) > 1 {
+		id = parts[1]
+	}
+	// user/api-keys/{id} is a collection of the user
+	if parts[0] == "user" && len(parts) > 1 {
+		route = r.Method + " user/" + parts[1]
+		id = ""
+		if len(parts) > 2 {
+			route += "/{id}"
+			id = parts[2]
+		}
+	}
+
+	if r.Method == "GET" {
+		rec := httptest.NewRecorder()
+		defer fakeETag(w, r, rec)
+		w = rec
+	}
+
+	switch route {
+	case "GET user":
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"id": "user-1", "full_name": "Demo User", "email": "demo@example.com", "default_organization_id": "org-1"})
+	case "GET projects":
+		list := []interface{}{}
+		for _, p := range f.projects {
+			list = append(list, p)
+		}
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"projects": list})
+	case "GET organizations":
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"organizations": []interface{}{f.organization()}})
+	case "GET organizations/{id}":
+		if id == "org-1" {
+			fakeJSON(w, http.StatusOK, f.organization())
+		} else {
+			fakeError(w, http.StatusNotFound, "Not found")
+		}
+	case "GET organizations/{id}/projects":
+		list := []interface{}{}
+		if id == "org-1" {
+			for _, p := range f.projects {
+				list = append(list, p)
+			}
+		}
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"projects": list})
+	case "GET organizations/{id}/invitations", "GET projects/{id}/invitations":
+		list := []interface{}{}
+		for _, inv := range f.invitations {
+			list = append(list, inv)
+		}
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"invitations": list})
+	case "POST projects/{id}/invitations":
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req["invitee"] == nil {
+			fakeError(w, http.StatusUnprocessableEntity, "invitee is required")
+			return
+		}
+		inv := map[string]interface{}{"id": f.newID(), "invitee": req["invitee"], "roles": req["roles"],
+			"projects": []interface{}{map[string]interface{}{"href": "/projects/" + id}}}
+		f.invitations[inv["id"].(string)] = inv
+		fakeJSON(w, http.StatusCreated, inv)
+	case "DELETE invitations/{id}":
+		if f.invitations[id] == nil {
+			fakeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		delete(f.invitations, id)
+		w.WriteHeader(http.StatusNoContent)
+	case "GET organizations/{id}/members", "GET projects/{id}/memberships":
+		key := "members"
+		if parts[0] == "projects" {
+			key = "memberships"
+		}
+		fakeJSON(w, http.StatusOK, map[string]interface{}{key: []interface{}{
+			map[string]interface{}{"id": "member-1", "roles": []string{"owner"}, "user": map[string]interface{}{"id": "user-1", "email": "demo@example.com", "full_name": "Demo User"}},
+		}})
+	case "GET projects/{id}":
+		if p := f.projects[id]; p != nil {
+			fakeJSON(w, http.StatusOK, p)
+		} else {
+			fakeError(w, http.StatusNotFound, "Not found")
+		}
+	case "DELETE projects/{id}":
+		if f.projects[id] == nil {
+			fakeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		for _, d := range f.devices {
+			if d.fields["project"].(map[string]interface{})["id"] == id {
+				fakeError(w, http.StatusUnprocessableEntity, "Cannot delete a project with devices")
+				return
+			}
+		}
+		delete(f.projects, id)
+		w.WriteHeader(http.StatusNoContent)
+	case "GET projects/{id}/devices":
+		f.listDevices(w, r, id)
+	case "POST projects/{id}/devices":
+		f.createDevice(w, r, id)
+	case "GET devices/{id}":
+		if d := f.devices[id]; d != nil {
+			fakeJSON(w, http.StatusOK, fakeEmbed(f.device(d), r))
+		} else {
+			fakeError(w, http.StatusNotFound, "Not found")
+		}
+	case "PUT devices/{id}":
+		f.updateDevice(w, r, id)
+	case "DELETE devices/{id}":
+		if f.devices[id] == nil {
+			fakeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		if f.devices[id].fields["locked"] == true {
+			fakeError(w, http.StatusUnprocessableEntity, "Cannot delete a locked device")
+			return
+		}
+		delete(f.devices, id)
+		w.WriteHeader(http.StatusNoContent)
+	case "GET devices/{id}/events":
+		d, ok := f.devices[id]
+		if !ok {
+			fakeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		fields := f.device(d)
+		events := []interface{}{}
+		if fields["state"] == "active" {
+			events = append(events, map[string]interface{}{"id": id + "-active", "type": "provisioning.complete",
+				"body": "Provisioning of " + fields["hostname"].(string) + " complete", "created_at": d.created.Add(f.ProvisionTime).UTC().Format(time.RFC3339)})
+		}
+		events = append(events, map[string]interface{}{"id": id + "-queued", "type": "provisioning.started",
+			"body": "Provisioning of " + fields["hostname"].(string) + " started", "created_at": d.created.UTC().Format(time.RFC3339)})
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"events": events})
+	case "GET devices/{id}/bandwidth":
+		if _, ok := f.devices[id]; !ok {
+			fakeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		from, _ := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+		until, _ := strconv.ParseInt(r.URL.Query().Get("until"), 10, 64)
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"bandwidth": fakeBandwidth(from, until)})
+	case "POST devices/{id}/actions":
+		f.deviceAction(w, r, id)
+	case "POST devices/{id}/ips":
+		d, ok := f.devices[id]
+		if !ok {
+			fakeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		var req struct{ Address string }
+		json.NewDecoder(r.Body).Decode(&req)
+		ip, network, err := net.ParseCIDR(req.Address)
+		if err != nil {
+			fakeError(w, http.StatusUnprocessableEntity, "Address must be in CIDR notation")
+			return
+		}
+		cidr, _ := network.Mask.Size()
+		for _, other := range f.devices {
+			addrs, _ := other.fields["ip_addresses"].([]interface{})
+			for _, a := range addrs {
+				if attrString(a, "network") == network.IP.String() && attrValue(a, "cidr") == cidr {
+					fakeError(w, http.StatusUnprocessableEntity, "Address is already assigned")
+					return
+				}
+			}
+		}
+		a := map[string]interface{}{
+			"id": f.newID(), "address": ip.String(), "network": network.IP.String(), "cidr": cidr,
+			"public": true, "address_family": 4, "management": false,
+		}
+		addrs, _ := d.fields["ip_addresses"].([]interface{})
+		d.fields["ip_addresses"] = append(addrs, a)
+		fakeJSON(w, http.StatusCreated, a)
+	case "DELETE ips/{id}":
+		if ip := f.ipReservations[id]; ip != nil {
+			for _, d := range f.devices {
+				addrs, _ := d.fields["ip_addresses"].([]interface{})
+				for _, a := range addrs {
+					if attrString(a, "network") == ip["network"] {
+						fakeError(w, http.StatusUnprocessableEntity, "Cannot remove an IP reservation with assignments")
+						return
+					}
+				}
+			}
+			delete(f.ipReservations, id)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		for _, d := range f.devices {
+			addrs, _ := d.fields["ip_addresses"].([]interface{})
+			for i, a := range addrs {
+				if attrString(a, "id") == id {
+					d.fields["ip_addresses"] = append(addrs[:i:i], addrs[i+1:]...)
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+		}
+		fakeError(w, http.StatusNotFound, "Not found")
+	case "GET projects/{id}/ips":
+		list := []interface{}{}
+		for _, ip := range f.ipReservations {
+			assignments := []interface{}{}
+			for _, d := range f.devices {
+				addrs, _ := d.fields["ip_addresses"].([]interface{})
+				for _, a := range addrs {
+					if attrString(a, "network") == ip["network"] {
+						assignments = append(assignments, map[string]interface{}{
+							"id": attrString(a, "id"), "address": attrString(a, "address"), "assigned_to": map[string]interface{}{"href": "/devices/" + attrString(d.fields, "id")},
+						})
+					}
+				}
+			}
+			ip["assignments"] = assignments
+			list = append(list, ip)
+		}
+		sort.Slice(list, func(i, j int) bool { return attrString(list[i], "id") < attrString(list[j], "id") })
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"ip_addresses": list})
+	case "POST projects/{id}/ips":
+		var req ipReservationRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		cidr := 32
+		for size := 1; size < req.Quantity; size *= 2 {
+			cidr--
+		}
+		size := 1 << uint(32-cidr)
+		start := (f.nextIP + size - 1) / size * size
+		if req.Type == "private_ipv4" || start+size > 256 {
+			fakeError(w, http.StatusUnprocessableEntity, "The fake only reserves public blocks of 198.51.100.0/24")
+			return
+		}
+		f.nextIP = start + size
+		network := "198.51.100." + strconv.Itoa(start)
+		ip := map[string]interface{}{
+			"id": f.newID(), "address": network, "network": network, "cidr": cidr, "address_family": 4,
+			"public": true, "management": false, "global_ip": req.Type == "global_ipv4",
+			"tags": append([]string{}, req.Tags...), "details": req.Comments, "assignments": []interface{}{},
+		}
+		if req.Metro != "" {
+			ip["metro"] = map[string]interface{}{"code": req.Metro}
+		} else if req.Facility != "" {
+			ip["facility"] = map[string]interface{}{"code": req.Facility}
+		}
+		f.ipReservations[ip["id"].(string)] = ip
+		fakeJSON(w, http.StatusCreated, ip)
+	case "GET projects/{id}/storage":
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"volumes": []interface{}{}})
+	case "GET ssh-keys", "GET projects/{id}/ssh-keys":
+		list := []interface{}{}
+		for _, k := range f.sshKeys {
+			list = append(list, k)
+		}
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"ssh_keys": list})
+	case "POST ssh-keys", "POST projects/{id}/ssh-keys":
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req["key"] == nil {
+			fakeError(w, http.StatusUnprocessableEntity, "key is required")
+			return
+		}
+		req["id"] = f.newID()
+		f.sshKeys[req["id"].(string)] = req
+		fakeJSON(w, http.StatusCreated, req)
+	case "GET ssh-keys/{id}":
+		if k := f.sshKeys[id]; k != nil {
+			fakeJSON(w, http.StatusOK, k)
+		} else {
+			fakeError(w, http.StatusNotFound, "Not found")
+		}
+	case "DELETE ssh-keys/{id}":
+		delete(f.sshKeys, id)
+		w.WriteHeader(http.StatusNoContent)
+	case "GET projects/{id}/usages":
+		list := []interface{}{}
+		for _, d := range f.devices {
+			if attrString(d.fields["project"], "id") != id {
+				continue
+			}
+			hours := math.Ceil(time.Since(d.created).Hours())
+			slug := attrString(d.fields["plan"], "slug")
+			list = append(list, map[string]interface{}{
+				"name": d.fields["hostname"], "type": "Instance", "plan": slug,
+				"unit": "hour", "quantity": hours, "price": fakePlanPrices[slug], "total": hours * fakePlanPrices[slug],
+			})
+		}
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"usages": list})
+	case "GET projects/{id}/plans", "GET plans":
+		var slugs []string
+		for slug := range fakePlanPrices {
+			slugs = append(slugs, slug)
+		}
+		sort.Strings(slugs)
+		list := []interface{}{}
+		for _, slug := range slugs {
+			list = append(list, map[string]interface{}{"slug": slug, "name": slug, "pricing": map[string]interface{}{"hour": fakePlanPrices[slug]}})
+		}
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"plans": list})
+	case "GET facilities":
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"facilities": []interface{}{
+			map[string]interface{}{"code": "ams1", "name": "Amsterdam, NL"},
+			map[string]interface{}{"code": "sjc1", "name": "Sunnyvale, CA"},
+		}})
+	case "GET locations/metros":
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"metros": []interface{}{
+			map[string]interface{}{"code": "am", "name": "Amsterdam"},
+			map[string]interface{}{"code": "sv", "name": "Silicon Valley"},
+		}})
+	case "GET operating-systems":
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"operating_systems": []interface{}{
+			map[string]interface{}{"slug": "ubuntu_22_04", "name": "Ubuntu 22.04 LTS"},
+			map[string]interface{}{"slug": "debian_12", "name": "Debian 12"},
+			map[string]interface{}{"slug": "centos_7", "name": "CentOS 7", "provisionable_on": []string{"baremetal_0"}},
+		}})
+	case "GET organizations/{id}/invoices":
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"invoices": []interface{}{
+			map[string]interface{}{"id": "invoice-1", "number": "INV-0001", "status": "paid", "currency": "USD",
+				"total": 12.5, "balance": 0, "created_on": "2026-10-01", "due_on": "2026-10-31"},
+		}})
+	case "GET user/api-keys", "GET projects/{id}/api-keys":
+		list := []interface{}{}
+		for _, k := range f.apiKeys {
+			if k["project"] == id {
+				list = append(list, k)
+			}
+		}
+		fakeJSON(w, http.StatusOK, map[string]interface{}{"api_keys": list})
+	case "POST user/api-keys", "POST projects/{id}/api-keys":
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			fakeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		req["id"] = f.newID()
+		req["token"] = fmt.Sprintf("%s-token-%d", req["id"], f.rand.Int())
+		req["project"] = id
+		f.apiKeys[req["id"].(string)] = req
+		fakeJSON(w, http.StatusCreated, req)
+	case "DELETE user/api-keys/{id}", "DELETE api-keys/{id}":
+		if f.apiKeys[id] == nil {
+			fakeError(w, http.StatusNotFound, "Not found")
+			return
+		}
+		delete(f.apiKeys, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fakeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// organization is the single organization owning the projects of the fake
+func (f *FakeAPI) organization() map[string]interface{} {
+	projects := []interface{}{}
+	for id := range f.projects {
+		projects = append(projects, map[string]interface{}{"href": "/projects/" + id})
+	}
+	return map[string]interface{}{"id": "org-1", "name": "Demo organization", "projects": projects}
+}
+
+// fakeBandwidth returns a daily wave of traffic in 5 minute steps, at
+// most 500 datapoints ending at until
+func fakeBandwidth(from, until int64) []interface{} {
+	const step = 300
+	from = (from + step - 1) / step * step
+	if until-from > 500*step {
+		from = until/step*step - 499*step
+	}
+	in, out := [][2]float64{}, [][2]float64{}
+	for ts := from; ts <= until; ts += step {
+		wave := 1 + math.Sin(float64(ts%86400)/86400*2*math.Pi)
+		in = append(in, [2]float64{math.Round(2e6 * wave), float64(ts)})
+		out = append(out, [2]float64{math.Round(15e6 * wave), float64(ts)})
+	}
+	return []interface{}{
+		map[string]interface{}{"target": "inbound", "datapoints": in},
+		map[string]interface{}{"target": "outbound", "datapoints": out},
+	}
+}
+
+// device returns the API object of the device, its state following the
+// time since it was created
+func (f *FakeAPI) device(d *fakeDevice) map[string]interface{} {
+	if state := d.fields["state"]; state == "queued" || state == "provisioning" {
+		switch age := time.Since(d.created); {
+		case age >= f.ProvisionTime:
+			d.fields["state"] = "active"
+			// numbered by device, so that devices active together differ
+			n := strconv.Itoa(2 + d.seq%250)
+			d.fields["ip_addresses"] = []interface{}{
+				map[string]interface{}{"address": "192.0.2." + n, "cidr": 31, "public": true, "address_family": 4, "management": true},
+				map[string]interface{}{"address": "10.0.0." + n, "cidr": 31, "public": false, "address_family": 4, "management": true},
+			}
+		case age >= f.ProvisionTime/2:
+			d.fields["state"] = "provisioning"
+			d.fields["provisioning_percentage"] = float64(100 * age / f.ProvisionTime)
+		}
+		if d.fields["state"] == "active" {
+			delete(d.fields, "provisioning_percentage")
+		}
+	}
+	if state := d.fields["state"]; (state == "rescuing" || state == "reinstalling") && time.Now().After(d.busyUntil) {
+		d.fields["state"] = "active"
+	}
+	return d.fields
+}
+
+func (f *FakeAPI) listDevices(w http.ResponseWriter, r *http.Request, projectID string) {
+	if f.projects[projectID] == nil {
+		fakeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage <= 0 {
+		perPage = 10
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	q := r.URL.Query()
+	devices := make([]*fakeDevice, 0, len(f.devices))
+	for _, d := range f.devices {
+		devices = append(devices, d)
+	}
+	// pages must not overlap, so the order cannot be that of the map
+	sort.Slice(devices, func(i, j int) bool { return devices[i].seq < devices[j].seq })
+	all := []interface{}{}
+	for _, d := range devices {
+		if d.fields["project"].(map[string]interface{})["id"] != projectID {
+			continue
+		}
+		if tag := q.Get("tag"); tag != "" && !hasTag(d.fields["tags"].([]string), tag) {
+			continue
+		}
+		if facility := q.Get("facility"); facility != "" && attrString(d.fields["facility"], "code") != facility {
+			continue
+		}
+		if search := q.Get("search"); search != "" && !strings.Contains(d.fields["hostname"].(string), search) {
+			continue
+		}
+		all = append(all, fakeEmbed(f.device(d), r))
+	}
+	lastPage := (len(all) + perPage - 1) / perPage
+	if lastPage == 0 {
+		lastPage = 1
+	}
+	start, end := (page-1)*perPage, page*perPage
+	if start > len(all) {
+		start = len(all)
+	}
+	if end > len(all) {
+		end = len(all)
+	}
+	fakeJSON(w, http.StatusOK, map[string]interface{}{
+		"devices": all[start:end],
+		"meta":    map[string]interface{}{"current_page": page, "last_page": lastPage, "total": len(all)},
+	})
+}
+
+func (f *FakeAPI) createDevice(w http.ResponseWriter, r *http.Request, projectID string) {
+	if f.projects[projectID] == nil {
+		fakeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	var req deviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fakeError(w, http.StatusUnprocessableEntity, "invalid request body")
+		return
+	}
+	var missing []string
+	for name, value := range map[string]string{"hostname": req.Hostname, "plan": req.Plan, "operating_system": req.OS} {
+		if value == "" {
+			missing = append(missing, name+" is required")
+		}
+	}
+	if len(req.Facility) == 0 && req.Metro == "" {
+		missing = append(missing, "facility or metro is required")
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		fakeError(w, http.StatusUnprocessableEntity, missing...)
+		return
+	}
+
+	now := time.Now()
+	fields := map[string]interface{}{
+		"id":               f.newID(),
+		"hostname":         req.Hostname,
+		"state":            "queued",
+		"created_at":       now.UTC().Format(time.RFC3339),
+		"updated_at":       now.UTC().Format(time.RFC3339),
+		"billing_cycle":    req.BillingCycle,
+		"plan":             map[string]interface{}{"slug": req.Plan},
+		"operating_system": map[string]interface{}{"slug": req.OS},
+		"project":          map[string]interface{}{"id": projectID},
+		"ip_addresses":     []interface{}{},
+		"tags":             append([]string{}, req.Tags...),
+	}
+	if req.TerminationTime != nil {
+		fields["termination_time"] = req.TerminationTime.Format(time.RFC3339)
+	}
+	if req.UserData != "" {
+		fields["userdata"] = req.UserData
+	}
+	if len(req.CustomData) > 0 {
+		var customdata interface{}
+		json.Unmarshal(req.CustomData, &customdata)
+		fields["customdata"] = customdata
+	}
+	if req.Metro != "" {
+		fields["metro"] = map[string]interface{}{"code": req.Metro}
+		fields["facility"] = map[string]interface{}{"code": req.Metro + "1"}
+	} else {
+		fields["facility"] = map[string]interface{}{"code": req.Facility[0]}
+	}
+	d := &fakeDevice{seq: f.nextID, created: now, fields: fields}
+	f.devices[fields["id"].(string)] = d
+	fakeJSON(w, http.StatusCreated, d.fields)
+}
+
+func (f *FakeAPI) updateDevice(w http.ResponseWriter, r *http.Request, id string) {
+	d := f.devices[id]
+	if d == nil {
+		fakeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	var req deviceUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fakeError(w, http.StatusUnprocessableEntity, "invalid request body")
+		return
+	}
+	if req.Tags != nil {
+		d.fields["tags"] = *req.Tags
+	}
+	if req.TerminationTime != nil {
+		d.fields["termination_time"] = req.TerminationTime.Format(time.RFC3339)
+	}
+	if req.Locked != nil {
+		d.fields["locked"] = *req.Locked
+	}
+	d.fields["updated_at"] = time.Now().UTC().Format(time.RFC3339)
+	fakeJSON(w, http.StatusOK, f.device(d))
+}
+
+func (f *FakeAPI) deviceAction(w http.ResponseWriter, r *http.Request, id string) {
+	d := f.devices[id]
+	if d == nil {
+		fakeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	var req struct {
+		Type string `json:"type"`
+		OS   string `json:"operating_system"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	switch req.Type {
+	case "rescue", "reinstall":
+		if d.fields["state"] != "active" {
+			fakeError(w, http.StatusUnprocessableEntity, "Device must be active")
+			return
+		}
+		d.fields["state"] = map[string]string{"rescue": "rescuing", "reinstall": "reinstalling"}[req.Type]
+		if req.OS != "" {
+			d.fields["operating_system"] = map[string]interface{}{"slug": req.OS}
+		}
+		d.busyUntil = time.Now().Add(f.ProvisionTime)
+	case "power_on":
+		d.fields["state"] = "active"
+	case "power_off":
+		d.fields["state"] = "inactive"
+	case "reboot":
+	default:
+		fakeError(w, http.StatusUnprocessableEntity, "unknown action type "+strconv.Quote(req.Type))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func fakeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// fakeEmbed returns a copy of the device without the fields in the exclude
+// parameter of the request. An included plan embeds its pricing, the other
+// embedded objects are always returned in full.
+func fakeEmbed(obj map[string]interface{}, r *http.Request) map[string]interface{} {
+	out := map[string]interface{}{}
+	for k, v := range obj {
+		out[k] = v
+	}
+	if include := r.URL.Query().Get("include"); hasTag(strings.Split(include, ","), "plan") {
+		slug := attrString(obj["plan"], "slug")
+		out["plan"] = map[string]interface{}{"slug": slug, "pricing": map[string]interface{}{"hour": fakePlanPrices[slug]}}
+	}
+	if exclude := r.URL.Query().Get("exclude"); exclude != "" {
+		for _, k := range strings.Split(exclude, ",") {
+			delete(out, k)
+		}
+	}
+	return out
+}
+
+// fakeETag writes the recorded response of a GET request with an ETag of
+// its body, or 304 Not Modified when the request sent that ETag
+func fakeETag(w http.ResponseWriter, r *http.Request, rec *httptest.ResponseRecorder) {
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	if rec.Code == http.StatusOK {
+		sum := sha256.Sum256(rec.Body.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.WriteHeader(rec.Code)
+	w.Write(rec.Body.Bytes())
+}
+
+func fakeError(w http.ResponseWriter, status int, errs ...string) {
+	fakeJSON(w, status, map[string]interface{}{"errors": errs})
+}
+
+// attrValue reads an attribute of an embedded API object
+func attrValue(obj interface{}, key string) interface{} {
+	m, _ := obj.(map[string]interface{})
+	return m[key]
+}
+
+// attrString reads a string attribute of an embedded API object
+func attrString(obj interface{}, key string) string {
+	s, _ := attrValue(obj, key).(string)
+	return s
+}
+
+// hasTag tells whether tags holds tag
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
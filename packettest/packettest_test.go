@@ -0,0 +1,175 @@
+package packettest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// call sends an authenticated request to the fake and decodes the JSON
+// response, if any
+func call(t *testing.T, f *FakeAPI, method, path string, body interface{}, header http.Header) (int, map[string]interface{}, http.Header) {
+	t.Helper()
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	r := httptest.NewRequest(method, path, bytes.NewReader(data))
+	r.Header.Set("X-Auth-Token", "t")
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	f.ServeHTTP(w, r)
+	var out map[string]interface{}
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", method, path, w.Body, err)
+		}
+	}
+	return w.Code, out, w.Header()
+}
+
+// create creates a device in project-1 and returns its ID
+func create(t *testing.T, f *FakeAPI, hostname string) string {
+	t.Helper()
+	status, d, _ := call(t, f, "POST", "/projects/project-1/devices",
+		map[string]interface{}{"hostname": hostname, "plan": "baremetal_0", "facility": []string{"ewr1"}, "operating_system": "ubuntu_22_04"}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("creating %s: status %d: %v", hostname, status, d)
+	}
+	return d["id"].(string)
+}
+
+func TestFakeAPIRequests(t *testing.T) {
+	f := NewFakeAPI()
+	f.OTP = "123456"
+	id := create(t, f, "web")
+	locked := create(t, f, "db")
+	call(t, f, "PUT", "/devices/"+locked, map[string]interface{}{"locked": true}, nil)
+	_, _, h := call(t, f, "GET", "/devices/"+id, nil, nil)
+	etag := h.Get("ETag")
+
+	otp := http.Header{"X-Auth-Otp": {"123456"}}
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       interface{}
+		header     http.Header
+		wantStatus int
+		wantErrors string
+	}{
+		{"no token", "GET", "/user", nil, http.Header{"X-Auth-Token": {""}}, http.StatusUnauthorized, "Invalid authentication token"},
+		{"unknown project", "GET", "/projects/project-2/devices", nil, nil, http.StatusNotFound, "Not found"},
+		{"missing fields", "POST", "/projects/project-1/devices", map[string]interface{}{"hostname": "x"}, nil, http.StatusUnprocessableEntity,
+			"facility or metro is required, operating_system is required, plan is required"},
+		{"unknown device", "GET", "/devices/8f2b1c4e-5a7d-4e3b-9c61-0d2f8a7b6e15", nil, nil, http.StatusNotFound, "Not found"},
+		{"not modified", "GET", "/devices/" + id, nil, http.Header{"If-None-Match": {etag}}, http.StatusNotModified, ""},
+		{"delete without OTP", "DELETE", "/devices/" + id, nil, nil, http.StatusForbidden, "OTP required for this action"},
+		{"delete locked device", "DELETE", "/devices/" + locked, nil, otp, http.StatusUnprocessableEntity, "Cannot delete a locked device"},
+		{"delete", "DELETE", "/devices/" + id, nil, otp, http.StatusNoContent, ""},
+		{"deleted device", "GET", "/devices/" + id, nil, nil, http.StatusNotFound, "Not found"},
+		{"unknown action", "POST", "/devices/" + locked + "/actions", map[string]interface{}{"type": "explode"}, nil, http.StatusUnprocessableEntity,
+			`unknown action type "explode"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, out, h := call(t, f, tt.method, tt.path, tt.body, tt.header)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %v", status, tt.wantStatus, out)
+			}
+			var errs []string
+			if list, ok := out["errors"].([]interface{}); ok {
+				for _, e := range list {
+					errs = append(errs, fmt.Sprint(e))
+				}
+			}
+			if got := strings.Join(errs, ", "); got != tt.wantErrors {
+				t.Errorf("errors = %q, want %q", got, tt.wantErrors)
+			}
+			if !strings.HasPrefix(h.Get("X-Request-Id"), "req-") {
+				t.Errorf("X-Request-Id = %q", h.Get("X-Request-Id"))
+			}
+		})
+	}
+}
+
+func TestFakeAPIProvisioning(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	f := NewFakeAPI()
+	f.ProvisionTime = 200 * time.Millisecond
+	first, second := create(t, f, "a"), create(t, f, "b")
+	for _, id := range []string{first, second} {
+		if !uuid.MatchString(id) {
+			t.Errorf("device ID %q is not a UUID", id)
+		}
+	}
+	if first == second {
+		t.Fatalf("both devices got the ID %s", first)
+	}
+
+	steps := []struct {
+		wait  time.Duration
+		state string
+		ips   int
+	}{
+		{0, "queued", 0},
+		{120 * time.Millisecond, "provisioning", 0},
+		{100 * time.Millisecond, "active", 2},
+	}
+	for _, s := range steps {
+		time.Sleep(s.wait)
+		_, d, _ := call(t, f, "GET", "/devices/"+first, nil, nil)
+		ips, _ := d["ip_addresses"].([]interface{})
+		if d["state"] != s.state || len(ips) != s.ips {
+			t.Fatalf("after %v: state %v with %d addresses, want %s with %d", s.wait, d["state"], len(ips), s.state, s.ips)
+		}
+	}
+	// devices active together get different addresses
+	_, d, _ := call(t, f, "GET", "/devices/"+second, nil, nil)
+	_, e, _ := call(t, f, "GET", "/devices/"+first, nil, nil)
+	if fmt.Sprint(d["ip_addresses"]) == fmt.Sprint(e["ip_addresses"]) {
+		t.Errorf("devices share the addresses %v", d["ip_addresses"])
+	}
+}
+
+func TestFakeAPIListsPages(t *testing.T) {
+	f := NewFakeAPI()
+	var created []string
+	for i := 0; i < 25; i++ {
+		created = append(created, create(t, f, fmt.Sprintf("node-%02d", i)))
+	}
+	tests := []struct {
+		query    string
+		want     []string
+		lastPage float64
+	}{
+		{"?per_page=10", created[:10], 3},
+		{"?per_page=10&page=3", created[20:], 3},
+		{"?per_page=10&page=4", nil, 3},
+		{"?per_page=100&search=node-1", created[10:20], 1},
+		{"?tag=none", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, out, _ := call(t, f, "GET", "/projects/project-1/devices"+tt.query, nil, nil)
+			var got []string
+			for _, d := range out["devices"].([]interface{}) {
+				got = append(got, d.(map[string]interface{})["id"].(string))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("devices = %v, want %v", got, tt.want)
+			}
+			if last := out["meta"].(map[string]interface{})["last_page"]; last != tt.lastPage {
+				t.Errorf("last_page = %v, want %v", last, tt.lastPage)
+			}
+		})
+	}
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "pipeline",
+		usage: "Run an ordered provisioning plan, rolling back the completed steps when one fails",
+		run:   runPipeline,
+	})
+}
+
+// Pipeline is an ordered plan of provisioning steps. The location is the
+// default of the steps that do not name one.
+type Pipeline struct {
+	Project  string         `json:"project,omitempty"`
+	Facility string         `json:"facility,omitempty"`
+	Metro    string         `json:"metro,omitempty"`
+	Steps    []PipelineStep `json:"steps"`
+}
+
+// PipelineStep is a step of a pipeline, exactly one of its actions is set.
+// A failing step is tried again Retries times before the pipeline fails.
+type PipelineStep struct {
+	Name       string          `json:"name,omitempty"`
+	Retries    int             `json:"retries,omitempty"`
+	ReserveIPs *ReserveIPsStep `json:"reserve_ips,omitempty"`
+	SSHKey     *SSHKeyStep     `json:"ssh_key,omitempty"`
+	VLAN       *VLANSpec       `json:"vlan,omitempty"`
+	Devices    *DevicesStep    `json:"devices,omitempty"`
+	AttachVLAN *AttachVLANStep `json:"attach_vlan,omitempty"`
+	RunScript  *RunScriptStep  `json:"run_script,omitempty"`
+}
+
+// ReserveIPsStep reserves a block of addresses such as ipv4/30
+type ReserveIPsStep struct {
+	Block       string   `json:"block"`
+	Facility    string   `json:"facility,omitempty"`
+	Metro       string   `json:"metro,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// SSHKeyStep uploads a public key to the project, given inline or as a
+// file
+type SSHKeyStep struct {
+	Label   string `json:"label"`
+	Key     string `json:"key,omitempty"`
+	KeyFile string `json:"key_file,omitempty"`
+}
+
+// DevicesStep creates Count devices from the spec. With more than one the
+// hostnames get a -1, -2... suffix.
+type DevicesStep struct {
+	Count int `json:"count,omitempty"`
+	DeviceSpec
+
+	// created are the active devices by hostname, a retry creates the rest
+	created map[string]*Device
+}
+
+// AttachVLANStep attaches the VLAN of the latest vlan step to a port of
+// every device of the pipeline. eth1 needs a hybrid device, devices are
+// converted first.
+type AttachVLANStep struct {
+	Port string `json:"port,omitempty"`
+}
+
+// RunScriptStep runs a local script on every device of the pipeline over
+// SSH, failing on a non-zero exit status
+type RunScriptStep struct {
+	Script string `json:"script"`
+
+	done map[string]bool
+}
+
+// action returns the name of the action of the step
+func (s *PipelineStep) action() string {
+	var actions []string
+	for name, set := range map[string]bool{
+		"reserve_ips": s.ReserveIPs != nil,
+		"ssh_key":     s.SSHKey != nil,
+		"vlan":        s.VLAN != nil,
+		"devices":     s.Devices != nil,
+		"attach_vlan": s.AttachVLAN != nil,
+		"run_script":  s.RunScript != nil,
+	} {
+		if set {
+			actions = append(actions, name)
+		}
+	}
+	if len(actions) != 1 {
+		return ""
+	}
+	return actions[0]
+}
+
+// hostnames returns the hostnames of the devices of the step
+func (s *DevicesStep) hostnames() []string {
+	if s.Count == 1 {
+		return []string{s.Hostname}
+	}
+	names := make([]string, s.Count)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", s.Hostname, i+1)
+	}
+	return names
+}
+
+func (s *PipelineStep) String() string {
+	switch {
+	case s.ReserveIPs != nil:
+		return "reserve " + s.ReserveIPs.Block + " in " + location(s.ReserveIPs.Metro, s.ReserveIPs.Facility)
+	case s.SSHKey != nil:
+		return "upload SSH key " + s.SSHKey.Label
+	case s.VLAN != nil:
+		return fmt.Sprintf("create VLAN %q in %s", s.VLAN.Description, location(s.VLAN.Metro, s.VLAN.Facility))
+	case s.Devices != nil:
+		return fmt.Sprintf("create %s (%s %s in %s)", strings.Join(s.Devices.hostnames(), ", "), s.Devices.Plan, s.Devices.OS, location(s.Devices.Metro, s.Devices.Facility))
+	case s.AttachVLAN != nil:
+		return "attach the VLAN to " + s.AttachVLAN.Port + " of the devices"
+	case s.RunScript != nil:
+		return "run " + s.RunScript.Script + " on the devices"
+	}
+	return s.action()
+}
+
+// location returns the metro, or the facility
+func location(metro, facility string) string {
+	if metro != "" {
+		return metro
+	}
+	return facility
+}
+
+// loadPipeline reads a YAML or JSON (by .json extension) pipeline file and
+// checks that every step can run: its fields are complete and the steps
+// it builds on come before it
+func loadPipeline(path string) (*Pipeline, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := new(Pipeline)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, p)
+	} else {
+		err = yamlUnmarshal(data, p)
+	}
+	if err != nil {
+		return nil, usageErrorf("%s: %s", path, err)
+	}
+	if len(p.Steps) == 0 {
+		return nil, usageErrorf("%s: no steps", path)
+	}
+
+	var haveVLAN, haveDevices bool
+	for i := range p.Steps {
+		s := &p.Steps[i]
+		action := s.action()
+		if action == "" {
+			return nil, usageErrorf("%s: step #%d needs exactly one of reserve_ips, ssh_key, vlan, devices, attach_vlan or run_script", path, i+1)
+		}
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("%d %s", i+1, action)
+		}
+		fail := func(format string, args ...interface{}) error {
+			return usageErrorf("%s: step %s: %s", path, s.Name, fmt.Sprintf(format, args...))
+		}
+		if s.Retries < 0 {
+			return nil, fail("retries cannot be negative")
+		}
+		switch action {
+		case "reserve_ips":
+			if _, err := parseIPBlock(s.ReserveIPs.Block); err != nil {
+				return nil, fail("%v", err)
+			}
+			if s.ReserveIPs.Metro == "" && s.ReserveIPs.Facility == "" {
+				s.ReserveIPs.Metro, s.ReserveIPs.Facility = p.Metro, p.Facility
+			}
+		case "ssh_key":
+			if s.SSHKey.Label == "" {
+				return nil, fail("label is needed")
+			}
+			if (s.SSHKey.Key == "") == (s.SSHKey.KeyFile == "") {
+				return nil, fail("either key or key_file is needed")
+			}
+			if s.SSHKey.KeyFile != "" {
+				if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(s.SSHKey.KeyFile, "~/") {
+					s.SSHKey.KeyFile = filepath.Join(home, s.SSHKey.KeyFile[2:])
+				}
+				key, err := ioutil.ReadFile(s.SSHKey.KeyFile)
+				if err != nil {
+					return nil, fail("%v", err)
+				}
+				s.SSHKey.Key = strings.TrimSpace(string(key))
+			}
+		case "vlan":
+			if s.VLAN.Metro == "" && s.VLAN.Facility == "" {
+				s.VLAN.Metro, s.VLAN.Facility = p.Metro, p.Facility
+			}
+			haveVLAN = true
+		case "devices":
+			d := s.Devices
+			if d.Count == 0 {
+				d.Count = 1
+			}
+			if d.Count < 0 {
+				return nil, fail("count cannot be negative")
+			}
+			if strings.TrimSpace(d.Hostname) == "" {
+				return nil, fail("hostname is needed")
+			}
+			if d.Metro == "" && d.Facility == "" {
+				d.Metro, d.Facility = p.Metro, p.Facility
+			}
+			if d.BillingCycle != "" {
+				if err := new(BillingCycle).Set(d.BillingCycle.String()); err != nil {
+					return nil, fail("%v", err)
+				}
+			}
+			if _, err := d.request(""); err != nil {
+				return nil, fail("plan, os and a facility or metro are needed")
+			}
+			d.created = map[string]*Device{}
+			haveDevices = true
+		case "attach_vlan":
+			if !haveVLAN || !haveDevices {
+				return nil, fail("a vlan and a devices step must come first")
+			}
+			if s.AttachVLAN.Port == "" {
+				s.AttachVLAN.Port = "eth1"
+			}
+		case "run_script":
+			if !haveDevices {
+				return nil, fail("a devices step must come first")
+			}
+			if _, err := os.Stat(s.RunScript.Script); err != nil {
+				return nil, fail("%v", err)
+			}
+			s.RunScript.done = map[string]bool{}
+		}
+	}
+	return p, nil
+}
+
+// pipelineRun holds what the steps of a pipeline created, for the steps
+// after them and for the rollback
+type pipelineRun struct {
+	client    *Client
+	projectID string
+
+	mu             sync.Mutex
+	IPReservations []*IPReservation  `json:"ip_reservations"`
+	SSHKeys        []*SSHKey         `json:"ssh_keys"`
+	VLANs          []*VirtualNetwork `json:"vlans"`
+	Devices        []*Device         `json:"devices"`
+	undo           undoStack
+}
+
+// run runs the steps in order, trying a failing step again after a pause
+// that doubles from 5 seconds
+func (r *pipelineRun) run(ctx context.Context, steps []PipelineStep) error {
+	for i := range steps {
+		s := &steps[i]
+		logger.Info(fmt.Sprintf("Step %d/%d: %s", i+1, len(steps), s), "step", s.Name)
+		backoff := 5 * time.Second
+		for attempt := 0; ; attempt++ {
+			err := r.step(ctx, s)
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil || attempt >= s.Retries || exitCodeOf(err) == exitUsage {
+				return fmt.Errorf("step %s: %w", s.Name, err)
+			}
+			logger.Warn("Step failed, trying again", "step", s.Name, "attempt", attempt+1, "retries", s.Retries, "error", err)
+			if err := sleep(ctx, backoff); err != nil {
+				return fmt.Errorf("step %s: %w", s.Name, err)
+			}
+			backoff *= 2
+		}
+	}
+	return nil
+}
+
+func (r *pipelineRun) step(ctx context.Context, s *PipelineStep) error {
+	c := r.client
+	switch {
+	case s.ReserveIPs != nil:
+		req, _ := parseIPBlock(s.ReserveIPs.Block)
+		req.Metro, req.Facility = s.ReserveIPs.Metro, s.ReserveIPs.Facility
+		req.Comments, req.Tags = s.ReserveIPs.Description, s.ReserveIPs.Tags
+		ip, err := requestIPReservation(ctx, r.projectID, req, c)
+		if err != nil {
+			return err
+		}
+		r.IPReservations = append(r.IPReservations, ip)
+		r.undo.push("release IP reservation "+ip.ID, func(ctx context.Context) error {
+			return releaseIPReservation(ctx, ip.ID, c)
+		})
+	case s.SSHKey != nil:
+		key, err := createSSHKey(ctx, r.projectID, s.SSHKey.Label, s.SSHKey.Key, c)
+		if err != nil {
+			return err
+		}
+		r.SSHKeys = append(r.SSHKeys, key)
+		r.undo.push("delete SSH key "+key.ID, func(ctx context.Context) error {
+			return deleteSSHKey(ctx, key.ID, c)
+		})
+	case s.VLAN != nil:
+		v, err := createVLAN(ctx, r.projectID, &VirtualNetworkRequest{
+			Metro: s.VLAN.Metro, Facility: s.VLAN.Facility, VXLAN: s.VLAN.VXLAN, Description: s.VLAN.Description,
+		}, c)
+		if err != nil {
+			return err
+		}
+		r.VLANs = append(r.VLANs, v)
+		r.undo.push("delete VLAN "+v.ID, func(ctx context.Context) error {
+			return deleteVLAN(ctx, v.ID, c)
+		})
+	case s.Devices != nil:
+		return r.createDevices(ctx, s.Devices)
+	case s.AttachVLAN != nil:
+		vlan := r.VLANs[len(r.VLANs)-1]
+		for _, d := range r.Devices {
+			if err := attachVLAN(ctx, r.client, &r.undo, d, s.AttachVLAN.Port, vlan); err != nil {
+				return fmt.Errorf("%s: %w", d.Hostname, err)
+			}
+		}
+	case s.RunScript != nil:
+		for _, d := range r.Devices {
+			if s.RunScript.done[d.ID] {
+				continue
+			}
+			if err := waitForSSH(ctx, d, sshTimeout); err != nil {
+				return err
+			}
+			code, err := runRemoteScript(ctx, d, s.RunScript.Script)
+			if err != nil {
+				return fmt.Errorf("%s: %w", d.Hostname, err)
+			}
+			if code != 0 {
+				return fmt.Errorf("%s: %s exited with status %d", d.Hostname, s.RunScript.Script, code)
+			}
+			s.RunScript.done[d.ID] = true
+		}
+	}
+	return nil
+}
+
+// createDevices creates the devices of the step that are not active yet,
+// in parallel. A device that fails to become active is deleted at once, so
+// that a retry does not leave it behind.
+func (r *pipelineRun) createDevices(ctx context.Context, step *DevicesStep) error {
+	var (
+		wg       sync.WaitGroup
+		failures []string
+		lastErr  error
+	)
+	for _, hostname := range step.hostnames() {
+		if step.created[hostname] != nil {
+			continue
+		}
+		spec := step.DeviceSpec
+		spec.Hostname = hostname
+		req, err := spec.request(r.projectID)
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := CreateDevice(ctx, r.client, req, provisionTimeout)
+			if err != nil && res != nil && res.Device != nil {
+				cleanupCtx, cancel := cleanupContext(ctx)
+				if _, delErr := DeleteDevice(cleanupCtx, r.client, res.Device.ID); delErr != nil {
+					pushDeleteDevice(&r.undo, r.client, res.Device)
+				}
+				cancel()
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if err != nil {
+				failures = append(failures, req.Hostname)
+				lastErr = err
+				return
+			}
+			d := res.Device
+			step.created[req.Hostname] = d
+			r.Devices = append(r.Devices, d)
+			pushDeleteDevice(&r.undo, r.client, d)
+			logger.Info("Device active", "hostname", d.Hostname, "device", d.ID, "duration", res.ProvisionTime)
+		}()
+	}
+	wg.Wait()
+	if len(failures) == 1 {
+		return fmt.Errorf("%s: %w", failures[0], lastErr)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d devices failed (%s), last error: %w", len(failures), strings.Join(failures, ", "), lastErr)
+	}
+	return nil
+}
+
+// attachVLAN attaches the VLAN to the port of the device, making the
+// device hybrid first when the port is in the layer-3 bond. Detaching it
+// again is pushed on undo.
+func attachVLAN(ctx context.Context, c *Client, undo *undoStack, d *Device, portName string, vlan *VirtualNetwork) error {
+	dev, err := getDevice(ctx, d.ID, c)
+	if err != nil {
+		return err
+	}
+	port := dev.Port(portName)
+	if port == nil {
+		return fmt.Errorf("device %s has no port %s", dev.ID, portName)
+	}
+	for _, v := range port.VirtualNetworks {
+		if strings.HasSuffix(attrString(v, "href"), "/"+vlan.ID) {
+			return nil
+		}
+	}
+	if portName == "eth1" {
+		if bond := dev.Port("bond0"); bond != nil && bond.NetworkType == NetworkLayer3 {
+			if err := convertNetworkType(ctx, dev, NetworkHybrid, c); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := assignPortVLAN(ctx, port.ID, vlan.ID, c); err != nil {
+		return err
+	}
+	undo.push("detach VLAN "+vlan.ID+" from "+dev.Hostname, func(ctx context.Context) error {
+		_, err := unassignPortVLAN(ctx, port.ID, vlan.ID, c)
+		return err
+	})
+	return nil
+}
+
+func runPipeline(ctx context.Context, args []string) error {
+	fs := newFlagSet("pipeline")
+	file := fs.String("f", "pipeline.yaml", "Pipeline file (YAML or JSON)")
+	fs.DurationVar(&provisionTimeout, "provision-timeout", DefaultProvisionTimeout, "How long to wait for created devices to become active, 0 for no limit")
+	addSSHFlags(fs)
+	addCostFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+
+	p, err := loadPipeline(*file)
+	if err != nil {
+		return err
+	}
+	if p.Project != "" && !isFlagPassed(fs, "prid") {
+		projectID = p.Project
+	}
+	if dryRun {
+		for i := range p.Steps {
+			fmt.Printf("%d. %s\n", i+1, &p.Steps[i])
+		}
+		return nil
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	var creates []*DeviceRequest
+	for _, s := range p.Steps {
+		if s.Devices == nil {
+			continue
+		}
+		for _, hostname := range s.Devices.hostnames() {
+			spec := s.Devices.DeviceSpec
+			spec.Hostname = hostname
+			req, _ := spec.request(projectID)
+			creates = append(creates, req)
+		}
+	}
+	if err := checkHourlyCost(ctx, client, billedPlans(creates...)); err != nil {
+		return err
+	}
+
+	r := &pipelineRun{client: client, projectID: projectID}
+	err = r.run(ctx, p.Steps)
+	if err != nil {
+		logger.Error("Pipeline failed", "error", err)
+		r.undo.rollback(ctx, err)
+		return err
+	}
+
+	if jsonOutput() {
+		prettyPrint(r)
+		return nil
+	}
+	fmt.Printf("Pipeline %s finished\n", *file)
+	for _, ip := range r.IPReservations {
+		fmt.Printf("IP reservation %s  %s/%d\n", ip.ID, ip.Network, ip.CIDR)
+	}
+	for _, k := range r.SSHKeys {
+		fmt.Printf("SSH key         %s  %s\n", k.ID, k.Label)
+	}
+	for _, v := range r.VLANs {
+		fmt.Printf("VLAN            %s  %d %s\n", v.ID, v.VXLAN, v.Description)
+	}
+	for _, d := range r.Devices {
+		fmt.Printf("Device          %s  %s %s\n", d.ID, d.Hostname, d.PublicIPv4())
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+var (
+	preferGreen  bool
+	greenMetros  string
+	metroOptions map[string]*MetroInfo
+)
+
+// MetroInfo annotates a metro with sustainability metadata
+type MetroInfo struct {
+	// CarbonIntensity of the local grid in gCO2eq/kWh, 0 when unknown
+	CarbonIntensity float64 `json:"carbon_intensity,omitempty"`
+	// Renewable is the share of renewable energy, between 0 and 1
+	Renewable float64 `json:"renewable,omitempty"`
+	// Preferred metros win over all others when they have capacity
+	Preferred bool `json:"preferred,omitempty"`
+}
+
+// capacityRequest asks whether servers of a plan are available in metros
+type capacityRequest struct {
+	Servers []capacityServer `json:"servers"`
+}
+
+type capacityServer struct {
+	Metro     string `json:"metro"`
+	Plan      string `json:"plan"`
+	Quantity  int    `json:"quantity"`
+	Available bool   `json:"available,omitempty"`
+}
+
+// placeGreen picks the most sustainable metro among the candidates that has
+// capacity for the plan and explains which factor decided the placement
+func placeGreen(ctx context.Context, c *Client, plan string) (string, string, error) {
+	if c.Flavor() != FlavorEquinixMetal {
+		return "", "", usageErrorf("--prefer-green places devices in metros, which requires the Equinix Metal API (--api-url %s)", EquinixMetalURL)
+	}
+	candidates := splitList(greenMetros)
+	if len(candidates) == 0 {
+		for code := range metroOptions {
+			candidates = append(candidates, code)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", "", usageErrorf("--prefer-green needs metros annotated in the metros section of %s or given by --metros", configPath())
+	}
+
+	req := &capacityRequest{}
+	for _, code := range candidates {
+		req.Servers = append(req.Servers, capacityServer{Metro: code, Plan: plan, Quantity: 1})
+	}
+	// the capacity query is a POST that changes nothing, a dry run needs
+	// its answer to show where the device would go
+	resp := new(capacityRequest)
+	if err := c.DoRequest(ReadOnly(ctx), "capacity/metros", "POST", req, resp, nil); err != nil {
+		return "", "", err
+	}
+	available := map[string]bool{}
+	for _, s := range resp.Servers {
+		available[s.Metro] = s.Available
+	}
+
+	ranked := rankMetros(candidates)
+	for i, code := range ranked {
+		if !available[code] {
+			continue
+		}
+		if i > 0 {
+			return code, fmt.Sprintf("capacity, %s had none for %s", strings.Join(ranked[:i], ", "), plan), nil
+		}
+		for _, next := range ranked[1:] {
+			if available[next] {
+				return code, decidingFactor(code, next), nil
+			}
+		}
+		return code, "only metro with capacity", nil
+	}
+	return "", "", &statusError{exitCapacity, fmt.Errorf("none of the metros %s has capacity for %s", strings.Join(ranked, ", "), plan)}
+}
+
+func metroInfo(code string) *MetroInfo {
+	if info := metroOptions[code]; info != nil {
+		return info
+	}
+	return new(MetroInfo)
+}
+
+// carbon returns the carbon intensity used for ranking, unknown ranks last
+func (m *MetroInfo) carbon() float64 {
+	if m.CarbonIntensity == 0 {
+		return math.Inf(1)
+	}
+	return m.CarbonIntensity
+}
+
+// rankMetros orders metros from the most to the least preferred one
+func rankMetros(codes []string) []string {
+	ranked := append([]string(nil), codes...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := metroInfo(ranked[i]), metroInfo(ranked[j])
+		switch {
+		case a.Preferred != b.Preferred:
+			return a.Preferred
+		case a.carbon() != b.carbon():
+			return a.carbon() < b.carbon()
+		case a.Renewable != b.Renewable:
+			return a.Renewable > b.Renewable
+		}
+		return ranked[i] < ranked[j]
+	})
+	return ranked
+}
+
+// decidingFactor describes why the winner ranked above the runner-up
+func decidingFactor(winner, runnerUp string) string {
+	a, b := metroInfo(winner), metroInfo(runnerUp)
+	switch {
+	case a.Preferred != b.Preferred:
+		return fmt.Sprintf("preferred metro over %s", runnerUp)
+	case a.carbon() != b.carbon():
+		if b.CarbonIntensity == 0 {
+			return fmt.Sprintf("carbon intensity %.0f gCO2eq/kWh, unknown for %s", a.CarbonIntensity, runnerUp)
+		}
+		return fmt.Sprintf("carbon intensity %.0f vs %.0f gCO2eq/kWh in %s", a.CarbonIntensity, b.CarbonIntensity, runnerUp)
+	case a.Renewable != b.Renewable:
+		return fmt.Sprintf("renewable share %.0f%% vs %.0f%% in %s", a.Renewable*100, b.Renewable*100, runnerUp)
+	}
+	return fmt.Sprintf("alphabetical order, same sustainability metadata as %s", runnerUp)
+}
+
+// splitList splits a comma separated flag value, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "plan",
+		usage: "Show the changes apply would make to match a device manifest, without making them",
+		run:   runPlan,
+	})
+}
+
+const (
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+// PlanResult is what apply would do, and the drift it cannot change
+type PlanResult struct {
+	Changes []Change `json:"changes"`
+	Drift   []Drift  `json:"drift"`
+}
+
+// planPrinter writes a plan, colored on a terminal
+type planPrinter struct {
+	w     io.Writer
+	color bool
+}
+
+func (p *planPrinter) line(color, format string, args ...interface{}) {
+	text := fmt.Sprintf(format, args...)
+	if p.color && color != "" {
+		text = color + text + resetStyle
+	}
+	fmt.Fprintln(p.w, text)
+}
+
+// change writes a change with the attributes it sets, and for updates the
+// values they replace
+func (p *planPrinter) change(c *Change, current *Device) {
+	switch c.Action {
+	case changeCreate:
+		p.line(colorGreen, "+ %s will be created", c.Hostname)
+		s := c.spec
+		for _, attr := range [][2]string{
+			{"plan", s.Plan}, {"metro", s.Metro}, {"facility", s.Facility}, {"os", s.OS}, {"billing_cycle", s.BillingCycle.String()},
+		} {
+			if attr[1] != "" {
+				p.line(colorGreen, "    + %-14s %s", attr[0]+":", attr[1])
+			}
+		}
+		if s.Tags != nil {
+			p.line(colorGreen, "    + %-14s %s", "tags:", sortedTags(s.Tags))
+		}
+		if s.UserData != "" {
+			p.line(colorGreen, "    + %-14s %d bytes", "userdata:", len(s.UserData))
+		}
+	case changeUpdate:
+		p.line(colorYellow, "~ %s (%s) will be updated in place", c.Hostname, c.DeviceID)
+		p.line(colorYellow, "    ~ %-14s %s -> %s", "tags:", sortedTags(current.Tags), sortedTags(c.Tags))
+	case changeDelete:
+		p.line(colorRed, "- %s (%s) will be deleted", c.Hostname, c.DeviceID)
+		if current != nil {
+			p.line(colorRed, "    - %-14s %s", "plan:", current.PlanSlug())
+			p.line(colorRed, "    - %-14s %s", "location:", current.MetroCode())
+		}
+	}
+}
+
+func runPlan(ctx context.Context, args []string) error {
+	fs := newFlagSet("plan")
+	addCacheFlags(fs)
+	file := fs.String("f", "devices.yaml", "Device manifest file (YAML or JSON)")
+	prune := fs.Bool("prune", false, "Show the deletions of apply --prune, of devices not declared in the manifest")
+	detailed := fs.Bool("detailed-exitcode", false, "Exit with status 2 when there are changes")
+	noColor := fs.Bool("no-color", os.Getenv("NO_COLOR") != "", "Do not color the plan")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+
+	m, err := loadManifest(*file)
+	if err != nil {
+		return err
+	}
+	if m.Project != "" && !isFlagPassed(fs, "prid") {
+		projectID = m.Project
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	devices, err := listDevices(ctx, projectID, client)
+	if err != nil {
+		return err
+	}
+	res := PlanResult{Changes: m.Changes(devices, *prune), Drift: []Drift{}}
+	for _, d := range m.Drift(devices) {
+		if d.Field != "" && d.Field != "tags" {
+			res.Drift = append(res.Drift, d)
+		}
+	}
+	for _, c := range res.Changes {
+		if c.Action == changeCreate {
+			if _, err := c.spec.request(projectID); err != nil {
+				return err
+			}
+		}
+	}
+	if res.Changes == nil {
+		res.Changes = []Change{}
+	}
+
+	if jsonOutput() {
+		prettyPrint(res)
+	} else {
+		fi, err := os.Stdout.Stat()
+		terminal := err == nil && fi.Mode()&os.ModeCharDevice != 0
+		p := &planPrinter{w: os.Stdout, color: terminal && !*noColor}
+		byID := map[string]*Device{}
+		byHostname := map[string]*Device{}
+		for i := range devices {
+			byID[devices[i].ID] = &devices[i]
+			if _, ok := byHostname[devices[i].Hostname]; !ok {
+				byHostname[devices[i].Hostname] = &devices[i]
+			}
+		}
+		for i := range res.Changes {
+			c := &res.Changes[i]
+			p.change(c, byID[c.DeviceID])
+		}
+		for _, d := range res.Drift {
+			p.line("", "! %s (%s): %s %s, manifest wants %s; apply does not recreate devices", d.Hostname, byHostname[d.Hostname].ID, d.Field, d.Got, d.Want)
+		}
+		if len(res.Changes) == 0 {
+			fmt.Printf("No changes, project %s matches %s\n", projectID, *file)
+		} else {
+			counts := map[string]int{}
+			for _, c := range res.Changes {
+				counts[c.Action]++
+			}
+			fmt.Println()
+			fmt.Printf("Plan: %d to create, %d to update, %d to delete.\n", counts[changeCreate], counts[changeUpdate], counts[changeDelete])
+		}
+		if !*prune {
+			undeclared := 0
+			for _, d := range devices {
+				if !hostnameDeclared(m, d.Hostname) {
+					undeclared++
+				}
+			}
+			if undeclared > 0 {
+				fmt.Printf("%d device(s) of the project are not in the manifest and are left alone, --prune shows their deletion\n", undeclared)
+			}
+		}
+	}
+
+	if *detailed && len(res.Changes) > 0 {
+		return exitCode(exitDrift)
+	}
+	return nil
+}
+
+// hostnameDeclared reports whether the manifest declares the hostname
+func hostnameDeclared(m *Manifest, hostname string) bool {
+	for _, s := range m.Devices {
+		if s.Hostname == hostname {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// pluginPrefix starts the names of the executables that add commands, e.g.
+// packet-go-demo-foo runs as packet-go-demo foo
+const pluginPrefix = "packet-go-demo-"
+
+// lookupPlugin finds the plugin of the longest command name made of the
+// leading non-flag arguments, so packet-go-demo-foo-bar is preferred over
+// packet-go-demo-foo for "foo bar". It returns the path of the plugin and
+// the arguments left for it.
+func lookupPlugin(args []string) (string, []string) {
+	n := 0
+	for n < len(args) && n < 3 && !strings.HasPrefix(args[n], "-") {
+		n++
+	}
+	for ; n > 0; n-- {
+		path, err := exec.LookPath(pluginPrefix + strings.Join(args[:n], "-"))
+		if err == nil {
+			return path, args[n:]
+		}
+	}
+	return "", args
+}
+
+// listPlugins returns the command names of the plugins on PATH, a plugin
+// shadowed by one earlier on PATH is listed once
+func listPlugins() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			name := f.Name()
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			if !strings.HasPrefix(name, pluginPrefix) || f.IsDir() || (runtime.GOOS != "windows" && f.Mode()&0111 == 0) {
+				continue
+			}
+			name = strings.Replace(strings.TrimPrefix(name, pluginPrefix), "-", " ", -1)
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pluginEnv returns the environment of a plugin: that of the tool, with the
+// settings resolved from the configuration file and the keyring, so that
+// plugins authenticate like the built-in commands
+func pluginEnv() ([]string, error) {
+	if err := parseFlags(newFlagSet("plugin"), nil); err != nil {
+		return nil, err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]string{
+		"PACKET_GO_DEMO":    self,
+		"PACKET_API_URL":    apiURL,
+		"PACKET_PROJECT_ID": projectID,
+		"PACKET_PROFILE":    profileName,
+	}
+	switch {
+	case tokenCommand != "":
+		settings["PACKET_TOKEN_COMMAND"] = tokenCommand
+	case len(profileTokens) > 0:
+		tok, err := newTokenPool(profileTokens, projectID, false).Token()
+		if err != nil {
+			return nil, err
+		}
+		settings["PACKET_AUTH_TOKEN"] = tok.Value
+	default:
+		settings["PACKET_AUTH_TOKEN"] = token
+	}
+
+	env := os.Environ()
+	for key, value := range settings {
+		if value != "" {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env, nil
+}
+
+// runPlugin runs the plugin with the arguments and returns its exit status.
+// Ctrl-C reaches the plugin directly, the tool waits for it to exit.
+func runPlugin(path string, args []string) int {
+	env, err := pluginEnv()
+	if err != nil {
+		if _, reported := err.(exitCode); !reported {
+			logRunError(err)
+		}
+		return exitCodeOf(err)
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code >= 0 {
+			return code
+		}
+		// killed by a signal
+		return interruptedExitCode
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Running plugin %s failed", path), "error", err)
+		return 1
+	}
+	return 0
+}
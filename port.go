@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nurfet-becirevic/packet-go-demo/packet"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "port list",
+		usage: "List the network ports of a device",
+		run:   runPortList,
+	})
+	registerCommand(&command{
+		name:  "port bond",
+		usage: "Add a port to its bond",
+		run:   func(ctx context.Context, args []string) error { return runPortAction(ctx, "bond", args) },
+	})
+	registerCommand(&command{
+		name:  "port disbond",
+		usage: "Take a port out of its bond",
+		run:   func(ctx context.Context, args []string) error { return runPortAction(ctx, "disbond", args) },
+	})
+	registerCommand(&command{
+		name:  "port convert",
+		usage: "Convert a bond port to layer-2 or layer-3",
+		run:   func(ctx context.Context, args []string) error { return runPortAction(ctx, "convert", args) },
+	})
+	registerCommand(&command{
+		name:  "port assign",
+		usage: "Attach a VLAN to a port",
+		run:   func(ctx context.Context, args []string) error { return runPortAction(ctx, "assign", args) },
+	})
+	registerCommand(&command{
+		name:  "port unassign",
+		usage: "Detach a VLAN from a port",
+		run:   func(ctx context.Context, args []string) error { return runPortAction(ctx, "unassign", args) },
+	})
+	registerCommand(&command{
+		name:  "device network-type",
+		usage: "Convert the network of a device to layer3, hybrid, layer2-bonded or layer2-individual",
+		run:   runDeviceNetworkType,
+	})
+}
+
+// portAction runs an action such as bond or convert/layer-2 on a port
+func portAction(ctx context.Context, portID, action string, req interface{}, c *Client) (*Port, error) {
+	p := new(Port)
+	if err := c.DoRequest(ctx, "ports/"+portID+"/"+action, "POST", req, p, nil); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// bondPort adds the port to its bond, with bulk all ports of the device
+func bondPort(ctx context.Context, portID string, bulk bool, c *Client) (*Port, error) {
+	return portAction(ctx, portID, "bond", map[string]bool{"bulk_enable": bulk}, c)
+}
+
+// disbondPort takes the port out of its bond, with bulk all ports of the
+// device
+func disbondPort(ctx context.Context, portID string, bulk bool, c *Client) (*Port, error) {
+	return portAction(ctx, portID, "disbond", map[string]bool{"bulk_disable": bulk}, c)
+}
+
+// convertPort converts a bond port to layer-2 or layer-3
+func convertPort(ctx context.Context, portID, layer string, c *Client) (*Port, error) {
+	switch layer {
+	case "layer2", "layer-2":
+		return portAction(ctx, portID, "convert/layer-2", nil, c)
+	case "layer3", "layer-3":
+		return portAction(ctx, portID, "convert/layer-3", nil, c)
+	}
+	return nil, usageErrorf("unknown layer %q, use layer2 or layer3", layer)
+}
+
+// assignPortVLAN attaches a VLAN, given by ID or VXLAN, to the port
+func assignPortVLAN(ctx context.Context, portID, vlan string, c *Client) (*Port, error) {
+	return portAction(ctx, portID, "assign", map[string]string{"vnid": vlan}, c)
+}
+
+// unassignPortVLAN detaches a VLAN, given by ID or VXLAN, from the port
+func unassignPortVLAN(ctx context.Context, portID, vlan string, c *Client) (*Port, error) {
+	return portAction(ctx, portID, "unassign", map[string]string{"vnid": vlan}, c)
+}
+
+func runPortList(ctx context.Context, args []string) error {
+	fs := newFlagSet("port list")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo port list [flags] <device-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	dev, err := getDevice(ctx, fs.Arg(0), newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if dev.NetworkPorts == nil {
+			dev.NetworkPorts = []Port{}
+		}
+		prettyPrint(dev.NetworkPorts)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tBONDED\tNETWORK TYPE\tVLANS")
+	for _, p := range dev.NetworkPorts {
+		networkType := p.NetworkType
+		if networkType == "" {
+			networkType = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%d\n", p.Name, p.ID, p.Data.Bonded, networkType, len(p.VirtualNetworks))
+	}
+	return w.Flush()
+}
+
+// runPortAction runs the port commands, which all take a port ID and bond
+// and disbond a --bulk flag
+func runPortAction(ctx context.Context, action string, args []string) error {
+	fs := newFlagSet("port " + action)
+	bulk := fs.Bool("bulk", false, "Bond or disbond all ports of the device")
+	operand := map[string]string{"convert": " <layer2|layer3>", "assign": " <vlan>", "unassign": " <vlan>"}[action]
+	fs.Usage = func() {
+		fmt.Printf("Usage: packet-go-demo port %s [flags] <port-id>%s\n", action, operand)
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	want := 1
+	if operand != "" {
+		want = 2
+	}
+	if fs.NArg() != want {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if *bulk && action != "bond" && action != "disbond" {
+		return usageErrorf("--bulk only applies to bond and disbond")
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	portID := fs.Arg(0)
+	var (
+		p   *Port
+		err error
+	)
+	switch action {
+	case "bond":
+		p, err = bondPort(ctx, portID, *bulk, client)
+	case "disbond":
+		p, err = disbondPort(ctx, portID, *bulk, client)
+	case "convert":
+		p, err = convertPort(ctx, portID, fs.Arg(1), client)
+	case "assign":
+		p, err = assignPortVLAN(ctx, portID, fs.Arg(1), client)
+	case "unassign":
+		p, err = unassignPortVLAN(ctx, portID, fs.Arg(1), client)
+	}
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(p)
+		return nil
+	}
+	logger.Info(fmt.Sprintf("Port %s: %s done", p.Name, action), "port", p.ID, "bonded", p.Data.Bonded, "network_type", p.NetworkType)
+	return nil
+}
+
+func runDeviceNetworkType(ctx context.Context, args []string) error {
+	fs := newFlagSet("device network-type")
+	fs.Usage = func() {
+		fmt.Printf("Usage: packet-go-demo device network-type [flags] <device-id> <%s>\n", strings.Join(packet.NetworkTypes, "|"))
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	var networkType NetworkType
+	if err := networkType.Set(fs.Arg(1)); err != nil {
+		return usageErrorf("%v", err)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	dev, err := getDevice(ctx, fs.Arg(0), client)
+	if err != nil {
+		return err
+	}
+	if err := convertNetworkType(ctx, dev, networkType, client); err != nil {
+		return err
+	}
+	logger.Info("Device "+dev.ID+" converted to "+fs.Arg(1), "device", dev.ID)
+	return nil
+}
+
+// convertNetworkType runs the port actions that give the device the
+// network type, starting from any other type
+func convertNetworkType(ctx context.Context, dev *Device, networkType NetworkType, c *Client) error {
+	bond := dev.Port("bond0")
+	if bond == nil {
+		return fmt.Errorf("device %s has no bond0 port", dev.ID)
+	}
+
+	type step struct {
+		desc string
+		do   func() (*Port, error)
+	}
+	bondAll := step{"bond all ports", func() (*Port, error) { return bondPort(ctx, bond.ID, true, c) }}
+	toLayer3 := step{"convert bond0 to layer-3", func() (*Port, error) { return convertPort(ctx, bond.ID, "layer3", c) }}
+	toLayer2 := step{"convert bond0 to layer-2", func() (*Port, error) { return convertPort(ctx, bond.ID, "layer2", c) }}
+
+	var steps []step
+	switch networkType {
+	case NetworkLayer3:
+		steps = []step{bondAll, toLayer3}
+	case NetworkLayer2Bonded:
+		steps = []step{bondAll, toLayer2}
+	case NetworkLayer2Individual:
+		steps = []step{toLayer2, {"disbond all ports", func() (*Port, error) { return disbondPort(ctx, bond.ID, true, c) }}}
+	case NetworkHybrid:
+		eth1 := dev.Port("eth1")
+		if eth1 == nil {
+			return fmt.Errorf("device %s has no eth1 port to take out of the bond", dev.ID)
+		}
+		steps = []step{bondAll, toLayer3, {"disbond eth1", func() (*Port, error) { return disbondPort(ctx, eth1.ID, false, c) }}}
+	default:
+		return usageErrorf("unknown network type %q, use one of %s", networkType, strings.Join(packet.NetworkTypes, ", "))
+	}
+
+	if bond.NetworkType == networkType {
+		return fmt.Errorf("device %s is already %s", dev.ID, networkType)
+	}
+	for _, s := range steps {
+		logger.Info("Port action: "+s.desc, "device", dev.ID)
+		if _, err := s.do(); err != nil {
+			return fmt.Errorf("%s: %w", s.desc, err)
+		}
+	}
+	return nil
+}
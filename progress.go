@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// noProgress hides the provisioning progress, set by --no-progress
+var noProgress bool
+
+// provisionProgress is what is known of a device being provisioned
+type provisionProgress struct {
+	hostname string
+	plan     string
+	start    time.Time
+	estimate time.Duration
+
+	state   DeviceState
+	percent float64
+	event   string
+}
+
+// newProvisionProgress starts showing the progress of a device, nil when
+// progress is not shown. Remove it from progressUI once done.
+func newProvisionProgress(req *DeviceRequest, start time.Time) *provisionProgress {
+	if !progressUI.enabled() {
+		return nil
+	}
+	p := &provisionProgress{
+		hostname: req.Hostname,
+		plan:     req.Plan,
+		start:    start,
+		estimate: estimateProvisionTime(req.Plan, requestLocation(req)),
+		state:    StateQueued,
+	}
+	progressUI.add(p)
+	return p
+}
+
+// poll takes in the device and the latest event of its provisioning
+func (p *provisionProgress) poll(ctx context.Context, dev *Device, c *Client) {
+	var event string
+	if events, err := listEvents(ctx, "devices/"+dev.ID+"/events", 1, c); err == nil && len(events) > 0 {
+		event = events[0].Message()
+	}
+	progressUI.update(func() {
+		p.state = dev.State
+		p.percent = dev.ProvisioningPercentage
+		if event != "" {
+			p.event = event
+		}
+	})
+}
+
+const progressBarWidth = 20
+
+// line renders the progress, e.g.
+// web-1  provisioning  [########------------]  40%  1m12s elapsed, about 1m50s left  Installing OS
+func (p *provisionProgress) line(now time.Time) string {
+	elapsed := now.Sub(p.start).Round(time.Second)
+	fraction := p.percent / 100
+	if fraction <= 0 && p.estimate > 0 {
+		fraction = float64(elapsed) / float64(p.estimate)
+	}
+	if fraction > 0.99 {
+		fraction = 0.99
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %s  ", p.hostname, p.state)
+	if fraction > 0 {
+		done := int(fraction * progressBarWidth)
+		fmt.Fprintf(&b, "[%s%s] %3.0f%%  ", strings.Repeat("#", done), strings.Repeat("-", progressBarWidth-done), fraction*100)
+	}
+	fmt.Fprintf(&b, "%s elapsed", elapsed)
+	switch {
+	case p.estimate == 0:
+		b.WriteString(", no estimate yet")
+	case elapsed < p.estimate:
+		fmt.Fprintf(&b, ", about %s left", (p.estimate - elapsed).Round(time.Second))
+	default:
+		fmt.Fprintf(&b, ", longer than the usual %s", p.estimate.Round(time.Second))
+	}
+	if p.event != "" {
+		b.WriteString("  " + p.event)
+	}
+	return b.String()
+}
+
+// progressBoard draws a line per device being provisioned at the bottom of
+// stderr, redrawn every second. Log lines are written above it.
+type progressBoard struct {
+	mu      sync.Mutex
+	entries []*provisionProgress
+	lines   int
+	stop    chan struct{}
+}
+
+var progressUI = &progressBoard{}
+
+// enabled reports whether progress is shown: on a terminal, with text
+// output and logs, and without --no-progress
+func (b *progressBoard) enabled() bool {
+	if noProgress || outputFormat != "text" || logFormat != "text" {
+		return false
+	}
+	fi, err := os.Stderr.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (b *progressBoard) add(p *provisionProgress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = append(b.entries, p)
+	if b.stop == nil {
+		b.stop = make(chan struct{})
+		go b.redraw(b.stop)
+	}
+	b.drawLocked()
+}
+
+// remove stops showing the progress, nil is ignored
+func (b *progressBoard) remove(p *provisionProgress) {
+	if p == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, e := range b.entries {
+		if e == p {
+			b.entries = append(b.entries[:i], b.entries[i+1:]...)
+			break
+		}
+	}
+	b.clearLocked()
+	if len(b.entries) == 0 && b.stop != nil {
+		close(b.stop)
+		b.stop = nil
+	}
+	b.drawLocked()
+}
+
+func (b *progressBoard) update(f func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f()
+	b.clearLocked()
+	b.drawLocked()
+}
+
+// suspend clears the progress while write prints, e.g. a log line
+func (b *progressBoard) suspend(write func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clearLocked()
+	write()
+	b.drawLocked()
+}
+
+func (b *progressBoard) redraw(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			b.update(func() {})
+		}
+	}
+}
+
+func (b *progressBoard) clearLocked() {
+	if b.lines > 0 {
+		fmt.Fprintf(os.Stderr, "\033[%dA\033[J", b.lines)
+		b.lines = 0
+	}
+}
+
+func (b *progressBoard) drawLocked() {
+	now := time.Now()
+	// lines are cut at the edge of the terminal instead of wrapping, so
+	// that clearLocked knows how many lines to clear
+	for _, p := range b.entries {
+		fmt.Fprint(os.Stderr, "\033[?7l"+p.line(now)+"\033[?7h\n")
+	}
+	b.lines = len(b.entries)
+}
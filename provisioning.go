@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "stats provisioning",
+		usage: "Show how long devices took to provision and how often it failed, per plan and location",
+		run:   runStatsProvisioning,
+	})
+}
+
+// provisioning attempts kept in the history, the oldest are dropped first
+const maxProvisionRecords = 1000
+
+// recent successful provisionings an estimate is based on
+const maxProvisionSamples = 20
+
+var provisionHistoryMu sync.Mutex
+
+// provisionRecord is a provisioning attempt of the history
+type provisionRecord struct {
+	Time     time.Time `json:"time"`
+	Plan     string    `json:"plan"`
+	Location string    `json:"location"`
+	OS       string    `json:"os"`
+	Seconds  float64   `json:"seconds"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// provisionHistoryFile stores the provisioning attempts of every run
+func provisionHistoryFile() string {
+	return filepath.Join(userCacheDir(), "provisioning.json")
+}
+
+// requestLocation is the metro or first facility the device is requested in
+func requestLocation(req *DeviceRequest) string {
+	if req.Metro == "" && len(req.Facility) > 0 {
+		return req.Facility[0]
+	}
+	return req.Metro
+}
+
+func loadProvisionHistory() []provisionRecord {
+	var records []provisionRecord
+	data, err := ioutil.ReadFile(provisionHistoryFile())
+	if err == nil {
+		if err := json.Unmarshal(data, &records); err != nil {
+			logger.Debug("Ignoring unreadable provisioning history", "file", provisionHistoryFile(), "error", err)
+		}
+	}
+	return records
+}
+
+// recordProvisioning adds a provisioning attempt of the request to the
+// history, err is nil when the device became active
+func recordProvisioning(req *DeviceRequest, d time.Duration, err error) {
+	r := provisionRecord{
+		Time:     time.Now().UTC(),
+		Plan:     req.Plan,
+		Location: requestLocation(req),
+		OS:       req.OS,
+		Seconds:  d.Seconds(),
+		Success:  err == nil,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	provisionHistoryMu.Lock()
+	defer provisionHistoryMu.Unlock()
+	records := append(loadProvisionHistory(), r)
+	if len(records) > maxProvisionRecords {
+		records = records[len(records)-maxProvisionRecords:]
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(provisionHistoryFile()), 0700); err != nil {
+		logger.Debug("Cannot store provisioning history", "error", err)
+		return
+	}
+	if err := ioutil.WriteFile(provisionHistoryFile(), append(data, '\n'), 0600); err != nil {
+		logger.Debug("Cannot store provisioning history", "error", err)
+	}
+}
+
+// estimateProvisionTime returns the median of the recent successful
+// provisioning times of the plan in the location, 0 when there are none
+func estimateProvisionTime(plan, location string) time.Duration {
+	provisionHistoryMu.Lock()
+	records := loadProvisionHistory()
+	provisionHistoryMu.Unlock()
+
+	var samples []float64
+	for i := len(records) - 1; i >= 0 && len(samples) < maxProvisionSamples; i-- {
+		r := records[i]
+		if r.Success && r.Plan == plan && r.Location == location {
+			samples = append(samples, r.Seconds)
+		}
+	}
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Float64s(samples)
+	return time.Duration(samples[len(samples)/2] * float64(time.Second))
+}
+
+// ProvisioningStats sums up the provisioning attempts of a plan in a
+// location
+type ProvisioningStats struct {
+	Plan     string   `json:"plan"`
+	Location string   `json:"location"`
+	Attempts int      `json:"attempts"`
+	Failed   int      `json:"failed"`
+	Average  Duration `json:"average"`
+	Median   Duration `json:"median"`
+	Fastest  Duration `json:"fastest"`
+	Slowest  Duration `json:"slowest"`
+}
+
+// FailureRate is the share of attempts that failed, from 0 to 1
+func (s *ProvisioningStats) FailureRate() float64 {
+	return float64(s.Failed) / float64(s.Attempts)
+}
+
+// provisioningStats groups the records by plan and location. Durations
+// are of successful attempts only. Plans are sorted by name and the
+// locations of a plan fastest first.
+func provisioningStats(records []provisionRecord) []ProvisioningStats {
+	byKey := map[[2]string][]provisionRecord{}
+	for _, r := range records {
+		key := [2]string{r.Plan, r.Location}
+		byKey[key] = append(byKey[key], r)
+	}
+
+	stats := []ProvisioningStats{}
+	for key, group := range byKey {
+		s := ProvisioningStats{Plan: key[0], Location: key[1], Attempts: len(group)}
+		var durations []time.Duration
+		var total time.Duration
+		for _, r := range group {
+			if !r.Success {
+				s.Failed++
+				continue
+			}
+			d := time.Duration(r.Seconds * float64(time.Second))
+			durations = append(durations, d)
+			total += d
+		}
+		if len(durations) > 0 {
+			sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+			s.Average = Duration(total / time.Duration(len(durations)))
+			s.Median = Duration(durations[len(durations)/2])
+			s.Fastest = Duration(durations[0])
+			s.Slowest = Duration(durations[len(durations)-1])
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Plan != b.Plan {
+			return a.Plan < b.Plan
+		}
+		// locations without a successful attempt last
+		if (a.Average == 0) != (b.Average == 0) {
+			return b.Average == 0
+		}
+		if a.Average != b.Average {
+			return a.Average < b.Average
+		}
+		return a.Location < b.Location
+	})
+	return stats
+}
+
+func runStatsProvisioning(ctx context.Context, args []string) error {
+	fs := newFlagSet("stats provisioning")
+	plan := fs.String("plan", "", "Only show this plan, e.g. c3.small.x86")
+	osSlug := fs.String("os", "", "Only count devices provisioned with this operating system")
+	since := fs.Duration("since", 0, "Only count devices provisioned this recently, e.g. 720h for 30 days")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo stats provisioning [flags]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+
+	provisionHistoryMu.Lock()
+	all := loadProvisionHistory()
+	provisionHistoryMu.Unlock()
+	var records []provisionRecord
+	for _, r := range all {
+		if (*plan == "" || r.Plan == *plan) && (*osSlug == "" || r.OS == *osSlug) && (*since == 0 || time.Since(r.Time) <= *since) {
+			records = append(records, r)
+		}
+	}
+	stats := provisioningStats(records)
+
+	if jsonOutput() {
+		prettyPrint(stats)
+		return nil
+	}
+	if len(stats) == 0 {
+		logger.Info("No devices provisioned yet, they are recorded in " + provisionHistoryFile())
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PLAN\tLOCATION\tDEVICES\tFAILED\tAVERAGE\tMEDIAN\tFASTEST\tSLOWEST")
+	for _, s := range stats {
+		durations := []string{"-", "-", "-", "-"}
+		if s.Average > 0 {
+			durations = []string{s.Average.String(), s.Median.String(), s.Fastest.String(), s.Slowest.String()}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d (%.0f%%)\t%s\t%s\t%s\t%s\n", s.Plan, s.Location, s.Attempts, s.Failed, 100*s.FailureRate(),
+			durations[0], durations[1], durations[2], durations[3])
+	}
+	return w.Flush()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "reaper",
+		usage: "Delete forgotten devices of the project matching age, tag and hostname filters",
+		run:   runReaper,
+	})
+}
+
+// ReaperFilter selects the devices the reaper deletes. Devices must match
+// every filter that is set.
+type ReaperFilter struct {
+	OlderThan      time.Duration
+	Tags           []string
+	HostnamePrefix string
+}
+
+// Match reports whether the device is selected, as of now
+func (f *ReaperFilter) Match(d *Device, now time.Time) bool {
+	if f.OlderThan > 0 {
+		created, err := time.Parse(time.RFC3339, d.Created)
+		if err != nil || now.Sub(created) < f.OlderThan {
+			return false
+		}
+	}
+	for _, tag := range f.Tags {
+		if !hasTag(d.Tags, tag) {
+			return false
+		}
+	}
+	return strings.HasPrefix(d.Hostname, f.HostnamePrefix)
+}
+
+func runReaper(ctx context.Context, args []string) error {
+	fs := newFlagSet("reaper")
+	filter := &ReaperFilter{}
+	fs.DurationVar(&filter.OlderThan, "older-than", 0, "Only delete devices created longer ago than this, e.g. 24h")
+	tags := fs.String("tag", "", "Only delete devices with these tags, comma separated")
+	fs.StringVar(&filter.HostnamePrefix, "hostname-prefix", "", "Only delete devices whose hostname starts with this")
+	yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *tags != "" {
+		filter.Tags = strings.Split(*tags, ",")
+	}
+	if filter.OlderThan <= 0 && len(filter.Tags) == 0 && filter.HostnamePrefix == "" {
+		return usageErrorf("at least one of --older-than, --tag or --hostname-prefix is required, the reaper does not delete every device")
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	devices, err := listDevices(ctx, projectID, client)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var matched []Device
+	for i := range devices {
+		d := &devices[i]
+		if !filter.Match(d, now) {
+			continue
+		}
+		if d.Locked {
+			logger.Warn("Skipping locked device", "device", d.ID, "hostname", d.Hostname)
+			continue
+		}
+		matched = append(matched, *d)
+	}
+
+	if jsonOutput() {
+		if matched == nil {
+			matched = []Device{}
+		}
+		prettyPrint(matched)
+	} else {
+		printDeviceSummary(matched, now)
+	}
+	if len(matched) == 0 || dryRun {
+		return nil
+	}
+
+	if !*yes {
+		ok, err := confirm(fmt.Sprintf("Delete %d device(s) of project %s?", len(matched), projectID))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			logger.Info("Nothing deleted")
+			return nil
+		}
+	}
+
+	failures := 0
+	for _, d := range matched {
+		if _, err := DeleteDevice(ctx, client, d.ID); err != nil {
+			logger.Error("Deleting device failed", "device", d.ID, "hostname", d.Hostname, "error", err)
+			failures++
+			continue
+		}
+		logger.Info("Deleted device", "device", d.ID, "hostname", d.Hostname)
+	}
+	if failures > 0 {
+		return &statusError{exitPartial, fmt.Errorf("%d of %d devices could not be deleted", failures, len(matched))}
+	}
+	return nil
+}
+
+// printDeviceSummary prints the devices about to be acted on, with their
+// age as of now
+func printDeviceSummary(devices []Device, now time.Time) {
+	if len(devices) == 0 {
+		fmt.Println("No devices match")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tHOSTNAME\tSTATE\tAGE\tTAGS")
+	for _, d := range devices {
+		age := ""
+		if created, err := time.Parse(time.RFC3339, d.Created); err == nil {
+			age = now.Sub(created).Round(time.Minute).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.ID, d.Hostname, d.State, age, strings.Join(d.Tags, ","))
+	}
+	w.Flush()
+}
+
+// confirm asks a yes or no question on the terminal. Without a terminal
+// nothing can be confirmed and an error tells to pass --yes instead.
+func confirm(question string) (bool, error) {
+	fi, err := os.Stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false, usageErrorf("not asking for confirmation without a terminal, pass --yes")
+	}
+	fmt.Fprint(os.Stderr, question+" [y/N] ")
+	line, err := promptReader.ReadString('\n')
+	if err != nil && line == "" {
+		return false, nil
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// remote script is streamed over stdin into a temporary file, so that its
+// shebang line is honoured, and removed once it finished
+const runScriptCommand = `f=$(mktemp) && cat > "$f" && chmod +x "$f" && "$f"; rc=$?; rm -f "$f"; exit $rc`
+
+var (
+	sshUser    string
+	sshKey     string
+	sshTimeout time.Duration
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "device exec",
+		usage: "Run a command or script on a device over SSH",
+		run:   runDeviceExec,
+	})
+}
+
+func addSSHFlags(fs *flag.FlagSet) {
+	fs.StringVar(&sshUser, "ssh-user", "root", "User to connect as over SSH")
+	fs.StringVar(&sshKey, "ssh-key", "", "Private key file used for SSH (default ssh client configuration)")
+	fs.DurationVar(&sshTimeout, "ssh-timeout", 5*time.Minute, "How long to wait for the device to accept SSH connections")
+}
+
+func runDeviceExec(ctx context.Context, args []string) error {
+	fs := newFlagSet("device exec")
+	addSSHFlags(fs)
+	script := fs.String("script", "", "Local script to upload and run instead of a command")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo device exec [flags] <device-id> [-- <command>]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	// flag parsing stops at the device ID, so the separator is still there
+	rest := fs.Args()[1:]
+	if len(rest) > 0 && rest[0] == "--" {
+		rest = rest[1:]
+	}
+	remoteCmd := strings.Join(rest, " ")
+	if (remoteCmd == "") == (*script == "") {
+		return usageErrorf("provide either a command after -- or --script")
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	device, err := getDevice(ctx, fs.Arg(0), client)
+	if err != nil {
+		return err
+	}
+
+	var code int
+	if *script != "" {
+		code, err = runRemoteScript(ctx, device, *script)
+	} else {
+		code, err = runRemote(ctx, device, remoteCmd, nil)
+	}
+	if err != nil {
+		return err
+	}
+	return exitStatus(code)
+}
+
+// exitStatus converts a remote exit code to the error returned by a command
+func exitStatus(code int) error {
+	if code != 0 {
+		return exitCode(code)
+	}
+	return nil
+}
+
+// waitForSSH blocks until the device accepts connections on the SSH port
+func waitForSSH(ctx context.Context, device *Device, timeout time.Duration) error {
+	ip := device.PublicIPv4()
+	if ip == "" {
+		return fmt.Errorf("device %s has no public IPv4 address", device.ID)
+	}
+
+	addr := net.JoinHostPort(ip, "22")
+	deadline := time.Now().Add(timeout)
+	for {
+		dialer := net.Dialer{Timeout: 5 * time.Second}
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("device %s is not reachable over SSH: %w", device.ID, err)
+		}
+		if err := sleep(ctx, 5*time.Second); err != nil {
+			return err
+		}
+	}
+}
+
+// runRemote executes a shell command on the device, streaming its output,
+// and returns the remote exit code
+func runRemote(ctx context.Context, device *Device, remoteCmd string, stdin io.Reader) (int, error) {
+	ip := device.PublicIPv4()
+	if ip == "" {
+		return 0, fmt.Errorf("device %s has no public IPv4 address", device.ID)
+	}
+
+	args := []string{"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=accept-new"}
+	if sshKey != "" {
+		args = append(args, "-i", sshKey)
+	}
+	args = append(args, sshUser+"@"+ip, remoteCmd)
+
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		// ssh reports its own failures, e.g. refused authentication, as 255
+		if exitErr.ExitCode() == 255 {
+			return 0, fmt.Errorf("ssh connection to %s failed", ip)
+		}
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// runBootstrapScript runs the --run-script of the demo on the new device
+// and returns the exit code the demo should finish with
+func runBootstrapScript(ctx context.Context, device *Device) int {
+	logger.Info("Waiting for SSH to come up...", "device", device.ID)
+	if err := waitForSSH(ctx, device, sshTimeout); err != nil {
+		logger.Error(err.Error(), "device", device.ID)
+		return 1
+	}
+
+	code, err := runRemoteScript(ctx, device, runScript)
+	if err != nil {
+		logger.Error(err.Error(), "device", device.ID)
+		return 1
+	}
+	logger.Info("Script "+runScript+" finished", "script", runScript, "status", code)
+	return code
+}
+
+// runRemoteScript uploads a local script to the device and runs it
+func runRemoteScript(ctx context.Context, device *Device, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return runRemote(ctx, device, runScriptCommand, f)
+}
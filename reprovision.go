@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "device reprovision",
+		usage: "Recreate a device with a new plan or OS, keeping its hostname, tags and elastic IPs",
+		run:   runDeviceReprovision,
+	})
+}
+
+// reprovisionRequest returns the request recreating the device in the same
+// location, or metro when metro is set, with a new plan or OS, an empty
+// one keeping what the device has
+func reprovisionRequest(d *Device, plan, os string, metro bool) *DeviceRequest {
+	req := &DeviceRequest{
+		Hostname:              d.Hostname,
+		Plan:                  d.PlanSlug(),
+		OS:                    d.OSSlug(),
+		BillingCycle:          d.BillingCycle,
+		ProjectID:             attrString(d.Project, "id"),
+		HardwareReservationID: attrString(d.HardwareReservation, "id"),
+		Tags:                  d.Tags,
+	}
+	if req.ProjectID == "" {
+		req.ProjectID = projectID
+	}
+	if metro {
+		req.Metro = d.MetroCode()
+	} else {
+		req.Facility = []string{d.FacilityCode()}
+	}
+	if plan != "" {
+		req.Plan = plan
+	}
+	if os != "" {
+		req.OS = os
+	}
+	return req
+}
+
+func runDeviceReprovision(ctx context.Context, args []string) error {
+	fs := newFlagSet("device reprovision")
+	newPlan := fs.String("plan", "", "Plan of the new device (default plan of the device)")
+	newOS := fs.String("os", "", "OS slug of the new device (default OS of the device)")
+	fs.DurationVar(&provisionTimeout, "provision-timeout", DefaultProvisionTimeout, "How long to wait for the new device to become active, 0 for no limit")
+	addCostFlags(fs)
+	yes := fs.Bool("yes", false, "Delete the device without asking for confirmation")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo device reprovision [flags] <device-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if *newPlan == "" && *newOS == "" {
+		return usageErrorf("--plan or --os is required, use device reinstall to reinstall the same OS")
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	old, err := getDevice(ctx, fs.Arg(0), client)
+	if err != nil {
+		return err
+	}
+	if old.Locked {
+		return usageErrorf("%s is locked, unlock it with device unlock first", old.Hostname)
+	}
+	req := reprovisionRequest(old, *newPlan, *newOS, client.Flavor() == FlavorEquinixMetal)
+	addrs := old.ElasticAddresses()
+	logger.Info("Reprovisioning device", "device", old.ID, "hostname", old.Hostname,
+		"plan", old.PlanSlug()+" -> "+req.Plan, "os", old.OSSlug()+" -> "+req.OS, "elastic_ips", len(addrs))
+	if err := checkHourlyCost(ctx, client, billedPlans(req)); err != nil {
+		return err
+	}
+	if dryRun {
+		prettyPrint(req)
+		return nil
+	}
+	if !*yes {
+		ok, err := confirm(fmt.Sprintf("Delete device %s (%s) and create it again?", old.Hostname, old.ID))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			logger.Info("Nothing changed")
+			return nil
+		}
+	}
+
+	if _, err := DeleteDevice(ctx, client, old.ID); err != nil {
+		return err
+	}
+	logger.Info("Deleted device, provisioning its replacement... please wait", "device", old.ID)
+	res, err := CreateDevice(ctx, client, req, provisionTimeout)
+	if err != nil {
+		if res != nil {
+			logger.Warn("The new device exists but is not active, its elastic IPs are not reassigned", "device", res.Device.ID, "elastic_ips", addrs)
+		}
+		return err
+	}
+	printCreateResult(res)
+
+	failures := 0
+	for _, addr := range addrs {
+		if _, err := assignIP(ctx, res.Device.ID, addr, client); err != nil {
+			logger.Error("Reassigning "+addr+" failed: "+err.Error(), "device", res.Device.ID)
+			failures++
+			continue
+		}
+		logger.Info("Reassigned "+addr+" to the device", "device", res.Device.ID)
+	}
+	if failures > 0 {
+		return &statusError{exitPartial, fmt.Errorf("%d of %d elastic IPs could not be reassigned, assign them with ip assign", failures, len(addrs))}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nurfet-becirevic/packet-go-demo/packet"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "device rescue",
+		usage: "Boot a device into the in-memory rescue OS and wait until it is back",
+		run:   runDeviceRescue,
+	})
+	registerCommand(&command{
+		name:  "device reinstall",
+		usage: "Reinstall the OS of a device and wait until it is active again",
+		run:   runDeviceReinstall,
+	})
+}
+
+// ReinstallRequest is the reinstall action of a device
+type ReinstallRequest struct {
+	Type            string `json:"type"`
+	OS              string `json:"operating_system,omitempty"`
+	PreserveData    bool   `json:"preserve_data,omitempty"`
+	DeprovisionFast bool   `json:"deprovision_fast,omitempty"`
+}
+
+// rescueDevice boots the device into the rescue OS
+func rescueDevice(ctx context.Context, deviceID string, c *Client) error {
+	req := map[string]string{"type": "rescue"}
+	return c.DoRequest(ctx, "devices/"+deviceID+"/actions", "POST", req, nil, nil)
+}
+
+// reinstallDevice reinstalls the OS of the device, its current OS when
+// req.OS is empty
+func reinstallDevice(ctx context.Context, deviceID string, req *ReinstallRequest, c *Client) error {
+	req.Type = "reinstall"
+	return c.DoRequest(ctx, "devices/"+deviceID+"/actions", "POST", req, nil, nil)
+}
+
+// waitAfterAction waits until the device is active again after an action.
+// The device is first given a minute to leave the active state, as a poll
+// right after the action may still see it active.
+func waitAfterAction(ctx context.Context, deviceID string, c *Client, timeout time.Duration) (*Device, error) {
+	start := time.Now()
+	for leave := start.Add(time.Minute); time.Now().Before(leave); {
+		if err := sleep(ctx, 5*time.Second); err != nil {
+			return nil, err
+		}
+		dev, err := getDevice(ctx, deviceID, c)
+		if err != nil {
+			return nil, err
+		}
+		if dev.State != StateActive {
+			lifecycle("device.state", "device_id", deviceID, "state", dev.State, "previous", StateActive)
+			break
+		}
+	}
+	dev, _, err := packet.WaitForDevice(ctx, c, deviceID, start.Add(timeout), timeout, nil)
+	return dev, err
+}
+
+func runDeviceRescue(ctx context.Context, args []string) error {
+	fs := newFlagSet("device rescue")
+	fs.DurationVar(&provisionTimeout, "provision-timeout", DefaultProvisionTimeout, "How long to wait for the device to be active again, 0 for no limit")
+	noWait := fs.Bool("no-wait", false, "Return once the action is accepted instead of waiting for the device")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo device rescue [flags] <device-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	id := fs.Arg(0)
+	if err := rescueDevice(ctx, id, client); err != nil {
+		return err
+	}
+	logger.Info("Booting device into rescue mode... please wait", "device", id)
+	if *noWait {
+		return nil
+	}
+	dev, err := waitAfterAction(ctx, id, client, provisionTimeout)
+	if err != nil {
+		return err
+	}
+	printDeviceBack(dev, "Device is in rescue mode, log in over SSH as root")
+	return nil
+}
+
+func runDeviceReinstall(ctx context.Context, args []string) error {
+	fs := newFlagSet("device reinstall")
+	req := &ReinstallRequest{}
+	fs.StringVar(&req.OS, "os", "", "OS slug to install (default current OS of the device)")
+	fs.BoolVar(&req.PreserveData, "preserve-data", false, "Keep the data on the non-OS disks")
+	fs.BoolVar(&req.DeprovisionFast, "deprovision-fast", false, "Skip wiping the disks before the reinstall")
+	fs.DurationVar(&provisionTimeout, "provision-timeout", DefaultProvisionTimeout, "How long to wait for the device to be active again, 0 for no limit")
+	noWait := fs.Bool("no-wait", false, "Return once the action is accepted instead of waiting for the device")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo device reinstall [flags] <device-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	id := fs.Arg(0)
+	if err := reinstallDevice(ctx, id, req, client); err != nil {
+		return err
+	}
+	logger.Info("Reinstalling device... please wait", "device", id, "os", req.OS, "preserve_data", req.PreserveData)
+	if *noWait {
+		return nil
+	}
+	dev, err := waitAfterAction(ctx, id, client, provisionTimeout)
+	if err != nil {
+		return err
+	}
+	printDeviceBack(dev, "Device reinstalled")
+	return nil
+}
+
+func printDeviceBack(dev *Device, msg string) {
+	if jsonOutput() {
+		prettyPrint(dev)
+		return
+	}
+	logger.Info(msg, "device", dev.ID, "hostname", dev.Hostname, "os", dev.OSSlug(), "ip", dev.PublicIPv4())
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "reservation list",
+		usage: "List the hardware reservations of the project",
+		run:   runReservationList,
+	})
+}
+
+// nextAvailableReservation picks any provisionable reservation of the plan
+// in the facility or metro of the device
+const nextAvailableReservation = "next-available"
+
+// HardwareReservation is a server reserved for the project, on which
+// devices are deployed without on-demand billing
+type HardwareReservation struct {
+	ID            string      `json:"id"`
+	ShortID       string      `json:"short_id,omitempty"`
+	Provisionable bool        `json:"provisionable"`
+	Spare         bool        `json:"spare"`
+	Created       string      `json:"created_at,omitempty"`
+	Plan          interface{} `json:"plan,omitempty"`
+	Facility      interface{} `json:"facility,omitempty"`
+	Device        interface{} `json:"device,omitempty"`
+}
+
+// Location returns the metro of the reservation, or its facility
+func (r *HardwareReservation) Location() string {
+	if code := attrString(attrValue(r.Facility, "metro"), "code"); code != "" {
+		return code
+	}
+	return attrString(r.Facility, "code")
+}
+
+// listHardwareReservations returns the hardware reservations of the project,
+// following pagination
+func listHardwareReservations(ctx context.Context, projectID string, c *Client) ([]HardwareReservation, error) {
+	var reservations []HardwareReservation
+	for page := 1; ; page++ {
+		list := new(struct {
+			HardwareReservations []HardwareReservation `json:"hardware_reservations"`
+			Meta                 struct {
+				LastPage int `json:"last_page"`
+			} `json:"meta"`
+		})
+		uri := fmt.Sprintf("projects/%s/hardware-reservations?page=%d&per_page=100", projectID, page)
+		if err := c.DoRequest(ctx, uri, "GET", nil, list, nil); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, list.HardwareReservations...)
+		if page >= list.Meta.LastPage {
+			return reservations, nil
+		}
+	}
+}
+
+func runReservationList(ctx context.Context, args []string) error {
+	fs := newFlagSet("reservation list")
+	addCacheFlags(fs)
+	available := fs.Bool("available", false, "Only list reservations a device can be deployed on")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	all, err := listHardwareReservations(ctx, projectID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	reservations := []HardwareReservation{}
+	for _, r := range all {
+		if !*available || (r.Provisionable && r.Device == nil) {
+			reservations = append(reservations, r)
+		}
+	}
+	if jsonOutput() {
+		prettyPrint(reservations)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPLAN\tLOCATION\tPROVISIONABLE\tSPARE\tDEVICE")
+	for _, r := range reservations {
+		device := attrString(r.Device, "id")
+		if device == "" {
+			device = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%t\t%s\n", r.ID, attrString(r.Plan, "slug"), r.Location(), r.Provisionable, r.Spare, device)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// rollbackMode decides what happens to the resources of an operation that
+// failed midway, set by --rollback
+type rollbackMode string
+
+const (
+	rollbackAuto   rollbackMode = "auto"
+	rollbackPrompt rollbackMode = "prompt"
+	rollbackNever  rollbackMode = "never"
+)
+
+var rollbackModes = []string{"auto", "prompt", "never"}
+
+var rollbackPolicy = rollbackAuto
+
+func (m rollbackMode) String() string {
+	return string(m)
+}
+
+// Set parses a rollback mode from a flag
+func (m *rollbackMode) Set(s string) error {
+	if err := checkEnum("rollback mode", s, rollbackModes); err != nil {
+		return err
+	}
+	*m = rollbackMode(s)
+	return nil
+}
+
+// undoStack collects how to revert the completed steps of an operation,
+// e.g. deleting a device whose IP assignment then failed
+type undoStack struct {
+	mu    sync.Mutex
+	steps []undoStep
+}
+
+type undoStep struct {
+	desc string
+	do   func(ctx context.Context) error
+}
+
+// push registers how to revert a step, steps are reverted last first
+func (u *undoStack) push(desc string, do func(ctx context.Context) error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.steps = append(u.steps, undoStep{desc, do})
+}
+
+// RollbackReport tells what became of the completed steps of a failed
+// operation
+type RollbackReport struct {
+	RolledBack []string `json:"rolled_back"`
+	Failed     []string `json:"failed,omitempty"`
+	Kept       []string `json:"kept,omitempty"`
+}
+
+// rollback reverts the steps as --rollback says, after asking with
+// prompt. A failed revert does not stop the others. What was rolled back
+// and what is left is logged and emitted as an operation.rolled_back event.
+func (u *undoStack) rollback(ctx context.Context, cause error) *RollbackReport {
+	u.mu.Lock()
+	steps := append([]undoStep(nil), u.steps...)
+	u.steps = nil
+	u.mu.Unlock()
+	if len(steps) == 0 {
+		return nil
+	}
+
+	report := &RollbackReport{RolledBack: []string{}, Failed: []string{}, Kept: []string{}}
+	mode := rollbackPolicy
+	if mode == rollbackPrompt {
+		fmt.Fprintln(os.Stderr, "The operation failed midway. Rolling back would:")
+		for i := len(steps) - 1; i >= 0; i-- {
+			fmt.Fprintln(os.Stderr, "  "+steps[i].desc)
+		}
+		ok, err := confirm("Roll back?")
+		if err != nil {
+			logger.Warn("Not rolling back, there is no terminal to ask")
+		}
+		if !ok {
+			mode = rollbackNever
+		}
+	}
+
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+	for i := len(steps) - 1; i >= 0; i-- {
+		s := steps[i]
+		if mode == rollbackNever {
+			report.Kept = append(report.Kept, s.desc)
+			continue
+		}
+		if err := s.do(ctx); err != nil && !errors.Is(err, ErrDryRun) {
+			logger.Error("Rollback failed: "+s.desc, "error", err)
+			report.Failed = append(report.Failed, s.desc)
+			continue
+		}
+		logger.Info("Rolled back: " + s.desc)
+		report.RolledBack = append(report.RolledBack, s.desc)
+	}
+
+	if mode != rollbackNever {
+		logger.Info(fmt.Sprintf("Rolled back %d of %d steps", len(report.RolledBack), len(steps)))
+	}
+	if left := append(append([]string(nil), report.Kept...), report.Failed...); len(left) > 0 {
+		hint := "clean up with cleanup"
+		if runState != nil {
+			hint = "clean up with cleanup --run " + runState.run
+		}
+		for _, desc := range left {
+			logger.Warn("Not rolled back, "+hint, "step", desc)
+		}
+	}
+	emit("operation.rolled_back", "cause", cause.Error(), "rolled_back", report.RolledBack, "failed", report.Failed, "kept", report.Kept)
+	return report
+}
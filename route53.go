@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// route53DNS manages the records of a hosted zone with the Route 53 API,
+// signing requests with AWS Signature Version 4
+type route53DNS struct {
+	key, secret, session string
+	zone                 string
+	baseURL              string
+	client               *http.Client
+	zoneID               string
+}
+
+// route53RecordSet is a resource record set of the Route 53 API
+type route53RecordSet struct {
+	Name    string          `xml:"Name"`
+	Type    string          `xml:"Type"`
+	TTL     int             `xml:"TTL"`
+	Records []route53Record `xml:"ResourceRecords>ResourceRecord"`
+}
+
+type route53Record struct {
+	Value string `xml:"Value"`
+}
+
+// route53Change is the body of ChangeResourceRecordSets
+type route53Change struct {
+	XMLName xml.Name         `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ChangeResourceRecordSetsRequest"`
+	Action  string           `xml:"ChangeBatch>Changes>Change>Action"`
+	Set     route53RecordSet `xml:"ChangeBatch>Changes>Change>ResourceRecordSet"`
+}
+
+// route53Error is the error response of the Route 53 API
+type route53Error struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// sign adds the Signature Version 4 authorization of the request, Route 53
+// is a global service signed for us-east-1
+func (r *route53DNS) sign(req *http.Request, body []byte, now time.Time) {
+	const region, service = "us-east-1", "route53"
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if r.session != "" {
+		req.Header.Set("X-Amz-Security-Token", r.session)
+	}
+
+	headers := map[string]string{"host": req.URL.Host, "x-amz-date": amzDate}
+	if r.session != "" {
+		headers["x-amz-security-token"] = r.session
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.Replace(req.URL.Query().Encode(), "+", "%20", -1),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := []byte("AWS4" + r.secret)
+	for _, part := range []string{day, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		r.key, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// do sends a signed request to the Route 53 API and decodes the XML
+// response into result
+func (r *route53DNS) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		data, err := xml.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = append([]byte(xml.Header), data...)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.baseURL+"2013-04-01/"+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+	r.sign(req, payload, time.Now())
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e route53Error
+		if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+			return fmt.Errorf("route53: %s: %s", e.Code, e.Message)
+		}
+		return fmt.Errorf("route53: %s %s: status %s", method, path, resp.Status)
+	}
+	if result != nil {
+		return xml.Unmarshal(data, result)
+	}
+	return nil
+}
+
+// lookupZone finds the ID of the hosted zone by its name
+func (r *route53DNS) lookupZone(ctx context.Context) error {
+	if r.zoneID != "" {
+		return nil
+	}
+	zone := strings.Trim(r.zone, ".") + "."
+	var zones struct {
+		HostedZones []struct {
+			ID   string `xml:"Id"`
+			Name string `xml:"Name"`
+		} `xml:"HostedZones>HostedZone"`
+	}
+	q := url.Values{"dnsname": {zone}, "maxitems": {"1"}}
+	if err := r.do(ctx, "GET", "hostedzonesbyname?"+q.Encode(), nil, &zones); err != nil {
+		return err
+	}
+	if len(zones.HostedZones) == 0 || zones.HostedZones[0].Name != zone {
+		return fmt.Errorf("route53: no hosted zone %s", zone)
+	}
+	r.zoneID = strings.TrimPrefix(zones.HostedZones[0].ID, "/hostedzone/")
+	return nil
+}
+
+// find returns the record set of the name and type, nil when there is none
+func (r *route53DNS) find(ctx context.Context, name, typ string) (*route53RecordSet, error) {
+	if err := r.lookupZone(ctx); err != nil {
+		return nil, err
+	}
+	var sets struct {
+		Sets []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	}
+	q := url.Values{"name": {name + "."}, "type": {typ}, "maxitems": {"1"}}
+	if err := r.do(ctx, "GET", "hostedzone/"+r.zoneID+"/rrset?"+q.Encode(), nil, &sets); err != nil {
+		return nil, err
+	}
+	// the listing starts at the name, the first set may be the next one
+	if len(sets.Sets) == 0 || sets.Sets[0].Name != name+"." || sets.Sets[0].Type != typ {
+		return nil, nil
+	}
+	return &sets.Sets[0], nil
+}
+
+func (r *route53DNS) UpsertRecord(ctx context.Context, name, typ, value string, ttl int) error {
+	if err := r.lookupZone(ctx); err != nil {
+		return err
+	}
+	change := route53Change{Action: "UPSERT", Set: route53RecordSet{Name: name + ".", Type: typ, TTL: ttl, Records: []route53Record{{value}}}}
+	return r.do(ctx, "POST", "hostedzone/"+r.zoneID+"/rrset/", change, nil)
+}
+
+func (r *route53DNS) DeleteRecord(ctx context.Context, name, typ string) error {
+	// a deletion must match the record set exactly
+	set, err := r.find(ctx, name, typ)
+	if err != nil || set == nil {
+		return err
+	}
+	return r.do(ctx, "POST", "hostedzone/"+r.zoneID+"/rrset/", route53Change{Action: "DELETE", Set: *set}, nil)
+}
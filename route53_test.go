@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoute53Sign(t *testing.T) {
+	// the signatures were computed independently of the signer, following
+	// the Signature Version 4 documentation
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		body    string
+		session string
+		want    string
+	}{
+		{"query", "GET", "https://route53.amazonaws.com/2013-04-01/hostedzonesbyname?dnsname=example.com.", "", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/route53/aws4_request, SignedHeaders=host;x-amz-date, " +
+				"Signature=886ca688cdc0f8d79ffe012bfbbda7084f8a804cdb009c5545b46a24fae0f793"},
+		{"sorted and escaped query", "GET", "https://route53.amazonaws.com/2013-04-01/hostedzone/Z1/rrset?type=A&name=web+example.com.", "", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/route53/aws4_request, SignedHeaders=host;x-amz-date, " +
+				"Signature=32d3e15c150fcd72e5f8a038ce2d6962365daf96fa6826cab0e1df67a3af999b"},
+		{"body and session token", "POST", "https://route53.amazonaws.com/2013-04-01/hostedzone/Z1/rrset/", "<ChangeResourceRecordSetsRequest/>", "session-token",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/route53/aws4_request, SignedHeaders=host;x-amz-date;x-amz-security-token, " +
+				"Signature=c1abdd39eecc1ae10c6d43c4387e198edb972a49d33bcfbd81ced93377f6ef03"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &route53DNS{key: "AKIDEXAMPLE", secret: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", session: tt.session}
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.sign(req, []byte(tt.body), now)
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("Authorization =\n%s\nwant\n%s", got, tt.want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %s", got)
+			}
+			if got := req.Header.Get("X-Amz-Security-Token"); got != tt.session {
+				t.Errorf("X-Amz-Security-Token = %q, want %q", got, tt.session)
+			}
+		})
+	}
+}
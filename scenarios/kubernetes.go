@@ -0,0 +1,283 @@
+package scenarios
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nurfet-becirevic/packet-go-demo/packet"
+)
+
+// DefaultKubernetesVersion is the minor Kubernetes version installed when
+// KubernetesOptions.Version is empty
+const DefaultKubernetesVersion = "1.33"
+
+// roles of the nodes of a Kubernetes cluster, telling what kubeadm does
+const (
+	kubeadmInit             = "init"
+	kubeadmJoinControlPlane = "control-plane"
+	kubeadmJoinWorker       = "worker"
+)
+
+var kubernetesVersionPattern = regexp.MustCompile(`^1\.[0-9]+$`)
+
+// ValidKubernetesVersion tells whether version is a minor Kubernetes
+// version such as 1.33
+func ValidKubernetesVersion(version string) bool {
+	return kubernetesVersionPattern.MatchString(version)
+}
+
+// kubeadmConfig holds the secrets the nodes of a cluster share: the
+// bootstrap token the nodes join with, and the key the control-plane
+// certificates are uploaded with for the other control-plane nodes
+type kubeadmConfig struct {
+	version        string
+	token          string
+	certificateKey string
+}
+
+func newKubeadmConfig(version string) (*kubeadmConfig, error) {
+	if !kubernetesVersionPattern.MatchString(version) {
+		return nil, fmt.Errorf("Kubernetes version %q is not a minor version such as %s", version, DefaultKubernetesVersion)
+	}
+	// bootstrap tokens are [a-z0-9]{6}.[a-z0-9]{16}
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	id, err := randomString(6, chars)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomString(16, chars)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &kubeadmConfig{version: version, token: id + "." + secret, certificateKey: hex.EncodeToString(key)}, nil
+}
+
+// kubeadmPrepare installs containerd, kubeadm, kubelet and kubectl on
+// Ubuntu or Debian, %[1]s is the minor Kubernetes version
+const kubeadmPrepare = `#!/bin/bash
+# written by packet-go-demo k8s bootstrap
+set -euxo pipefail
+exec >>/var/log/k8s-bootstrap.log 2>&1
+
+swapoff -a
+sed -i '/ swap / s/^/#/' /etc/fstab
+printf 'overlay\nbr_netfilter\n' >/etc/modules-load.d/k8s.conf
+modprobe overlay
+modprobe br_netfilter
+printf 'net.bridge.bridge-nf-call-iptables = 1\nnet.bridge.bridge-nf-call-ip6tables = 1\nnet.ipv4.ip_forward = 1\n' >/etc/sysctl.d/k8s.conf
+sysctl --system
+
+export DEBIAN_FRONTEND=noninteractive
+apt-get update
+apt-get install -y containerd apt-transport-https ca-certificates curl gpg
+mkdir -p /etc/containerd
+containerd config default | sed 's/SystemdCgroup = false/SystemdCgroup = true/' >/etc/containerd/config.toml
+systemctl restart containerd
+
+mkdir -p /etc/apt/keyrings
+curl -fsSL https://pkgs.k8s.io/core:/stable:/v%[1]s/deb/Release.key | gpg --dearmor -o /etc/apt/keyrings/kubernetes-apt-keyring.gpg
+echo 'deb [signed-by=/etc/apt/keyrings/kubernetes-apt-keyring.gpg] https://pkgs.k8s.io/core:/stable:/v%[1]s/deb/ /' >/etc/apt/sources.list.d/kubernetes.list
+apt-get update
+apt-get install -y kubelet kubeadm kubectl
+apt-mark hold kubelet kubeadm kubectl
+
+# the API server listens on the private address, the public one is added
+# to its certificate for kubectl from outside
+PRIVATE_IP=$(ip -4 -o addr show scope global | awk '$4 ~ /^10\./ {split($4, a, "/"); print a[1]; exit}')
+PUBLIC_IP=$(ip -4 -o addr show scope global | awk '$4 !~ /^10\./ {split($4, a, "/"); print a[1]; exit}')
+`
+
+// userdata returns the script a node of the role runs on boot, joining
+// the API server at endpoint unless it is the first control-plane node
+func (k *kubeadmConfig) userdata(role, endpoint string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, kubeadmPrepare, k.version)
+	switch role {
+	case kubeadmInit:
+		fmt.Fprintf(&b, `
+kubeadm init --token %s --token-ttl 2h --upload-certs --certificate-key %s \
+  --control-plane-endpoint "$PRIVATE_IP:6443" --apiserver-advertise-address "$PRIVATE_IP" \
+  --apiserver-cert-extra-sans "$PUBLIC_IP" --pod-network-cidr 10.244.0.0/16
+kubectl --kubeconfig /etc/kubernetes/admin.conf apply -f https://github.com/flannel-io/flannel/releases/latest/download/kube-flannel.yml
+`, k.token, k.certificateKey)
+	case kubeadmJoinControlPlane:
+		fmt.Fprintf(&b, `
+until kubeadm join %s --token %s --discovery-token-unsafe-skip-ca-verification \
+  --control-plane --certificate-key %s --apiserver-advertise-address "$PRIVATE_IP"; do
+  kubeadm reset -f
+  sleep 20
+done
+`, endpoint, k.token, k.certificateKey)
+	default:
+		fmt.Fprintf(&b, `
+until kubeadm join %s --token %s --discovery-token-unsafe-skip-ca-verification; do
+  kubeadm reset -f
+  sleep 20
+done
+`, endpoint, k.token)
+	}
+	return b.String()
+}
+
+// KubernetesOptions configures BootstrapKubernetes
+type KubernetesOptions struct {
+	// Name starts the hostnames of the nodes, <name>-cp-1... and
+	// <name>-worker-1...
+	Name string
+	// ControlPlane is the request of the control-plane nodes and Worker
+	// that of the workers, the control-plane one when nil. Their hostname
+	// and userdata are replaced.
+	ControlPlane  *packet.DeviceRequest
+	Worker        *packet.DeviceRequest
+	ControlPlanes int
+	Workers       int
+	// Version is the minor Kubernetes version installed, e.g. 1.33,
+	// DefaultKubernetesVersion when empty
+	Version string
+	Timeout time.Duration
+	// KeepPartial keeps the nodes that became active when others failed,
+	// instead of deleting the whole cluster
+	KeepPartial bool
+}
+
+// KubernetesCluster holds the nodes of a cluster in hostname order, and
+// the errors of the nodes that failed by hostname. Endpoint is the API
+// server, on the private address of the first control-plane node.
+type KubernetesCluster struct {
+	ControlPlanes []*packet.Device `json:"control_planes"`
+	Workers       []*packet.Device `json:"workers"`
+	Endpoint      string           `json:"endpoint"`
+	Failed        map[string]error `json:"-"`
+}
+
+// BootstrapKubernetes creates the first control-plane node with userdata
+// running kubeadm init, then the other nodes in parallel with userdata
+// joining them to it. kubeadm runs while the nodes boot, so the cluster is
+// ready a few minutes after the nodes are active. When a node fails the
+// others are deleted too, unless KeepPartial is set; the returned cluster
+// holds the nodes left.
+func BootstrapKubernetes(ctx context.Context, devices packet.DeviceService, opts KubernetesOptions) (*KubernetesCluster, error) {
+	if opts.ControlPlane == nil || opts.ControlPlanes < 1 || opts.Workers < 0 {
+		return nil, errors.New("a Kubernetes cluster needs a control-plane request and at least one control-plane node")
+	}
+	version := opts.Version
+	if version == "" {
+		version = DefaultKubernetesVersion
+	}
+	kc, err := newKubeadmConfig(version)
+	if err != nil {
+		return nil, err
+	}
+	worker := opts.Worker
+	if worker == nil {
+		worker = opts.ControlPlane
+	}
+
+	cluster := &KubernetesCluster{Failed: map[string]error{}}
+	discard := func(err error) (*KubernetesCluster, error) {
+		if opts.KeepPartial {
+			return cluster, err
+		}
+		cleanupCtx, cancel := cleanupContext(ctx)
+		defer cancel()
+		deleteNodes := func(nodes []*packet.Device) []*packet.Device {
+			var kept []*packet.Device
+			for _, d := range nodes {
+				if _, derr := devices.Delete(cleanupCtx, d.ID); derr != nil {
+					kept = append(kept, d)
+					err = fmt.Errorf("%w, and deleting node %s failed: %s", err, d.ID, derr)
+				}
+			}
+			return kept
+		}
+		cluster.ControlPlanes = deleteNodes(cluster.ControlPlanes)
+		cluster.Workers = deleteNodes(cluster.Workers)
+		return cluster, err
+	}
+
+	first := *opts.ControlPlane
+	first.Hostname = opts.Name + "-cp-1"
+	first.UserData = kc.userdata(kubeadmInit, "")
+	res, err := ProvisionAndWait(ctx, devices, ProvisionOptions{Request: &first, Timeout: opts.Timeout})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", first.Hostname, err)
+	}
+	cluster.ControlPlanes = []*packet.Device{res.Device}
+	ip := res.Device.PrivateIPv4()
+	if ip == "" {
+		return discard(fmt.Errorf("%s has no private IPv4 address for the other nodes to join", res.Device.ID))
+	}
+	cluster.Endpoint = net.JoinHostPort(ip, "6443")
+
+	var joins []*packet.DeviceRequest
+	for i := 2; i <= opts.ControlPlanes; i++ {
+		req := *opts.ControlPlane
+		req.Hostname = fmt.Sprintf("%s-cp-%d", opts.Name, i)
+		req.UserData = kc.userdata(kubeadmJoinControlPlane, cluster.Endpoint)
+		joins = append(joins, &req)
+	}
+	for i := 1; i <= opts.Workers; i++ {
+		req := *worker
+		req.Hostname = fmt.Sprintf("%s-worker-%d", opts.Name, i)
+		req.UserData = kc.userdata(kubeadmJoinWorker, cluster.Endpoint)
+		joins = append(joins, &req)
+	}
+	nodes := make([]*packet.Device, len(joins))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, req := range joins {
+		i, req := i, req
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			created, err := ProvisionAndWait(ctx, devices, ProvisionOptions{Request: req, Timeout: opts.Timeout})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				cluster.Failed[req.Hostname] = err
+				return
+			}
+			nodes[i] = created.Device
+		}()
+	}
+	wg.Wait()
+	for i, d := range nodes {
+		switch {
+		case d == nil:
+		case i < opts.ControlPlanes-1:
+			cluster.ControlPlanes = append(cluster.ControlPlanes, d)
+		default:
+			cluster.Workers = append(cluster.Workers, d)
+		}
+	}
+	if len(cluster.Failed) > 0 {
+		return discard(fmt.Errorf("%d of %d nodes failed", len(cluster.Failed), opts.ControlPlanes+opts.Workers))
+	}
+	return cluster, nil
+}
+
+// randomString generates n characters of chars from a secure source
+func randomString(n int, chars string) (string, error) {
+	b := make([]byte, n)
+	for i := range b {
+		k, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = chars[k.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,249 @@
+// Package scenarios holds the flows of packet-go-demo, for code embedding
+// the client: creating a device and waiting for it, a cluster, a
+// blue-green swap and a Kubernetes cluster. They work on the service
+// interfaces of the packet package, so that tests of code built on them
+// can pass packet.DeviceServiceMock and packet.IPServiceMock.
+package scenarios
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nurfet-becirevic/packet-go-demo/packet"
+)
+
+// cleanupTimeout bounds the cleanup of a flow whose context was cancelled
+const cleanupTimeout = 2 * time.Minute
+
+// cleanupContext returns a context for undoing the work of a flow, e.g.
+// deleting a device, that keeps working after ctx was cancelled
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
+
+// ProvisionOptions configures ProvisionAndWait
+type ProvisionOptions struct {
+	Request *packet.DeviceRequest
+	// Timeout is how long to wait for the device to become active,
+	// packet.DefaultProvisionTimeout when zero
+	Timeout time.Duration
+	// KeepOnFailure keeps a device that was created but did not become
+	// active, instead of deleting it
+	KeepOnFailure bool
+}
+
+// ProvisionAndWait creates a device and waits until it is active. A device
+// that does not become active is deleted, unless KeepOnFailure is set.
+func ProvisionAndWait(ctx context.Context, devices packet.DeviceService, opts ProvisionOptions) (*packet.CreateDeviceResult, error) {
+	if opts.Request == nil {
+		return nil, errors.New("no device request")
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = packet.DefaultProvisionTimeout
+	}
+	res, err := devices.Create(ctx, opts.Request, timeout)
+	if err != nil && res != nil && res.Device != nil && !opts.KeepOnFailure {
+		cleanupCtx, cancel := cleanupContext(ctx)
+		defer cancel()
+		if _, derr := devices.Delete(cleanupCtx, res.Device.ID); derr != nil {
+			return res, fmt.Errorf("%w, and deleting device %s failed: %s", err, res.Device.ID, derr)
+		}
+	}
+	return res, err
+}
+
+// ClusterOptions configures ProvisionCluster
+type ClusterOptions struct {
+	// Request is the request of every node, its hostname is suffixed with
+	// -1, -2...
+	Request *packet.DeviceRequest
+	Count   int
+	// Parallel is how many nodes are created at a time, all when zero
+	Parallel int
+	Timeout  time.Duration
+	// KeepPartial keeps the nodes that became active when others failed,
+	// instead of deleting the whole cluster
+	KeepPartial bool
+}
+
+// ClusterResult holds the nodes of a cluster, in hostname order, and the
+// errors of the nodes that failed by hostname
+type ClusterResult struct {
+	Devices []*packet.Device `json:"devices"`
+	Failed  map[string]error `json:"-"`
+}
+
+// ProvisionCluster creates Count devices in parallel and waits until they
+// are active. When a node fails the others are deleted too, unless
+// KeepPartial is set, and the error lists the nodes that failed.
+func ProvisionCluster(ctx context.Context, devices packet.DeviceService, opts ClusterOptions) (*ClusterResult, error) {
+	if opts.Request == nil || opts.Count < 1 {
+		return nil, errors.New("a cluster needs a device request and at least one node")
+	}
+	parallel := opts.Parallel
+	if parallel < 1 || parallel > opts.Count {
+		parallel = opts.Count
+	}
+
+	nodes := make([]*packet.Device, opts.Count)
+	res := &ClusterResult{Failed: map[string]error{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i := 0; i < opts.Count; i++ {
+		req := *opts.Request
+		req.Hostname = fmt.Sprintf("%s-%d", opts.Request.Hostname, i+1)
+		i := i
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			created, err := ProvisionAndWait(ctx, devices, ProvisionOptions{Request: &req, Timeout: opts.Timeout})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				res.Failed[req.Hostname] = err
+				return
+			}
+			nodes[i] = created.Device
+		}()
+	}
+	wg.Wait()
+	for _, d := range nodes {
+		if d != nil {
+			res.Devices = append(res.Devices, d)
+		}
+	}
+	if len(res.Failed) == 0 {
+		return res, nil
+	}
+
+	err := fmt.Errorf("%d of %d nodes failed", len(res.Failed), opts.Count)
+	if opts.KeepPartial {
+		return res, err
+	}
+	cleanupCtx, cancel := cleanupContext(ctx)
+	defer cancel()
+	var kept []*packet.Device
+	for _, d := range res.Devices {
+		if _, derr := devices.Delete(cleanupCtx, d.ID); derr != nil {
+			kept = append(kept, d)
+			err = fmt.Errorf("%w, and deleting node %s failed: %s", err, d.ID, derr)
+		}
+	}
+	res.Devices = kept
+	return res, err
+}
+
+// BlueGreenOptions configures BlueGreenSwap
+type BlueGreenOptions struct {
+	// BlueDeviceID is the device serving now
+	BlueDeviceID string
+	// Green is the request of its replacement
+	Green   *packet.DeviceRequest
+	Timeout time.Duration
+	// Addresses are the elastic IPs moved from blue to green, in CIDR
+	// notation, all the elastic IPs of blue when empty
+	Addresses []string
+	// Check is called with green once it is active, before any address
+	// moves. An error aborts the swap and deletes green.
+	Check func(ctx context.Context, green *packet.Device) error
+	// KeepBlue keeps blue once its addresses moved, instead of deleting it
+	KeepBlue bool
+}
+
+// BlueGreenResult tells how far a swap got. Moved are the addresses taken
+// from blue, after a failed swap those that could not be given back.
+type BlueGreenResult struct {
+	Green       *packet.Device `json:"green"`
+	Moved       []string       `json:"moved"`
+	BlueDeleted bool           `json:"blue_deleted"`
+}
+
+// BlueGreenSwap replaces a device without changing its addresses: it
+// creates green, checks it, moves the elastic IPs of blue to green and
+// deletes blue. When an address cannot be moved, the addresses moved so
+// far go back to blue and both devices are kept.
+func BlueGreenSwap(ctx context.Context, devices packet.DeviceService, ips packet.IPService, opts BlueGreenOptions) (*BlueGreenResult, error) {
+	blue, err := devices.Get(ctx, opts.BlueDeviceID)
+	if err != nil {
+		return nil, err
+	}
+	addresses := opts.Addresses
+	if len(addresses) == 0 {
+		addresses = blue.ElasticAddresses()
+	}
+	assignments := blue.Assignments()
+	for _, addr := range addresses {
+		if assignments[addr] == "" {
+			return nil, fmt.Errorf("%s is not assigned to device %s", addr, blue.ID)
+		}
+	}
+
+	created, err := ProvisionAndWait(ctx, devices, ProvisionOptions{Request: opts.Green, Timeout: opts.Timeout})
+	if err != nil {
+		return nil, err
+	}
+	res := &BlueGreenResult{Green: created.Device, Moved: []string{}}
+	if opts.Check != nil {
+		if err := opts.Check(ctx, res.Green); err != nil {
+			cleanupCtx, cancel := cleanupContext(ctx)
+			defer cancel()
+			if _, derr := devices.Delete(cleanupCtx, res.Green.ID); derr != nil {
+				return res, fmt.Errorf("green device %s failed its check: %w, and deleting it failed: %s", res.Green.ID, err, derr)
+			}
+			return nil, fmt.Errorf("green device failed its check: %w", err)
+		}
+	}
+
+	onGreen := map[string]string{}
+	for _, addr := range addresses {
+		if err := ips.Unassign(ctx, assignments[addr]); err != nil {
+			return res, moveBack(ctx, ips, blue.ID, res, onGreen, fmt.Errorf("unassigning %s from blue device %s: %w", addr, blue.ID, err))
+		}
+		res.Moved = append(res.Moved, addr)
+		a, err := ips.Assign(ctx, res.Green.ID, addr)
+		if err != nil {
+			return res, moveBack(ctx, ips, blue.ID, res, onGreen, fmt.Errorf("assigning %s to green device %s: %w", addr, res.Green.ID, err))
+		}
+		onGreen[addr] = a.ID
+	}
+
+	if !opts.KeepBlue {
+		if _, err := devices.Delete(ctx, blue.ID); err != nil {
+			return res, fmt.Errorf("the addresses moved to %s, but deleting blue device %s failed: %w", res.Green.ID, blue.ID, err)
+		}
+		res.BlueDeleted = true
+	}
+	return res, nil
+}
+
+// moveBack assigns the addresses a failed swap took from blue to blue
+// again, unassigning those already on green by their assignment in
+// onGreen. It returns cause with the addresses that are not back.
+func moveBack(ctx context.Context, ips packet.IPService, blueID string, res *BlueGreenResult, onGreen map[string]string, cause error) error {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+	stuck := []string{}
+	for _, addr := range res.Moved {
+		if id := onGreen[addr]; id != "" {
+			if err := ips.Unassign(ctx, id); err != nil {
+				stuck = append(stuck, addr)
+				continue
+			}
+		}
+		if _, err := ips.Assign(ctx, blueID, addr); err != nil {
+			stuck = append(stuck, addr)
+		}
+	}
+	res.Moved = stuck
+	if len(stuck) > 0 {
+		return fmt.Errorf("%w; %v could not be moved back to blue device %s", cause, stuck, blueID)
+	}
+	return cause
+}
@@ -0,0 +1,277 @@
+package scenarios
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nurfet-becirevic/packet-go-demo/packet"
+)
+
+// fakeDevices is a device service whose devices of the hostnames in fail
+// are created but do not become active. It records the devices created and
+// deleted, by hostname.
+type fakeDevices struct {
+	packet.DeviceServiceMock
+	fail       map[string]bool
+	failDelete bool
+
+	mu      sync.Mutex
+	created []string
+	deleted []string
+	byID    map[string]*packet.Device
+}
+
+func newFakeDevices(fail ...string) *fakeDevices {
+	f := &fakeDevices{fail: map[string]bool{}, byID: map[string]*packet.Device{}}
+	for _, h := range fail {
+		f.fail[h] = true
+	}
+	f.CreateFunc = func(ctx context.Context, req *packet.DeviceRequest, timeout time.Duration) (*packet.CreateDeviceResult, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.created = append(f.created, req.Hostname)
+		d := &packet.Device{ID: "id-" + req.Hostname, Hostname: req.Hostname, UserData: req.UserData, Network: []interface{}{
+			map[string]interface{}{"address": fmt.Sprintf("10.0.0.%d", len(f.created)), "address_family": float64(4), "cidr": float64(31), "public": false, "management": true},
+		}}
+		f.byID[d.ID] = d
+		if f.fail[req.Hostname] {
+			d.State = packet.StateFailed
+			return &packet.CreateDeviceResult{Device: d}, errors.New("provisioning failed")
+		}
+		d.State = packet.StateActive
+		return &packet.CreateDeviceResult{Device: d}, nil
+	}
+	f.DeleteFunc = func(ctx context.Context, id string) (*packet.DeleteDeviceResult, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.failDelete {
+			return nil, errors.New("API unavailable")
+		}
+		f.deleted = append(f.deleted, f.byID[id].Hostname)
+		return &packet.DeleteDeviceResult{}, nil
+	}
+	return f
+}
+
+// sorted returns the hostnames in order, the nodes are created in parallel
+func sorted(hostnames []string) string {
+	s := append([]string(nil), hostnames...)
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func hostnames(devices []*packet.Device) string {
+	var names []string
+	for _, d := range devices {
+		names = append(names, d.Hostname)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestProvisionAndWait(t *testing.T) {
+	tests := []struct {
+		name        string
+		fail        bool
+		keep        bool
+		failDelete  bool
+		wantErr     string
+		wantDeleted string
+	}{
+		{"active", false, false, false, "", ""},
+		{"failed device is deleted", true, false, false, "provisioning failed", "web"},
+		{"failed device is kept", true, true, false, "provisioning failed", ""},
+		{"deleting fails too", true, false, true, "provisioning failed, and deleting device id-web failed: API unavailable", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devices := newFakeDevices()
+			devices.fail["web"] = tt.fail
+			devices.failDelete = tt.failDelete
+			res, err := ProvisionAndWait(context.Background(), devices, ProvisionOptions{Request: &packet.DeviceRequest{Hostname: "web"}, KeepOnFailure: tt.keep})
+			if fmt.Sprint(err) != fmt.Sprint(errorOrNil(tt.wantErr)) {
+				t.Errorf("ProvisionAndWait() error = %v, want %q", err, tt.wantErr)
+			}
+			if res == nil || res.Device.Hostname != "web" {
+				t.Errorf("ProvisionAndWait() = %+v, want the created device", res)
+			}
+			if got := sorted(devices.deleted); got != tt.wantDeleted {
+				t.Errorf("deleted %q, want %q", got, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+// errorOrNil returns an error of the message, nil for an empty one
+func errorOrNil(msg string) error {
+	if msg == "" {
+		return nil
+	}
+	return errors.New(msg)
+}
+
+func TestProvisionCluster(t *testing.T) {
+	tests := []struct {
+		name        string
+		count       int
+		fail        []string
+		keep        bool
+		wantErr     string
+		wantNodes   string
+		wantDeleted string
+	}{
+		{"all active", 3, nil, false, "", "node-1,node-2,node-3", ""},
+		{"failed node deletes the cluster", 3, []string{"node-2"}, false, "1 of 3 nodes failed", "", "node-1,node-2,node-3"},
+		{"partial cluster is kept", 3, []string{"node-2"}, true, "1 of 3 nodes failed", "node-1,node-3", "node-2"},
+		{"no nodes", 0, nil, false, "a cluster needs a device request and at least one node", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devices := newFakeDevices(tt.fail...)
+			res, err := ProvisionCluster(context.Background(), devices, ClusterOptions{
+				Request: &packet.DeviceRequest{Hostname: "node"}, Count: tt.count, Parallel: 2, KeepPartial: tt.keep,
+			})
+			if fmt.Sprint(err) != fmt.Sprint(errorOrNil(tt.wantErr)) {
+				t.Errorf("ProvisionCluster() error = %v, want %q", err, tt.wantErr)
+			}
+			if res != nil {
+				if got := hostnames(res.Devices); got != tt.wantNodes {
+					t.Errorf("nodes %q, want %q", got, tt.wantNodes)
+				}
+				for _, h := range tt.fail {
+					if res.Failed[h] == nil {
+						t.Errorf("failure of %s not reported", h)
+					}
+				}
+			}
+			if got := sorted(devices.deleted); got != tt.wantDeleted {
+				t.Errorf("deleted %q, want %q", got, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestBlueGreenSwap(t *testing.T) {
+	blue := &packet.Device{ID: "id-blue", Hostname: "blue", Network: []interface{}{
+		map[string]interface{}{"id": "mgmt", "address": "192.0.2.3", "cidr": float64(31), "public": true, "management": true},
+		map[string]interface{}{"id": "assign-1", "address": "198.51.100.8", "cidr": float64(32), "public": true},
+		map[string]interface{}{"id": "assign-2", "address": "198.51.100.9", "cidr": float64(32), "public": true},
+	}}
+	tests := []struct {
+		name       string
+		addresses  []string
+		check      error
+		failAssign string
+		keepBlue   bool
+		wantErr    string
+		wantCalls  string
+		wantMoved  string
+		wantDelete string
+	}{
+		{"moves every elastic address", nil, nil, "", false, "",
+			"unassign assign-1,assign 198.51.100.8/32 to id-green,unassign assign-2,assign 198.51.100.9/32 to id-green", "198.51.100.8/32,198.51.100.9/32", "blue"},
+		{"keeps blue", []string{"198.51.100.9/32"}, nil, "", true, "",
+			"unassign assign-2,assign 198.51.100.9/32 to id-green", "198.51.100.9/32", ""},
+		{"address not on blue", []string{"203.0.113.1/32"}, nil, "", false, "203.0.113.1/32 is not assigned to device id-blue", "", "", ""},
+		{"failed check deletes green", nil, errors.New("unhealthy"), "", false, "green device failed its check: unhealthy", "", "", "green"},
+		{"failed move goes back to blue", nil, nil, "198.51.100.9/32", false,
+			"assigning 198.51.100.9/32 to green device id-green: no capacity",
+			"unassign assign-1,assign 198.51.100.8/32 to id-green,unassign assign-2,assign 198.51.100.9/32 to id-green," +
+				"unassign new-198.51.100.8/32,assign 198.51.100.8/32 to id-blue,assign 198.51.100.9/32 to id-blue", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devices := newFakeDevices()
+			devices.GetFunc = func(ctx context.Context, id string) (*packet.Device, error) { return blue, nil }
+			devices.byID[blue.ID] = blue
+			var calls []string
+			ips := &packet.IPServiceMock{
+				UnassignFunc: func(ctx context.Context, id string) error {
+					calls = append(calls, "unassign "+id)
+					return nil
+				},
+				AssignFunc: func(ctx context.Context, deviceID, address string) (*packet.IPAssignment, error) {
+					calls = append(calls, "assign "+address+" to "+deviceID)
+					if address == tt.failAssign && deviceID == "id-green" {
+						return nil, errors.New("no capacity")
+					}
+					return &packet.IPAssignment{ID: "new-" + address}, nil
+				},
+			}
+			opts := BlueGreenOptions{BlueDeviceID: blue.ID, Green: &packet.DeviceRequest{Hostname: "green"}, Addresses: tt.addresses, KeepBlue: tt.keepBlue}
+			if tt.check != nil {
+				opts.Check = func(ctx context.Context, green *packet.Device) error { return tt.check }
+			}
+			res, err := BlueGreenSwap(context.Background(), devices, ips, opts)
+			if fmt.Sprint(err) != fmt.Sprint(errorOrNil(tt.wantErr)) {
+				t.Errorf("BlueGreenSwap() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := strings.Join(calls, ","); got != tt.wantCalls {
+				t.Errorf("IP calls:\n%s\nwant\n%s", got, tt.wantCalls)
+			}
+			if res != nil {
+				if got := strings.Join(res.Moved, ","); got != tt.wantMoved {
+					t.Errorf("moved %q, want %q", got, tt.wantMoved)
+				}
+				if res.BlueDeleted != (tt.wantDelete == "blue") {
+					t.Errorf("BlueDeleted = %v", res.BlueDeleted)
+				}
+			}
+			if got := sorted(devices.deleted); got != tt.wantDelete {
+				t.Errorf("deleted %q, want %q", got, tt.wantDelete)
+			}
+		})
+	}
+}
+
+func TestBootstrapKubernetes(t *testing.T) {
+	tests := []struct {
+		name         string
+		controlPlane int
+		workers      int
+		fail         []string
+		wantErr      string
+		wantCreated  string
+		wantDeleted  string
+	}{
+		{"cluster", 3, 2, nil, "", "k-cp-1,k-cp-2,k-cp-3,k-worker-1,k-worker-2", ""},
+		{"first node fails", 1, 2, []string{"k-cp-1"}, "k-cp-1: provisioning failed", "k-cp-1", "k-cp-1"},
+		{"worker fails", 1, 2, []string{"k-worker-2"}, "1 of 3 nodes failed", "k-cp-1,k-worker-1,k-worker-2", "k-cp-1,k-worker-1,k-worker-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devices := newFakeDevices(tt.fail...)
+			cluster, err := BootstrapKubernetes(context.Background(), devices, KubernetesOptions{
+				Name: "k", ControlPlane: &packet.DeviceRequest{Plan: "c3.small.x86"}, ControlPlanes: tt.controlPlane, Workers: tt.workers,
+			})
+			if fmt.Sprint(err) != fmt.Sprint(errorOrNil(tt.wantErr)) {
+				t.Errorf("BootstrapKubernetes() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := sorted(devices.created); got != tt.wantCreated {
+				t.Errorf("created %q, want %q", got, tt.wantCreated)
+			}
+			if got := sorted(devices.deleted); got != tt.wantDeleted {
+				t.Errorf("deleted %q, want %q", got, tt.wantDeleted)
+			}
+			if err != nil {
+				return
+			}
+			// the first control-plane node runs kubeadm init, the others
+			// join it on its private address
+			if cluster.Endpoint != "10.0.0.1:6443" {
+				t.Errorf("endpoint = %s, want 10.0.0.1:6443", cluster.Endpoint)
+			}
+			for _, d := range append(cluster.ControlPlanes, cluster.Workers...) {
+				init := strings.Contains(d.UserData, "kubeadm init")
+				if init != (d.Hostname == "k-cp-1") || (!init && !strings.Contains(d.UserData, cluster.Endpoint)) {
+					t.Errorf("userdata of %s:\n%s", d.Hostname, d.UserData)
+				}
+			}
+		})
+	}
+}
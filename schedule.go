@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule keeps devices powered on during its windows and powered off
+// outside of them. Devices are selected by ID or hostname, or as a fleet
+// by tag.
+type Schedule struct {
+	Name    string   `json:"name"`
+	Devices []string `json:"devices,omitempty"`
+	Tag     string   `json:"tag,omitempty"`
+	// Timezone of the windows, e.g. Europe/Amsterdam, default local time
+	Timezone string `json:"timezone,omitempty"`
+	// On lists the windows the devices run in, e.g. "Mon-Fri 08:00-19:00"
+	On []string `json:"on"`
+
+	location *time.Location
+	windows  []scheduleWindow
+}
+
+// scheduleWindow is a daily time range on some weekdays, in minutes since
+// midnight. A window ending before it starts runs past midnight.
+type scheduleWindow struct {
+	days       [7]bool
+	start, end int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parse validates the schedule and prepares its windows
+func (s *Schedule) parse() error {
+	if s.Name == "" {
+		return fmt.Errorf("schedule without name")
+	}
+	if len(s.Devices) == 0 && s.Tag == "" {
+		return fmt.Errorf("schedule %s selects no devices, give devices or a tag", s.Name)
+	}
+	if len(s.On) == 0 {
+		return fmt.Errorf("schedule %s has no windows", s.Name)
+	}
+
+	s.location = time.Local
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %s", s.Name, err)
+		}
+		s.location = loc
+	}
+
+	s.windows = nil
+	for _, spec := range s.On {
+		w, err := parseScheduleWindow(spec)
+		if err != nil {
+			return fmt.Errorf("schedule %s: window %q: %s", s.Name, spec, err)
+		}
+		s.windows = append(s.windows, w)
+	}
+	return nil
+}
+
+// parseScheduleWindow parses "<days> <HH:MM>-<HH:MM>". Days are "daily",
+// a weekday, a range such as Mon-Fri or a comma separated list of both.
+func parseScheduleWindow(spec string) (scheduleWindow, error) {
+	var w scheduleWindow
+	fields := strings.Fields(spec)
+	if len(fields) != 2 {
+		return w, fmt.Errorf("want <days> <start>-<end>")
+	}
+
+	for _, part := range strings.Split(strings.ToLower(fields[0]), ",") {
+		if part == "daily" {
+			w.days = [7]bool{true, true, true, true, true, true, true}
+			continue
+		}
+		from, to := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			from, to = part[:i], part[i+1:]
+		}
+		first, ok1 := weekdays[from]
+		last, ok2 := weekdays[to]
+		if !ok1 || !ok2 {
+			return w, fmt.Errorf("unknown days %q", part)
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			w.days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+
+	times := strings.Split(fields[1], "-")
+	if len(times) != 2 {
+		return w, fmt.Errorf("want a time range such as 08:00-19:00")
+	}
+	var err error
+	if w.start, err = parseClock(times[0]); err != nil {
+		return w, err
+	}
+	if w.end, err = parseClock(times[1]); err != nil {
+		return w, err
+	}
+	if w.start == w.end {
+		return w, fmt.Errorf("window is empty")
+	}
+	return w, nil
+}
+
+// parseClock parses HH:MM into minutes since midnight, 24:00 is allowed
+func parseClock(s string) (int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) == 2 {
+		h, err1 := strconv.Atoi(parts[0])
+		m, err2 := strconv.Atoi(parts[1])
+		if err1 == nil && err2 == nil && h >= 0 && m >= 0 && m < 60 && h*60+m <= 24*60 {
+			return h*60 + m, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+}
+
+// PoweredOn reports whether the devices should run at t
+func (s *Schedule) PoweredOn(t time.Time) bool {
+	t = t.In(s.location)
+	minute := t.Hour()*60 + t.Minute()
+	yesterday := (t.Weekday() + 6) % 7
+	for _, w := range s.windows {
+		if w.start < w.end {
+			if w.days[t.Weekday()] && minute >= w.start && minute < w.end {
+				return true
+			}
+			continue
+		}
+		// the window starts on one of its days and ends the next day
+		if (w.days[t.Weekday()] && minute >= w.start) || (w.days[yesterday] && minute < w.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// selects reports whether the schedule applies to the device
+func (s *Schedule) selects(d *Device) bool {
+	for _, name := range s.Devices {
+		if name == d.ID || name == d.Hostname {
+			return true
+		}
+	}
+	if s.Tag != "" {
+		for _, tag := range d.Tags {
+			if tag == s.Tag {
+				return true
+			}
+		}
+	}
+	return false
+}
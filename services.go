@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// The services of Client only talk to the API. The commands pass the
+// services below to the flows instead, which also record what they create
+// in the state of the run, for cleanup --run, and report device progress.
+
+// cliDevices returns the device service of the commands
+func cliDevices(c *Client) DeviceService { return cliDeviceService{c.Devices(), c} }
+
+// cliIPs returns the IP service of the commands
+func cliIPs(c *Client) IPService { return cliIPService{c.IPs(), c} }
+
+type cliDeviceService struct {
+	DeviceService
+	c *Client
+}
+
+func (s cliDeviceService) Create(ctx context.Context, req *DeviceRequest, timeout time.Duration) (*CreateDeviceResult, error) {
+	return CreateDevice(ctx, s.c, req, timeout)
+}
+
+func (s cliDeviceService) Delete(ctx context.Context, deviceID string) (*DeleteDeviceResult, error) {
+	return DeleteDevice(ctx, s.c, deviceID)
+}
+
+type cliIPService struct {
+	IPService
+	c *Client
+}
+
+func (s cliIPService) Request(ctx context.Context, projectID string, req *IPReservationRequest) (*IPReservation, error) {
+	return requestIPReservation(ctx, projectID, req, s.c)
+}
+
+func (s cliIPService) Release(ctx context.Context, reservationID string) error {
+	return releaseIPReservation(ctx, reservationID, s.c)
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "volume snapshot create",
+		usage: "Take a snapshot of a volume",
+		run:   runSnapshotCreate,
+	})
+	registerCommand(&command{
+		name:  "volume snapshot list",
+		usage: "List the snapshots of a volume",
+		run:   runSnapshotList,
+	})
+	registerCommand(&command{
+		name:  "volume snapshot restore",
+		usage: "Restore a volume to one of its snapshots",
+		run:   runSnapshotRestore,
+	})
+	registerCommand(&command{
+		name:  "volume snapshot-policy list",
+		usage: "List the snapshot policies of a volume",
+		run:   runSnapshotPolicyList,
+	})
+	registerCommand(&command{
+		name:  "volume snapshot-policy create",
+		usage: "Snapshot a volume on a schedule",
+		run:   runSnapshotPolicyCreate,
+	})
+	registerCommand(&command{
+		name:  "volume snapshot-policy update",
+		usage: "Change the schedule of a snapshot policy",
+		run:   runSnapshotPolicyUpdate,
+	})
+	registerCommand(&command{
+		name:  "volume snapshot-policy delete",
+		usage: "Delete a snapshot policy",
+		run:   runSnapshotPolicyDelete,
+	})
+}
+
+// snapshot frequencies accepted by snapshot policies
+var snapshotFrequencies = []string{"15min", "1hour", "1day", "1week", "1month", "1year"}
+
+// Snapshot is a point in time copy of a volume
+type Snapshot struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	Size      int    `json:"size,omitempty"`
+	Timestamp string `json:"timestamp"`
+	Created   string `json:"created_at,omitempty"`
+}
+
+// SnapshotPolicy takes snapshots of a volume at a frequency, keeping the
+// latest count of them
+type SnapshotPolicy struct {
+	ID        string `json:"id"`
+	Frequency string `json:"snapshot_frequency"`
+	Count     int    `json:"snapshot_count"`
+}
+
+// listSnapshots returns the snapshots of the volume
+func listSnapshots(ctx context.Context, volumeID string, c *Client) ([]Snapshot, error) {
+	list := new(struct {
+		Snapshots []Snapshot `json:"snapshots"`
+	})
+	if err := c.DoRequest(ctx, "storage/"+volumeID+"/snapshots", "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.Snapshots, nil
+}
+
+// createSnapshot takes a snapshot of the volume, which completes in the
+// background
+func createSnapshot(ctx context.Context, volumeID string, c *Client) error {
+	return c.DoRequest(ctx, "storage/"+volumeID+"/snapshots", "POST", nil, nil, nil)
+}
+
+// restoreSnapshot restores the volume to the snapshot taken at timestamp
+func restoreSnapshot(ctx context.Context, volumeID, timestamp string, c *Client) error {
+	req := map[string]string{"restore_point": timestamp}
+	return c.DoRequest(ctx, "storage/"+volumeID+"/restore", "POST", req, nil, nil)
+}
+
+// listSnapshotPolicies returns the snapshot policies of the volume
+func listSnapshotPolicies(ctx context.Context, volumeID string, c *Client) ([]SnapshotPolicy, error) {
+	v := new(struct {
+		SnapshotPolicies []SnapshotPolicy `json:"snapshot_policies"`
+	})
+	if err := c.DoRequest(ctx, "storage/"+volumeID+"?include=snapshot_policies", "GET", nil, v, nil); err != nil {
+		return nil, err
+	}
+	return v.SnapshotPolicies, nil
+}
+
+// createSnapshotPolicy adds a snapshot policy to the volume
+func createSnapshotPolicy(ctx context.Context, volumeID, frequency string, count int, c *Client) (*SnapshotPolicy, error) {
+	p := new(SnapshotPolicy)
+	uri := fmt.Sprintf("storage/%s/snapshot-policies?snapshot_frequency=%s&snapshot_count=%d", volumeID, frequency, count)
+	if err := c.DoRequest(ctx, uri, "POST", nil, p, nil); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// updateSnapshotPolicy changes the frequency and count of a policy, empty
+// values are left unchanged
+func updateSnapshotPolicy(ctx context.Context, policyID, frequency string, count int, c *Client) (*SnapshotPolicy, error) {
+	req := map[string]interface{}{}
+	if frequency != "" {
+		req["snapshot_frequency"] = frequency
+	}
+	if count > 0 {
+		req["snapshot_count"] = count
+	}
+	p := new(SnapshotPolicy)
+	if err := c.DoRequest(ctx, "storage/snapshot-policies/"+policyID, "PATCH", req, p, nil); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// deleteSnapshotPolicy deletes a snapshot policy, keeping its snapshots
+func deleteSnapshotPolicy(ctx context.Context, policyID string, c *Client) error {
+	return c.DoRequest(ctx, "storage/snapshot-policies/"+policyID, "DELETE", nil, nil, nil)
+}
+
+func checkSnapshotFrequency(frequency string) error {
+	for _, f := range snapshotFrequencies {
+		if f == frequency {
+			return nil
+		}
+	}
+	return usageErrorf("invalid snapshot frequency %q, use %s", frequency, strings.Join(snapshotFrequencies, ", "))
+}
+
+// parseIDArgs parses the flags of a command taking n IDs as arguments,
+// printing usage when they are missing
+func parseIDArgs(fs *flag.FlagSet, args []string, usage string, n int) error {
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo " + usage)
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != n {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	return checkToken()
+}
+
+func runSnapshotCreate(ctx context.Context, args []string) error {
+	fs := newFlagSet("volume snapshot create")
+	if err := parseIDArgs(fs, args, "volume snapshot create [flags] <volume-id>", 1); err != nil {
+		return err
+	}
+
+	if err := createSnapshot(ctx, fs.Arg(0), newCLIClient()); err != nil {
+		return err
+	}
+	logger.Info("Requested snapshot of volume " + fs.Arg(0))
+	return nil
+}
+
+func runSnapshotList(ctx context.Context, args []string) error {
+	fs := newFlagSet("volume snapshot list")
+	if err := parseIDArgs(fs, args, "volume snapshot list [flags] <volume-id>", 1); err != nil {
+		return err
+	}
+
+	snapshots, err := listSnapshots(ctx, fs.Arg(0), newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if snapshots == nil {
+			snapshots = []Snapshot{}
+		}
+		prettyPrint(snapshots)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tTIMESTAMP")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.ID, s.Status, s.Timestamp)
+	}
+	return w.Flush()
+}
+
+func runSnapshotRestore(ctx context.Context, args []string) error {
+	fs := newFlagSet("volume snapshot restore")
+	if err := parseIDArgs(fs, args, "volume snapshot restore [flags] <volume-id> <snapshot-id>", 2); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	volumeID, snapshotID := fs.Arg(0), fs.Arg(1)
+	snapshots, err := listSnapshots(ctx, volumeID, client)
+	if err != nil {
+		return err
+	}
+	var snapshot *Snapshot
+	for i := range snapshots {
+		if snapshots[i].ID == snapshotID {
+			snapshot = &snapshots[i]
+		}
+	}
+	if snapshot == nil {
+		return fmt.Errorf("volume %s has no snapshot %s", volumeID, snapshotID)
+	}
+
+	if err := restoreSnapshot(ctx, volumeID, snapshot.Timestamp, client); err != nil {
+		return err
+	}
+	logger.Info("Restoring volume "+volumeID+" to "+snapshot.Timestamp, "snapshot", snapshot.ID)
+	return nil
+}
+
+func runSnapshotPolicyList(ctx context.Context, args []string) error {
+	fs := newFlagSet("volume snapshot-policy list")
+	if err := parseIDArgs(fs, args, "volume snapshot-policy list [flags] <volume-id>", 1); err != nil {
+		return err
+	}
+
+	policies, err := listSnapshotPolicies(ctx, fs.Arg(0), newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if policies == nil {
+			policies = []SnapshotPolicy{}
+		}
+		prettyPrint(policies)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFREQUENCY\tCOUNT")
+	for _, p := range policies {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", p.ID, p.Frequency, p.Count)
+	}
+	return w.Flush()
+}
+
+func runSnapshotPolicyCreate(ctx context.Context, args []string) error {
+	fs := newFlagSet("volume snapshot-policy create")
+	frequency := fs.String("frequency", "1day", "How often to take a snapshot: "+strings.Join(snapshotFrequencies, ", "))
+	count := fs.Int("count", 7, "Number of snapshots to keep")
+	if err := parseIDArgs(fs, args, "volume snapshot-policy create [flags] <volume-id>", 1); err != nil {
+		return err
+	}
+	if err := checkSnapshotFrequency(*frequency); err != nil {
+		return err
+	}
+	if *count < 1 {
+		return usageErrorf("--count must be at least 1")
+	}
+
+	p, err := createSnapshotPolicy(ctx, fs.Arg(0), *frequency, *count, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(p)
+		return nil
+	}
+	logger.Info(fmt.Sprintf("Volume %s gets a snapshot every %s, keeping the last %d", fs.Arg(0), p.Frequency, p.Count), "id", p.ID)
+	return nil
+}
+
+func runSnapshotPolicyUpdate(ctx context.Context, args []string) error {
+	fs := newFlagSet("volume snapshot-policy update")
+	frequency := fs.String("frequency", "", "How often to take a snapshot: "+strings.Join(snapshotFrequencies, ", "))
+	count := fs.Int("count", 0, "Number of snapshots to keep")
+	if err := parseIDArgs(fs, args, "volume snapshot-policy update [flags] <policy-id>", 1); err != nil {
+		return err
+	}
+	if *frequency == "" && *count == 0 {
+		return usageErrorf("provide --frequency or --count")
+	}
+	if *frequency != "" {
+		if err := checkSnapshotFrequency(*frequency); err != nil {
+			return err
+		}
+	}
+	if *count < 0 {
+		return usageErrorf("--count must be at least 1")
+	}
+
+	p, err := updateSnapshotPolicy(ctx, fs.Arg(0), *frequency, *count, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(p)
+		return nil
+	}
+	logger.Info("Snapshot policy "+p.ID+" updated", "frequency", p.Frequency, "count", p.Count)
+	return nil
+}
+
+func runSnapshotPolicyDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("volume snapshot-policy delete")
+	if err := parseIDArgs(fs, args, "volume snapshot-policy delete [flags] <policy-id>", 1); err != nil {
+		return err
+	}
+
+	if err := deleteSnapshotPolicy(ctx, fs.Arg(0), newCLIClient()); err != nil {
+		return err
+	}
+	logger.Info("Snapshot policy " + fs.Arg(0) + " deleted")
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "device ssh-config",
+		usage: "Print ssh_config Host blocks for the project devices",
+		run:   runDeviceSSHConfig,
+	})
+}
+
+func runDeviceSSHConfig(ctx context.Context, args []string) error {
+	fs := newFlagSet("device ssh-config")
+	addCacheFlags(fs)
+	addSSHFlags(fs)
+	knownHosts := fs.Bool("known-hosts", false, "Scan the host keys of the devices with ssh-keyscan and add them to --known-hosts-file")
+	knownHostsFile := fs.String("known-hosts-file", defaultKnownHostsFile(), "known_hosts file the scanned host keys are added to")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo device ssh-config [flags] [<device-id or hostname>...]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	devices, err := listDevices(ctx, projectID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	selected, err := selectDevices(devices, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	var ips []string
+	for _, d := range selected {
+		ip := d.PublicIPv4()
+		if ip == "" {
+			logger.Warn("Skipping device without a public IPv4 address", "device", d.ID, "hostname", d.Hostname, "state", d.State)
+			continue
+		}
+		ips = append(ips, ip)
+		fmt.Print(sshConfigBlock(d, ip))
+	}
+
+	if *knownHosts && len(ips) > 0 {
+		added, err := addKnownHosts(ctx, *knownHostsFile, ips)
+		if err != nil {
+			return err
+		}
+		logger.Info(fmt.Sprintf("Added %d host key(s) to %s", added, *knownHostsFile))
+	}
+	return nil
+}
+
+// selectDevices picks the devices named by ID or hostname, all of them when
+// no names are given
+func selectDevices(devices []Device, names []string) ([]*Device, error) {
+	var selected []*Device
+	if len(names) == 0 {
+		for i := range devices {
+			selected = append(selected, &devices[i])
+		}
+		return selected, nil
+	}
+	for _, name := range names {
+		found := false
+		for i := range devices {
+			if devices[i].ID == name || devices[i].Hostname == name {
+				selected = append(selected, &devices[i])
+				found = true
+			}
+		}
+		if !found {
+			return nil, usageErrorf("no device %s in project %s", name, projectID)
+		}
+	}
+	return selected, nil
+}
+
+// sshConfigBlock is the Host block reaching the device at ip, named by its
+// hostname so that "ssh <hostname>" works
+func sshConfigBlock(d *Device, ip string) string {
+	alias := d.Hostname
+	if alias == "" {
+		alias = d.ID
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Host %s\n", alias)
+	fmt.Fprintf(&b, "  HostName %s\n", ip)
+	fmt.Fprintf(&b, "  User %s\n", sshUser)
+	if sshKey != "" {
+		fmt.Fprintf(&b, "  IdentityFile %s\n", sshKey)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func defaultKnownHostsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "known_hosts"
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+// addKnownHosts scans the host keys of the addresses and appends the ones
+// the file does not have yet. It returns how many keys were added.
+func addKnownHosts(ctx context.Context, path string, ips []string) (int, error) {
+	cmd := exec.CommandContext(ctx, "ssh-keyscan", append([]string{"-T", "10"}, ips...)...)
+	// ssh-keyscan comments on every host it reaches on stderr
+	out, err := cmd.Output()
+	if err != nil && len(out) == 0 {
+		return 0, fmt.Errorf("scanning host keys: %w", err)
+	}
+
+	existing, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	known := map[string]bool{}
+	for _, line := range strings.Split(string(existing), "\n") {
+		known[strings.TrimSpace(line)] = true
+	}
+
+	var add bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	added := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || known[line] {
+			continue
+		}
+		known[line] = true
+		add.WriteString(line + "\n")
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		f.WriteString("\n")
+	}
+	if _, err := f.Write(add.Bytes()); err != nil {
+		f.Close()
+		return 0, err
+	}
+	return added, f.Close()
+}
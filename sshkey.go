@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// SSHKey is a public key installed on the devices of the project
+type SSHKey struct {
+	ID          string `json:"id"`
+	Label       string `json:"label"`
+	Key         string `json:"key"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// listSSHKeys returns the public keys of the project
+func listSSHKeys(ctx context.Context, projectID string, c *Client) ([]SSHKey, error) {
+	list := new(struct {
+		SSHKeys []SSHKey `json:"ssh_keys"`
+	})
+	uri := fmt.Sprintf("projects/%s/ssh-keys", projectID)
+	if err := c.DoRequest(ctx, uri, "GET", nil, list, nil); err != nil {
+		return nil, err
+	}
+	return list.SSHKeys, nil
+}
+
+// createSSHKey uploads a public key to the project, devices created
+// afterwards get it in authorized_keys
+func createSSHKey(ctx context.Context, projectID, label, key string, c *Client) (*SSHKey, error) {
+	k := new(SSHKey)
+	uri := fmt.Sprintf("projects/%s/ssh-keys", projectID)
+	req := map[string]string{"label": label, "key": key}
+	if err := c.DoRequest(ctx, uri, "POST", req, k, nil); err != nil {
+		return nil, err
+	}
+	runState.created("ssh_key", k.ID, k.Label, projectID)
+	return k, nil
+}
+
+// deleteSSHKey removes a public key, devices keep their authorized_keys
+func deleteSSHKey(ctx context.Context, keyID string, c *Client) error {
+	if err := c.DoRequest(ctx, "ssh-keys/"+keyID, "DELETE", nil, nil, nil); err != nil {
+		return err
+	}
+	runState.deleted(keyID)
+	return nil
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "cleanup",
+		usage: "Delete everything a run of the tool created, as recorded in the state file",
+		run:   runCleanup,
+	})
+}
+
+// resource types recorded in the state file, in the order cleanup deletes
+// them: devices first, as they hold on to addresses and volumes
+var resourceTypes = []string{"device", "dns_record", "volume", "ip_reservation", "vlan", "ssh_key"}
+
+// Resource is a resource created by the tool, recorded in the state file
+// until the tool deletes it
+type Resource struct {
+	Run       string    `json:"run"`
+	Type      string    `json:"type"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	ProjectID string    `json:"project_id,omitempty"`
+	DeviceID  string    `json:"device_id,omitempty"`
+	Created   time.Time `json:"created_at"`
+}
+
+// stateFile records the resources created by runs of the tool, so that
+// resources of a failed or interrupted run can be found and deleted
+// instead of being billed. Each change re-reads the file, so that runs in
+// parallel rarely lose each other's records.
+type stateFile struct {
+	path     string
+	run      string
+	mu       sync.Mutex
+	announce sync.Once
+}
+
+// runState is the state file of the command line, nil when resources are
+// not recorded
+var runState *stateFile
+
+// statePath is where the resources created by the tool are recorded
+func statePath() string {
+	if path := os.Getenv("PACKET_STATE_FILE"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "packet-go-demo", "state.json")
+}
+
+// openRunState starts recording the resources of this run. PACKET_RUN_ID
+// groups several invocations, e.g. the steps of a CI job, into one run.
+func openRunState() {
+	run := os.Getenv("PACKET_RUN_ID")
+	if run == "" {
+		run = time.Now().UTC().Format("20060102-150405") + "-" + strings.ToLower(RandomLetters(4))
+	}
+	runState = &stateFile{path: statePath(), run: run}
+}
+
+func (s *stateFile) load() ([]Resource, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resources []Resource
+	if err := json.Unmarshal(data, &resources); err != nil {
+		return nil, fmt.Errorf("reading state file %s: %s", s.path, err)
+	}
+	return resources, nil
+}
+
+func (s *stateFile) save(resources []Resource) error {
+	if resources == nil {
+		resources = []Resource{}
+	}
+	data, err := json.MarshalIndent(resources, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, append(data, '\n'), 0600)
+}
+
+// update changes the recorded resources with f. A state file that cannot
+// be written is reported but does not fail the run that created them.
+func (s *stateFile) update(f func([]Resource) []Resource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resources, err := s.load()
+	if err == nil {
+		err = s.save(f(resources))
+	}
+	if err != nil {
+		logger.Warn("Updating the state file failed", "path", s.path, "error", err)
+	}
+}
+
+// created records a resource created by this run
+func (s *stateFile) created(typ, id, name, projectID string) {
+	if s == nil || id == "" {
+		return
+	}
+	s.record(Resource{Type: typ, ID: id, Name: name, ProjectID: projectID})
+}
+
+// createdRecord records a DNS record pointing at a device
+func (s *stateFile) createdRecord(id, name, deviceID string) {
+	if s == nil {
+		return
+	}
+	s.record(Resource{Type: "dns_record", ID: id, Name: name, DeviceID: deviceID})
+}
+
+func (s *stateFile) record(r Resource) {
+	r.Run, r.Created = s.run, time.Now().UTC()
+	s.update(func(resources []Resource) []Resource {
+		kept := resources[:0]
+		for _, old := range resources {
+			// a record updated in place replaces the old one
+			if old.ID != r.ID {
+				kept = append(kept, old)
+			}
+		}
+		return append(kept, r)
+	})
+	s.announce.Do(func() {
+		logger.Info("Created resources are recorded, delete them with cleanup --run " + s.run)
+	})
+}
+
+// records returns the DNS records pointing at a device, whichever run
+// created them
+func (s *stateFile) records(deviceID string) []Resource {
+	if s == nil {
+		return nil
+	}
+	resources, err := s.load()
+	if err != nil {
+		logger.Warn("Reading the state file failed", "path", s.path, "error", err)
+		return nil
+	}
+	var records []Resource
+	for _, r := range resources {
+		if r.Type == "dns_record" && r.DeviceID == deviceID {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// deleted forgets a resource that no longer exists, whichever run created it
+func (s *stateFile) deleted(id string) {
+	if s == nil {
+		return
+	}
+	s.update(func(resources []Resource) []Resource {
+		kept := resources[:0]
+		for _, r := range resources {
+			if r.ID != id {
+				kept = append(kept, r)
+			}
+		}
+		return kept
+	})
+}
+
+// runSummary is a run with resources left in the state file
+type runSummary struct {
+	Run       string   `json:"run"`
+	Started   string   `json:"started_at"`
+	Resources int      `json:"resources"`
+	Projects  []string `json:"projects"`
+}
+
+func runCleanup(ctx context.Context, args []string) error {
+	fs := newFlagSet("cleanup")
+	run := fs.String("run", "", "ID of the run whose resources are deleted")
+	list := fs.Bool("list", false, "List the runs with resources left instead of deleting anything")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *run == "" && !*list {
+		return usageErrorf("--run is required, see cleanup --list for the runs with resources left")
+	}
+
+	resources, err := runState.load()
+	if err != nil {
+		return err
+	}
+	if *list {
+		return printRuns(resources)
+	}
+
+	var todo []Resource
+	for _, typ := range resourceTypes {
+		for _, r := range resources {
+			if r.Run == *run && r.Type == typ {
+				todo = append(todo, r)
+			}
+		}
+	}
+	if len(todo) == 0 {
+		fmt.Printf("Nothing to clean up, run %s has no resources left in %s\n", *run, runState.path)
+		return nil
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	failures := 0
+	for _, r := range todo {
+		err := deleteResource(ctx, client, r)
+		if isNotFound(err) {
+			// deleted by other means, there is nothing left to bill
+			runState.deleted(r.ID)
+			err = nil
+		}
+		if errors.Is(err, ErrDryRun) {
+			continue
+		}
+		if err != nil {
+			logger.Error("Deleting resource failed, it stays in the state file", "type", r.Type, "id", r.ID, "name", r.Name, "error", err)
+			failures++
+			continue
+		}
+		logger.Info("Deleted "+strings.Replace(r.Type, "_", " ", -1), "id", r.ID, "name", r.Name)
+	}
+	if failures > 0 {
+		return &statusError{exitPartial, fmt.Errorf("%d of %d resources of run %s could not be deleted, run cleanup again once they are idle", failures, len(todo), *run)}
+	}
+	return nil
+}
+
+func deleteResource(ctx context.Context, client *Client, r Resource) error {
+	switch r.Type {
+	case "device":
+		_, err := DeleteDevice(ctx, client, r.ID)
+		return err
+	case "volume":
+		return deleteVolume(ctx, r.ID, client)
+	case "ip_reservation":
+		return releaseIPReservation(ctx, r.ID, client)
+	case "vlan":
+		return deleteVLAN(ctx, r.ID, client)
+	case "ssh_key":
+		return deleteSSHKey(ctx, r.ID, client)
+	case "dns_record":
+		if dnsProvider == "" {
+			return fmt.Errorf("--dns-provider or PACKET_DNS_PROVIDER is needed to delete DNS records")
+		}
+		provider, err := newDNSProvider()
+		if err != nil {
+			return err
+		}
+		return deleteDNSRecord(ctx, provider, r)
+	}
+	return fmt.Errorf("unknown resource type %q", r.Type)
+}
+
+func printRuns(resources []Resource) error {
+	byRun := map[string]*runSummary{}
+	seen := map[string]bool{}
+	var runs []*runSummary
+	for _, r := range resources {
+		s, ok := byRun[r.Run]
+		if !ok {
+			s = &runSummary{Run: r.Run, Started: r.Created.Format(time.RFC3339)}
+			byRun[r.Run] = s
+			runs = append(runs, s)
+		}
+		s.Resources++
+		if r.ProjectID != "" && !seen[r.Run+"/"+r.ProjectID] {
+			seen[r.Run+"/"+r.ProjectID] = true
+			s.Projects = append(s.Projects, r.ProjectID)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Started < runs[j].Started })
+
+	if jsonOutput() {
+		if runs == nil {
+			runs = []*runSummary{}
+		}
+		prettyPrint(runs)
+		return nil
+	}
+	if len(runs) == 0 {
+		fmt.Printf("No resources left in %s\n", runState.path)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tSTARTED\tRESOURCES\tPROJECTS")
+	for _, s := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", s.Run, s.Started, s.Resources, strings.Join(s.Projects, ","))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var showStats bool
+
+// callStats sums up the API calls of a run for --stats
+type callStats struct {
+	mu     sync.Mutex
+	calls  int
+	failed int
+	// notModified are calls answered 304 from a stored ETag
+	notModified int
+	latency     time.Duration
+	retries     int
+	// rate limit of the last response announcing one, -1 when none did
+	rateLimit, rateRemaining int
+	rateReset                string
+}
+
+var runStats = &callStats{rateLimit: -1, rateRemaining: -1}
+
+// record adds an API call, reading the rate limit headers of its response
+func (s *callStats) record(call apiCall, resp *http.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	s.latency += call.Duration
+	if call.Status == http.StatusNotModified {
+		s.notModified++
+	} else if call.Err != "" || call.Status > 299 {
+		s.failed++
+	}
+	if resp == nil {
+		return
+	}
+	if n, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		s.rateRemaining = n
+		s.rateLimit, _ = strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+		s.rateReset = resp.Header.Get("X-RateLimit-Reset")
+	}
+}
+
+// retried counts n requests that were sent again
+func (s *callStats) retried(n int) {
+	s.mu.Lock()
+	s.retries += n
+	s.mu.Unlock()
+}
+
+// write prints the summary
+func (s *callStats) write(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	avg := time.Duration(0)
+	if s.calls > 0 {
+		avg = s.latency / time.Duration(s.calls)
+	}
+	if s.notModified > 0 {
+		fmt.Fprintf(w, "API calls:  %d (%d failed, %d not modified)\n", s.calls, s.failed, s.notModified)
+	} else {
+		fmt.Fprintf(w, "API calls:  %d (%d failed)\n", s.calls, s.failed)
+	}
+	fmt.Fprintf(w, "Latency:    %s total, %s average\n", s.latency.Round(time.Millisecond), avg.Round(time.Millisecond))
+	fmt.Fprintf(w, "Retries:    %d\n", s.retries)
+	switch {
+	case s.rateRemaining < 0:
+		fmt.Fprintln(w, "Rate limit: not announced by the API")
+	case s.rateLimit > 0:
+		fmt.Fprintf(w, "Rate limit: %d of %d requests left", s.rateRemaining, s.rateLimit)
+	default:
+		fmt.Fprintf(w, "Rate limit: %d requests left", s.rateRemaining)
+	}
+	if s.rateRemaining >= 0 {
+		if reset, err := strconv.ParseInt(s.rateReset, 10, 64); err == nil {
+			fmt.Fprintf(w, ", resets at %s", time.Unix(reset, 0).Format("15:04:05"))
+		}
+		fmt.Fprintln(w)
+	}
+}
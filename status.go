@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "status",
+		usage: "Summarize devices, IP blocks and estimated cost of the project",
+		run:   runStatus,
+	})
+}
+
+// ProjectStatus is an at-a-glance summary of a project
+type ProjectStatus struct {
+	Project string         `json:"project"`
+	Devices int            `json:"devices"`
+	ByState map[string]int `json:"by_state"`
+	ByPlan  map[string]int `json:"by_plan"`
+	// ByLocation counts the devices by metro, or by facility for devices
+	// of the Packet API that are not in a metro
+	ByLocation       map[string]int `json:"by_location"`
+	Reserved         int            `json:"reserved"`
+	OnDemand         int            `json:"on_demand"`
+	IPBlocks         int            `json:"ip_blocks"`
+	IPBlocksAssigned int            `json:"ip_blocks_assigned"`
+	HourlyCost       float64        `json:"estimated_hourly_cost"`
+}
+
+func runStatus(ctx context.Context, args []string) error {
+	fs := newFlagSet("status")
+	addCacheFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	var (
+		devices []Device
+		ips     []IPReservation
+	)
+	g, gctx := newGroup(ctx)
+	g.Go(func() (err error) {
+		devices, err = searchDevices(gctx, projectID, nil, DeviceSummaryOptions, client)
+		return err
+	})
+	g.Go(func() (err error) {
+		ips, err = listIPReservations(gctx, projectID, client)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	status := newProjectStatus(projectID, devices, ips)
+	if jsonOutput() {
+		prettyPrint(status)
+		return nil
+	}
+	status.print()
+	return nil
+}
+
+func newProjectStatus(projectID string, devices []Device, ips []IPReservation) *ProjectStatus {
+	s := &ProjectStatus{
+		Project:    projectID,
+		Devices:    len(devices),
+		ByState:    map[string]int{},
+		ByPlan:     map[string]int{},
+		ByLocation: map[string]int{},
+	}
+	for i := range devices {
+		d := &devices[i]
+		s.ByState[d.State.String()]++
+		s.ByPlan[d.PlanSlug()]++
+		s.ByLocation[d.MetroCode()]++
+		if d.HardwareReservation != nil {
+			// reserved hardware is paid for by the reservation
+			s.Reserved++
+			continue
+		}
+		s.OnDemand++
+		if d.State != StateInactive {
+			s.HourlyCost += d.HourlyPrice()
+		}
+	}
+
+	for _, ip := range ips {
+		// management addresses come with every device and are not reserved
+		if ip.Management {
+			continue
+		}
+		s.IPBlocks++
+		if len(ip.Assignments) > 0 {
+			s.IPBlocksAssigned++
+		}
+	}
+	return s
+}
+
+func (s *ProjectStatus) print() {
+	fmt.Printf("Project %s\n\n", s.Project)
+	fmt.Printf("Devices:   %d (%d on-demand, %d reserved)\n", s.Devices, s.OnDemand, s.Reserved)
+	fmt.Printf("IP blocks: %d reserved, %d assigned\n", s.IPBlocks, s.IPBlocksAssigned)
+	fmt.Printf("Estimated hourly burn: $%.2f\n", s.HourlyCost)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, group := range []struct {
+		title  string
+		counts map[string]int
+	}{
+		{"STATE", s.ByState},
+		{"PLAN", s.ByPlan},
+		{"LOCATION", s.ByLocation},
+	} {
+		if len(group.counts) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s\tDEVICES\n", group.title)
+		for _, key := range sortedKeys(group.counts) {
+			name := key
+			if name == "" {
+				name = "-"
+			}
+			fmt.Fprintf(w, "%s\t%d\n", name, group.counts[key])
+		}
+	}
+	w.Flush()
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+)
+
+var storageFile string
+
+// addStorageFlags adds the flag setting the disk layout of a device
+func addStorageFlags(fs *flag.FlagSet) {
+	fs.StringVar(&storageFile, "storage-file", "", "JSON custom partitioning and RAID (CPR) layout of the device disks")
+}
+
+// loadStorageFile reads and validates the --storage-file layout, nil when
+// none is given
+func loadStorageFile() (*CPR, error) {
+	if storageFile == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(storageFile)
+	if err != nil {
+		return nil, usageErrorf("%s", err)
+	}
+	cpr := new(CPR)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cpr); err != nil {
+		return nil, usageErrorf("%s: %s", storageFile, err)
+	}
+	if err := cpr.Validate(); err != nil {
+		return nil, usageErrorf("%s: %s", storageFile, err)
+	}
+	return cpr, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// decodeArray decodes the elements of the array under key of the JSON
+// object read from r one at a time, calling each with the decoder
+// positioned at the next element. Other fields are skipped.
+func decodeArray(r io.Reader, key string, each func(*json.Decoder) error) error {
+	dec := json.NewDecoder(r)
+	if t, err := dec.Token(); err != nil {
+		return err
+	} else if t != json.Delim('{') {
+		return fmt.Errorf("expected a JSON object, got %v", t)
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if t != key {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+		if t, err := dec.Token(); err != nil {
+			return err
+		} else if t == nil {
+			continue
+		} else if t != json.Delim('[') {
+			return fmt.Errorf("expected %s to be an array, got %v", key, t)
+		}
+		for dec.More() {
+			if err := each(dec); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamArray requests url and calls each with every element of the array
+// under key of the response, without holding the whole response in memory
+func streamArray(ctx context.Context, c *Client, url, key string, each func(*json.Decoder) error) error {
+	body, err := c.Stream(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return decodeArray(body, key, each)
+}
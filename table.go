@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	tableSort    string
+	tableColumns string
+)
+
+// tableColumn is a column of a table output, value returns the cell of the
+// row with the given index and key, when set, what the row is sorted by
+// instead. Hidden columns are only shown when asked for with --columns,
+// they can still be sorted by.
+type tableColumn struct {
+	name   string
+	value  func(row int) string
+	key    func(row int) string
+	hidden bool
+}
+
+// addTableFlags adds the flags tailoring the table output of a list command
+func addTableFlags(fs *flag.FlagSet, sortKeys string) {
+	fs.StringVar(&tableSort, "sort", "", "Sort the table by a column: "+sortKeys)
+	fs.StringVar(&tableColumns, "columns", "", "Comma separated columns of the table, e.g. id,hostname,ip,state")
+}
+
+// selectColumns returns the columns picked by --columns in its order, the
+// visible ones without it, and checks that --sort names a column
+func selectColumns(columns []tableColumn) ([]tableColumn, error) {
+	byName := map[string]tableColumn{}
+	var names []string
+	for _, c := range columns {
+		byName[c.name] = c
+		names = append(names, c.name)
+	}
+	if _, ok := byName[tableSort]; tableSort != "" && !ok {
+		return nil, usageErrorf("unknown --sort column %q, use one of %s", tableSort, strings.Join(names, ", "))
+	}
+
+	var selected []tableColumn
+	if tableColumns == "" {
+		for _, c := range columns {
+			if !c.hidden {
+				selected = append(selected, c)
+			}
+		}
+		return selected, nil
+	}
+	for _, name := range strings.Split(tableColumns, ",") {
+		c, ok := byName[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, usageErrorf("unknown column %q, use one of %s", name, strings.Join(names, ", "))
+		}
+		selected = append(selected, c)
+	}
+	return selected, nil
+}
+
+// printTable prints the rows as a table of the selected columns, sorted
+// by --sort
+func printTable(columns []tableColumn, rows int) error {
+	selected, err := selectColumns(columns)
+	if err != nil {
+		return err
+	}
+
+	order := make([]int, rows)
+	for i := range order {
+		order[i] = i
+	}
+	for _, c := range columns {
+		if c.name == tableSort {
+			value := c.value
+			if c.key != nil {
+				value = c.key
+			}
+			sort.SliceStable(order, func(i, j int) bool { return lessCell(value(order[i]), value(order[j])) })
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	cells := make([]string, len(selected))
+	for i, c := range selected {
+		cells[i] = strings.ToUpper(c.name)
+	}
+	fmt.Fprintln(w, strings.Join(cells, "\t"))
+	for _, row := range order {
+		for i, c := range selected {
+			cells[i] = c.value(row)
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	return w.Flush()
+}
+
+// lessCell orders cells starting with a number, such as sizes, by that
+// number and other cells alphabetically
+func lessCell(a, b string) bool {
+	x, errA := strconv.ParseFloat(firstField(a), 64)
+	y, errB := strconv.ParseFloat(firstField(b), 64)
+	if errA == nil && errB == nil {
+		return x < y
+	}
+	return a < b
+}
+
+func firstField(s string) string {
+	if f := strings.Fields(s); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
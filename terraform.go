@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "export terraform",
+		usage: "Write Terraform resources and import commands for the project devices",
+		run:   runExportTerraform,
+	})
+}
+
+func runExportTerraform(ctx context.Context, args []string) error {
+	fs := newFlagSet("export terraform")
+	addCacheFlags(fs)
+	file := fs.String("f", "", "Write the resources to this file instead of stdout, e.g. devices.tf")
+	script := fs.String("import-script", "", "Write the terraform import commands to this shell script instead of comments after the resources")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	devices, err := listDevices(ctx, projectID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	resources, imports := terraformDevices(projectID, devices)
+
+	var b strings.Builder
+	b.WriteString(resources)
+	if *script == "" {
+		b.WriteString("# Import the existing devices into the Terraform state with:\n")
+		for _, cmd := range imports {
+			b.WriteString("#   " + cmd + "\n")
+		}
+	} else {
+		data := "#!/bin/sh\nset -e\n" + strings.Join(imports, "\n") + "\n"
+		if err := ioutil.WriteFile(*script, []byte(data), 0755); err != nil {
+			return err
+		}
+	}
+
+	if *file == "" {
+		_, err = io.WriteString(os.Stdout, b.String())
+		return err
+	}
+	if err := ioutil.WriteFile(*file, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	logger.Info("Wrote Terraform resources to "+*file, "project", projectID, "devices", len(devices), "import_script", *script)
+	return nil
+}
+
+// terraformDevices returns the equinix_metal_device resources describing
+// the devices and the terraform import commands adopting them
+func terraformDevices(projectID string, devices []Device) (string, []string) {
+	var b strings.Builder
+	b.WriteString(`terraform {
+  required_providers {
+    equinix = {
+      source = "equinix/equinix"
+    }
+  }
+}
+`)
+
+	var imports []string
+	names := map[string]bool{}
+	for i := range devices {
+		d := &devices[i]
+		name := terraformName(d.Hostname)
+		for n := 2; names[name]; n++ {
+			name = fmt.Sprintf("%s_%d", terraformName(d.Hostname), n)
+		}
+		names[name] = true
+
+		fmt.Fprintf(&b, "\nresource \"equinix_metal_device\" %q {\n", name)
+		fmt.Fprintf(&b, "  hostname         = %s\n", hclString(d.Hostname))
+		fmt.Fprintf(&b, "  plan             = %s\n", hclString(d.PlanSlug()))
+		if code := attrString(d.Metro, "code"); code != "" {
+			fmt.Fprintf(&b, "  metro            = %s\n", hclString(code))
+		} else {
+			fmt.Fprintf(&b, "  facilities       = [%s]\n", hclString(d.FacilityCode()))
+		}
+		fmt.Fprintf(&b, "  operating_system = %s\n", hclString(d.OSSlug()))
+		fmt.Fprintf(&b, "  billing_cycle    = %s\n", hclString(d.BillingCycle.String()))
+		fmt.Fprintf(&b, "  project_id       = %s\n", hclString(projectID))
+		if len(d.Tags) > 0 {
+			tags := make([]string, len(d.Tags))
+			for i, t := range d.Tags {
+				tags[i] = hclString(t)
+			}
+			fmt.Fprintf(&b, "  tags             = [%s]\n", strings.Join(tags, ", "))
+		}
+		b.WriteString("}\n")
+
+		imports = append(imports, fmt.Sprintf("terraform import equinix_metal_device.%s %s", name, d.ID))
+	}
+	if len(devices) > 0 {
+		b.WriteString("\n")
+	}
+	return b.String(), imports
+}
+
+// terraformName turns a hostname into a resource name, which may only
+// hold letters, digits, underscores and dashes and not start with a digit
+func terraformName(hostname string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, hostname)
+	if name == "" || name[0] >= '0' && name[0] <= '9' || name[0] == '-' {
+		name = "device_" + name
+	}
+	return name
+}
+
+// hclString quotes s as an HCL string, escaping template sequences
+func hclString(s string) string {
+	q := strconv.Quote(s)
+	q = strings.ReplaceAll(q, "${", "$${")
+	return strings.ReplaceAll(q, "%{", "%%{")
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how long a rate limited token is passed over when the API does not say
+const defaultRateLimitWait = time.Minute
+
+var (
+	// profileTokens are the tokens of the profile, used when no single
+	// token is given
+	profileTokens []ProfileToken
+	// tokenFailover retries rate limited requests with the next token,
+	// set by --token-failover
+	tokenFailover bool
+)
+
+// ProfileToken is one of several tokens of a profile. It is used for the
+// requests about its projects, or for any request when it lists none.
+type ProfileToken struct {
+	Name     string   `json:"name,omitempty"`
+	Token    string   `json:"token"`
+	Projects []string `json:"projects,omitempty"`
+}
+
+// label names the token in logs without giving it away
+func (t *ProfileToken) label(i int) string {
+	if t.Name != "" {
+		return t.Name
+	}
+	key := APIKey{Token: t.Token}
+	return fmt.Sprintf("token %d (%s)", i+1, key.MaskedToken())
+}
+
+// tokenPool picks the token of each request among the tokens of a profile:
+// the first one for the project the request is about, else the first one
+// for any project. With failover a rate limited token is passed over until
+// its limit resets, for the next token that may be used for the request.
+type tokenPool struct {
+	tokens   []ProfileToken
+	values   []*Token
+	project  string
+	failover bool
+
+	mu      sync.Mutex
+	limited map[int]time.Time
+}
+
+// the client asks the pool for the token of every request
+var _ RequestTokenSource = (*tokenPool)(nil)
+
+func newTokenPool(tokens []ProfileToken, project string, failover bool) *tokenPool {
+	p := &tokenPool{tokens: tokens, project: project, failover: failover, limited: map[int]time.Time{}}
+	for _, t := range tokens {
+		p.values = append(p.values, &Token{Value: t.Token})
+	}
+	return p
+}
+
+// Token returns the token of the project of the command
+func (p *tokenPool) Token() (*Token, error) {
+	return p.TokenFor("")
+}
+
+// projectFor returns the project of an API path such as
+// projects/{id}/devices, else the project of the command
+func (p *tokenPool) projectFor(path string) string {
+	parts := strings.SplitN(strings.SplitN(path, "?", 2)[0], "/", 3)
+	if len(parts) >= 2 && parts[0] == "projects" {
+		return parts[1]
+	}
+	return p.project
+}
+
+// candidates returns the indexes of the tokens that may be used for the
+// project, those listing it first
+func (p *tokenPool) candidates(project string) []int {
+	var own, any []int
+	for i, t := range p.tokens {
+		switch {
+		case len(t.Projects) == 0:
+			any = append(any, i)
+		case project != "" && contains(t.Projects, project):
+			own = append(own, i)
+		}
+	}
+	return append(own, any...)
+}
+
+// TokenFor returns the token for the request of the API path
+func (p *tokenPool) TokenFor(path string) (*Token, error) {
+	project := p.projectFor(path)
+	candidates := p.candidates(project)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no token of the profile is for project %s or for any project", project)
+	}
+	if !p.failover {
+		return p.values[candidates[0]], nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	soonest := candidates[0]
+	for _, i := range candidates {
+		if !now.Before(p.limited[i]) {
+			return p.values[i], nil
+		}
+		if p.limited[i].Before(p.limited[soonest]) {
+			soonest = i
+		}
+	}
+	// all are rate limited, the one that resets first gets the request
+	return p.values[soonest], nil
+}
+
+// RateLimited passes the token over until its limit resets, when failing
+// over, and reports whether another token can take the request
+func (p *tokenPool) RateLimited(tok *Token, path string, resp *http.Response) bool {
+	if !p.failover {
+		return false
+	}
+	wait := defaultRateLimitWait
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+		wait = time.Duration(s) * time.Second
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	limited := -1
+	for i, v := range p.values {
+		if v == tok {
+			limited = i
+			p.limited[i] = now.Add(wait)
+		}
+	}
+	if limited < 0 {
+		return false
+	}
+	project := p.projectFor(path)
+	for _, i := range p.candidates(project) {
+		if i != limited && !now.Before(p.limited[i]) {
+			logger.Warn("API rate limit reached, failing over to "+p.tokens[i].label(i), "token", p.tokens[limited].label(limited), "retry_after", wait)
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// poolTokens are tokens of a profile: one for project-a, one for project-a
+// and project-b, and two for any project
+var poolTokens = []ProfileToken{
+	{Name: "a", Token: "token-a", Projects: []string{"project-a"}},
+	{Name: "ab", Token: "token-ab", Projects: []string{"project-a", "project-b"}},
+	{Name: "any", Token: "token-any"},
+	{Name: "spare", Token: "token-spare"},
+}
+
+// rateLimited is a 429 response asking to wait for retryAfter seconds
+func rateLimited(retryAfter string) *http.Response {
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	if retryAfter != "" {
+		resp.Header.Set("Retry-After", retryAfter)
+	}
+	return resp
+}
+
+func TestTokenPoolTokenFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokens  []ProfileToken
+		project string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"project of the path", poolTokens, "", "projects/project-b/devices", "token-ab", false},
+		{"first token of the project", poolTokens, "", "projects/project-a/devices?page=2", "token-a", false},
+		{"project of the command", poolTokens, "project-b", "devices/8f2b1c4e-5a7d-4e3b-9c61-0d2f8a7b6e15", "token-ab", false},
+		{"path wins over the command", poolTokens, "project-b", "projects/project-a", "token-a", false},
+		{"token for any project", poolTokens, "project-c", "user", "token-any", false},
+		{"no project", poolTokens, "", "user", "token-any", false},
+		{"no token for the project", poolTokens[:2], "project-c", "user", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTokenPool(tt.tokens, tt.project, false)
+			tok, err := p.TokenFor(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("TokenFor(%q) = %s, want an error", tt.path, tok.Value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TokenFor(%q) error = %v", tt.path, err)
+			}
+			if tok.Value != tt.want {
+				t.Errorf("TokenFor(%q) = %s, want %s", tt.path, tok.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenPoolFailover(t *testing.T) {
+	tests := []struct {
+		name     string
+		failover bool
+		path     string
+		// limited is how many times in a row the token the pool gives out
+		// is rate limited
+		limited    int
+		retryAfter string
+		wantSent   string
+		wantRetry  string
+	}{
+		{"without failover", false, "projects/project-b/devices", 1, "", "token-ab", "false"},
+		{"fails over to any project", true, "projects/project-b/devices", 1, "60", "token-ab,token-any", "true"},
+		{"fails over in order", true, "projects/project-a/devices", 3, "", "token-a,token-ab,token-any,token-spare", "true,true,true"},
+		{"all limited", true, "projects/project-b/devices", 3, "30", "token-ab,token-any,token-spare,token-ab", "true,true,false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTokenPool(poolTokens, "", tt.failover)
+			var sent, retry []string
+			for i := 0; i < tt.limited; i++ {
+				tok, err := p.TokenFor(tt.path)
+				if err != nil {
+					t.Fatalf("TokenFor() error = %v", err)
+				}
+				sent = append(sent, tok.Value)
+				retry = append(retry, fmt.Sprint(p.RateLimited(tok, tt.path, rateLimited(tt.retryAfter))))
+			}
+			// with failover the request is sent again with the next token
+			if tok, err := p.TokenFor(tt.path); err == nil && tt.failover {
+				sent = append(sent, tok.Value)
+			}
+			if got := strings.Join(sent, ","); got != tt.wantSent {
+				t.Errorf("tokens sent = %s, want %s", got, tt.wantSent)
+			}
+			if got := strings.Join(retry, ","); got != tt.wantRetry {
+				t.Errorf("RateLimited() = %s, want %s", got, tt.wantRetry)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// span kinds and status codes of the OTLP protocol
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	spanStatusError = 2
+)
+
+// spans buffered before they are exported in the background
+const traceBatchSize = 100
+
+// tracer exports spans to an OpenTelemetry collector, Jaeger or Tempo over
+// OTLP/HTTP with JSON encoding. It is only set up when an OTLP endpoint is
+// configured, all span methods are no-ops otherwise.
+type tracer struct {
+	endpoint string
+	service  string
+	headers  map[string]string
+	// parent of the root span, from the TRACEPARENT of the calling pipeline
+	parent *span
+
+	mu    sync.Mutex
+	spans []*span
+}
+
+var (
+	activeTracer *tracer
+	// traceRoot spans the whole run and is ended by exitProcess
+	traceRoot *span
+)
+
+// span is a timed operation of a trace
+type span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    map[string]interface{}
+	err      string
+}
+
+type spanKey struct{}
+
+// setupTracing enables tracing when OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or
+// OTEL_EXPORTER_OTLP_ENDPOINT is set, as with the OpenTelemetry SDKs
+func setupTracing() {
+	if os.Getenv("OTEL_SDK_DISABLED") == "true" {
+		return
+	}
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	t := &tracer{endpoint: endpoint, service: os.Getenv("OTEL_SERVICE_NAME"), headers: map[string]string{}}
+	if t.service == "" {
+		t.service = DefaultUserAgent
+	}
+	for _, kv := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			t.headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	if tp := os.Getenv("TRACEPARENT"); tp != "" {
+		parent, sampled, err := parseTraceparent(tp)
+		if err != nil {
+			logger.Warn("Ignoring TRACEPARENT: " + err.Error())
+		} else if !sampled {
+			// the pipeline decided not to record this trace
+			return
+		}
+		t.parent = parent
+	}
+	activeTracer = t
+}
+
+// parseTraceparent parses a W3C trace context header such as
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func parseTraceparent(s string) (*span, bool, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return nil, false, fmt.Errorf("invalid trace context %q", s)
+	}
+	p := new(span)
+	_, err1 := hex.Decode(p.traceID[:], []byte(parts[1]))
+	_, err2 := hex.Decode(p.spanID[:], []byte(parts[2]))
+	flags, err3 := strconv.ParseUint(parts[3], 16, 8)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, false, fmt.Errorf("invalid trace context %q", s)
+	}
+	return p, flags&1 == 1, nil
+}
+
+// startSpan starts a span as child of the span of ctx, or of the
+// TRACEPARENT, and returns a context holding it
+func startSpan(ctx context.Context, name string, kind int) (context.Context, *span) {
+	if activeTracer == nil {
+		return ctx, nil
+	}
+	s := &span{name: name, kind: kind, start: time.Now(), attrs: map[string]interface{}{}}
+	parent := spanFromContext(ctx)
+	if parent == nil {
+		parent = activeTracer.parent
+	}
+	if parent != nil {
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func spanFromContext(ctx context.Context) *span {
+	s, _ := ctx.Value(spanKey{}).(*span)
+	return s
+}
+
+// setAttr sets an attribute of the span
+func (s *span) setAttr(key string, value interface{}) {
+	if s != nil {
+		s.attrs[key] = value
+	}
+}
+
+// finish ends the span, failed when err is not nil, and queues it for export
+func (s *span) finish(err error) {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+	t := activeTracer
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	full := len(t.spans) >= traceBatchSize
+	t.mu.Unlock()
+	if full {
+		go t.flush()
+	}
+}
+
+// traceparent returns the W3C trace context header of the span
+func (s *span) traceparent() string {
+	return fmt.Sprintf("00-%x-%x-01", s.traceID, s.spanID)
+}
+
+// flush exports the queued spans
+func (t *tracer) flush() {
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(t.otlpRequest(spans))
+	if err != nil {
+		logger.Warn("Encoding traces failed: " + err.Error())
+		return
+	}
+	r, err := http.NewRequest("POST", t.endpoint, bytes.NewReader(data))
+	if err != nil {
+		logger.Warn("Exporting traces failed: " + err.Error())
+		return
+	}
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		r.Header.Set(k, v)
+	}
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(r)
+	if err != nil {
+		logger.Warn("Exporting traces failed: " + err.Error())
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode > 299 {
+		logger.Warn(fmt.Sprintf("Exporting traces failed with status %d", resp.StatusCode), "endpoint", t.endpoint)
+	}
+}
+
+// otlpRequest builds the OTLP/JSON export request of the spans
+func (t *tracer) otlpRequest(spans []*span) interface{} {
+	type object = map[string]interface{}
+	var out []object
+	for _, s := range spans {
+		o := object{
+			"traceId":           hex.EncodeToString(s.traceID[:]),
+			"spanId":            hex.EncodeToString(s.spanID[:]),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+		}
+		if s.parentID != [8]byte{} {
+			o["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != "" {
+			o["status"] = object{"code": spanStatusError, "message": s.err}
+		}
+		out = append(out, o)
+	}
+	return object{"resourceSpans": []object{{
+		"resource":   object{"attributes": otlpAttributes(map[string]interface{}{"service.name": t.service})},
+		"scopeSpans": []object{{"scope": object{"name": DefaultUserAgent}, "spans": out}},
+	}}}
+}
+
+func otlpAttributes(attrs map[string]interface{}) []interface{} {
+	out := []interface{}{}
+	for k, v := range attrs {
+		var value map[string]interface{}
+		switch v := v.(type) {
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, map[string]interface{}{"key": k, "value": value})
+	}
+	return out
+}
+
+// finishTracing ends the root span of the run, failed for a non-zero exit
+// code, and exports the spans left
+func finishTracing(code int) {
+	if activeTracer == nil {
+		return
+	}
+	var err error
+	if code != 0 {
+		err = exitCode(code)
+	}
+	traceRoot.finish(err)
+	activeTracer.flush()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "device extend-ttl",
+		usage: "Postpone the termination time of a device",
+		run:   runDeviceExtendTTL,
+	})
+}
+
+// ways a device with a TTL is terminated
+const (
+	// ttlLocal waits out the TTL and deletes the device
+	ttlLocal = "local"
+	// ttlAPI sets the termination time of the device, the API deletes it
+	// without the tool running
+	ttlAPI = "api"
+)
+
+var (
+	ttl     time.Duration
+	ttlMode string
+)
+
+// addTTLFlags adds the flags of the demo limiting how long its device lives
+func addTTLFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&ttl, "ttl", 10*time.Second, "How long the device lives once it is ready, e.g. 2h")
+	fs.StringVar(&ttlMode, "ttl-mode", ttlLocal, "How the device is terminated after --ttl: local waits and deletes it, api sets its termination time and exits")
+}
+
+// checkTTL validates the TTL flags
+func checkTTL() error {
+	switch ttlMode {
+	case ttlLocal, ttlAPI:
+	default:
+		return usageErrorf("unknown --ttl-mode %q, use %s or %s", ttlMode, ttlLocal, ttlAPI)
+	}
+	if ttl < 0 || (ttlMode == ttlAPI && ttl < time.Minute) {
+		return usageErrorf("--ttl %s is too short, the API needs a termination time at least a minute ahead", ttl)
+	}
+	return nil
+}
+
+// terminationTime returns the time a device living for d from now is
+// terminated at
+func terminationTime(d time.Duration) *time.Time {
+	t := time.Now().Add(d).UTC().Truncate(time.Second)
+	return &t
+}
+
+func runDeviceExtendTTL(ctx context.Context, args []string) error {
+	fs := newFlagSet("device extend-ttl")
+	by := fs.Duration("by", time.Hour, "How much to postpone the termination time, from now for devices without one")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo device extend-ttl [flags] <device-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if *by <= 0 {
+		return usageErrorf("--by must be positive")
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	dev, err := getDevice(ctx, fs.Arg(0), client)
+	if err != nil {
+		return err
+	}
+	base := time.Now()
+	if t, err := time.Parse(time.RFC3339, dev.TerminationTime); err == nil && t.After(base) {
+		base = t
+	}
+	until := base.Add(*by).UTC().Truncate(time.Second)
+	dev, err = updateDevice(ctx, dev.ID, &DeviceUpdateRequest{TerminationTime: &until}, client)
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(dev)
+		return nil
+	}
+	logger.Info("Device is terminated at "+until.Format(time.RFC3339), "device", dev.ID, "hostname", dev.Hostname)
+	return nil
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "tui",
+		usage: "Terminal console of the project devices, with live state, details and reboot, delete and SSH keys",
+		run:   runTUI,
+	})
+}
+
+// terminal control sequences of the console
+const (
+	enterScreen = "\033[?1049h\033[?25l"
+	leaveScreen = "\033[?25h\033[?1049l"
+	clearScreen = "\033[H\033[2J"
+	reverse     = "\033[7m"
+	bold        = "\033[1m"
+	resetStyle  = "\033[0m"
+)
+
+// tui is the state of the terminal console
+type tui struct {
+	client    *Client
+	devices   []Device
+	selected  int
+	detail    bool
+	events    []Event
+	status    string
+	refreshed time.Time
+	// confirm is the action waiting for the user to press y
+	confirm string
+}
+
+// keyReader reads key presses while it is not paused. The terminal is set
+// to return from reads every tenth of a second, so that reading can be
+// paused for an SSH session without a pending read stealing its input.
+type keyReader struct {
+	keys  chan string
+	pause chan chan struct{}
+}
+
+func newKeyReader() *keyReader {
+	return &keyReader{keys: make(chan string, 64), pause: make(chan chan struct{})}
+}
+
+func (r *keyReader) run() {
+	buf := make([]byte, 16)
+	for {
+		select {
+		case resume := <-r.pause:
+			<-resume
+			continue
+		default:
+		}
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			r.keys <- string(buf[:n])
+		}
+		if err != nil && err != io.EOF {
+			close(r.keys)
+			return
+		}
+	}
+}
+
+// stop pauses reading until the returned channel is closed
+func (r *keyReader) stop() chan struct{} {
+	resume := make(chan struct{})
+	r.pause <- resume
+	return resume
+}
+
+// rawTerminal makes key presses available one at a time without echo
+func rawTerminal() error {
+	return stty("-icanon", "-echo", "min", "0", "time", "1")
+}
+
+func restoreTerminal() {
+	stty("icanon", "echo")
+}
+
+// terminalSize returns the rows and columns of the terminal
+func terminalSize() (int, int) {
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return 24, 80
+	}
+	var rows, cols int
+	if _, err := fmt.Sscan(string(out), &rows, &cols); err != nil || rows < 10 || cols < 40 {
+		return 24, 80
+	}
+	return rows, cols
+}
+
+func runTUI(ctx context.Context, args []string) error {
+	fs := newFlagSet("tui")
+	addSSHFlags(fs)
+	interval := fs.Duration("interval", 5*time.Second, "How often the devices are refreshed")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	for _, f := range []*os.File{os.Stdin, os.Stdout} {
+		if fi, err := f.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 || runtime.GOOS == "windows" {
+			return usageErrorf("tui needs a terminal, use device list --watch to follow devices otherwise")
+		}
+	}
+	if *interval < time.Second {
+		return usageErrorf("--interval must be at least 1s")
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	t := &tui{client: newCLIClient()}
+	if err := t.refresh(ctx); err != nil {
+		return err
+	}
+	if err := rawTerminal(); err != nil {
+		return fmt.Errorf("setting up the terminal: %w", err)
+	}
+	// log lines would be drawn over the console
+	defer func(l *slog.Logger) { logger = l }(logger)
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	fmt.Print(enterScreen)
+	defer func() {
+		fmt.Print(leaveScreen)
+		restoreTerminal()
+	}()
+
+	keys := newKeyReader()
+	go keys.run()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		t.draw()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := t.refresh(ctx); err != nil {
+				t.status = "Refresh failed: " + err.Error()
+			}
+		case key, ok := <-keys.keys:
+			if !ok || t.handle(ctx, key, keys) {
+				return nil
+			}
+		}
+	}
+}
+
+// refresh lists the devices again, keeping the selected device selected
+func (t *tui) refresh(ctx context.Context) error {
+	devices, err := listDevices(ctx, projectID, t.client)
+	if err != nil {
+		return err
+	}
+	id := ""
+	if d := t.current(); d != nil {
+		id = d.ID
+	}
+	t.devices = devices
+	t.selected = 0
+	for i, d := range devices {
+		if d.ID == id {
+			t.selected = i
+		}
+	}
+	t.refreshed = time.Now()
+	if t.detail {
+		t.loadEvents(ctx)
+	}
+	return nil
+}
+
+// current returns the selected device, nil for an empty project
+func (t *tui) current() *Device {
+	if t.selected < len(t.devices) {
+		return &t.devices[t.selected]
+	}
+	return nil
+}
+
+func (t *tui) loadEvents(ctx context.Context) {
+	t.events = nil
+	if d := t.current(); d != nil {
+		events, err := listEvents(ctx, "devices/"+d.ID+"/events", 5, t.client)
+		if err != nil {
+			t.status = "Loading events failed: " + err.Error()
+			return
+		}
+		t.events = events
+	}
+}
+
+// handle acts on a key press and reports whether the console should close
+func (t *tui) handle(ctx context.Context, key string, keys *keyReader) bool {
+	d := t.current()
+	if t.confirm != "" {
+		action := t.confirm
+		t.confirm = ""
+		t.status = ""
+		if key == "y" && d != nil {
+			t.run(ctx, action, d)
+		}
+		return false
+	}
+
+	switch key {
+	case "q":
+		return true
+	case "k", "\033[A":
+		if t.selected > 0 {
+			t.selected--
+		}
+	case "j", "\033[B":
+		if t.selected < len(t.devices)-1 {
+			t.selected++
+		}
+	case "\n", "\r", "\t":
+		t.detail = !t.detail
+	case " ":
+		if err := t.refresh(ctx); err != nil {
+			t.status = "Refresh failed: " + err.Error()
+		}
+		return false
+	case "r":
+		if d != nil {
+			t.confirm = "reboot"
+			t.status = "Reboot " + d.Hostname + "? y/n"
+		}
+		return false
+	case "d":
+		if d != nil {
+			t.confirm = "delete"
+			t.status = "Delete " + d.Hostname + "? y/n"
+		}
+		return false
+	case "s":
+		if d != nil {
+			t.ssh(d, keys)
+		}
+		return false
+	default:
+		return false
+	}
+	if t.detail {
+		t.loadEvents(ctx)
+	}
+	return false
+}
+
+// run performs a confirmed action on the device
+func (t *tui) run(ctx context.Context, action string, d *Device) {
+	switch action {
+	case "reboot":
+		if err := rebootDevice(ctx, d.ID, t.client); err != nil {
+			t.status = "Reboot failed: " + err.Error()
+			return
+		}
+		t.status = "Rebooting " + d.Hostname
+	case "delete":
+		if d.Locked {
+			t.status = d.Hostname + " is locked, unlock it with device unlock first"
+			return
+		}
+		hostname := d.Hostname
+		if _, err := DeleteDevice(ctx, t.client, d.ID); err != nil {
+			t.status = "Delete failed: " + err.Error()
+			return
+		}
+		t.status = "Deleted " + hostname
+	}
+	if err := t.refresh(ctx); err != nil {
+		t.status = "Refresh failed: " + err.Error()
+	}
+}
+
+// ssh opens an SSH session to the device in place of the console, which
+// comes back when the session ends
+func (t *tui) ssh(d *Device, keys *keyReader) {
+	ip := d.PublicIPv4()
+	if ip == "" {
+		t.status = d.Hostname + " has no public IPv4 address"
+		return
+	}
+	resume := keys.stop()
+	defer close(resume)
+	fmt.Print(leaveScreen)
+	restoreTerminal()
+
+	args := []string{"-o", "StrictHostKeyChecking=accept-new"}
+	if sshKey != "" {
+		args = append(args, "-i", sshKey)
+	}
+	cmd := exec.Command("ssh", append(args, sshUser+"@"+ip)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
+
+	rawTerminal()
+	fmt.Print(enterScreen)
+	if err != nil {
+		t.status = "SSH to " + d.Hostname + " failed: " + err.Error()
+		return
+	}
+	t.status = "SSH session to " + d.Hostname + " ended"
+}
+
+// draw renders the console, the device list on top and the details of the
+// selected device below
+func (t *tui) draw() {
+	rows, cols := terminalSize()
+	var b strings.Builder
+	b.WriteString(clearScreen)
+	line := func(style, s string) {
+		if r := []rune(s); len(r) > cols {
+			s = string(r[:cols])
+		}
+		if style != "" {
+			s = style + s + resetStyle
+		}
+		b.WriteString(s + "\r\n")
+	}
+
+	line(bold, fmt.Sprintf("Project %s  %d devices  refreshed %s", projectID, len(t.devices), t.refreshed.Format("15:04:05")))
+	line("", "")
+	var detail []string
+	if d := t.current(); t.detail && d != nil {
+		detail = t.detailLines(d)
+	}
+	// title, blank lines, header, status and help take 6 rows
+	visible := rows - 6 - len(detail)
+	if visible < 3 {
+		visible = 3
+	}
+	start := 0
+	if t.selected >= visible {
+		start = t.selected - visible + 1
+	}
+
+	columns := deviceColumns(t.devices)[:6]
+	widths := make([]int, len(columns))
+	for i, c := range columns {
+		widths[i] = len(c.name)
+		for row := range t.devices {
+			if w := len(c.value(row)); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	cells := func(value func(tableColumn) string) string {
+		var parts []string
+		for i, c := range columns {
+			parts = append(parts, fmt.Sprintf("%-*s", widths[i], value(c)))
+		}
+		return strings.Join(parts, "  ")
+	}
+	line(bold, cells(func(c tableColumn) string { return strings.ToUpper(c.name) }))
+	if len(t.devices) == 0 {
+		line("", "No devices")
+	}
+	for row := start; row < len(t.devices) && row < start+visible; row++ {
+		style := ""
+		if row == t.selected {
+			style = reverse
+		}
+		line(style, cells(func(c tableColumn) string { return c.value(row) }))
+	}
+	if len(detail) > 0 {
+		line("", "")
+		for _, l := range detail {
+			line("", l)
+		}
+	}
+	line("", "")
+	line(bold, t.status)
+	b.WriteString("↑/↓ select  enter details  r reboot  d delete  s ssh  space refresh  q quit")
+	fmt.Print(b.String())
+}
+
+// detailLines returns the detail pane of the device, its addresses and
+// latest events
+func (t *tui) detailLines(d *Device) []string {
+	lines := []string{
+		"── " + d.Hostname + " ──",
+		"ID        " + d.ID,
+		"OS        " + d.OSSlug(),
+		"Created   " + d.Created,
+		"Locked    " + strconv.FormatBool(d.Locked),
+		"Tags      " + strings.Join(d.Tags, ","),
+		"Addresses",
+	}
+	addrs, _ := d.Network.([]interface{})
+	for _, a := range addrs {
+		kind := "private"
+		if public, _ := attrValue(a, "public").(bool); public {
+			kind = "public"
+		}
+		cidr, _ := attrValue(a, "cidr").(float64)
+		lines = append(lines, fmt.Sprintf("  %s/%d %s", attrString(a, "address"), int(cidr), kind))
+	}
+	lines = append(lines, "Events")
+	for _, e := range t.events {
+		ts := e.Created
+		if parsed, err := time.Parse(time.RFC3339, e.Created); err == nil {
+			ts = parsed.Local().Format("2006-01-02 15:04:05")
+		}
+		lines = append(lines, "  "+ts+"  "+e.Message())
+	}
+	return lines
+}
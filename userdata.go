@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var (
+	userdataTemplate string
+	userdataVars     = templateVars{}
+	customdataFile   string
+)
+
+// templateVars collects repeated --var key=value flags
+type templateVars map[string]string
+
+func (v templateVars) String() string {
+	pairs := make([]string, 0, len(v))
+	for key, value := range v {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v templateVars) Set(s string) error {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("%q is not key=value", s)
+	}
+	v[parts[0]] = parts[1]
+	return nil
+}
+
+// addUserdataFlags adds the flags passing userdata and customdata to a
+// device
+func addUserdataFlags(fs *flag.FlagSet) {
+	fs.StringVar(&userdataTemplate, "userdata-template", "", "Go template rendered into the userdata of the device, e.g. a cloud-init config")
+	fs.Var(userdataVars, "var", "Variable of --userdata-template as key=value, may be repeated")
+	fs.StringVar(&customdataFile, "customdata-file", "", "JSON file attached to the device as customdata, readable on the device from the metadata service")
+}
+
+// loadCustomdata reads the --customdata-file document, which must be a
+// JSON object
+func loadCustomdata() (json.RawMessage, error) {
+	if customdataFile == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(customdataFile)
+	if err != nil {
+		return nil, usageErrorf("%s", err)
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, usageErrorf("%s: customdata must be a JSON object: %s", customdataFile, err)
+	}
+	return json.RawMessage(data), nil
+}
+
+// UserdataData is what userdata templates are rendered with, e.g.
+// {{.Hostname}} or {{.Vars.role}}
+type UserdataData struct {
+	Hostname  string
+	Plan      string
+	OS        string
+	Facility  string
+	Metro     string
+	ProjectID string
+	Vars      map[string]string
+}
+
+// renderUserdata renders the template file for the device requested by
+// req. Besides the request and the variables templates can use random,
+// petname and password to generate values, e.g. {{password 24}}.
+func renderUserdata(path string, req *DeviceRequest, vars map[string]string) (string, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Funcs(template.FuncMap{
+		"random":   RandomLetters,
+		"petname":  Petname,
+		"password": randomPassword,
+	}).Parse(string(text))
+	if err != nil {
+		return "", err
+	}
+
+	data := UserdataData{
+		Hostname:  req.Hostname,
+		Plan:      req.Plan,
+		OS:        req.OS,
+		Metro:     req.Metro,
+		ProjectID: req.ProjectID,
+		Vars:      vars,
+	}
+	if len(req.Facility) > 0 {
+		data.Facility = req.Facility[0]
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// applyUserdataTemplate renders --userdata-template into the userdata of
+// the request, once its hostname and location are settled
+func applyUserdataTemplate(req *DeviceRequest) error {
+	if userdataTemplate == "" {
+		return nil
+	}
+	data, err := renderUserdata(userdataTemplate, req, userdataVars)
+	if err != nil {
+		return usageErrorf("--userdata-template: %s", err)
+	}
+	req.UserData = data
+	return nil
+}
+
+const passwordChars = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// randomPassword generates a password of n characters from a secure source
+func randomPassword(n int) (string, error) {
+	return randomString(n, passwordChars)
+}
+
+// randomString generates n characters of chars from a secure source
+func randomString(n int, chars string) (string, error) {
+	b := make([]byte, n)
+	for i := range b {
+		k, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = chars[k.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/nurfet-becirevic/packet-go-demo/packet"
+)
+
+const (
+	// VCRRecord sends requests to the API and records the responses
+	VCRRecord = "record"
+	// VCRReplay answers requests with recorded responses, without the API
+	VCRReplay = "replay"
+	// defaultCassette is the cassette file used when PACKET_VCR_CASSETTE
+	// is not set
+	defaultCassette = "packet-cassette.json"
+	// scrubbedToken replaces the API token in recorded interactions
+	scrubbedToken = "REDACTED"
+)
+
+var (
+	vcrRecordPath string
+	cassette      *Cassette
+)
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        string      `json:"body,omitempty"`
+}
+
+// Cassette is a file of recorded interactions. Recording rewrites the
+// file after every interaction, so that an interrupted run keeps what it
+// recorded. Replaying answers each request with the next unused
+// interaction of the same method and URL, repeating the last one once all
+// were used, e.g. for the polls of a device being provisioned.
+type Cassette struct {
+	path string
+	mode string
+
+	mu           sync.Mutex
+	Interactions []Interaction `json:"interactions"`
+	used         map[string]int
+}
+
+// NewCassette opens the cassette at path for recording or replaying. A
+// cassette replayed must exist, a recorded one is started afresh.
+func NewCassette(path, mode string) (*Cassette, error) {
+	c := &Cassette{path: path, mode: mode, used: map[string]int{}}
+	switch mode {
+	case VCRRecord:
+		return c, c.save()
+	case VCRReplay:
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("reading cassette %s: %s", path, err)
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown VCR mode %q, use %s or %s", mode, VCRRecord, VCRReplay)
+}
+
+// Middleware records or replays the requests of a client. Interactions
+// are keyed by the path and query of the URL, so a cassette recorded
+// against one API URL replays against any other.
+func (c *Cassette) Middleware() Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			if c.mode == VCRReplay {
+				return c.replay(r)
+			}
+			return c.record(r, next)
+		}
+	}
+}
+
+func (c *Cassette) replay(r *http.Request) (*http.Response, error) {
+	key := r.Method + " " + r.URL.RequestURI()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matches []*Interaction
+	for i := range c.Interactions {
+		in := &c.Interactions[i]
+		if in.Method+" "+in.URL == key {
+			matches = append(matches, in)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("cassette %s has no response recorded for %s", c.path, key)
+	}
+	n := c.used[key]
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+	c.used[key]++
+
+	in := matches[n]
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode: in.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     in.Header.Clone(),
+		Body:       ioutil.NopCloser(strings.NewReader(in.Body)),
+		Request:    r,
+	}, nil
+}
+
+func (c *Cassette) record(r *http.Request, next RoundTripFunc) (*http.Response, error) {
+	var reqBody []byte
+	if r.GetBody != nil {
+		if body, err := r.GetBody(); err == nil {
+			reqBody, _ = ioutil.ReadAll(body)
+			body.Close()
+		}
+	}
+
+	resp, err := next(r)
+	if err != nil {
+		return resp, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+
+	tok := r.Header.Get("X-Auth-Token")
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	in := Interaction{
+		Method:      r.Method,
+		URL:         scrubToken(r.URL.RequestURI(), tok),
+		RequestBody: scrubBody(reqBody, tok),
+		Status:      resp.StatusCode,
+		Header:      header,
+		Body:        scrubBody(body, tok),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, in)
+	if err := c.save(); err != nil {
+		logger.Warn("Writing the cassette failed", "path", c.path, "error", err)
+	}
+	return resp, nil
+}
+
+func (c *Cassette) save() error {
+	if c.Interactions == nil {
+		c.Interactions = []Interaction{}
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, append(data, '\n'), 0600)
+}
+
+// scrubToken keeps the API token out of cassettes, which are meant to be
+// committed along with the tests replaying them
+func scrubToken(s, tok string) string {
+	if tok == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, tok, scrubbedToken)
+}
+
+// scrubBody keeps the API token and the credential fields of a JSON body,
+// such as the token of a new API key, out of cassettes
+func scrubBody(body []byte, tok string) string {
+	return scrubToken(string(packet.RedactSecrets(body, scrubbedToken)), tok)
+}
+
+// setupVCR opens the cassette selected by --record or PACKET_VCR. Replays
+// need no token, a placeholder one passes the credential checks.
+func setupVCR() error {
+	mode, path := os.Getenv("PACKET_VCR"), envOrDefault("PACKET_VCR_CASSETTE", defaultCassette)
+	if vcrRecordPath != "" {
+		mode, path = VCRRecord, vcrRecordPath
+	}
+	if mode == "" {
+		return nil
+	}
+	c, err := NewCassette(path, mode)
+	if err != nil {
+		return usageErrorf("%s", err)
+	}
+	cassette = c
+	if mode == VCRReplay && token == "" && tokenCommand == "" {
+		token = scrubbedToken
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nurfet-becirevic/packet-go-demo/packettest"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	srv := packettest.NewFakeAPI().Start()
+	defer srv.Close()
+
+	rec, err := NewCassette(path, VCRRecord)
+	if err != nil {
+		t.Fatalf("NewCassette(record) error = %v", err)
+	}
+	c := NewClient("secret-token", srv.URL+"/")
+	c.Use(rec.Middleware())
+	created, err := createAPIKey(ctx, apiKeyScope{}, &APIKeyRequest{Description: "ci"}, c)
+	if err != nil {
+		t.Fatalf("createAPIKey() error = %v", err)
+	}
+	recorded, err := listAPIKeys(ctx, apiKeyScope{}, c)
+	if err != nil {
+		t.Fatalf("listAPIKeys() error = %v", err)
+	}
+	if _, err := getDevice(ctx, "device-404", c); err == nil {
+		t.Fatal("getDevice() of a missing device succeeded")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-token", created.Token} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette holds the secret %q:\n%s", secret, data)
+		}
+	}
+
+	// the replaying client reaches no API, every answer is from the cassette
+	play, err := NewCassette(path, VCRReplay)
+	if err != nil {
+		t.Fatalf("NewCassette(replay) error = %v", err)
+	}
+	c = NewClient(scrubbedToken, "http://127.0.0.1:1/")
+	c.Use(play.Middleware())
+	key, err := createAPIKey(ctx, apiKeyScope{}, &APIKeyRequest{Description: "ci"}, c)
+	if err != nil {
+		t.Fatalf("replayed createAPIKey() error = %v", err)
+	}
+	if key.ID != created.ID || key.Description != "ci" || key.Token != scrubbedToken {
+		t.Errorf("replayed key = %+v, want %s with a scrubbed token", key, created.ID)
+	}
+	for i := 0; i < 2; i++ {
+		// the last interaction of a request answers it again
+		replayed, err := listAPIKeys(ctx, apiKeyScope{}, c)
+		if err != nil {
+			t.Fatalf("replayed listAPIKeys() error = %v", err)
+		}
+		if len(replayed) != len(recorded) || replayed[0].ID != recorded[0].ID {
+			t.Errorf("replayed keys = %+v, want %+v", replayed, recorded)
+		}
+	}
+	_, err = getDevice(ctx, "device-404", c)
+	if errResp, ok := err.(*ErrorResponse); !ok || errResp.StatusCode != 404 {
+		t.Errorf("replayed getDevice() error = %v, want the recorded 404", err)
+	}
+	if _, err := getDevice(ctx, "device-1", c); err == nil || !strings.Contains(err.Error(), "no response recorded") {
+		t.Errorf("getDevice() of a request not recorded error = %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "vlan list",
+		usage: "List the VLANs of the project",
+		run:   runVLANList,
+	})
+	registerCommand(&command{
+		name:  "vlan create",
+		usage: "Create a VLAN in a metro or facility",
+		run:   runVLANCreate,
+	})
+	registerCommand(&command{
+		name:  "vlan delete",
+		usage: "Delete a VLAN",
+		run:   runVLANDelete,
+	})
+}
+
+// listVLANs returns the virtual networks of the project
+func listVLANs(ctx context.Context, projectID string, c *Client) ([]VirtualNetwork, error) {
+	return c.VLANs().List(ctx, projectID)
+}
+
+// createVLAN creates a virtual network in the project and records it in
+// the state of the run
+func createVLAN(ctx context.Context, projectID string, req *VirtualNetworkRequest, c *Client) (*VirtualNetwork, error) {
+	v, err := c.VLANs().Create(ctx, projectID, req)
+	if err != nil {
+		return nil, err
+	}
+	runState.created("vlan", v.ID, v.Description, projectID)
+	return v, nil
+}
+
+// deleteVLAN deletes a virtual network, which must not be attached to ports
+func deleteVLAN(ctx context.Context, vlanID string, c *Client) error {
+	if err := c.VLANs().Delete(ctx, vlanID); err != nil {
+		return err
+	}
+	runState.deleted(vlanID)
+	return nil
+}
+
+func runVLANList(ctx context.Context, args []string) error {
+	fs := newFlagSet("vlan list")
+	addCacheFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	vlans, err := listVLANs(ctx, projectID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if vlans == nil {
+			vlans = []VirtualNetwork{}
+		}
+		prettyPrint(vlans)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tVXLAN\tLOCATION\tDESCRIPTION")
+	for _, v := range vlans {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", v.ID, v.VXLAN, v.Location(), v.Description)
+	}
+	return w.Flush()
+}
+
+func runVLANCreate(ctx context.Context, args []string) error {
+	fs := newFlagSet("vlan create")
+	req := &VirtualNetworkRequest{}
+	fs.StringVar(&req.Metro, "metro", "", "Metro of the VLAN (Equinix Metal API)")
+	fs.StringVar(&req.Facility, "facility", "", "Facility of the VLAN")
+	fs.IntVar(&req.VXLAN, "vxlan", 0, "VLAN ID to use in the metro, between 2 and 3999 (default assigned by the API)")
+	fs.StringVar(&req.Description, "description", "", "Description of the VLAN")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+	if (req.Metro == "") == (req.Facility == "") {
+		return usageErrorf("provide either --metro or --facility")
+	}
+	if req.VXLAN != 0 && (req.Metro == "" || req.VXLAN < 2 || req.VXLAN > 3999) {
+		return usageErrorf("--vxlan must be between 2 and 3999 and is only supported with --metro")
+	}
+
+	v, err := createVLAN(ctx, projectID, req, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(v)
+		return nil
+	}
+	logger.Info(fmt.Sprintf("Created VLAN %d in %s", v.VXLAN, v.Location()), "id", v.ID)
+	return nil
+}
+
+func runVLANDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("vlan delete")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo vlan delete [flags] <vlan-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	if err := deleteVLAN(ctx, fs.Arg(0), newCLIClient()); err != nil {
+		return err
+	}
+	logger.Info("VLAN " + fs.Arg(0) + " deleted")
+	return nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "volume list",
+		usage: "List the block storage volumes of the project",
+		run:   runVolumeList,
+	})
+	registerCommand(&command{
+		name:  "volume create",
+		usage: "Create a block storage volume",
+		run:   runVolumeCreate,
+	})
+	registerCommand(&command{
+		name:  "volume attach",
+		usage: "Attach a volume to a device once the volume is active",
+		run:   runVolumeAttach,
+	})
+	registerCommand(&command{
+		name:  "volume detach",
+		usage: "Detach a volume from its devices",
+		run:   runVolumeDetach,
+	})
+	registerCommand(&command{
+		name:  "volume delete",
+		usage: "Delete a volume",
+		run:   runVolumeDelete,
+	})
+}
+
+// how long volume attach waits for a new volume to become active
+const volumeReadyTimeout = 10 * time.Minute
+
+// listVolumes returns the volumes of the project
+func listVolumes(ctx context.Context, projectID string, c *Client) ([]Volume, error) {
+	return c.Volumes().List(ctx, projectID)
+}
+
+func getVolume(ctx context.Context, volumeID string, c *Client) (*Volume, error) {
+	return c.Volumes().Get(ctx, volumeID)
+}
+
+// createVolume creates a volume in the project, which is provisioned in
+// the background, and records it in the state of the run
+func createVolume(ctx context.Context, projectID string, req *VolumeRequest, c *Client) (*Volume, error) {
+	v, err := c.Volumes().Create(ctx, projectID, req)
+	if err != nil {
+		return nil, err
+	}
+	runState.created("volume", v.ID, v.Name, projectID)
+	return v, nil
+}
+
+// deleteVolume deletes a volume, which must not be attached
+func deleteVolume(ctx context.Context, volumeID string, c *Client) error {
+	if err := c.Volumes().Delete(ctx, volumeID); err != nil {
+		return err
+	}
+	runState.deleted(volumeID)
+	return nil
+}
+
+// attachVolume attaches the volume to the device
+func attachVolume(ctx context.Context, volumeID, deviceID string, c *Client) (*VolumeAttachment, error) {
+	return c.Volumes().Attach(ctx, volumeID, deviceID)
+}
+
+// detachVolume deletes a volume attachment
+func detachVolume(ctx context.Context, attachmentID string, c *Client) error {
+	return c.Volumes().Detach(ctx, attachmentID)
+}
+
+// waitForVolume polls the volume every 5 seconds until it is active, at
+// most timeout
+func waitForVolume(ctx context.Context, volumeID string, c *Client, timeout time.Duration) (*Volume, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		v, err := getVolume(ctx, volumeID, c)
+		if err != nil {
+			return nil, err
+		}
+		if v.State == "active" {
+			return v, nil
+		}
+		if v.State == "failed" {
+			return nil, fmt.Errorf("volume %s failed to provision", volumeID)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("volume %s is still %s after %s", volumeID, v.State, timeout)
+		}
+		logger.Debug("Waiting for volume", "volume", volumeID, "state", v.State)
+		if err := sleep(ctx, 5*time.Second); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func runVolumeList(ctx context.Context, args []string) error {
+	fs := newFlagSet("volume list")
+	addCacheFlags(fs)
+	addTableFlags(fs, "size, state, created or any other column")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if _, err := selectColumns(volumeColumns(nil)); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+
+	volumes, err := listVolumes(ctx, projectID, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		if volumes == nil {
+			volumes = []Volume{}
+		}
+		prettyPrint(volumes)
+		return nil
+	}
+	return printTable(volumeColumns(volumes), len(volumes))
+}
+
+// volumeColumns returns the columns of a volume table
+func volumeColumns(volumes []Volume) []tableColumn {
+	return []tableColumn{
+		{name: "id", value: func(i int) string { return volumes[i].ID }},
+		{name: "size", value: func(i int) string { return fmt.Sprintf("%d GB", volumes[i].Size) }},
+		{name: "state", value: func(i int) string { return volumes[i].State }},
+		{name: "location", value: func(i int) string { return volumes[i].Location() }},
+		{name: "attachments", value: func(i int) string { return strconv.Itoa(len(volumes[i].Attachments)) }},
+		{name: "description", value: func(i int) string { return volumes[i].Description }},
+		{name: "created", value: func(i int) string { return volumes[i].Created }, hidden: true},
+	}
+}
+
+func runVolumeCreate(ctx context.Context, args []string) error {
+	fs := newFlagSet("volume create")
+	req := &VolumeRequest{}
+	fs.IntVar(&req.Size, "size", 0, "Size of the volume in GB")
+	fs.StringVar(&req.Plan, "plan", "storage_1", "Storage plan: storage_1 (standard) or storage_2 (performance)")
+	fs.StringVar(&req.Metro, "metro", "", "Metro of the volume (Equinix Metal API)")
+	fs.StringVar(&req.Facility, "facility", "", "Facility of the volume")
+	fs.StringVar(&req.Description, "description", "", "Description of the volume")
+	req.BillingCycle = BillingHourly
+	fs.Var(&req.BillingCycle, "bilcycle", "Billing cycle: hourly, daily, monthly or yearly")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := checkCredentials(); err != nil {
+		return err
+	}
+	if req.Size <= 0 {
+		return usageErrorf("--size is required")
+	}
+	if (req.Metro == "") == (req.Facility == "") {
+		return usageErrorf("provide either --metro or --facility")
+	}
+
+	v, err := createVolume(ctx, projectID, req, newCLIClient())
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(v)
+		return nil
+	}
+	logger.Info(fmt.Sprintf("Created %d GB volume %s", v.Size, v.ID), "state", v.State)
+	return nil
+}
+
+func runVolumeAttach(ctx context.Context, args []string) error {
+	fs := newFlagSet("volume attach")
+	timeout := fs.Duration("timeout", volumeReadyTimeout, "How long to wait for the volume to become active")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo volume attach [flags] <volume-id> <device-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	volumeID, deviceID := fs.Arg(0), fs.Arg(1)
+	if _, err := waitForVolume(ctx, volumeID, client, *timeout); err != nil {
+		return err
+	}
+	a, err := attachVolume(ctx, volumeID, deviceID, client)
+	if err != nil {
+		return err
+	}
+	if jsonOutput() {
+		prettyPrint(a)
+		return nil
+	}
+	logger.Info("Attached volume "+volumeID+" to device "+deviceID, "attachment", a.AttachmentID())
+	return nil
+}
+
+func runVolumeDetach(ctx context.Context, args []string) error {
+	fs := newFlagSet("volume detach")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo volume detach [flags] <volume-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	v, err := getVolume(ctx, fs.Arg(0), client)
+	if err != nil {
+		return err
+	}
+	if len(v.Attachments) == 0 {
+		logger.Info("Volume " + v.ID + " is not attached")
+		return nil
+	}
+	for _, a := range v.Attachments {
+		if err := detachVolume(ctx, a.AttachmentID(), client); err != nil {
+			return err
+		}
+		logger.Info("Detached volume "+v.ID, "attachment", a.AttachmentID())
+	}
+	return nil
+}
+
+func runVolumeDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("volume delete")
+	fs.Usage = func() {
+		fmt.Println("Usage: packet-go-demo volume delete [flags] <volume-id>")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	if err := deleteVolume(ctx, fs.Arg(0), newCLIClient()); err != nil {
+		return err
+	}
+	logger.Info("Volume " + fs.Arg(0) + " deleted")
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "auth whoami",
+		usage: "Show the user the API token belongs to and whether it is read-only",
+		run:   runAuthWhoami,
+	})
+}
+
+// User is the user an API token authenticates as
+type User struct {
+	ID                    string `json:"id"`
+	FullName              string `json:"full_name,omitempty"`
+	Email                 string `json:"email"`
+	DefaultOrganizationID string `json:"default_organization_id,omitempty"`
+}
+
+// Whoami tells which credentials the tool picked up
+type Whoami struct {
+	User *User `json:"user"`
+	// DefaultOrganization is the name of the default organization
+	DefaultOrganization string `json:"default_organization,omitempty"`
+	TokenSource         string `json:"token_source"`
+	Token               string `json:"token,omitempty"`
+	// ReadOnly is nil when it cannot be told, e.g. for tokens of
+	// --token-command or of a profile with several
+	ReadOnly *bool  `json:"read_only"`
+	APIURL   string `json:"api_url"`
+	Profile  string `json:"profile,omitempty"`
+}
+
+func getUser(ctx context.Context, c *Client) (*User, error) {
+	user := new(User)
+	if err := c.DoRequest(ctx, "user", "GET", nil, user, nil); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func runAuthWhoami(ctx context.Context, args []string) error {
+	fs := newFlagSet("auth whoami")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitCode(exitUsage)
+	}
+	if err := checkToken(); err != nil {
+		return err
+	}
+
+	client := newCLIClient()
+	me := &Whoami{TokenSource: tokenSource(fs), APIURL: apiURL, Profile: profileName}
+	if token != "" {
+		key := APIKey{Token: token}
+		me.Token = key.MaskedToken()
+	}
+	user, err := getUser(ctx, client)
+	var errResp *ErrorResponse
+	if errors.As(err, &errResp) && errResp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("the token from %s is not a user API key, project API keys cannot tell their user: %w", me.TokenSource, err)
+	}
+	if err != nil {
+		return err
+	}
+	me.User = user
+
+	if user.DefaultOrganizationID != "" {
+		if org, err := getOrganization(ctx, user.DefaultOrganizationID, client); err == nil {
+			me.DefaultOrganization = org.Name
+		} else {
+			logger.Debug("Cannot read the default organization", "organization", user.DefaultOrganizationID, "error", err)
+		}
+	}
+	// the API keys of the user hold their token, the one in use tells
+	// whether it is read-only
+	if token != "" {
+		if keys, err := listAPIKeys(ctx, apiKeyScope{}, client); err == nil {
+			for _, k := range keys {
+				if k.Token == token {
+					readOnly := k.ReadOnly
+					me.ReadOnly = &readOnly
+				}
+			}
+		} else {
+			logger.Debug("Cannot list the API keys of the user", "error", err)
+		}
+	}
+
+	if jsonOutput() {
+		prettyPrint(me)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "USER\t%s\n", user.ID)
+	if user.FullName != "" {
+		fmt.Fprintf(w, "NAME\t%s\n", user.FullName)
+	}
+	fmt.Fprintf(w, "EMAIL\t%s\n", user.Email)
+	if user.DefaultOrganizationID != "" {
+		org := user.DefaultOrganizationID
+		if me.DefaultOrganization != "" {
+			org = me.DefaultOrganization + " (" + org + ")"
+		}
+		fmt.Fprintf(w, "DEFAULT ORGANIZATION\t%s\n", org)
+	}
+	if me.Token != "" {
+		fmt.Fprintf(w, "TOKEN\t%s from %s\n", me.Token, me.TokenSource)
+	} else {
+		fmt.Fprintf(w, "TOKEN\tfrom %s\n", me.TokenSource)
+	}
+	switch {
+	case me.ReadOnly == nil:
+		fmt.Fprintf(w, "READ-ONLY\tunknown\n")
+	case *me.ReadOnly:
+		fmt.Fprintf(w, "READ-ONLY\tyes, commands changing resources will fail\n")
+	default:
+		fmt.Fprintf(w, "READ-ONLY\tno\n")
+	}
+	fmt.Fprintf(w, "API\t%s\n", apiURL)
+	if profileName != "" {
+		fmt.Fprintf(w, "PROFILE\t%s\n", profileName)
+	}
+	return w.Flush()
+}
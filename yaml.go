@@ -0,0 +1,620 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlUnmarshal decodes a YAML document into v. Only the subset of YAML
+// used by manifests and config files is supported: block mappings and
+// sequences, flow collections on a single line, and plain, quoted and
+// block (| and >) scalars. The document is converted through encoding/json,
+// so v is described with the usual json struct tags.
+func yamlUnmarshal(data []byte, v interface{}) error {
+	p, err := newYAMLParser(data)
+	if err != nil {
+		return err
+	}
+	doc, err := p.document()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string // content without indentation and trailing comment
+	raw    string // original line, needed by block scalars
+}
+
+type yamlParser struct {
+	lines []*yamlLine
+	pos   int
+}
+
+func newYAMLParser(data []byte) (*yamlParser, error) {
+	p := new(yamlParser)
+	src := strings.Replace(string(data), "\r\n", "\n", -1)
+	for i, raw := range strings.Split(src, "\n") {
+		trimmed := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs are not allowed for indentation", i+1)
+		}
+		p.lines = append(p.lines, &yamlLine{
+			num:    i + 1,
+			indent: len(raw) - len(trimmed),
+			text:   strings.TrimRight(stripYAMLComment(trimmed), " \t"),
+			raw:    raw,
+		})
+	}
+	return p, nil
+}
+
+// peek returns the next line with content, skipping blanks and comments.
+// The "..." marker ends the document.
+func (p *yamlParser) peek() *yamlLine {
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent == 0 && l.text == "..." {
+			return nil
+		}
+		if l.text != "" && !(l.indent == 0 && l.text == "---") {
+			return l
+		}
+		p.pos++
+	}
+	return nil
+}
+
+func (p *yamlParser) document() (interface{}, error) {
+	l := p.peek()
+	if l == nil {
+		return nil, nil
+	}
+	v, err := p.node(l.indent)
+	if err != nil {
+		return nil, err
+	}
+	if l = p.peek(); l != nil {
+		return nil, fmt.Errorf("yaml: line %d: unexpected content %q", l.num, l.text)
+	}
+	return v, nil
+}
+
+func (p *yamlParser) node(indent int) (interface{}, error) {
+	l := p.peek()
+	switch {
+	case isYAMLSeqItem(l.text):
+		return p.sequence(indent)
+	case isYAMLMapping(l.text):
+		return p.mapping(indent)
+	}
+	p.pos++
+	return p.value(l.text, l)
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	out := []interface{}{}
+	for {
+		l := p.peek()
+		if l == nil || l.indent < indent {
+			return out, nil
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("yaml: line %d: unexpected indentation", l.num)
+		}
+		if !isYAMLSeqItem(l.text) {
+			return out, nil
+		}
+
+		rest := strings.TrimLeft(l.text[1:], " ")
+		var v interface{}
+		var err error
+		switch {
+		case rest == "":
+			p.pos++
+			if next := p.peek(); next != nil && next.indent > indent {
+				v, err = p.node(next.indent)
+			}
+		case isYAMLSeqItem(rest) || isYAMLMapping(rest):
+			// nested collection starting on the item line: reparse the
+			// line as if the collection began on its own at that column
+			l.indent += len(l.text) - len(rest)
+			l.text = rest
+			v, err = p.node(l.indent)
+		default:
+			p.pos++
+			v, err = p.value(rest, l)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	out := map[string]interface{}{}
+	for {
+		l := p.peek()
+		if l == nil || l.indent < indent || (l.indent == indent && isYAMLSeqItem(l.text)) {
+			return out, nil
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("yaml: line %d: unexpected indentation", l.num)
+		}
+		key, rest, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("yaml: line %d: expected \"key: value\", got %q", l.num, l.text)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("yaml: line %d: duplicate key %q", l.num, key)
+		}
+		p.pos++
+
+		var v interface{}
+		var err error
+		if rest == "" {
+			next := p.peek()
+			switch {
+			case next != nil && next.indent > indent:
+				v, err = p.node(next.indent)
+			case next != nil && next.indent == indent && isYAMLSeqItem(next.text):
+				v, err = p.sequence(indent)
+			}
+		} else {
+			v, err = p.value(rest, l)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[key] = v
+	}
+}
+
+// value parses the inline part of a line, which is a scalar, a flow
+// collection or the header of a block scalar spanning the following lines
+func (p *yamlParser) value(s string, l *yamlLine) (interface{}, error) {
+	if s[0] == '|' || s[0] == '>' {
+		return p.blockScalar(s, l)
+	}
+	v, err := parseYAMLInline(s)
+	if err != nil {
+		return nil, fmt.Errorf("yaml: line %d: %s", l.num, err)
+	}
+	return v, nil
+}
+
+func (p *yamlParser) blockScalar(header string, parent *yamlLine) (interface{}, error) {
+	folded := header[0] == '>'
+	chomp := strings.TrimSpace(header[1:])
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, fmt.Errorf("yaml: line %d: unsupported block scalar header %q", parent.num, header)
+	}
+
+	var lines []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if strings.TrimSpace(l.raw) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		if blockIndent < 0 {
+			if l.indent <= parent.indent {
+				break
+			}
+			blockIndent = l.indent
+		}
+		if l.indent < blockIndent {
+			break
+		}
+		lines = append(lines, l.raw[blockIndent:])
+		p.pos++
+	}
+
+	// trailing blank lines belong to the chomping indicator, not the content
+	content := len(lines)
+	for content > 0 && lines[content-1] == "" {
+		content--
+	}
+	var b strings.Builder
+	for i, line := range lines[:content] {
+		if i > 0 {
+			switch {
+			case !folded:
+				b.WriteByte('\n')
+			case line == "":
+				// folding turns a blank line into a line break
+				b.WriteByte('\n')
+			case lines[i-1] == "":
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	switch {
+	case chomp == "-" || content == 0:
+	case chomp == "+":
+		b.WriteString(strings.Repeat("\n", len(lines)-content+1))
+	default:
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+func isYAMLSeqItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+func isYAMLMapping(s string) bool {
+	_, _, ok := splitYAMLKey(s)
+	return ok
+}
+
+// splitYAMLKey splits "key: value" into its parts
+func splitYAMLKey(s string) (string, string, bool) {
+	if s == "" || s[0] == '[' || s[0] == '{' {
+		return "", "", false
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		f := &yamlFlow{s: s}
+		key, err := f.quoted()
+		if err != nil || !strings.HasPrefix(s[f.i:], ":") {
+			return "", "", false
+		}
+		rest := s[f.i+1:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(rest), true
+	}
+	if strings.HasSuffix(s, ":") && !strings.Contains(s, ": ") {
+		return strings.TrimSpace(s[:len(s)-1]), "", true
+	}
+	i := strings.Index(s, ": ")
+	if i < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+2:]), true
+}
+
+// stripYAMLComment removes a trailing "# comment" that is not quoted
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return s[:i]
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [{,:", s[i-1]) >= 0):
+			quote = c
+		}
+	}
+	return s
+}
+
+func parseYAMLInline(s string) (interface{}, error) {
+	switch s[0] {
+	case '[', '{', '"', '\'':
+		f := &yamlFlow{s: s}
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		if f.skipSpace(); f.i < len(f.s) {
+			return nil, fmt.Errorf("unexpected %q after value", f.s[f.i:])
+		}
+		return v, nil
+	}
+	return yamlPlainScalar(s), nil
+}
+
+// yamlPlainScalar resolves an unquoted scalar to null, bool, number or string
+func yamlPlainScalar(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if strings.Trim(s, "+-.0123456789eE") == "" && strings.ContainsAny(s, "0123456789") {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+// yamlFlow parses flow collections such as [a, b] and {a: 1}
+type yamlFlow struct {
+	s string
+	i int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+func (f *yamlFlow) value() (interface{}, error) {
+	f.skipSpace()
+	if f.i >= len(f.s) {
+		return nil, fmt.Errorf("unexpected end of flow collection")
+	}
+	switch f.s[f.i] {
+	case '[':
+		return f.sequence()
+	case '{':
+		return f.mapping()
+	case '"', '\'':
+		return f.quoted()
+	}
+	start := f.i
+	for f.i < len(f.s) && strings.IndexByte(",]}", f.s[f.i]) < 0 {
+		f.i++
+	}
+	return yamlPlainScalar(strings.TrimSpace(f.s[start:f.i])), nil
+}
+
+func (f *yamlFlow) sequence() (interface{}, error) {
+	out := []interface{}{}
+	f.i++
+	for {
+		f.skipSpace()
+		if f.i < len(f.s) && f.s[f.i] == ']' {
+			f.i++
+			return out, nil
+		}
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+		if err := f.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *yamlFlow) mapping() (interface{}, error) {
+	out := map[string]interface{}{}
+	f.i++
+	for {
+		f.skipSpace()
+		if f.i < len(f.s) && f.s[f.i] == '}' {
+			f.i++
+			return out, nil
+		}
+		var key string
+		if f.i < len(f.s) && (f.s[f.i] == '"' || f.s[f.i] == '\'') {
+			k, err := f.quoted()
+			if err != nil {
+				return nil, err
+			}
+			key = k
+		} else {
+			start := f.i
+			for f.i < len(f.s) && strings.IndexByte(":,}", f.s[f.i]) < 0 {
+				f.i++
+			}
+			key = strings.TrimSpace(f.s[start:f.i])
+		}
+		f.skipSpace()
+		if f.i >= len(f.s) || f.s[f.i] != ':' {
+			return nil, fmt.Errorf("expected ':' after key %q", key)
+		}
+		f.i++
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		out[key] = v
+		if err := f.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator consumes the comma between items, leaving the closing bracket
+func (f *yamlFlow) separator(end byte) error {
+	f.skipSpace()
+	if f.i >= len(f.s) {
+		return fmt.Errorf("missing %q", end)
+	}
+	switch f.s[f.i] {
+	case ',':
+		f.i++
+		return nil
+	case end:
+		return nil
+	}
+	return fmt.Errorf("unexpected %q in flow collection", f.s[f.i])
+}
+
+func (f *yamlFlow) quoted() (string, error) {
+	q := f.s[f.i]
+	start := f.i
+	for f.i++; f.i < len(f.s); f.i++ {
+		switch c := f.s[f.i]; {
+		case c == '\\' && q == '"':
+			f.i++
+		case c == '\'' && q == '\'' && f.i+1 < len(f.s) && f.s[f.i+1] == '\'':
+			f.i++
+		case c == q:
+			f.i++
+			lit := f.s[start:f.i]
+			if q == '\'' {
+				return strings.Replace(lit[1:len(lit)-1], "''", "'", -1), nil
+			}
+			return strconv.Unquote(lit)
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted string")
+}
+
+// yamlMarshal encodes v as a YAML document that yamlUnmarshal reads back.
+// Like yamlUnmarshal it goes through encoding/json, so json struct tags
+// apply and struct fields keep their declaration order.
+func yamlMarshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	node, err := decodeOrderedJSON(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	if isYAMLBlock(node) {
+		writeYAMLBlock(&b, node, "")
+	} else {
+		b.WriteString(yamlInline(node) + "\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// yamlMap is a mapping that keeps the order of its keys
+type yamlMap []yamlMapItem
+
+type yamlMapItem struct {
+	key   string
+	value interface{}
+}
+
+// decodeOrderedJSON decodes the next JSON value, keeping objects in order
+func decodeOrderedJSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		m := yamlMap{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, yamlMapItem{key.(string), v})
+		}
+		_, err = dec.Token()
+		return m, err
+	case json.Delim('['):
+		s := []interface{}{}
+		for dec.More() {
+			v, err := decodeOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+		}
+		_, err = dec.Token()
+		return s, err
+	}
+	return tok, nil
+}
+
+// isYAMLBlock reports whether v is written in block style. Empty
+// collections and sequences of scalars are written inline.
+func isYAMLBlock(v interface{}) bool {
+	switch v := v.(type) {
+	case yamlMap:
+		return len(v) > 0
+	case []interface{}:
+		for _, item := range v {
+			switch item.(type) {
+			case yamlMap, []interface{}:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeYAMLBlock(b *strings.Builder, v interface{}, indent string) {
+	switch v := v.(type) {
+	case yamlMap:
+		for _, item := range v {
+			if isYAMLBlock(item.value) {
+				fmt.Fprintf(b, "%s%s:\n", indent, yamlString(item.key))
+				writeYAMLBlock(b, item.value, indent+"  ")
+			} else {
+				fmt.Fprintf(b, "%s%s: %s\n", indent, yamlString(item.key), yamlInline(item.value))
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if !isYAMLBlock(item) {
+				fmt.Fprintf(b, "%s- %s\n", indent, yamlInline(item))
+				continue
+			}
+			// the first line of the item goes after the dash
+			var sub strings.Builder
+			writeYAMLBlock(&sub, item, indent+"  ")
+			b.WriteString(indent + "- " + strings.TrimPrefix(sub.String(), indent+"  "))
+		}
+	}
+}
+
+func yamlInline(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return yamlString(v)
+	case yamlMap:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = yamlString(item.key) + ": " + yamlInline(item.value)
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = yamlInline(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return fmt.Sprint(v)
+}
+
+// yamlString writes s plain when it reads back as the same string and
+// double quoted otherwise
+func yamlString(s string) string {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s, ":#,[]{}\"'\\\n\t") ||
+		strings.IndexByte("-?&*!|>%@`", s[0]) >= 0 || yamlPlainScalar(s) != s {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// yamlJSON decodes a YAML document and returns it as JSON, for comparing
+// documents without spelling out nested interface{} values
+func yamlJSON(t *testing.T, doc string) (string, error) {
+	t.Helper()
+	var v interface{}
+	if err := yamlUnmarshal([]byte(doc), &v); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("encoding %#v: %v", v, err)
+	}
+	return string(b), nil
+}
+
+func TestYAMLUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"empty", "", `null`},
+		{"comments only", "# nothing\n\n", `null`},
+		{"scalars", "s: text\ni: 42\nf: 1.5\nexp: 1e3\nyes: true\nno: False\nnil: ~\nempty:\n",
+			`{"empty":null,"exp":1000,"f":1.5,"i":42,"nil":null,"no":false,"s":"text","yes":true}`},
+		{"plain strings that look like other types", "version: 1.2.3\nslug: c3.small.x86\ntime: 10:30\nsign: -",
+			`{"sign":"-","slug":"c3.small.x86","time":"10:30","version":"1.2.3"}`},
+		{"nested mappings", "a:\n  b:\n    c: 1\n  d: 2\ne: 3",
+			`{"a":{"b":{"c":1},"d":2},"e":3}`},
+		{"sequence", "- a\n- b\n-\n- 3", `["a","b",null,3]`},
+		{"sequence under key at same indent", "tags:\n- web\n- prod\nname: x",
+			`{"name":"x","tags":["web","prod"]}`},
+		{"sequence of mappings", "devices:\n  - hostname: a\n    plan: p1\n  - hostname: b\n    plan: p2",
+			`{"devices":[{"hostname":"a","plan":"p1"},{"hostname":"b","plan":"p2"}]}`},
+		{"nested sequences", "- - a\n  - b\n- - c", `[["a","b"],["c"]]`},
+		{"item on its own line", "-\n  k: v", `[{"k":"v"}]`},
+		{"trailing comments", "a: 1 # one\nb: x#y\nc: \"#\" # hash", `{"a":1,"b":"x#y","c":"#"}`},
+		{"document markers", "---\na: 1\n...\n", `{"a":1}`},
+		{"windows line endings", "a: 1\r\nb: 2\r\n", `{"a":1,"b":2}`},
+
+		{"flow sequence", "tags: [web, prod, 3, true]", `{"tags":["web","prod",3,true]}`},
+		{"flow mapping", "size: {min: 1, max: 5}", `{"size":{"max":5,"min":1}}`},
+		{"nested flow collections", "x: {a: [1, [2, 3]], b: {c: d}}", `{"x":{"a":[1,[2,3]],"b":{"c":"d"}}}`},
+		{"empty flow collections", "a: []\nb: {}", `{"a":[],"b":{}}`},
+		{"quoted items in flow", `a: ["x, y", 'it''s', "]"]`, `{"a":["x, y","it's","]"]}`},
+		{"quoted keys in flow", `a: {"k: 1": v}`, `{"a":{"k: 1":"v"}}`},
+
+		{"double quoted", `a: "line\nbreak \"quoted\" \u00e9"`, `{"a":"line\nbreak \"quoted\" é"}`},
+		{"single quoted", `a: 'it''s \n raw'`, `{"a":"it's \\n raw"}`},
+		{"quoted keeps type", "a: \"true\"\nb: '42'\nc: \"\"", `{"a":"true","b":"42","c":""}`},
+		{"quoted key", "\"a: b\": 1\n'c d': 2", `{"a: b":1,"c d":2}`},
+
+		{"literal block", "script: |\n  echo a\n    indented\n  echo b\nnext: 1",
+			`{"next":1,"script":"echo a\n  indented\necho b\n"}`},
+		{"literal block strip", "s: |-\n  a\n  b\n\n", `{"s":"a\nb"}`},
+		{"literal block keep", "s: |+\n  a\n\n\nt: 1", `{"s":"a\n\n\n","t":1}`},
+		{"literal block with blank lines", "s: |\n  a\n\n  b\n", `{"s":"a\n\nb\n"}`},
+		{"folded block", "s: >\n  one\n  two\n\n  three\n", `{"s":"one two\nthree\n"}`},
+		{"empty block", "s: |\nt: 1", `{"s":"","t":1}`},
+		{"block in sequence", "- |\n  a\n  b\n- c", `["a\nb\n","c"]`},
+		{"block keeps hashes", "s: |\n  # not a comment\n  a # b\n", `{"s":"# not a comment\na # b\n"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := yamlJSON(t, tt.doc)
+			if err != nil {
+				t.Fatalf("yamlUnmarshal() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("yamlUnmarshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"tab indentation", "a:\n\tb: 1", "yaml: line 2: tabs are not allowed for indentation"},
+		{"unexpected indentation", "a: 1\n  b: 2", "yaml: line 2: unexpected indentation"},
+		{"indented sequence item", "- a\n  - b", "yaml: line 2: unexpected indentation"},
+		{"duplicate key", "a: 1\nb: 2\na: 3", `yaml: line 3: duplicate key "a"`},
+		{"not a mapping", "a: 1\njust text", `yaml: line 2: expected "key: value", got "just text"`},
+		{"content after document", "- a\nb: 1", `yaml: line 2: unexpected content "b: 1"`},
+		{"unterminated quote", "a: 1\nb: \"open", "yaml: line 2: unterminated quoted string"},
+		{"unterminated flow", "a:\n  b: [1, 2", `yaml: line 2: missing ']'`},
+		{"flow mapping without colon", "a: {b}", `yaml: line 1: expected ':' after key "b"`},
+		{"text after flow", "a: [1] x", `yaml: line 1: unexpected "x" after value`},
+		{"block scalar header", "a: 1\nb: |2\n  x", `yaml: line 2: unsupported block scalar header "|2"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := yamlJSON(t, tt.doc)
+			if err == nil {
+				t.Fatalf("yamlUnmarshal() succeeded, want error %q", tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("yamlUnmarshal() error = %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLUnmarshalStruct(t *testing.T) {
+	doc := `
+defaults:
+  plan: c3.small.x86
+  billing_cycle: hourly
+devices:
+  - hostname: web-1
+    tags: [web, "prod"]
+    userdata: |
+      #!/bin/sh
+      echo hello
+  - hostname: db-1
+    count: 2
+`
+	type device struct {
+		Hostname string   `json:"hostname"`
+		Tags     []string `json:"tags"`
+		UserData string   `json:"userdata"`
+		Count    int      `json:"count"`
+	}
+	var got struct {
+		Defaults map[string]string `json:"defaults"`
+		Devices  []device          `json:"devices"`
+	}
+	if err := yamlUnmarshal([]byte(doc), &got); err != nil {
+		t.Fatalf("yamlUnmarshal() error = %v", err)
+	}
+	want := []device{
+		{Hostname: "web-1", Tags: []string{"web", "prod"}, UserData: "#!/bin/sh\necho hello\n"},
+		{Hostname: "db-1", Count: 2},
+	}
+	if !reflect.DeepEqual(got.Devices, want) {
+		t.Errorf("devices = %+v, want %+v", got.Devices, want)
+	}
+	if got.Defaults["plan"] != "c3.small.x86" || got.Defaults["billing_cycle"] != "hourly" {
+		t.Errorf("defaults = %v", got.Defaults)
+	}
+}
+
+func TestYAMLMarshal(t *testing.T) {
+	type spec struct {
+		Hostname string            `json:"hostname"`
+		Tags     []string          `json:"tags"`
+		Count    int               `json:"count"`
+		Spot     bool              `json:"spot"`
+		Labels   map[string]string `json:"labels,omitempty"`
+		Ports    []struct {
+			Name string `json:"name"`
+		} `json:"ports"`
+	}
+	v := spec{Hostname: "web", Tags: []string{"a", "b c"}, Count: 2, Labels: map[string]string{"env": "prod"}}
+	v.Ports = append(v.Ports, struct {
+		Name string `json:"name"`
+	}{"eth0"})
+	got, err := yamlMarshal(v)
+	if err != nil {
+		t.Fatalf("yamlMarshal() error = %v", err)
+	}
+	want := `hostname: web
+tags: [a, b c]
+count: 2
+spot: false
+labels:
+  env: prod
+ports:
+  - name: eth0
+`
+	if string(got) != want {
+		t.Errorf("yamlMarshal() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	strs := []string{
+		"", " leading", "trailing ", "a: b", "#hash", "x # y", "[x]", "{x}", "a,b",
+		"true", "False", "null", "~", "42", "1.5", "1e3", "-dash", "?q", "*star", "&amp", "!tag", "|pipe", ">gt",
+		"%pct", "@at", "`tick", "it's", `say "hi"`, `back\slash`, "line\nbreak", "tab\there", "é ünïcode", "plain",
+	}
+	docs := []interface{}{
+		map[string]interface{}{"s": "x", "n": float64(3), "f": 1.25, "b": true, "z": nil},
+		map[string]interface{}{"list": []interface{}{"a", float64(1), nil, []interface{}{"nested"}}},
+		map[string]interface{}{"maps": []interface{}{
+			map[string]interface{}{"a": float64(1), "b": map[string]interface{}{"c": []interface{}{}}},
+			map[string]interface{}{"d": map[string]interface{}{}},
+		}},
+		[]interface{}{[]interface{}{map[string]interface{}{"deep": "x"}}, "y"},
+		"scalar document",
+		[]interface{}{},
+	}
+	for _, s := range strs {
+		docs = append(docs, map[string]interface{}{s: s, "list": []interface{}{s}, "flow": map[string]interface{}{"k": []interface{}{s, s}}})
+	}
+	for _, doc := range docs {
+		out, err := yamlMarshal(doc)
+		if err != nil {
+			t.Fatalf("yamlMarshal(%#v) error = %v", doc, err)
+		}
+		var got interface{}
+		if err := yamlUnmarshal(out, &got); err != nil {
+			t.Errorf("yamlUnmarshal() of %#v error = %v, document:\n%s", doc, err, out)
+			continue
+		}
+		if !reflect.DeepEqual(got, doc) {
+			t.Errorf("round trip of %#v = %#v, document:\n%s", doc, got, out)
+		}
+	}
+}
+
+func TestYAMLString(t *testing.T) {
+	for _, s := range []string{"plain", "c3.small.x86", "with space", "ewr1"} {
+		if got := yamlString(s); got != s {
+			t.Errorf("yamlString(%q) = %s, want it plain", s, got)
+		}
+	}
+	for _, s := range []string{"", "true", "7", "a: b", "- x", "x\ny"} {
+		if got := yamlString(s); !strings.HasPrefix(got, `"`) {
+			t.Errorf("yamlString(%q) = %s, want it quoted", s, got)
+		}
+	}
+}